@@ -37,8 +37,8 @@ var _ bind.ContractBackend = (*rpcBackend)(nil)
 // Ethereum contracts bound to Go structs. It uses an RPC connection to delegate
 // all its functionality.
 //
-// Note: The current implementation is a blocking one. This should be replaced
-// by a proper async version when a real RPC client is created.
+// Deprecated: ethclient.Client implements bind.ContractBackend on top of the
+// context-aware rpc.ContextClient and should be used instead.
 type rpcBackend struct {
 	client rpc.Client // RPC client connection to interact with an API server
 	autoid uint32     // ID number to use for the next API request
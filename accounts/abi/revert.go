@@ -0,0 +1,102 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+)
+
+var (
+	// revertSelector is the selector of the Error(string) pseudo-function
+	// Solidity uses to encode revert reasons.
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+	// panicSelector is the selector of the Panic(uint256) pseudo-function
+	// Solidity uses to encode failed assertions and other internal errors.
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// panicReasons maps the Panic(uint256) codes emitted by Solidity to readable
+// descriptions.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// errBadRevert is returned when the data passed to UnpackRevert doesn't hold
+// an abi-encoded revert reason.
+var errBadRevert = errors.New("abi: invalid revert data")
+
+// UnpackRevert resolves the abi-encoded revert reason. According to the solidity
+// spec https://solidity.readthedocs.io/en/latest/control-structures.html#revert,
+// the provided revert reason is abi-encoded as if it were a call to a function
+// `Error(string)`. Failed assertions and other internal errors are encoded as
+// a call to `Panic(uint256)`, whose code is resolved into a description.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errBadRevert
+	}
+	switch {
+	case bytes.Equal(data[:4], revertSelector):
+		return readString(data[4:])
+	case bytes.Equal(data[:4], panicSelector):
+		if len(data) != 4+32 {
+			return "", errBadRevert
+		}
+		code := new(big.Int).SetBytes(data[4:])
+		if code.IsUint64() {
+			if reason, ok := panicReasons[code.Uint64()]; ok {
+				return reason, nil
+			}
+		}
+		return fmt.Sprintf("unknown panic code: %#x", code), nil
+	}
+	return "", errBadRevert
+}
+
+// readString decodes a single abi-encoded dynamic string. Unlike toGoType it
+// doesn't trust the encoded offset and length, as revert data is returned by
+// arbitrary contract code.
+func readString(output []byte) (string, error) {
+	if len(output) < 32 {
+		return "", errBadRevert
+	}
+	offset := new(big.Int).SetBytes(output[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(output)-32) {
+		return "", errBadRevert
+	}
+	start := offset.Uint64() + 32
+
+	size := new(big.Int).SetBytes(output[start-32 : start])
+	if !size.IsUint64() || size.Uint64() > uint64(len(output))-start {
+		return "", errBadRevert
+	}
+	return string(output[start : start+size.Uint64()]), nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+)
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+		fail   bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a0", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+		// length pointing past the end of the data
+		{"08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000fff72657665727420726561736f6e00000000000000000000000000000000000000", "", true},
+		// offset overflowing 64 bits
+		{"08c379a0ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "", true},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000001", "assert(false)", false},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000011", "arithmetic underflow or overflow", false},
+		{"4e487b7100000000000000000000000000000000000000000000000000000000000000ff", "unknown panic code: 0xff", false},
+		// truncated panic code
+		{"4e487b7100000000000000000000000000000000000000000000000000000000000000", "", true},
+	}
+	for i, test := range tests {
+		reason, err := UnpackRevert(common.Hex2Bytes(test.input))
+		if test.fail {
+			if err == nil {
+				t.Errorf("test %d: expected error, got reason %q", i, reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if reason != test.expect {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, test.expect)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/eth-classic/go-ethereum/p2p/dnsdisc"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+)
+
+// dnsTreeOutput is the result of the dnstree subcommand. Records maps DNS
+// names to the TXT record content which must be published under them.
+type dnsTreeOutput struct {
+	URL     string            `json:"url"`
+	Seq     uint              `json:"seq"`
+	Records map[string]string `json:"records"`
+}
+
+// runDNSTree builds and signs a DNS node tree from a list of node records and
+// links, and prints its TXT records as JSON. It exits 0 if successful.
+func runDNSTree(args []string) {
+	fs := flag.NewFlagSet("dnstree", flag.ExitOnError)
+	var (
+		domain      = fs.String("domain", "", "domain name the tree is published at")
+		seq         = fs.Uint("seq", 1, "sequence number of the tree, must increase with every update")
+		nodeKeyFile = fs.String("nodekey", "", "signing key filename")
+		nodeKeyHex  = fs.String("nodekeyhex", "", "signing key as hex (for testing)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: bootnode dnstree -domain <domain> -nodekey <file> [nodes file]")
+		fmt.Fprintln(os.Stderr, "The nodes file holds one enr: record or enrtree:// link per line (default stdin).")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *domain == "" {
+		log.Fatal("Use -domain to specify the domain of the tree")
+	}
+	key := loadNodeKey(*nodeKeyFile, *nodeKeyHex)
+
+	in := os.Stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			log.Fatalf("error opening nodes file: %v", err)
+		}
+		in = f
+	}
+	nodes, links, err := readTreeInput(in)
+	in.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tree, err := dnsdisc.MakeTree(*seq, nodes, links)
+	if err != nil {
+		log.Fatalf("can't create tree: %v", err)
+	}
+	url, err := tree.Sign(key, *domain)
+	if err != nil {
+		log.Fatalf("can't sign tree: %v", err)
+	}
+	out, _ := json.MarshalIndent(dnsTreeOutput{url, tree.Seq(), tree.ToTXT(*domain)}, "", "  ")
+	fmt.Println(string(out))
+	os.Exit(0)
+}
+
+// readTreeInput reads node records and links, one per line. Empty lines and
+// lines starting with '#' are skipped.
+func readTreeInput(r io.Reader) (nodes []*enr.Record, links []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "enrtree://"):
+			if _, _, err := dnsdisc.ParseURL(text); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			links = append(links, text)
+		default:
+			rec, err := enr.Parse(text)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid node record: %v", line, err)
+			}
+			nodes = append(nodes, rec)
+		}
+	}
+	return nodes, links, scanner.Err()
+}
@@ -15,6 +15,7 @@
 // along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
 
 // bootnode runs a bootstrap node for the Ethereum Discovery Protocol.
+// With the dnstree subcommand it builds a signed DNS node list (EIP-1459).
 package main
 
 import (
@@ -61,7 +62,43 @@ func onlyDoGenKey() {
 	os.Exit(0)
 }
 
+// loadNodeKey reads the private key given by the -nodekey or -nodekeyhex
+// options.
+func loadNodeKey(file, hex string) *ecdsa.PrivateKey {
+	var nodeKey *ecdsa.PrivateKey
+	switch {
+	case file == "" && hex == "":
+		log.Fatal("Use -nodekey or -nodekeyhex to specify a private key")
+	case file != "" && hex != "":
+		log.Fatal("Options -nodekey and -nodekeyhex are mutually exclusive")
+	case file != "":
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("error opening node key file: %v", err)
+		}
+		nodeKey, err = crypto.LoadECDSA(f)
+		if err := f.Close(); err != nil {
+			log.Fatalf("error closing key file: %v", err)
+		}
+		if err != nil {
+			log.Fatalf("nodekey: %s", err)
+		}
+	case hex != "":
+		var err error
+		nodeKey, err = crypto.HexToECDSA(hex)
+		if err != nil {
+			log.Fatalf("nodekeyhex: %s", err)
+		}
+	}
+	return nodeKey
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dnstree" {
+		// exits 0 if successful
+		runDNSTree(os.Args[2:])
+	}
+
 	flag.Var(glog.GetVerbosity(), "verbosity", "log verbosity (0-9)")
 	flag.Var(glog.GetVModule(), "vmodule", "log verbosity pattern")
 	glog.SetToStderr(true)
@@ -82,31 +119,7 @@ func main() {
 		log.Fatalf("nat: %s", err)
 	}
 
-	var nodeKey *ecdsa.PrivateKey
-	switch {
-	case *nodeKeyFile == "" && *nodeKeyHex == "":
-		log.Fatal("Use -nodekey or -nodekeyhex to specify a private key")
-	case *nodeKeyFile != "" && *nodeKeyHex != "":
-		log.Fatal("Options -nodekey and -nodekeyhex are mutually exclusive")
-	case *nodeKeyFile != "":
-		f, err := os.Open(*nodeKeyFile)
-		if err != nil {
-			log.Fatalf("error opening node key file: %v", err)
-		}
-		nodeKey, err = crypto.LoadECDSA(f)
-		if err := f.Close(); err != nil {
-			log.Fatalf("error closing key file: %v", err)
-		}
-		if err != nil {
-			log.Fatalf("nodekey: %s", err)
-		}
-	case *nodeKeyHex != "":
-		var err error
-		nodeKey, err = crypto.HexToECDSA(*nodeKeyHex)
-		if err != nil {
-			log.Fatalf("nodekeyhex: %s", err)
-		}
-	}
+	nodeKey := loadNodeKey(*nodeKeyFile, *nodeKeyHex)
 
 	if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm, ""); err != nil {
 		log.Fatal(err)
@@ -234,15 +234,24 @@ func (ruleSet) IsAtlantis(*big.Int) bool { return true }
 // IsAgharta defaults to true for tests
 func (ruleSet) IsAgharta(*big.Int) bool { return true }
 
+// IsPhoenix defaults to true for tests
+func (ruleSet) IsPhoenix(*big.Int) bool { return true }
+
+// IsEIP2929 is false, the EIP-2929 access list isn't prepared for tests
+func (ruleSet) IsEIP2929(*big.Int) bool { return false }
+
+// GetChainID defaults to the mainnet chain id
+func (ruleSet) GetChainID() *big.Int { return big.NewInt(61) }
+
 func (ruleSet) GasTable(*big.Int) *vm.GasTable {
-	// IsAgharta will always return true here,
-	// just have gastable default to returning the Agharta GasTable
+	// IsPhoenix will always return true here,
+	// just have gastable default to returning the Phoenix GasTable
 	return &vm.GasTable{
 		ExtcodeSize:     big.NewInt(700),
 		ExtcodeCopy:     big.NewInt(700),
-		ExtcodeHash:     big.NewInt(400),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
+		ExtcodeHash:     big.NewInt(700),
+		Balance:         big.NewInt(700),
+		SLoad:           big.NewInt(800),
 		Calls:           big.NewInt(700),
 		Suicide:         big.NewInt(5000),
 		ExpByte:         big.NewInt(50),
@@ -31,7 +31,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/eth-classic/ethash"
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core"
 	"github.com/eth-classic/go-ethereum/core/state"
@@ -42,6 +41,7 @@ import (
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/node"
 	"github.com/eth-classic/go-ethereum/pow"
+	"github.com/eth-classic/go-ethereum/pow/etchash"
 	"github.com/eth-classic/go-ethereum/rlp"
 	"gopkg.in/urfave/cli.v1"
 	"math"
@@ -414,6 +414,16 @@ func formatEthConfigPretty(ethConfig *eth.Config) (s []string) {
 	ss = append(ss, printable{0, "GPO max gas price", ethConfig.GpoMaxGasPrice})
 	// MinerThreads
 	ss = append(ss, printable{0, "Miner threads", ethConfig.MinerThreads})
+	// TxPool
+	ss = append(ss, printable{0, "Transaction pool", nil})
+	ss = append(ss, printable{1, "Price bump (%)", ethConfig.TxPool.PriceBump})
+	ss = append(ss, printable{1, "Account slots", ethConfig.TxPool.AccountSlots})
+	ss = append(ss, printable{1, "Global slots", ethConfig.TxPool.GlobalSlots})
+	ss = append(ss, printable{1, "Account queue", ethConfig.TxPool.AccountQueue})
+	ss = append(ss, printable{1, "Global queue", ethConfig.TxPool.GlobalQueue})
+	ss = append(ss, printable{1, "Queue lifetime", ethConfig.TxPool.Lifetime})
+	ss = append(ss, printable{1, "Journal", ethConfig.TxPool.Journal})
+	ss = append(ss, printable{1, "Rejournal interval", ethConfig.TxPool.Rejournal})
 
 	for _, v := range ss {
 		if v.val != nil {
@@ -759,7 +769,10 @@ func makedag(ctx *cli.Context) error {
 			}
 			glog.V(logger.Info).Infoln("making DAG, this could take awhile...")
 			glog.D(logger.Warn).Infoln("making DAG, this could take awhile...")
-			ethash.MakeDAG(blockNum, dir)
+			transition := mustMakeSufficientChainConfig(ctx).ChainConfig.GetEthashECIP1099Transition()
+			if err := etchash.MakeDAG(blockNum, dir, transition); err != nil {
+				glog.Fatal("Could not make DAG: ", err)
+			}
 		}
 	default:
 		wrongArgs()
@@ -888,7 +901,7 @@ func recoverChaindata(ctx *cli.Context) error {
 
 	pow := pow.PoW(core.FakePow{})
 	if !ctx.GlobalBool(aliasableName(FakePoWFlag.Name, ctx)) {
-		pow = ethash.New()
+		pow = etchash.New(sconf.ChainConfig.GetEthashECIP1099Transition())
 	} else {
 		glog.V(logger.Warn).Info("Consensus: fake")
 	}
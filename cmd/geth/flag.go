@@ -30,7 +30,6 @@ import (
 
 	"errors"
 
-	"github.com/eth-classic/ethash"
 	"github.com/eth-classic/go-ethereum/accounts"
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core"
@@ -46,8 +45,10 @@ import (
 	"github.com/eth-classic/go-ethereum/miner"
 	"github.com/eth-classic/go-ethereum/node"
 	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/dnsdisc"
 	"github.com/eth-classic/go-ethereum/p2p/nat"
 	"github.com/eth-classic/go-ethereum/pow"
+	"github.com/eth-classic/go-ethereum/pow/etchash"
 	"github.com/eth-classic/go-ethereum/whisper"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -341,6 +342,34 @@ func MakeBootstrapNodesFromContext(ctx *cli.Context) []*discover.Node {
 	return core.ParseBootstrapNodeStrings(strings.Split(ctx.GlobalString(aliasableName(BootnodesFlag.Name, ctx)), ","))
 }
 
+// MakeBootstrapNodesV5FromContext creates the list of discovery v5 bootstrap
+// nodes from the command line flags, reverting to the v4 ones if none have
+// been specified.
+func MakeBootstrapNodesV5FromContext(ctx *cli.Context, v4 []*discover.Node) []*discover.Node {
+	if !ctx.GlobalIsSet(aliasableName(BootnodesV5Flag.Name, ctx)) {
+		return v4
+	}
+	return core.ParseBootstrapNodeStrings(strings.Split(ctx.GlobalString(aliasableName(BootnodesV5Flag.Name, ctx)), ","))
+}
+
+// MakeDiscoveryDNSFromContext returns the enrtree:// URLs of the DNS node
+// lists set on the command line.
+func MakeDiscoveryDNSFromContext(ctx *cli.Context) []string {
+	input := ctx.GlobalString(aliasableName(DiscoveryDNSFlag.Name, ctx))
+	if input == "" {
+		return nil
+	}
+	var urls []string
+	for _, url := range strings.Split(input, ",") {
+		url = strings.TrimSpace(url)
+		if _, _, err := dnsdisc.ParseURL(url); err != nil {
+			log.Fatalf("Option %s: invalid URL %q: %v", aliasableName(DiscoveryDNSFlag.Name, ctx), url, err)
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
 // MakeListenAddress creates a TCP listening address string from set command
 // line flags.
 func MakeListenAddress(ctx *cli.Context) string {
@@ -560,24 +589,27 @@ func shouldAttemptDirMigration(ctx *cli.Context) bool {
 func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientChainConfig) (stackConf *node.Config, shhEnable bool) {
 	// Configure the node's service container
 	stackConf = &node.Config{
-		DataDir:         MustMakeChainDataDir(ctx),
-		PrivateKey:      MakeNodeKey(ctx),
-		Name:            name,
-		NoDiscovery:     ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
-		BootstrapNodes:  config.ParsedBootstrap,
-		ListenAddr:      MakeListenAddress(ctx),
-		NAT:             MakeNAT(ctx),
-		MaxPeers:        ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
-		MaxPendingPeers: ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
-		IPCPath:         MakeIPCPath(ctx),
-		HTTPHost:        MakeHTTPRpcHost(ctx),
-		HTTPPort:        ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
-		HTTPCors:        ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
-		HTTPModules:     MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
-		WSHost:          MakeWSRpcHost(ctx),
-		WSPort:          ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
-		WSOrigins:       ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
-		WSModules:       MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
+		DataDir:          MustMakeChainDataDir(ctx),
+		PrivateKey:       MakeNodeKey(ctx),
+		Name:             name,
+		NoDiscovery:      ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
+		DiscoveryV5:      ctx.GlobalBool(aliasableName(DiscoveryV5Flag.Name, ctx)),
+		BootstrapNodes:   config.ParsedBootstrap,
+		BootstrapNodesV5: MakeBootstrapNodesV5FromContext(ctx, config.ParsedBootstrap),
+		DiscoveryDNS:     MakeDiscoveryDNSFromContext(ctx),
+		ListenAddr:       MakeListenAddress(ctx),
+		NAT:              MakeNAT(ctx),
+		MaxPeers:         ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
+		MaxPendingPeers:  ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
+		IPCPath:          MakeIPCPath(ctx),
+		HTTPHost:         MakeHTTPRpcHost(ctx),
+		HTTPPort:         ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
+		HTTPCors:         ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
+		HTTPModules:      MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
+		WSHost:           MakeWSRpcHost(ctx),
+		WSPort:           ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
+		WSOrigins:        ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
+		WSModules:        MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
 	}
 
 	// Configure the Whisper service
@@ -615,6 +647,26 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		}
 	}
 
+	gcmode := ctx.GlobalString(aliasableName(GCModeFlag.Name, ctx))
+	if gcmode != "full" && gcmode != "archive" {
+		glog.Fatalf("%v: --%s must be either 'full' or 'archive'", ErrInvalidFlag, GCModeFlag.Name)
+	}
+	var finality core.ArtificialFinality
+	switch mess := ctx.GlobalString(aliasableName(ArtificialFinalityFlag.Name, ctx)); mess {
+	case "auto":
+		finality = core.ArtificialFinalityAuto
+	case "on":
+		finality = core.ArtificialFinalityOn
+	case "off":
+		finality = core.ArtificialFinalityOff
+	default:
+		glog.Fatalf("%v: --%s must be one of 'auto', 'on' or 'off'", ErrInvalidFlag, ArtificialFinalityFlag.Name)
+	}
+	freezerThreshold := ctx.GlobalInt(aliasableName(FreezerThresholdFlag.Name, ctx))
+	if freezerThreshold < 0 {
+		glog.Fatalf("%v: --%s must not be negative", ErrInvalidFlag, FreezerThresholdFlag.Name)
+	}
+
 	ethConf := &eth.Config{
 		ChainConfig:             sconf.ChainConfig,
 		Genesis:                 sconf.Genesis,
@@ -622,6 +674,10 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		BlockChainVersion:       ctx.GlobalInt(aliasableName(BlockchainVersionFlag.Name, ctx)),
 		DatabaseCache:           ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx)),
 		DatabaseHandles:         MakeDatabaseHandles(),
+		NoPruning:               gcmode == "archive",
+		Snapshot:                ctx.GlobalBool(aliasableName(SnapshotFlag.Name, ctx)),
+		FreezerThreshold:        uint64(freezerThreshold),
+		ArtificialFinality:      finality,
 		NetworkId:               sconf.Network,
 		MaxPeers:                ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
 		AccountManager:          accman,
@@ -638,6 +694,19 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		GpobaseCorrectionFactor: ctx.GlobalInt(aliasableName(GpobaseCorrectionFactorFlag.Name, ctx)),
 		SolcPath:                ctx.GlobalString(aliasableName(SolcPathFlag.Name, ctx)),
 		AutoDAG:                 ctx.GlobalBool(aliasableName(AutoDAGFlag.Name, ctx)) || ctx.GlobalBool(aliasableName(MiningEnabledFlag.Name, ctx)),
+		TxPool: core.TxPoolConfig{
+			PriceBump:    txPoolLimit(ctx, TxPoolPriceBumpFlag),
+			AccountSlots: txPoolLimit(ctx, TxPoolAccountSlotsFlag),
+			GlobalSlots:  txPoolLimit(ctx, TxPoolGlobalSlotsFlag),
+			AccountQueue: txPoolLimit(ctx, TxPoolAccountQueueFlag),
+			GlobalQueue:  txPoolLimit(ctx, TxPoolGlobalQueueFlag),
+			Lifetime:     ctx.GlobalDuration(aliasableName(TxPoolLifetimeFlag.Name, ctx)),
+			Journal:      ctx.GlobalString(aliasableName(TxPoolJournalFlag.Name, ctx)),
+			Rejournal:    ctx.GlobalDuration(aliasableName(TxPoolRejournalFlag.Name, ctx)),
+		},
+	}
+	if ctx.GlobalBool(aliasableName(TxPoolNoJournalFlag.Name, ctx)) {
+		ethConf.TxPool.Journal = ""
 	}
 
 	if ctx.GlobalBool(aliasableName(FastSyncFlag.Name, ctx)) {
@@ -673,6 +742,16 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 	return ethConf
 }
 
+// txPoolLimit reads a transaction pool limit from the given flag, refusing
+// negative values which would wrap around to huge limits.
+func txPoolLimit(ctx *cli.Context, flag cli.IntFlag) uint64 {
+	limit := ctx.GlobalInt(aliasableName(flag.Name, ctx))
+	if limit < 0 {
+		glog.Fatalf("%v: --%s must not be negative", ErrInvalidFlag, flag.Name)
+	}
+	return uint64(limit)
+}
+
 // mustMakeSufficientChainConfig makes a sufficent chain configuration (id, chainconfig, nodes,...)
 // based on --chain or defaults or fails hard.
 // - User must provide a full and complete config file if any is specified located at /custom/chain.json
@@ -847,6 +926,15 @@ func MakeChainDatabase(ctx *cli.Context) ethdb.Database {
 	if err != nil {
 		glog.Fatal("Could not open database: ", err)
 	}
+	// Blocks moved into the ancient store are only readable through it
+	ancient := filepath.Join(chaindir, "chaindata", "ancient")
+	if common.FileExist(ancient) {
+		db, err := core.NewDatabaseWithFreezer(chainDb, ancient)
+		if err != nil {
+			glog.Fatal("Could not open ancient store: ", err)
+		}
+		return db
+	}
 	return chainDb
 }
 
@@ -872,7 +960,7 @@ func MakeChain(ctx *cli.Context) (chain *core.BlockChain, chainDb ethdb.Database
 
 	pow := pow.PoW(core.FakePow{})
 	if !ctx.GlobalBool(aliasableName(FakePoWFlag.Name, ctx)) {
-		pow = ethash.New()
+		pow = etchash.New(sconf.ChainConfig.GetEthashECIP1099Transition())
 	} else {
 		glog.V(logger.Info).Infoln("Consensus: fake")
 		glog.D(logger.Warn).Warnln("Consensus: fake")
@@ -85,6 +85,24 @@ var (
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 1024,
 	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Maintain flat snapshots of the recent states to accelerate state reads (generated in the background)",
+	}
+	ArtificialFinalityFlag = cli.StringFlag{
+		Name:  "mess",
+		Usage: `Artificial finality against deep reorgs (MESS, ECBP-1100): "auto" as configured by the chain, "on" or "off"`,
+		Value: "auto",
+	}
+	FreezerThresholdFlag = cli.IntFlag{
+		Name:  "freezer-threshold",
+		Usage: "Number of recent blocks kept in the database, older canonical blocks are moved to the ancient store (e.g. 90000, 0 = disabled)",
+	}
 	BlockchainVersionFlag = cli.IntFlag{
 		Name:  "blockchain-version,blockchainversion",
 		Usage: "Blockchain version (integer)",
@@ -372,6 +390,20 @@ var (
 		Name:  "no-discover,nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
 	}
+	DiscoveryV5Flag = cli.BoolFlag{
+		Name:  "v5disc",
+		Usage: "Enables the discovery v5 protocol alongside v4, sharing the UDP port",
+	}
+	BootnodesV5Flag = cli.StringFlag{
+		Name:  "bootnodesv5",
+		Usage: "Comma separated enode URLs or node records for discovery v5 bootstrap (defaults to --bootnodes)",
+		Value: "",
+	}
+	DiscoveryDNSFlag = cli.StringFlag{
+		Name:  "dnsdisc",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists used as dial candidates (EIP-1459)",
+		Value: "",
+	}
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable Whisper",
@@ -388,6 +420,52 @@ var (
 		Value: "solc",
 	}
 
+	// Transaction pool settings
+	TxPoolPriceBumpFlag = cli.IntFlag{
+		Name:  "txpool-price-bump",
+		Usage: "Price bump percentage to replace an already existing transaction",
+		Value: int(core.DefaultTxPoolConfig.PriceBump),
+	}
+	TxPoolAccountSlotsFlag = cli.IntFlag{
+		Name:  "txpool-account-slots",
+		Usage: "Minimum number of executable transaction slots guaranteed per account",
+		Value: int(core.DefaultTxPoolConfig.AccountSlots),
+	}
+	TxPoolGlobalSlotsFlag = cli.IntFlag{
+		Name:  "txpool-global-slots",
+		Usage: "Maximum number of executable transaction slots for all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalSlots),
+	}
+	TxPoolAccountQueueFlag = cli.IntFlag{
+		Name:  "txpool-account-queue",
+		Usage: "Maximum number of non-executable transaction slots permitted per account",
+		Value: int(core.DefaultTxPoolConfig.AccountQueue),
+	}
+	TxPoolGlobalQueueFlag = cli.IntFlag{
+		Name:  "txpool-global-queue",
+		Usage: "Maximum number of non-executable transaction slots for all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalQueue),
+	}
+	TxPoolLifetimeFlag = cli.DurationFlag{
+		Name:  "txpool-lifetime",
+		Usage: "Maximum amount of time non-executable transaction are queued",
+		Value: core.DefaultTxPoolConfig.Lifetime,
+	}
+	TxPoolJournalFlag = cli.StringFlag{
+		Name:  "txpool-journal",
+		Usage: "Disk journal for local transaction to survive node restarts (relative to the data directory)",
+		Value: core.DefaultTxPoolConfig.Journal,
+	}
+	TxPoolRejournalFlag = cli.DurationFlag{
+		Name:  "txpool-rejournal",
+		Usage: "Time interval to regenerate the local transaction journal",
+		Value: core.DefaultTxPoolConfig.Rejournal,
+	}
+	TxPoolNoJournalFlag = cli.BoolFlag{
+		Name:  "txpool-no-journal",
+		Usage: "Disables the disk journal of local transactions",
+	}
+
 	// Gas price oracle settings
 	GpoMinGasPriceFlag = cli.StringFlag{
 		Name:  "gpo-min,gpomin",
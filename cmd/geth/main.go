@@ -136,6 +136,8 @@ func makeCLIApp() (app *cli.App) {
 		versionCommand,
 		makeMlogDocCommand,
 		buildAddrTxIndexCommand,
+		witnessCommand,
+		pruneStateCommand,
 	}
 
 	app.Flags = []cli.Flag{
@@ -157,6 +159,10 @@ func makeCLIApp() (app *cli.App) {
 		AddrTxIndexFlag,
 		AddrTxIndexAutoBuildFlag,
 		CacheFlag,
+		GCModeFlag,
+		SnapshotFlag,
+		FreezerThresholdFlag,
+		ArtificialFinalityFlag,
 		LightKDFFlag,
 		JSpathFlag,
 		ListenPortFlag,
@@ -172,6 +178,9 @@ func makeCLIApp() (app *cli.App) {
 		NATFlag,
 		NatspecEnabledFlag,
 		NoDiscoverFlag,
+		DiscoveryV5Flag,
+		BootnodesV5Flag,
+		DiscoveryDNSFlag,
 		NodeKeyFileFlag,
 		NodeKeyHexFlag,
 		RPCEnabledFlag,
@@ -213,6 +222,15 @@ func makeCLIApp() (app *cli.App) {
 		MetricsFlag,
 		FakePoWFlag,
 		SolcPathFlag,
+		TxPoolPriceBumpFlag,
+		TxPoolAccountSlotsFlag,
+		TxPoolGlobalSlotsFlag,
+		TxPoolAccountQueueFlag,
+		TxPoolGlobalQueueFlag,
+		TxPoolLifetimeFlag,
+		TxPoolJournalFlag,
+		TxPoolRejournalFlag,
+		TxPoolNoJournalFlag,
 		GpoMinGasPriceFlag,
 		GpoMaxGasPriceFlag,
 		GpoFullBlockRatioFlag,
@@ -0,0 +1,89 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/state/pruner"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var pruneStateCommand = cli.Command{
+	Action: pruneState,
+	Name:   "prune-state",
+	Usage:  "Delete the state data of all but the head block",
+	Description: `
+	Walks the state of the current head block, recording its trie nodes and
+	contract codes in a bloom filter, and deletes all other state data from the
+	chain database. The states of older blocks are no longer available afterwards.
+
+	The bloom filter is saved in the chain data directory before anything is
+	deleted. If pruning is interrupted, running the command again resumes it.
+	Use --dry-run to report how much data would be freed without deleting it.
+	The node must not be running while pruning.
+			`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Report the state data that would be deleted, without deleting it",
+		},
+		cli.IntFlag{
+			Name:  "bloomsize",
+			Usage: "Megabytes of memory allocated to the bloom filter of the head state",
+			Value: 2048,
+		},
+	},
+}
+
+func pruneState(ctx *cli.Context) error {
+	bloomSize := ctx.Int("bloomsize")
+	if bloomSize < 1 {
+		log.Fatal("--bloomsize must be at least 1 megabyte")
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	ldb, ok := core.KeyValueStore(chainDb).(*ethdb.LDBDatabase)
+	if !ok {
+		log.Fatal("state pruning requires a LevelDB chain database")
+	}
+	head := chain.CurrentBlock()
+	p := pruner.NewPruner(ldb, filepath.Join(MustMakeChainDataDir(ctx), "statebloom.bin"), uint64(bloomSize)*1024*1024)
+
+	if ctx.Bool("dry-run") {
+		if p.Interrupted() {
+			log.Fatal("an interrupted pruning must be resumed before a dry run")
+		}
+		stats, err := p.DryRun(head.Root())
+		if err != nil {
+			log.Fatal("state pruning: ", err)
+		}
+		fmt.Printf("Pruning to the state of block #%d [%x…] would delete %d entries (%v)\n", head.NumberU64(), head.Hash().Bytes()[:4], stats.Nodes, stats.Size)
+		return nil
+	}
+	stats, err := p.Prune(head.Root())
+	if err != nil {
+		log.Fatal("state pruning: ", err)
+	}
+	fmt.Printf("Pruned to the state of block #%d [%x…], deleted %d entries (%v)\n", head.NumberU64(), head.Hash().Bytes()[:4], stats.Nodes, stats.Size)
+	return nil
+}
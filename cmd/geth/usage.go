@@ -79,6 +79,10 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			FastSyncFlag,
 			SlowSyncFlag,
 			CacheFlag,
+			GCModeFlag,
+			SnapshotFlag,
+			FreezerThresholdFlag,
+			ArtificialFinalityFlag,
 			LightKDFFlag,
 			SputnikVMFlag,
 			BlockchainVersionFlag,
@@ -136,6 +140,9 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			MaxPendingPeersFlag,
 			NATFlag,
 			NoDiscoverFlag,
+			DiscoveryV5Flag,
+			BootnodesV5Flag,
+			DiscoveryDNSFlag,
 			NodeKeyFileFlag,
 			NodeKeyHexFlag,
 		},
@@ -156,6 +163,20 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			ExtraDataFlag,
 		},
 	},
+	{
+		Name: "TRANSACTION POOL",
+		Flags: []cli.Flag{
+			TxPoolPriceBumpFlag,
+			TxPoolAccountSlotsFlag,
+			TxPoolGlobalSlotsFlag,
+			TxPoolAccountQueueFlag,
+			TxPoolGlobalQueueFlag,
+			TxPoolLifetimeFlag,
+			TxPoolJournalFlag,
+			TxPoolRejournalFlag,
+			TxPoolNoJournalFlag,
+		},
+	},
 	{
 		Name: "GAS PRICE ORACLE",
 		Flags: []cli.Flag{
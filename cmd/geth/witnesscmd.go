@@ -0,0 +1,163 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var witnessCommand = cli.Command{
+	Name:  "witness",
+	Usage: "Export and verify stateless block execution witnesses",
+	Description: `
+	A witness holds the state trie nodes, contract codes and ancestor headers
+	accessed while processing a block, which is enough to execute the block
+	and check its post-state root without a state database.
+
+	'$ geth witness <command> --help' shows help for any subcommand.
+		`,
+	Subcommands: []cli.Command{
+		{
+			Action: exportWitness,
+			Name:   "export",
+			Usage:  "Export a block and its execution witness to a file",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Write the witness as JSON instead of RLP",
+				},
+			},
+			Description: `
+geth witness export [--json] <blockNum> <file>
+
+	Re-executes the block on top of its parent state, recording the data it
+	accesses, and writes the block together with the witness to the file.
+			`,
+		},
+		{
+			Action: verifyWitness,
+			Name:   "verify",
+			Usage:  "Verify a block using only its execution witness",
+			Description: `
+geth witness verify <file>
+
+	Executes the block in a file written by 'geth witness export' using only
+	the witness, and checks the resulting state root against the block header.
+	The chain database is not opened; the chain configuration is taken from
+	the selected chain.
+			`,
+		},
+	},
+}
+
+// witnessFile is the RLP encoded content of a witness file.
+type witnessFile struct {
+	Block   *types.Block
+	Witness *core.Witness
+}
+
+// witnessFileJSON is the JSON encoded content of a witness file.
+type witnessFileJSON struct {
+	Block   hexutil.Bytes `json:"block"`
+	Witness *core.Witness `json:"witness"`
+}
+
+func exportWitness(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		log.Fatal("This command requires two arguments: <blockNum> <file>")
+	}
+	number, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		log.Fatal("witness export parameter: ", err)
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	block := chain.GetBlockByNumber(number)
+	if block == nil {
+		log.Fatalf("block #%d not found", number)
+	}
+	witness, err := chain.ExecutionWitness(block)
+	if err != nil {
+		log.Fatal("witness export: ", err)
+	}
+	var out []byte
+	if ctx.Bool("json") {
+		enc, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, err = json.MarshalIndent(&witnessFileJSON{Block: enc, Witness: witness}, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		out, err = rlp.EncodeToBytes(&witnessFile{Block: block, Witness: witness})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(ctx.Args().Get(1), out, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Exported witness of block #%d (%d headers, %d state entries)\n", number, len(witness.Headers), len(witness.State))
+	return nil
+}
+
+func verifyWitness(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		log.Fatal("This command requires an argument.")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		log.Fatal(err)
+	}
+	var file witnessFile
+	if trimmed := bytes.TrimSpace(blob); len(trimmed) > 0 && trimmed[0] == '{' {
+		var dec witnessFileJSON
+		if err := json.Unmarshal(trimmed, &dec); err != nil {
+			log.Fatal("invalid witness file: ", err)
+		}
+		file.Block = new(types.Block)
+		if err := rlp.DecodeBytes(dec.Block, file.Block); err != nil {
+			log.Fatal("invalid witness block: ", err)
+		}
+		file.Witness = dec.Witness
+	} else if err := rlp.DecodeBytes(blob, &file); err != nil {
+		log.Fatal("invalid witness file: ", err)
+	}
+	if file.Block == nil || file.Witness == nil {
+		log.Fatal("invalid witness file: missing block or witness")
+	}
+	config := mustMakeSufficientChainConfig(ctx).ChainConfig
+	if err := core.VerifyWitness(config, file.Block, file.Witness); err != nil {
+		log.Fatalf("Block #%d [%x…] failed verification: %v", file.Block.NumberU64(), file.Block.Hash().Bytes()[:4], err)
+	}
+	fmt.Printf("Block #%d [%x…] verified, state root %x\n", file.Block.NumberU64(), file.Block.Hash().Bytes()[:4], file.Block.Root())
+	return nil
+}
@@ -22,6 +22,12 @@ import (
 	"path/filepath"
 )
 
+// FileExist checks if a file exists at filePath.
+func FileExist(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil || !os.IsNotExist(err)
+}
+
 func EnsurePathAbsoluteOrRelativeTo(Datadir string, filename string) string {
 	if filepath.IsAbs(filename) {
 		return filename
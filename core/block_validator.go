@@ -78,7 +78,7 @@ func NewBlockValidator(config *ChainConfig, blockchain *BlockChain, pow pow.PoW)
 // false positives where a header is present but the state is not.
 func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 	if v.bc.HasBlock(block.Hash()) {
-		if _, err := state.New(block.Root(), state.NewDatabase(v.bc.chainDb)); err == nil {
+		if _, err := state.New(block.Root(), v.bc.stateDatabase); err == nil {
 			return &KnownBlockError{block.Number(), block.Hash()}
 		}
 	}
@@ -86,7 +86,7 @@ func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 	if parent == nil {
 		return ParentError(block.ParentHash())
 	}
-	if _, err := state.New(parent.Root(), state.NewDatabase(v.bc.chainDb)); err != nil {
+	if _, err := state.New(parent.Root(), v.bc.stateDatabase); err != nil {
 		return ParentError(block.ParentHash())
 	}
 
@@ -121,6 +121,12 @@ func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 // itself. ValidateState returns a database batch if the validation was a success
 // otherwise nil and an error is returned.
 func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *state.StateDB, receipts types.Receipts, usedGas *big.Int) (err error) {
+	return validateState(v.config, block, statedb, receipts, usedGas)
+}
+
+// validateState checks the gas used, bloom, receipt root and state root of the
+// processed block against the values in its header.
+func validateState(config *ChainConfig, block *types.Block, statedb *state.StateDB, receipts types.Receipts, usedGas *big.Int) error {
 	header := block.Header()
 	if block.GasUsed().Cmp(usedGas) != 0 {
 		return validateError(fmt.Sprintf("gas used error (%v / %v)", block.GasUsed(), usedGas))
@@ -138,7 +144,7 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	}
 	// Validate the state root against the received state root and throw
 	// an error if they don't match.
-	if root := statedb.IntermediateRoot(v.config.IsAtlantis(header.Number)); header.Root != root {
+	if root := statedb.IntermediateRoot(config.IsAtlantis(header.Number)); header.Root != root {
 		return fmt.Errorf("invalid merkle root: header=%x computed=%x", header.Root, root)
 	}
 	return nil
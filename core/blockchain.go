@@ -36,6 +36,7 @@ import (
 
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/core/state/snapshot"
 	"github.com/eth-classic/go-ethereum/core/types"
 	"github.com/eth-classic/go-ethereum/core/vm"
 	"github.com/eth-classic/go-ethereum/crypto"
@@ -65,8 +66,38 @@ const (
 	// must be bumped when consensus algorithm is changed, this forces the upgradedb
 	// command to be run (forces the blocks to be imported again using the new algorithm)
 	BlockChainVersion = 3
+
+	// Number of recent block states kept in memory when garbage collecting the state.
+	triesInMemory = 128
+
+	// Time between checks for canonical blocks to move into the ancient store.
+	freezerRecheckInterval = time.Minute
+
+	// Maximum number of blocks moved into the ancient store in one go.
+	freezerBatchLimit = 30000
 )
 
+// CacheConfig contains the configuration values for the in-memory caching and
+// garbage collection of the state tries.
+type CacheConfig struct {
+	Disabled      bool   // Whether to write all states to disk, keeping them forever (archive node)
+	TrieNodeLimit int    // Memory limit (MB) at which to flush the in-memory tries to disk
+	FlushInterval uint64 // Number of blocks after which to flush the in-memory tries to disk
+}
+
+// DefaultCacheConfig is the configuration used to garbage collect the state
+// of a full node.
+var DefaultCacheConfig = &CacheConfig{
+	TrieNodeLimit: 256,
+	FlushInterval: 1024,
+}
+
+// trieRef is an in-memory state trie root referenced by the chain.
+type trieRef struct {
+	root   common.Hash
+	number uint64
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -93,14 +124,24 @@ type BlockChain struct {
 	chainmu sync.RWMutex // blockchain insertion lock
 	procmu  sync.RWMutex // block processor lock
 
+	freezerMu sync.Mutex // Serializes block migrations into the ancient store with its truncation
+
 	currentBlock     *types.Block // Current head of the block chain
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
-	stateCache   *state.StateDB // State database to reuse between imports (contains state cache)
-	bodyCache    *lru.Cache     // Cache for the most recent block bodies
-	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
-	blockCache   *lru.Cache     // Cache for the most recent entire blocks
-	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	cacheConfig        *CacheConfig       // Configuration of the state trie caching and garbage collection
+	stateDatabase      state.Database     // State and trie node database shared by the states of the chain
+	stateCache         *state.StateDB     // State database to reuse between imports (contains state cache)
+	snaps              *snapshot.Tree     // Flat snapshots of the recent states, if enabled
+	artificialFinality ArtificialFinality // Whether MESS is applied to reorgs (guarded by mu)
+	triegc             []trieRef          // State roots held in memory, to garbage collect once they're old
+	lastFlush          uint64             // Number of the last block whose state was flushed to disk
+	bodyCache          *lru.Cache         // Cache for the most recent block bodies
+	bodyRLPCache       *lru.Cache         // Cache for the most recent block bodies in RLP encoded format
+	blockCache         *lru.Cache         // Cache for the most recent entire blocks
+	futureBlocks       *lru.Cache         // future blocks are blocks added for later processing
+
+	freezeThreshold uint64 // Number of recent blocks kept out of the ancient store, accessed atomically
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -160,6 +201,9 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		pow:          pow,
+
+		cacheConfig:   &CacheConfig{Disabled: true},
+		stateDatabase: state.NewDatabase(chainDb),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, pow))
 	bc.SetProcessor(NewStateProcessor(config, bc))
@@ -208,6 +252,9 @@ func NewBlockChainDryrun(chainDb ethdb.Database, config *ChainConfig, pow pow.Po
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		pow:          pow,
+
+		cacheConfig:   &CacheConfig{Disabled: true},
+		stateDatabase: state.NewDatabase(chainDb),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, pow))
 	bc.SetProcessor(NewStateProcessor(config, bc))
@@ -245,6 +292,73 @@ func (bc *BlockChain) GetEventMux() *event.TypeMux {
 	return bc.eventMux
 }
 
+// SetCacheConfig sets the configuration of the state trie caching. Unless
+// caching is disabled, the states of old blocks are garbage collected and the
+// database is marked as pruned.
+func (bc *BlockChain) SetCacheConfig(c *CacheConfig) error {
+	if !c.Disabled {
+		if err := WriteStatePruned(bc.chainDb); err != nil {
+			return err
+		}
+	}
+	bc.chainmu.Lock()
+	bc.cacheConfig = c
+	bc.chainmu.Unlock()
+	return nil
+}
+
+// SetFreezerThreshold starts moving the canonical blocks older than the given
+// number of blocks from the key-value store of the chain database into its
+// ancient store. It fails if the database has no ancient store.
+func (bc *BlockChain) SetFreezerThreshold(threshold uint64) error {
+	store, ok := bc.chainDb.(AncientStore)
+	if !ok {
+		return errors.New("chain database has no ancient store")
+	}
+	if threshold == 0 {
+		return errors.New("zero freezer threshold")
+	}
+	if atomic.SwapUint64(&bc.freezeThreshold, threshold) == 0 {
+		bc.wg.Add(1)
+		go bc.freeze(store)
+	}
+	return nil
+}
+
+// EnableSnapshots maintains flat snapshots of the recent states, which the
+// states of the chain read their accounts and storage from instead of walking
+// the tries. A persisted snapshot not matching the head state is regenerated
+// in the background.
+func (bc *BlockChain) EnableSnapshots() error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	root := bc.CurrentBlock().Root()
+	triedb := bc.stateDatabase.TrieDB()
+	snaps, err := snapshot.New(KeyValueStore(bc.chainDb), triedb, root, true)
+	if err != nil {
+		return err
+	}
+	bc.snaps = snaps
+	bc.stateDatabase = state.NewDatabaseWithSnapshots(triedb, snaps)
+
+	statedb, err := state.New(root, bc.stateDatabase)
+	if err != nil {
+		return err
+	}
+	bc.stateCache = statedb
+	return nil
+}
+
+// rebuildSnapshots regenerates the state snapshots if they don't follow the
+// head anymore, e.g. after rewinding the chain or a reorg deeper than the
+// diff layers kept in memory.
+func (bc *BlockChain) rebuildSnapshots(head *types.Block) {
+	if bc.snaps != nil && bc.snaps.Snapshot(head.Root()) == nil {
+		bc.snaps.Rebuild(head.Root())
+	}
+}
+
 // SetAtxi sets the db and in-use var for atx indexing.
 func (bc *BlockChain) SetAtxi(a *AtxiT) {
 	bc.atxi = a
@@ -416,6 +530,10 @@ func (bc *BlockChain) blockIsInvalid(b *types.Block) error {
 		return nil
 	}
 
+	// The state of any block may have been garbage collected, so there's no
+	// telling fast and full blocks apart by their states.
+	pruned := IsStatePruned(bc.chainDb)
+
 	// Since we're using absent state to decide that a full block check isn't required,
 	// we need to be sure that an absent state isn't actually a db corruption/inconsistency.
 	// Check parent block and confirm that there is NOT any state available for that block, either.
@@ -428,8 +546,8 @@ func (bc *BlockChain) blockIsInvalid(b *types.Block) error {
 		if cb.Header() == nil {
 			return fmt.Errorf("preceding nil header block=#%d, while checking block=#%d health", pi, b.NumberU64())
 		}
-		// Genesis will have state.
-		if pi > 1 {
+		// Genesis will have state. Pruned states may be absent for any block.
+		if pi > 1 && !pruned {
 			if bc.HasBlockAndState(cb.Hash()) {
 				return fmt.Errorf("checking block=%d without state, found nonabsent state for block #%d", b.NumberU64(), pi)
 			}
@@ -442,6 +560,12 @@ func (bc *BlockChain) blockIsInvalid(b *types.Block) error {
 		return e
 	}
 
+	// Only the checks not depending on state apply to pruned blocks.
+	if pruned {
+		glog.V(logger.Debug).Infof("Validating recovery block #%d with pruned state", b.Number())
+		return fastBlockCheck(b)
+	}
+
 	// Separate checks for fast/full blocks.
 	//
 	// Assume state is not missing.
@@ -609,6 +733,20 @@ func (bc *BlockChain) LoadLastState(dryrun bool) error {
 		return errors.New("nil currentBlock")
 	}
 
+	// The head state may be missing after an unclean shutdown if the state
+	// is garbage collected, rewind to the newest block with a state.
+	if !dryrun && IsStatePruned(bc.chainDb) && !bc.HasBlockAndState(currentBlock.Hash()) {
+		number := currentBlock.NumberU64()
+		if err := bc.repair(&currentBlock); err != nil {
+			glog.V(logger.Warn).Errorf("Failed to repair head block state: %v\nAttempting chain reset with recovery.", err)
+			return recoverOrReset()
+		}
+		glog.V(logger.Warn).Infof("Head state missing, rewound head block from #%d to #%d [%x…]", number, currentBlock.Number(), currentBlock.Hash().Bytes()[:4])
+		if err := WriteHeadBlockHash(bc.chainDb, currentBlock.Hash()); err != nil {
+			return err
+		}
+	}
+
 	// If currentBlock (fullblock) is not genesis, check that it is valid
 	// and that it has a state associated with it.
 	if currentBlock.Number().Cmp(new(big.Int)) > 0 {
@@ -726,12 +864,13 @@ func (bc *BlockChain) LoadLastState(dryrun bool) error {
 	}
 
 	// Initialize a statedb cache to ensure singleton account bloom filter generation
-	statedb, err := state.New(bc.currentBlock.Root(), state.NewDatabase(bc.chainDb))
+	statedb, err := state.New(bc.currentBlock.Root(), bc.stateDatabase)
 	if err != nil {
 		return err
 	}
 	bc.stateCache = statedb
 	bc.stateCache.GetAccount(common.Address{})
+	bc.lastFlush = bc.currentBlock.NumberU64()
 
 	// Issue a status log and return
 	headerTd := bc.GetTd(bc.hc.CurrentHeader().Hash())
@@ -783,6 +922,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
+	bc.truncateAncients(currentHeader.Number.Uint64())
 
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
@@ -794,8 +934,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if bc.currentBlock != nil && currentHeader.Number.Uint64() < bc.currentBlock.NumberU64() {
 		bc.currentBlock = bc.GetBlock(currentHeader.Hash())
 	}
-	if bc.currentBlock != nil {
-		if _, err := state.New(bc.currentBlock.Root(), state.NewDatabase(bc.chainDb)); err != nil {
+	if bc.currentBlock != nil && !bc.HasBlockAndState(bc.currentBlock.Hash()) {
+		if IsStatePruned(bc.chainDb) {
+			// Rewound state garbage collected, roll back to the newest available
+			if err := bc.repair(&bc.currentBlock); err != nil {
+				bc.currentBlock = nil
+			}
+		} else {
 			// Rewound state missing, rolled back to before pivot, reset to genesis
 			bc.currentBlock = nil
 		}
@@ -812,6 +957,8 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		bc.currentFastBlock = bc.genesisBlock
 	}
 
+	bc.rebuildSnapshots(bc.currentBlock)
+
 	if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
 		glog.Fatalf("failed to reset head block hash: %v", err)
 	}
@@ -971,7 +1118,13 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, state.NewDatabase(bc.chainDb))
+	return state.New(root, bc.stateDatabase)
+}
+
+// StateDatabase returns the state database of the chain, holding the recent
+// states not yet flushed to disk.
+func (bc *BlockChain) StateDatabase() state.Database {
+	return bc.stateDatabase
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -1122,7 +1275,7 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 		return false
 	}
 	// Ensure the associated state is also present
-	_, err := state.New(block.Root(), state.NewDatabase(bc.chainDb))
+	_, err := state.New(block.Root(), bc.stateDatabase)
 	return err == nil
 }
 
@@ -1187,9 +1340,101 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Persist the snapshot diff layers, so that the next start can reuse them
+	if bc.snaps != nil {
+		if err := bc.snaps.Journal(bc.CurrentBlock().Root()); err != nil {
+			glog.V(logger.Error).Errorf("Failed to journal state snapshot: %v", err)
+		}
+	}
+	// Flush the state of the head block from memory, so that the next start
+	// can resume from it without reprocessing any blocks
+	if !bc.cacheConfig.Disabled {
+		if err := bc.stateDatabase.TrieDB().Commit(bc.CurrentBlock().Root()); err != nil {
+			glog.V(logger.Error).Errorf("Failed to flush head state: %v", err)
+		}
+	}
 	glog.V(logger.Info).Infoln("Chain manager stopped")
 }
 
+// writeState commits the state of a processed block. With caching disabled
+// the state is written straight to disk. Otherwise it is held in memory, where
+// the states of blocks older than triesInMemory are garbage collected and the
+// state of a canonical block is flushed to disk every FlushInterval blocks,
+// or sooner if the memory limit is exceeded.
+func (bc *BlockChain) writeState(block *types.Block, statedb *state.StateDB) error {
+	deleteEmptyObjects := bc.config.IsAtlantis(block.Number())
+	if bc.cacheConfig.Disabled {
+		root, err := statedb.CommitTo(bc.chainDb, deleteEmptyObjects)
+		if err == nil {
+			bc.capSnapshots(root)
+		}
+		return err
+	}
+	root, err := statedb.Commit(deleteEmptyObjects)
+	if err != nil {
+		return err
+	}
+	// Flatten the old snapshot diff layers before their tries are released
+	bc.capSnapshots(root)
+
+	triedb := bc.stateDatabase.TrieDB()
+	triedb.Reference(root, common.Hash{})
+	bc.triegc = append(bc.triegc, trieRef{root: root, number: block.NumberU64()})
+
+	current := block.NumberU64()
+	if current <= triesInMemory {
+		return nil
+	}
+	chosen := current - triesInMemory
+
+	limit := common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+	if (chosen > bc.lastFlush && chosen-bc.lastFlush >= bc.cacheConfig.FlushInterval) || triedb.Size() > limit {
+		if header := bc.GetHeaderByNumber(chosen); header != nil {
+			if err := triedb.Commit(header.Root); err != nil {
+				return err
+			}
+			bc.lastFlush = chosen
+		}
+	}
+	// Garbage collect the states which are no longer needed in memory
+	live := bc.triegc[:0]
+	for _, ref := range bc.triegc {
+		if ref.number > chosen {
+			live = append(live, ref)
+			continue
+		}
+		triedb.Dereference(ref.root, common.Hash{})
+	}
+	bc.triegc = live
+	return nil
+}
+
+// capSnapshots flattens the snapshot diff layers below the given state root
+// which are older than the states kept in memory.
+func (bc *BlockChain) capSnapshots(root common.Hash) {
+	if bc.snaps == nil {
+		return
+	}
+	if err := bc.snaps.Cap(root, triesInMemory); err != nil {
+		glog.V(logger.Debug).Infof("Failed to cap state snapshot %x: %v", root, err)
+	}
+}
+
+// repair rewinds the given head block to its newest ancestor whose state is
+// available. The state of the head may be missing if the node wasn't shut down
+// cleanly while garbage collecting the state; the blocks above the new head
+// are processed again as the chain syncs.
+func (bc *BlockChain) repair(head **types.Block) error {
+	for !bc.HasBlockAndState((*head).Hash()) {
+		block := bc.GetBlock((*head).ParentHash())
+		if block == nil {
+			return fmt.Errorf("missing block #%d [%x…]", (*head).NumberU64()-1, (*head).ParentHash().Bytes()[:4])
+		}
+		*head = block
+	}
+	return nil
+}
+
 type WriteStatus byte
 
 const (
@@ -1223,6 +1468,7 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 			}
 		}
 	}
+	bc.truncateAncients(bc.hc.CurrentHeader().Number.Uint64())
 }
 
 // InsertReceiptChain attempts to complete an already existing header chain with
@@ -1440,6 +1686,23 @@ func (bc *BlockChain) WriteBlockAddrTxIndexesBatch(indexDb ethdb.Database, start
 	return txsCount, batch.Write()
 }
 
+// WriteBlockWithState commits the state of a block processed outside of the
+// chain, such as a locally mined one, and writes the block to the chain. The
+// state goes through the same in-memory trie database and garbage collection
+// as the states of imported blocks.
+func (bc *BlockChain) WriteBlockWithState(block *types.Block, statedb *state.StateDB) (WriteStatus, error) {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	if err := bc.writeState(block, statedb); err != nil {
+		return NonStatTy, err
+	}
+	return bc.WriteBlock(block)
+}
+
 // WriteBlock writes the block to the chain.
 func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err error) {
 
@@ -1506,6 +1769,26 @@ func (bc *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err er
 		// Split same-difficulty blocks by number, then at random
 		reorg = block.NumberU64() < bc.currentBlock.NumberU64() || (block.NumberU64() == bc.currentBlock.NumberU64() && mrand.Float64() < 0.5)
 	}
+	// Protect against deep reorgs by requiring side chains to add more difficulty
+	// the older their common ancestor is (MESS, ECBP-1100)
+	if reorg && block.ParentHash() != bc.currentBlock.Hash() && bc.isArtificialFinality(bc.currentBlock.Number()) {
+		if err := bc.checkArtificialFinality(bc.currentBlock, block, externTd); err != nil {
+			glog.V(logger.Warn).Warnf("Keeping block #%v [%s…] as side chain: %v", block.Number(), block.Hash().Hex()[:10], err)
+			glog.D(logger.Warn).Warnf("Rejected reorg to block #%v [%s…]: %v", block.Number(), block.Hash().Hex()[:10], err)
+			if logger.MlogEnabled() {
+				rejection := err.(*errReorgFinality)
+				mlogBlockchainRejectReorg.AssignDetails(
+					rejection.ancestor.Hash().Hex(),
+					rejection.ancestor.Number,
+					rejection.age,
+					rejection.ratio,
+					bc.currentBlock.Hash().Hex(),
+					block.Hash().Hex(),
+				).Send(mlogBlockchain)
+			}
+			reorg = false
+		}
+	}
 
 	if reorg {
 		// Reorganise the chain if the parent is not the head block
@@ -1656,8 +1939,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (res *ChainInsertResult) {
 			return
 		}
 		// Write state changes to database
-		_, err = bc.stateCache.CommitTo(bc.chainDb, bc.config.IsAtlantis(block.Number()))
-		if err != nil {
+		if err := bc.writeState(block, bc.stateCache); err != nil {
 			res.Error = err
 			return
 		}
@@ -1926,6 +2208,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			}
 		}()
 	}
+	bc.rebuildSnapshots(newBlock)
 
 	return nil
 }
@@ -1975,6 +2258,124 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// freeze periodically moves the ancient canonical blocks into the ancient
+// store, until the chain is stopped.
+func (bc *BlockChain) freeze(store AncientStore) {
+	defer bc.wg.Done()
+
+	for {
+		frozen, err := bc.freezeBlocks(store)
+		if err != nil {
+			glog.V(logger.Error).Errorf("Failed to move blocks into ancient store: %v", err)
+		}
+		// Keep going right away if there is a backlog, otherwise wait a bit
+		wait := freezerRecheckInterval
+		if err == nil && frozen == freezerBatchLimit {
+			wait = 0
+		}
+		select {
+		case <-bc.quit:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// freezeBlocks moves a batch of canonical blocks older than the freezer
+// threshold into the ancient store, returning the number of blocks moved.
+// The blocks are deleted from the key-value store once the ancient store is
+// synced to disk, so a crash at any point may leave a stale copy in the
+// key-value store, but never lose a block.
+//
+// The chain lock is only held to read the current head, so that block imports
+// aren't stalled by the migration. A rewind racing with it may end the batch
+// early with an error, and truncates whatever was moved above the new head
+// once the batch is done.
+func (bc *BlockChain) freezeBlocks(store AncientStore) (int, error) {
+	bc.mu.RLock()
+	head := bc.currentBlock.NumberU64()
+	bc.mu.RUnlock()
+
+	threshold := atomic.LoadUint64(&bc.freezeThreshold)
+	if head < threshold {
+		return 0, nil
+	}
+	bc.freezerMu.Lock()
+	defer bc.freezerMu.Unlock()
+
+	var (
+		limit  = head - threshold
+		hashes []common.Hash
+		start  = time.Now()
+	)
+freeze:
+	for number := store.Ancients(); number <= limit && len(hashes) < freezerBatchLimit; number++ {
+		select {
+		case <-bc.quit:
+			break freeze
+		default:
+		}
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			return len(hashes), fmt.Errorf("canonical hash #%d missing", number)
+		}
+		header, body, td := GetHeaderRLP(bc.chainDb, hash), GetBodyRLP(bc.chainDb, hash), getTdRLP(bc.chainDb, hash)
+		if len(header) == 0 || len(body) == 0 || len(td) == 0 {
+			return len(hashes), fmt.Errorf("block #%d [%x…] data missing", number, hash.Bytes()[:4])
+		}
+		receipts := getBlockReceiptsRLP(bc.chainDb, hash)
+		if len(receipts) == 0 {
+			receipts = rlp.EmptyList
+		}
+		if err := WriteAncientNumber(bc.chainDb, hash, number); err != nil {
+			return len(hashes), err
+		}
+		if err := store.AppendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			return len(hashes), err
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+	if err := store.SyncAncient(); err != nil {
+		return 0, err
+	}
+	for _, hash := range hashes {
+		DeleteHeader(bc.chainDb, hash)
+		DeleteBody(bc.chainDb, hash)
+		DeleteTd(bc.chainDb, hash)
+		DeleteBlockReceipts(bc.chainDb, hash)
+	}
+	glog.V(logger.Info).Infof("Moved blocks into ancient store: count=%d frozen=%d elapsed=%v", len(hashes), store.Ancients(), time.Since(start))
+	return len(hashes), nil
+}
+
+// truncateAncients discards the blocks above a rewound head from the ancient
+// store, as they are no longer immutable.
+func (bc *BlockChain) truncateAncients(head uint64) {
+	store, ok := bc.chainDb.(AncientStore)
+	if !ok {
+		return
+	}
+	bc.freezerMu.Lock()
+	defer bc.freezerMu.Unlock()
+
+	frozen := store.Ancients()
+	if frozen <= head+1 {
+		return
+	}
+	for number := head + 1; number < frozen; number++ {
+		if hash, err := store.Ancient(freezerHashTable, number); err == nil {
+			DeleteAncientNumber(bc.chainDb, common.BytesToHash(hash))
+		}
+	}
+	if err := store.TruncateAncients(head + 1); err != nil {
+		glog.Fatalf("failed to truncate ancient store: %v", err)
+	}
+	glog.V(logger.Warn).Infof("Truncated ancient store: frozen=%d", head+1)
+}
+
 // InsertHeaderChain attempts to insert the given header chain in to the local
 // chain, possibly creating a reorg. If an error is returned, it will return the
 // index number of the failing header as well an error describing what went wrong.
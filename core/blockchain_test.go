@@ -36,6 +36,7 @@ import (
 	"github.com/eth-classic/go-ethereum/event"
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
 	"github.com/hashicorp/golang-lru"
 	"io/ioutil"
 	"strings"
@@ -501,6 +502,9 @@ func chm(t testing.TB, genesis *types.Block, db ethdb.Database) *BlockChain {
 		eventMux:     &eventMux,
 		pow:          FakePow{},
 		config:       config,
+
+		cacheConfig:   &CacheConfig{Disabled: true},
+		stateDatabase: state.NewDatabase(db),
 	}
 	valFn := func() HeaderValidator { return bc.Validator() }
 	var err error
@@ -1838,3 +1842,216 @@ func TestBlockChain_BlockIsGenesis(t *testing.T) {
 		t.Errorf("expected: is not genesis block")
 	}
 }
+
+// Tests that the states of old blocks are garbage collected from memory when
+// state caching is enabled, with only the periodically flushed states and the
+// head state on shutdown written to disk.
+func TestTrieGarbageCollection(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		db, _  = ethdb.NewMemDatabase()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewChainIdSigner(big.NewInt(63))
+		config = MakeDiehardChainConfig()
+	)
+	// Generate the chain in a separate database, which receives all states
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(gendb, GenesisAccount{addr, big.NewInt(1000000000)})
+	WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, _ := GenerateChain(config, genesis, gendb, 2*triesInMemory, func(i int, gen *BlockGen) {
+		tx, err := types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SetCacheConfig(&CacheConfig{TrieNodeLimit: 256, FlushInterval: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	onDisk := func(block *types.Block) bool {
+		_, err := state.New(block.Root(), state.NewDatabase(db))
+		return err == nil
+	}
+	// The recent states should be in memory only, except for the flushed ones
+	for _, block := range blocks {
+		number := block.NumberU64()
+		flushed := number == 64 || number == 128
+		if have := onDisk(block); have != flushed {
+			t.Errorf("block #%d: state on disk mismatch: have %v, want %v", number, have, flushed)
+		}
+		recent := number > triesInMemory
+		if have := blockchain.HasBlockAndState(block.Hash()); have != (recent || flushed) {
+			t.Errorf("block #%d: state availability mismatch: have %v, want %v", number, have, recent || flushed)
+		}
+	}
+	// Stopping the chain should flush the head state
+	head := blocks[len(blocks)-1]
+	blockchain.Stop()
+	if !onDisk(head) {
+		t.Fatalf("head state not flushed on stop")
+	}
+}
+
+// Tests that states committed through WriteBlockWithState, as the miner does,
+// are garbage collected without losing the trie nodes shared with older states.
+func TestWriteBlockWithStateGarbageCollection(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		db, _  = ethdb.NewMemDatabase()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewChainIdSigner(big.NewInt(63))
+		config = MakeDiehardChainConfig()
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(gendb, GenesisAccount{addr, big.NewInt(1000000000)})
+	WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, _ := GenerateChain(config, genesis, gendb, 2*triesInMemory, func(i int, gen *BlockGen) {
+		tx, err := types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SetCacheConfig(&CacheConfig{TrieNodeLimit: 256, FlushInterval: 64}); err != nil {
+		t.Fatal(err)
+	}
+	// Import the first blocks, leaving their states in memory, then process and
+	// write the rest the way locally mined blocks are
+	if res := blockchain.InsertChain(blocks[:triesInMemory]); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	for _, block := range blocks[triesInMemory:] {
+		statedb, err := blockchain.StateAt(blockchain.GetBlock(block.ParentHash()).Root())
+		if err != nil {
+			t.Fatalf("block #%d: failed to open parent state: %v", block.NumberU64(), err)
+		}
+		if _, _, _, err := blockchain.processor.Process(block, statedb); err != nil {
+			t.Fatalf("block #%d: failed to process: %v", block.NumberU64(), err)
+		}
+		if status, err := blockchain.WriteBlockWithState(block, statedb); err != nil || status != CanonStatTy {
+			t.Fatalf("block #%d: failed to write: status %v, err %v", block.NumberU64(), status, err)
+		}
+	}
+	blockchain.Stop()
+
+	// The head state must be complete on disk, including untouched subtries
+	head := blocks[len(blocks)-1]
+	tr, err := trie.New(head.Root(), db)
+	if err != nil {
+		t.Fatalf("head state missing: %v", err)
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("head state incomplete: %v", err)
+	}
+}
+
+// Tests that a chain which wasn't stopped cleanly while garbage collecting the
+// state is rewound to the newest block with its state on disk.
+func TestTrieGarbageCollectionRepair(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		db, _  = ethdb.NewMemDatabase()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewChainIdSigner(big.NewInt(63))
+		config = MakeDiehardChainConfig()
+	)
+	// Generate the chain in a separate database, which receives all states
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(gendb, GenesisAccount{addr, big.NewInt(1000000000)})
+	WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, _ := GenerateChain(config, genesis, gendb, triesInMemory+100, func(i int, gen *BlockGen) {
+		tx, err := types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SetCacheConfig(&CacheConfig{TrieNodeLimit: 256, FlushInterval: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	// Reopen the chain without stopping it, dropping the in-memory states
+	blockchain, err = NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != blocks[63].Hash() {
+		t.Fatalf("head block mismatch: have #%d, want #64", head.NumberU64())
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Errorf("head header mismatch: have #%d, want #%d", head.Number, len(blocks))
+	}
+	// The rewound blocks should be processed again
+	if err := blockchain.SetCacheConfig(&CacheConfig{TrieNodeLimit: 256, FlushInterval: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks[64:]); res.Error != nil {
+		t.Fatalf("failed to reinsert block %d: %v", res.Index, res.Error)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Errorf("head block mismatch after reinsertion: have #%d, want #%d", head.NumberU64(), len(blocks))
+	}
+}
+
+// Tests that the recovery health check of a pruned chain skips only the state
+// checks, still catching a missing canonical ancestor.
+func TestBlockIsInvalidPrunedState(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		db, _  = ethdb.NewMemDatabase()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewChainIdSigner(big.NewInt(63))
+		config = MakeDiehardChainConfig()
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(gendb, GenesisAccount{addr, big.NewInt(1000000000)})
+	WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, _ := GenerateChain(config, genesis, gendb, triesInMemory+10, func(i int, gen *BlockGen) {
+		tx, err := types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SetCacheConfig(&CacheConfig{TrieNodeLimit: 256, FlushInterval: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	// Blocks with and without their states are healthy alike
+	for _, block := range blocks {
+		if err := blockchain.blockIsInvalid(block); err != nil {
+			t.Fatalf("block #%d: unexpected health error: %v", block.NumberU64(), err)
+		}
+	}
+	// A missing canonical ancestor is still detected
+	DeleteCanonicalHash(db, 10)
+	if err := blockchain.blockIsInvalid(blocks[10]); err == nil {
+		t.Errorf("block #11: missing preceding block not detected")
+	}
+}
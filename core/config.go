@@ -301,6 +301,17 @@ func (c *ChainConfig) GetChainID() *big.Int {
 	return n
 }
 
+// GetEthashECIP1099Transition gets the block number from which the ethash epoch
+// length is doubled to 60000 blocks as specified in ECIP-1099 (etchash).
+// It returns nil if no fork configures the ecip1099 feature.
+func (c *ChainConfig) GetEthashECIP1099Transition() *big.Int {
+	_, fork, ok := c.HasFeature("ecip1099")
+	if !ok || fork.Block == nil {
+		return nil
+	}
+	return new(big.Int).Set(fork.Block)
+}
+
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	if c.ForkByName("Homestead").Block == nil || num == nil {
@@ -355,6 +366,45 @@ func (c *ChainConfig) IsAgharta(num *big.Int) bool {
 	return num.Cmp(fork.Block) >= 0
 }
 
+// IsPhoenix returns true if num is greater than Phoenix config block
+func (c *ChainConfig) IsPhoenix(num *big.Int) bool {
+	fork := c.ForkByName("Phoenix")
+	if fork.Block == nil || num == nil {
+		return false
+	}
+	return num.Cmp(fork.Block) >= 0
+}
+
+// IsEIP2929 returns true if the warm/cold state access gas costs (EIP-2929) are
+// in effect at num, i.e. the eip2929 feature is configured by a fork at or
+// before num.
+func (c *ChainConfig) IsEIP2929(num *big.Int) bool {
+	_, _, ok := c.GetFeature(num, "eip2929")
+	return ok
+}
+
+// IsEIP2718 returns true if typed transaction envelopes (EIP-2718) and access
+// list transactions (EIP-2930) are enabled at num, i.e. the eip2718 feature is
+// configured by a fork at or before num.
+func (c *ChainConfig) IsEIP2718(num *big.Int) bool {
+	_, _, ok := c.GetFeature(num, "eip2718")
+	return ok
+}
+
+// IsECBP1100 returns true if the artificial finality of MESS (ECBP-1100) protects
+// the chain against deep reorgs at num, i.e. the ecbp1100 feature is configured
+// by a fork at or before num, and num is before its optional deactivation block.
+func (c *ChainConfig) IsECBP1100(num *big.Int) bool {
+	feat, _, ok := c.GetFeature(num, "ecbp1100")
+	if !ok {
+		return false
+	}
+	if deactivation, ok := feat.GetBigInt("deactivation"); ok {
+		return num.Cmp(deactivation) < 0
+	}
+	return true
+}
+
 // ForkByName looks up a Fork by its name, assumed to be unique
 func (c *ChainConfig) ForkByName(name string) *Fork {
 	for i := range c.Forks {
@@ -457,6 +507,9 @@ func (c *ChainConfig) GetSigner(blockNumber *big.Int) types.Signer {
 	feature, _, configured := c.GetFeature(blockNumber, "eip155")
 	if configured {
 		if chainId, ok := feature.GetBigInt("chainID"); ok {
+			if c.IsEIP2718(blockNumber) {
+				return types.NewAccessListSigner(chainId)
+			}
 			return types.NewChainIdSigner(chainId)
 		} else {
 			panic(fmt.Errorf("chainID is not set for EIP-155 at %v", blockNumber))
@@ -485,6 +538,8 @@ func (c *ChainConfig) GasTable(num *big.Int) *vm.GasTable {
 		return DefaultDiehardGasTable
 	case "agharta":
 		return DefaultAghartaGasTable
+	case "phoenix":
+		return DefaultPhoenixGasTable
 	default:
 		panic(fmt.Errorf("Unsupported gastable value '%v' at block: %v", name, num))
 	}
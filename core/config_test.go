@@ -115,6 +115,79 @@ func TestChainConfig_IsExplosion(t *testing.T) {
 
 }
 
+func TestChainConfig_IsPhoenix(t *testing.T) {
+	for _, test := range []struct {
+		config *ChainConfig
+		block  int64
+	}{
+		{DefaultConfigMainnet.ChainConfig, 10500839},
+		{DefaultConfigMordor.ChainConfig, 999983},
+	} {
+		if test.config.IsPhoenix(big.NewInt(test.block - 1)) {
+			t.Errorf("Unexpected for %d", test.block-1)
+		}
+		if !test.config.IsPhoenix(big.NewInt(test.block)) {
+			t.Errorf("Expected for %d", test.block)
+		}
+		if gt := test.config.GasTable(big.NewInt(test.block)); gt != DefaultPhoenixGasTable {
+			t.Errorf("Expected Phoenix gas table for %d, got %v", test.block, gt)
+		}
+	}
+}
+
+func TestChainConfig_GetEthashECIP1099Transition(t *testing.T) {
+	for _, test := range []struct {
+		config *ChainConfig
+		want   *big.Int
+	}{
+		{DefaultConfigMainnet.ChainConfig, big.NewInt(11700000)},
+		{DefaultConfigMordor.ChainConfig, big.NewInt(2520000)},
+		{DefaultConfigMorden.ChainConfig, nil},
+	} {
+		got := test.config.GetEthashECIP1099Transition()
+		if (got == nil) != (test.want == nil) || (got != nil && got.Cmp(test.want) != 0) {
+			t.Errorf("Unexpected ECIP-1099 transition: got %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestChainConfig_IsEIP2929(t *testing.T) {
+	for _, test := range []struct {
+		config *ChainConfig
+		block  int64
+	}{
+		{DefaultConfigMainnet.ChainConfig, 13189133},
+		{DefaultConfigMordor.ChainConfig, 3985893},
+	} {
+		if test.config.IsEIP2929(big.NewInt(test.block - 1)) {
+			t.Errorf("Unexpected for %d", test.block-1)
+		}
+		if !test.config.IsEIP2929(big.NewInt(test.block)) {
+			t.Errorf("Expected for %d", test.block)
+		}
+	}
+}
+
+func TestChainConfig_IsEIP2718(t *testing.T) {
+	for _, test := range []struct {
+		config *ChainConfig
+		block  int64
+	}{
+		{DefaultConfigMainnet.ChainConfig, 13189133},
+		{DefaultConfigMordor.ChainConfig, 3985893},
+	} {
+		if test.config.IsEIP2718(big.NewInt(test.block - 1)) {
+			t.Errorf("Unexpected for %d", test.block-1)
+		}
+		if !test.config.IsEIP2718(big.NewInt(test.block)) {
+			t.Errorf("Expected for %d", test.block)
+		}
+		if _, ok := test.config.GetSigner(big.NewInt(test.block)).(types.AccessListSigner); !ok {
+			t.Errorf("Expected access list signer for %d", test.block)
+		}
+	}
+}
+
 func sameGenesisDumpAllocationsBalances(gd1, gd2 *GenesisDump) bool {
 	for address, alloc := range gd2.Alloc {
 		if gd1.Alloc[address] != nil {
@@ -433,6 +506,10 @@ func TestChainConfig_GetFeature6_DefaultGasTables(t *testing.T) {
 		big.NewInt(0).Sub(DefaultConfigMainnet.ChainConfig.ForkByName("Diehard").Block, big.NewInt(1)): "eip150",
 		DefaultConfigMainnet.ChainConfig.ForkByName("Diehard").Block:                                   "eip160",
 		big.NewInt(0).Add(DefaultConfigMainnet.ChainConfig.ForkByName("Diehard").Block, big.NewInt(1)): "eip160",
+
+		big.NewInt(0).Sub(DefaultConfigMainnet.ChainConfig.ForkByName("Phoenix").Block, big.NewInt(1)): "agharta",
+		DefaultConfigMainnet.ChainConfig.ForkByName("Phoenix").Block:                                   "phoenix",
+		big.NewInt(0).Add(DefaultConfigMainnet.ChainConfig.ForkByName("Phoenix").Block, big.NewInt(1)): "phoenix",
 	}
 	for block, expected := range tables {
 		feat, fork, ok := c.GetFeature(block, "gastable")
@@ -620,7 +697,10 @@ func TestChainConfig_GetSigner(t *testing.T) {
 				if !ok {
 					t.Errorf("unexpected missing eip155 chainid, block: %v", current)
 				}
-				shouldb := types.NewChainIdSigner(cid)
+				var shouldb types.Signer = types.NewChainIdSigner(cid)
+				if c.IsEIP2718(current) {
+					shouldb = types.NewAccessListSigner(cid)
+				}
 				if !signer.Equal(shouldb) {
 					t.Errorf("want: %v, got: %v", shouldb, current)
 				}
@@ -631,6 +711,24 @@ func TestChainConfig_GetSigner(t *testing.T) {
 
 }
 
+func TestChainConfig_IsECBP1100(t *testing.T) {
+	c := getDefaultChainConfigSorted()
+	for _, tt := range []struct {
+		block int64
+		want  bool
+	}{
+		{0, false},
+		{11379999, false},
+		{11380000, true},
+		{19249999, true},
+		{19250000, false},
+	} {
+		if got := c.IsECBP1100(big.NewInt(tt.block)); got != tt.want {
+			t.Errorf("block %d: got: %v, want: %v", tt.block, got, tt.want)
+		}
+	}
+}
+
 func TestResolvePath(t *testing.T) {
 	cases := []struct {
 		args []string
@@ -68,3 +68,17 @@ var DefaultAghartaGasTable = &vm.GasTable{
 	ExpByte:         big.NewInt(50),
 	CreateBySuicide: big.NewInt(25000),
 }
+
+// DefaultPhoenixGasTable applies the EIP-1884 repricing of trie-size-dependent
+// opcodes (SLOAD, BALANCE, EXTCODEHASH).
+var DefaultPhoenixGasTable = &vm.GasTable{
+	ExtcodeSize:     big.NewInt(700),
+	ExtcodeCopy:     big.NewInt(700),
+	ExtcodeHash:     big.NewInt(700),
+	Balance:         big.NewInt(700),
+	SLoad:           big.NewInt(800),
+	Calls:           big.NewInt(700),
+	Suicide:         big.NewInt(5000),
+	ExpByte:         big.NewInt(50),
+	CreateBySuicide: big.NewInt(25000),
+}
@@ -34,12 +34,15 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 
+	statePrunedKey = []byte("StatePruned")
+
 	blockPrefix    = []byte("block-")
 	blockNumPrefix = []byte("block-num-")
 
 	headerSuffix = []byte("-header")
 	bodySuffix   = []byte("-body")
 	tdSuffix     = []byte("-td")
+	numberSuffix = []byte("-num") // blockPrefix + hash + numberSuffix -> number of an ancient block
 
 	txMetaSuffix        = []byte{0x01}
 	receiptsPrefix      = []byte("receipts-")
@@ -109,6 +112,9 @@ func GetHeadFastBlockHash(db ethdb.Database) common.Hash {
 // if the header's not found.
 func GetHeaderRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(append(append(blockPrefix, hash[:]...), headerSuffix...))
+	if len(data) == 0 {
+		data = readAncient(db, freezerHeaderTable, hash)
+	}
 	return data
 }
 
@@ -130,6 +136,9 @@ func GetHeader(db ethdb.Database, hash common.Hash) *types.Header {
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(append(append(blockPrefix, hash[:]...), bodySuffix...))
+	if len(data) == 0 {
+		data = readAncient(db, freezerBodiesTable, hash)
+	}
 	return data
 }
 
@@ -151,7 +160,7 @@ func GetBody(db ethdb.Database, hash common.Hash) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db ethdb.Database, hash common.Hash) *big.Int {
-	data, _ := db.Get(append(append(blockPrefix, hash.Bytes()...), tdSuffix...))
+	data := getTdRLP(db, hash)
 	if len(data) == 0 {
 		return nil
 	}
@@ -163,6 +172,15 @@ func GetTd(db ethdb.Database, hash common.Hash) *big.Int {
 	return td
 }
 
+// getTdRLP retrieves a block's total difficulty in its raw RLP encoding.
+func getTdRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
+	data, _ := db.Get(append(append(blockPrefix, hash.Bytes()...), tdSuffix...))
+	if len(data) == 0 {
+		data = readAncient(db, freezerDifficultyTable, hash)
+	}
+	return data
+}
+
 // GetBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -186,7 +204,7 @@ func GetBlock(db ethdb.Database, hash common.Hash) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db ethdb.Database, hash common.Hash) types.Receipts {
-	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+	data := getBlockReceiptsRLP(db, hash)
 	if len(data) == 0 {
 		return nil
 	}
@@ -202,6 +220,46 @@ func GetBlockReceipts(db ethdb.Database, hash common.Hash) types.Receipts {
 	return receipts
 }
 
+// getBlockReceiptsRLP retrieves the receipts of a block in their raw RLP
+// storage encoding.
+func getBlockReceiptsRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
+	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+	if len(data) == 0 {
+		data = readAncient(db, freezerReceiptTable, hash)
+	}
+	return data
+}
+
+// GetAncientNumber retrieves the number of a block moved into the ancient
+// store, or false if the block is not an ancient one.
+func GetAncientNumber(db ethdb.Database, hash common.Hash) (uint64, bool) {
+	data, _ := db.Get(append(append(blockPrefix, hash[:]...), numberSuffix...))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// readAncient retrieves the data of the given kind of a block from the ancient
+// store of the database, nil if the database has no ancient store or the block
+// is not in there.
+func readAncient(db ethdb.Database, kind string, hash common.Hash) []byte {
+	store, ok := db.(AncientStore)
+	if !ok {
+		return nil
+	}
+	number, ok := GetAncientNumber(db, hash)
+	if !ok {
+		return nil
+	}
+	// The number is only a hint, make sure the block is still the one stored
+	if stored, _ := store.Ancient(freezerHashTable, number); !bytes.Equal(stored, hash[:]) {
+		return nil
+	}
+	data, _ := store.Ancient(kind, number)
+	return data
+}
+
 // GetTransaction retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func GetTransaction(db ethdb.Database, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
@@ -428,11 +486,28 @@ func WriteReceipts(db ethdb.Database, receipts types.Receipts) error {
 	return nil
 }
 
+// WriteAncientNumber stores the number of a block moved into the ancient store,
+// which is addressed by number.
+func WriteAncientNumber(db ethdb.Database, hash common.Hash, number uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	if err := db.Put(append(append(blockPrefix, hash[:]...), numberSuffix...), enc); err != nil {
+		glog.Fatalf("failed to store ancient block number into database: %v", err)
+		return err
+	}
+	return nil
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db ethdb.Database, number uint64) {
 	db.Delete(append(blockNumPrefix, big.NewInt(int64(number)).Bytes()...))
 }
 
+// DeleteAncientNumber removes the number of an ancient block.
+func DeleteAncientNumber(db ethdb.Database, hash common.Hash) {
+	db.Delete(append(append(blockPrefix, hash[:]...), numberSuffix...))
+}
+
 // DeleteHeader removes all block header data associated with a hash.
 func DeleteHeader(db ethdb.Database, hash common.Hash) {
 	db.Delete(append(append(blockPrefix, hash.Bytes()...), headerSuffix...))
@@ -590,3 +665,20 @@ func WriteBlockChainVersion(db ethdb.Database, vsn int) {
 	enc, _ := rlp.EncodeToBytes(uint(vsn))
 	db.Put([]byte("BlockchainVersion"), enc)
 }
+
+// IsStatePruned reports whether the states of historical blocks may have been
+// garbage collected from the database.
+func IsStatePruned(db ethdb.Database) bool {
+	has, _ := db.Has(statePrunedKey)
+	return has
+}
+
+// WriteStatePruned marks the database as possibly missing the states of
+// historical blocks, which are garbage collected rather than kept forever.
+func WriteStatePruned(db ethdb.Database) error {
+	if err := db.Put(statePrunedKey, []byte{1}); err != nil {
+		glog.Fatalf("failed to store state pruned marker into database: %v", err)
+		return err
+	}
+	return nil
+}
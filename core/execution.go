@@ -45,6 +45,9 @@ var (
 // the necessary steps to create accounts and reverses the state in case of an
 // execution error or failed value transfer.
 func Call(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
+	exit := traceEnter(env, vm.CALL, caller.Address(), addr, input, gas, value)
+	defer func() { exit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
@@ -65,10 +68,7 @@ func Call(env vm.Environment, caller vm.ContractRef, addr common.Address, input
 		isAtlantis = env.RuleSet().IsAtlantis(env.BlockNumber())
 	)
 	if !env.Db().Exist(addr) {
-		precompiles := vm.PrecompiledPreAtlantis
-		if isAtlantis {
-			precompiles = vm.PrecompiledAtlantis
-		}
+		precompiles := vm.ActivePrecompiles(env.RuleSet(), env.BlockNumber())
 		if precompiles[addr.Str()] == nil && isAtlantis && value.BitLen() == 0 {
 			caller.ReturnGas(gas, gasPrice)
 			return nil, nil
@@ -101,6 +101,9 @@ func Call(env vm.Environment, caller vm.ContractRef, addr common.Address, input
 
 // CallCode executes the given address' code as the given contract address
 func CallCode(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
+	exit := traceEnter(env, vm.CALLCODE, caller.Address(), addr, input, gas, value)
+	defer func() { exit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
@@ -138,6 +141,9 @@ func CallCode(env vm.Environment, caller vm.ContractRef, addr common.Address, in
 
 // DelegateCall is equivalent to CallCode except that sender and value propagates from parent scope to child scope
 func DelegateCall(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice *big.Int) (ret []byte, err error) {
+	exit := traceEnter(env, vm.DELEGATECALL, caller.Address(), addr, input, gas, nil)
+	defer func() { exit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
@@ -178,6 +184,9 @@ func DelegateCall(env vm.Environment, caller vm.ContractRef, addr common.Address
 
 // StaticCall executes within the given contract and throws exception if state is attempted to be changed
 func StaticCall(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice *big.Int) (ret []byte, err error) {
+	exit := traceEnter(env, vm.STATICCALL, caller.Address(), addr, input, gas, nil)
+	defer func() { exit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
@@ -225,6 +234,10 @@ func StaticCall(env vm.Environment, caller vm.ContractRef, addr common.Address,
 func Create(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPrice, value *big.Int) (ret []byte, address common.Address, err error) {
 	nonce := env.Db().GetNonce(caller.Address())
 	addr := crypto.CreateAddress(caller.Address(), nonce)
+
+	exit := traceEnter(env, vm.CREATE, caller.Address(), addr, code, gas, value)
+	defer func() { exit(ret, err) }()
+
 	ret, address, err = create(env, caller, addr, code, gas, gasPrice, value)
 	// Here we get an error if we run into maximum stack depth,
 	// See: https://github.com/ethereum/yellowpaper/pull/131
@@ -236,6 +249,10 @@ func Create(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPric
 // Create2 creates a new contract with the given code
 func Create2(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPrice, salt, value *big.Int) (ret []byte, address common.Address, err error) {
 	addr := crypto.CreateAddress2(caller.Address(), common.BigToHash(salt).Bytes(), crypto.Keccak256(code))
+
+	exit := traceEnter(env, vm.CREATE2, caller.Address(), addr, code, gas, value)
+	defer func() { exit(ret, err) }()
+
 	ret, address, err = create(env, caller, addr, code, gas, gasPrice, value)
 	// Here we get an error if we run into maximum stack depth,
 	// See: https://github.com/ethereum/yellowpaper/pull/131
@@ -268,6 +285,12 @@ func create(env vm.Environment, caller vm.ContractRef, address common.Address, c
 		return nil, common.Address{}, errContractAddressCollision
 	}
 
+	// The created address is warm under EIP-2929. It is added before taking
+	// the snapshot, so a failed creation doesn't roll back the access.
+	if env.RuleSet().IsEIP2929(env.BlockNumber()) {
+		env.Db().AddAddressToAccessList(address)
+	}
+
 	// Create a new account on the state
 	snapshot := env.SnapshotDatabase()
 
@@ -327,6 +350,22 @@ func create(env vm.Environment, caller vm.ContractRef, address common.Address, c
 
 }
 
+// traceEnter notifies the tracer of env's EVM, if any, that a call frame is
+// being entered and returns the function reporting the frame's result. The
+// gas used by the frame is derived from the remaining gas once it returns.
+func traceEnter(env vm.Environment, typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) func(ret []byte, err error) {
+	tracer := env.Vm().Tracer()
+	if tracer == nil {
+		return func([]byte, error) {}
+	}
+	startGas := new(big.Int).Set(gas)
+	tracer.CaptureEnter(typ, from, to, input, startGas, value)
+
+	return func(ret []byte, err error) {
+		tracer.CaptureExit(ret, new(big.Int).Sub(startGas, gas), err)
+	}
+}
+
 // generic transfer method
 func Transfer(from, to vm.Account, amount *big.Int) {
 	from.SubBalance(amount)
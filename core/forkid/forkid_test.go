@@ -0,0 +1,168 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+var (
+	mainnetGenesis = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+	mordorGenesis  = common.HexToHash("0xa68ebde7932eccb177d38d55dcc6461a019dd795a681e59b5a3e4f3a7259a3f1")
+)
+
+// Tests that the fork IDs of the default chain configurations match the ones
+// announced by the other ETC clients.
+func TestCreation(t *testing.T) {
+	type testcase struct {
+		head uint64
+		want ID
+	}
+	tests := []struct {
+		config  *core.ChainConfig
+		genesis common.Hash
+		cases   []testcase
+	}{
+		// Mainnet test cases
+		{
+			core.DefaultConfigMainnet.ChainConfig,
+			mainnetGenesis,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},         // Unsynced
+				{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},   // Last Frontier block
+				{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 2500000}},   // First Homestead block
+				{1920000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 2500000}},   // DAO fork not applied
+				{2500000, ID{Hash: checksumToBytes(0xdb06803f), Next: 3000000}},   // First GasReprice block
+				{3000000, ID{Hash: checksumToBytes(0xaff4bed4), Next: 5000000}},   // First Diehard block
+				{5000000, ID{Hash: checksumToBytes(0xf79a63c0), Next: 5900000}},   // First Gotham block
+				{5900000, ID{Hash: checksumToBytes(0x744899d6), Next: 8772000}},   // First Defuse Difficulty Bomb block
+				{8772000, ID{Hash: checksumToBytes(0x518b59c6), Next: 9573000}},   // First Atlantis block
+				{9573000, ID{Hash: checksumToBytes(0x7ba22882), Next: 10500839}},  // First Agharta block
+				{10500839, ID{Hash: checksumToBytes(0x9007bfcc), Next: 11700000}}, // First Phoenix block
+				{11380000, ID{Hash: checksumToBytes(0x9007bfcc), Next: 11700000}}, // MESS not part of the ID
+				{11700000, ID{Hash: checksumToBytes(0xdb63a1ca), Next: 13189133}}, // First Thanos block
+				{13189133, ID{Hash: checksumToBytes(0x0f6bf187), Next: 0}},        // First Magneto block
+			},
+		},
+		// Mordor test cases
+		{
+			core.DefaultConfigMordor.ChainConfig,
+			mordorGenesis,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x175782aa), Next: 301243}},        // Unsynced, all forks up to Atlantis at genesis
+				{301243, ID{Hash: checksumToBytes(0x604f6ee1), Next: 999983}},   // First Agharta block
+				{999983, ID{Hash: checksumToBytes(0xf42f5539), Next: 2520000}},  // First Phoenix block
+				{2520000, ID{Hash: checksumToBytes(0x66b5c286), Next: 3985893}}, // First Thanos block
+				{3985893, ID{Hash: checksumToBytes(0x92b323e0), Next: 0}},       // First Magneto block
+			},
+		},
+	}
+	for i, tt := range tests {
+		for j, ttt := range tt.cases {
+			if have := NewID(tt.config, tt.genesis, ttt.head); have != ttt.want {
+				t.Errorf("test %d, case %d: fork ID mismatch: have %x, want %x", i, j, have, ttt.want)
+			}
+		}
+	}
+}
+
+// Tests that IDs are properly RLP encoded (specifically important because we
+// use uint32 to store the hash, but we need to encode it as [4]byte).
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the fork ID filter validates remote IDs as per EIP-2124.
+func TestValidation(t *testing.T) {
+	config := core.DefaultConfigMainnet.ChainConfig
+
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet Phoenix, remote announces the same. No future fork is announced.
+		{10500839, ID{Hash: checksumToBytes(0x9007bfcc), Next: 0}, nil},
+
+		// Local is mainnet Phoenix, remote announces the same. Remote also announces
+		// the next fork at block 11700000, the Thanos fork we know about.
+		{10500839, ID{Hash: checksumToBytes(0x9007bfcc), Next: 11700000}, nil},
+
+		// Local is mainnet Phoenix, remote announces the same. Remote also announces
+		// a fork at an arbitrary future block, which is still fine.
+		{10500839, ID{Hash: checksumToBytes(0x9007bfcc), Next: math.MaxUint64}, nil},
+
+		// Local is mainnet currently in Agharta only (so it's aware of Phoenix), remote
+		// announces also Agharta, but it's not yet aware of Phoenix (e.g. non updated node
+		// before the fork). In this case we don't know if Phoenix passed yet or not.
+		{10500838, ID{Hash: checksumToBytes(0x7ba22882), Next: 0}, nil},
+
+		// Local is mainnet Agharta, remote announces Phoenix. Local is out of sync, accept.
+		{10500838, ID{Hash: checksumToBytes(0x9007bfcc), Next: 11700000}, nil},
+
+		// Local is mainnet Atlantis, remote announces Thanos. Local is out of sync, accept.
+		{9000000, ID{Hash: checksumToBytes(0xdb63a1ca), Next: 13189133}, nil},
+
+		// Local is mainnet Magneto, remote announces Agharta with Phoenix next. Remote
+		// is definitely out of sync, but it may or may not need an update.
+		{13189133, ID{Hash: checksumToBytes(0x7ba22882), Next: 10500839}, nil},
+
+		// Local is mainnet Phoenix, remote announces Agharta without the Phoenix fork
+		// block. Remote needs a software update.
+		{10500839, ID{Hash: checksumToBytes(0x7ba22882), Next: 0}, ErrRemoteStale},
+
+		// Local is mainnet Phoenix, remote announces Phoenix and a fork at 10600000 we
+		// haven't heard of, but have already passed. Local is stale or incompatible.
+		{11000000, ID{Hash: checksumToBytes(0x9007bfcc), Next: 10600000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Diehard, remote announces the ETH mainnet Byzantium fork
+		// ID (the DAO fork applied). Incompatible chains sharing the genesis.
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Magneto, remote is a random unknown network.
+		{13189133, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := newFilter(config, mainnetGenesis, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
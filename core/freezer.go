@@ -0,0 +1,244 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+)
+
+const (
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient
+// tables. Hashes and difficulties don't compress well.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:       true,
+	freezerHeaderTable:     false,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
+// AncientStore is implemented by chain databases which move the immutable
+// part of the canonical chain out of the key-value store into append-only
+// flat files. Ancient data is addressed by block number and kind, which is
+// the name of one of the freezer tables.
+type AncientStore interface {
+	// HasAncient returns an indicator whether the specified ancient data exists.
+	HasAncient(kind string, number uint64) bool
+
+	// Ancient retrieves an ancient binary blob from the append-only store.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks in the ancient store.
+	Ancients() uint64
+
+	// AppendAncient injects all the data of a block at the end of the store.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n blocks of the store.
+	TruncateAncients(n uint64) error
+
+	// SyncAncient flushes the ancient store to disk.
+	SyncAncient() error
+}
+
+// freezer is an append-only store of the ancient canonical chain, holding
+// one flat file table for each kind of block data.
+type freezer struct {
+	frozen uint64 // Number of blocks already frozen, accessed atomically
+
+	tables map[string]*freezerTable
+}
+
+// newFreezer opens the ancient store in the given directory, truncating all
+// tables to the number of blocks fully stored.
+func newFreezer(dir string) (*freezer, error) {
+	f := &freezer{tables: make(map[string]*freezerTable)}
+	for name, noComp := range freezerNoSnappy {
+		table, err := newTable(dir, name, noComp)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[name] = table
+	}
+	// A crash may have left some tables ahead of the others
+	frozen := ^uint64(0)
+	for _, table := range f.tables {
+		if items := table.Items(); items < frozen {
+			frozen = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(frozen); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, frozen)
+	return f, nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists.
+func (f *freezer) HasAncient(kind string, number uint64) bool {
+	if table := f.tables[kind]; table != nil && number < table.Items() {
+		return true
+	}
+	return false
+}
+
+// Ancient retrieves an ancient binary blob from the append-only store.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, fmt.Errorf("unknown ancient table %q", kind)
+}
+
+// Ancients returns the number of blocks in the ancient store.
+func (f *freezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.frozen)
+}
+
+// AppendAncient injects all the data of a block at the end of the store. If
+// any of the tables fails, they are all rolled back to the previous block.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				table.Truncate(number)
+			}
+		}
+	}()
+	for name, blob := range map[string][]byte{
+		freezerHashTable:       hash,
+		freezerHeaderTable:     header,
+		freezerBodiesTable:     body,
+		freezerReceiptTable:    receipts,
+		freezerDifficultyTable: td,
+	} {
+		if err := f.tables[name].Append(number, blob); err != nil {
+			return fmt.Errorf("failed to freeze block #%d %s: %v", number, name, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards all but the first n blocks of the store.
+func (f *freezer) TruncateAncients(n uint64) error {
+	if f.Ancients() <= n {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(n); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, n)
+	return nil
+}
+
+// SyncAncient flushes the ancient store to disk.
+func (f *freezer) SyncAncient() error {
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all the tables of the store.
+func (f *freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// freezerDatabase is a chain database made of a key-value store for the
+// recent and mutable data, and an ancient store for the immutable part of
+// the canonical chain.
+type freezerDatabase struct {
+	ethdb.Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer creates a chain database on top of a key-value store,
+// keeping ancient blocks in flat files in the given directory. Blocks are only
+// moved into the ancient store by a running BlockChain, see SetFreezerThreshold.
+func NewDatabaseWithFreezer(db ethdb.Database, dir string) (ethdb.Database, error) {
+	f, err := newFreezer(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Refuse to mix up the ancient store and key-value store of different chains
+	if f.Ancients() > 0 {
+		genesis, err := f.Ancient(freezerHashTable, 0)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if kvgenesis := GetCanonicalHash(db, 0); kvgenesis != (common.Hash{}) && !bytes.Equal(kvgenesis[:], genesis) {
+			f.Close()
+			return nil, fmt.Errorf("genesis mismatch: ancient store %x, key-value store %x", genesis, kvgenesis)
+		}
+	}
+	return &freezerDatabase{Database: db, freezer: f}, nil
+}
+
+// Close closes both the ancient and the key-value store.
+func (db *freezerDatabase) Close() {
+	db.freezer.Close()
+	db.Database.Close()
+}
+
+// KeyValueStore returns the key-value store of a chain database, which is the
+// database itself unless it has an ancient store.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if fdb, ok := db.(*freezerDatabase); ok {
+		return fdb.Database
+	}
+	return db
+}
@@ -0,0 +1,258 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/event"
+)
+
+// Tests that freezer tables store, truncate and recover their items, with and
+// without compression.
+func TestFreezerTable(t *testing.T) {
+	for _, noComp := range []bool{true, false} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		item := func(i uint64) []byte { return bytes.Repeat([]byte{byte(i)}, int(i)+1) }
+
+		table, err := newTable(dir, "test", noComp)
+		if err != nil {
+			t.Fatalf("noComp %v: failed to open table: %v", noComp, err)
+		}
+		for i := uint64(0); i < 100; i++ {
+			if err := table.Append(i, item(i)); err != nil {
+				t.Fatalf("noComp %v: failed to append item %d: %v", noComp, i, err)
+			}
+		}
+		if err := table.Append(101, item(101)); err == nil {
+			t.Errorf("noComp %v: out of order append accepted", noComp)
+		}
+		if err := table.Truncate(50); err != nil {
+			t.Fatalf("noComp %v: failed to truncate: %v", noComp, err)
+		}
+		table.Close()
+
+		// Reopen with a torn write at the end of the index
+		idx := filepath.Join(dir, "test.cidx")
+		if noComp {
+			idx = filepath.Join(dir, "test.ridx")
+		}
+		f, _ := os.OpenFile(idx, os.O_WRONLY|os.O_APPEND, 0644)
+		f.Write([]byte{0xff, 0xff, 0xff})
+		f.Close()
+
+		if table, err = newTable(dir, "test", noComp); err != nil {
+			t.Fatalf("noComp %v: failed to reopen table: %v", noComp, err)
+		}
+		if items := table.Items(); items != 50 {
+			t.Fatalf("noComp %v: item count mismatch: have %d, want 50", noComp, items)
+		}
+		for i := uint64(0); i < 50; i++ {
+			blob, err := table.Retrieve(i)
+			if err != nil {
+				t.Fatalf("noComp %v: failed to retrieve item %d: %v", noComp, i, err)
+			}
+			if !bytes.Equal(blob, item(i)) {
+				t.Errorf("noComp %v: item %d mismatch: have %x, want %x", noComp, i, blob, item(i))
+			}
+		}
+		if _, err := table.Retrieve(50); err != errOutOfBounds {
+			t.Errorf("noComp %v: truncated item error mismatch: have %v, want %v", noComp, err, errOutOfBounds)
+		}
+		table.Close()
+	}
+}
+
+// Tests that ancient canonical blocks are moved out of the key-value store,
+// remain readable through the chain database and are discarded on rewinds.
+func TestFreezeBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		kvdb, _ = ethdb.NewMemDatabase()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.NewChainIdSigner(big.NewInt(63))
+		config  = MakeDiehardChainConfig()
+	)
+	db, err := NewDatabaseWithFreezer(kvdb, dir)
+	if err != nil {
+		t.Fatalf("failed to open ancient store: %v", err)
+	}
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(gendb, GenesisAccount{addr, big.NewInt(1000000000)})
+	WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, receipts := GenerateChain(config, genesis, gendb, 64, func(i int, gen *BlockGen) {
+		tx, err := types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i)}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	// Freeze everything but the last 16 blocks
+	blockchain.freezeThreshold = 16
+	store := db.(AncientStore)
+	if n, err := blockchain.freezeBlocks(store); err != nil || n != 49 {
+		t.Fatalf("freeze result mismatch: have %d/%v, want 49/nil", n, err)
+	}
+	if frozen := store.Ancients(); frozen != 49 {
+		t.Fatalf("ancient count mismatch: have %d, want 49", frozen)
+	}
+	for i, block := range blocks {
+		hash := block.Hash()
+		if has, _ := kvdb.Has(append(append(blockPrefix, hash[:]...), headerSuffix...)); has != (block.NumberU64() > 48) {
+			t.Errorf("block #%d: header in key-value store mismatch: have %v", block.NumberU64(), has)
+		}
+		if have := GetBlock(db, hash); have == nil || have.Hash() != hash {
+			t.Errorf("block #%d: not retrievable", block.NumberU64())
+		}
+		if have := GetBlockReceipts(db, hash); len(have) != len(receipts[i]) {
+			t.Errorf("block #%d: receipt count mismatch: have %d, want %d", block.NumberU64(), len(have), len(receipts[i]))
+		}
+		if GetTd(db, hash) == nil {
+			t.Errorf("block #%d: total difficulty missing", block.NumberU64())
+		}
+	}
+	if blockchain.GetBlockByNumber(0).Hash() != blockchain.Genesis().Hash() {
+		t.Error("frozen genesis not retrievable")
+	}
+	// Nothing more to freeze until the chain progresses
+	if n, err := blockchain.freezeBlocks(store); err != nil || n != 0 {
+		t.Fatalf("refreeze result mismatch: have %d/%v, want 0/nil", n, err)
+	}
+	// Rewinding below the frozen blocks must discard them from the ancient store
+	if err := blockchain.SetHead(32); err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if frozen := store.Ancients(); frozen != 33 {
+		t.Fatalf("ancient count after rewind mismatch: have %d, want 33", frozen)
+	}
+	if GetHeader(db, blocks[40].Hash()) != nil {
+		t.Error("rewound ancient header still retrievable")
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != blocks[31].Hash() {
+		t.Fatalf("head mismatch: have #%d, want #32", head.NumberU64())
+	}
+	// The rewound blocks must be importable again
+	if res := blockchain.InsertChain(blocks[32:]); res.Error != nil {
+		t.Fatalf("failed to reinsert block %d: %v", res.Index, res.Error)
+	}
+	blockchain.Stop()
+	db.Close()
+
+	// Reopening the database should keep serving the ancient blocks, but
+	// refuse a key-value store of another chain
+	if db, err = NewDatabaseWithFreezer(kvdb, dir); err != nil {
+		t.Fatalf("failed to reopen ancient store: %v", err)
+	}
+	if have := GetBlock(db, blocks[20].Hash()); have == nil || have.Hash() != blocks[20].Hash() {
+		t.Error("ancient block not retrievable after reopen")
+	}
+	db.Close()
+
+	otherdb, _ := ethdb.NewMemDatabase()
+	WriteGenesisBlockForTesting(otherdb, GenesisAccount{addr, big.NewInt(1)})
+	if _, err := NewDatabaseWithFreezer(otherdb, dir); err == nil {
+		t.Error("ancient store of a different chain accepted")
+	}
+}
+
+// lockProbeStore is an ancient store checking on sync that the chain can be
+// locked for writing, i.e. that the migration doesn't block block imports.
+type lockProbeStore struct {
+	AncientStore
+	chain   *BlockChain
+	blocked bool
+}
+
+func (s *lockProbeStore) SyncAncient() error {
+	locked := make(chan struct{})
+	go func() {
+		s.chain.mu.Lock()
+		s.chain.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		s.blocked = true
+	}
+	return s.AncientStore.SyncAncient()
+}
+
+// Tests that the chain isn't locked while blocks are moved into the ancient
+// store.
+func TestFreezeBlocksUnlocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, _ := ethdb.NewMemDatabase()
+	db, err := NewDatabaseWithFreezer(kvdb, dir)
+	if err != nil {
+		t.Fatalf("failed to open ancient store: %v", err)
+	}
+	defer db.Close()
+
+	genesis := WriteGenesisBlockForTesting(db)
+	blocks, _ := GenerateChain(MakeDiehardChainConfig(), genesis, db, 32, func(i int, gen *BlockGen) {})
+	blockchain, err := NewBlockChain(db, MakeDiehardChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	blockchain.freezeThreshold = 16
+	store := &lockProbeStore{AncientStore: db.(AncientStore), chain: blockchain}
+	if n, err := blockchain.freezeBlocks(store); err != nil || n != 17 {
+		t.Fatalf("freeze result mismatch: have %d/%v, want 17/nil", n, err)
+	}
+	if store.blocked {
+		t.Error("chain locked while syncing the ancient store")
+	}
+}
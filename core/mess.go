@@ -0,0 +1,154 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/core/types"
+)
+
+// ArtificialFinality selects whether the Modified Exponential Subjective
+// Scoring (MESS, ECBP-1100) of reorgs is applied.
+type ArtificialFinality int
+
+const (
+	ArtificialFinalityAuto ArtificialFinality = iota // Apply MESS where the chain config enables it
+	ArtificialFinalityOn                             // Always apply MESS
+	ArtificialFinalityOff                            // Never apply MESS
+)
+
+var (
+	// messDenominator is the denominator of the antigravity polynomial, which
+	// yields the required ratio of total difficulties times this value.
+	messDenominator = big.NewInt(128)
+
+	// messXCap is the time (in seconds, ~7 hours) after which the antigravity
+	// stops increasing, i.e. floor(8000*pi).
+	messXCap = big.NewInt(25132)
+
+	// messHeight is the maximum increase of the antigravity above 1, being
+	// twice the amplitude of 15, times the denominator.
+	messHeight = new(big.Int).Mul(messDenominator, big.NewInt(2*15))
+)
+
+// errReorgFinality is returned if MESS rejects a reorg to a side chain whose
+// difficulty isn't high enough considering the age of the common ancestor.
+type errReorgFinality struct {
+	ancestor *types.Header
+	age      *big.Int
+	ratio    float64 // Proposed segment's share of the required total difficulty
+}
+
+func (e *errReorgFinality) Error() string {
+	return fmt.Sprintf("reorg rejected by artificial finality: common ancestor #%v [%s…] is %vs old, side chain reaches %.6f of the required difficulty",
+		e.ancestor.Number, e.ancestor.Hash().Hex()[:10], e.age, e.ratio)
+}
+
+// SetArtificialFinality overrides whether the MESS artificial finality is
+// applied to reorgs, which by default follows the chain configuration.
+func (bc *BlockChain) SetArtificialFinality(mode ArtificialFinality) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.artificialFinality = mode
+}
+
+// isArtificialFinality returns whether reorgs away from a head with the given
+// number are subject to MESS.
+func (bc *BlockChain) isArtificialFinality(num *big.Int) bool {
+	switch bc.artificialFinality {
+	case ArtificialFinalityOn:
+		return true
+	case ArtificialFinalityOff:
+		return false
+	}
+	return bc.config.IsECBP1100(num)
+}
+
+// checkArtificialFinality implements MESS (ECBP-1100): a reorg from the current
+// head to the proposed block is only allowed if the total difficulty the side
+// chain added since the common ancestor exceeds that of the local chain by the
+// antigravity factor, which grows with the time since the common ancestor.
+func (bc *BlockChain) checkArtificialFinality(current, proposed *types.Block, proposedTd *big.Int) error {
+	ancestor := bc.findCommonAncestor(current.Header(), proposed.Header())
+	if ancestor == nil {
+		return nil // the reorg itself will fail
+	}
+	ancestorTd := bc.GetTd(ancestor.Hash())
+	if ancestorTd == nil {
+		return nil
+	}
+	localTd := new(big.Int).Sub(bc.GetTd(current.Hash()), ancestorTd)
+	sideTd := new(big.Int).Sub(proposedTd, ancestorTd)
+
+	age := new(big.Int).Sub(current.Time(), ancestor.Time)
+	if age.Sign() < 0 {
+		age.SetInt64(0)
+	}
+	want := new(big.Int).Mul(messAntigravity(age), localTd)
+	got := new(big.Int).Mul(sideTd, messDenominator)
+	if got.Cmp(want) >= 0 {
+		return nil
+	}
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(got), new(big.Float).SetInt(want)).Float64()
+	return &errReorgFinality{ancestor: ancestor, age: age, ratio: ratio}
+}
+
+// findCommonAncestor returns the most recent header shared by the chains of the
+// two given headers, or nil if one of them isn't linked to a known ancestor.
+func (bc *BlockChain) findCommonAncestor(a, b *types.Header) *types.Header {
+	for a.Number.Cmp(b.Number) > 0 {
+		if a = bc.GetHeader(a.ParentHash); a == nil {
+			return nil
+		}
+	}
+	for b.Number.Cmp(a.Number) > 0 {
+		if b = bc.GetHeader(b.ParentHash); b == nil {
+			return nil
+		}
+	}
+	for a.Hash() != b.Hash() {
+		if a = bc.GetHeader(a.ParentHash); a == nil {
+			return nil
+		}
+		if b = bc.GetHeader(b.ParentHash); b == nil {
+			return nil
+		}
+	}
+	return a
+}
+
+// messAntigravity returns the ratio of total difficulties a side chain needs to
+// reorg a chain whose common ancestor is x seconds old, times messDenominator.
+// It follows the cubic curve from ECBP-1100, ranging from 1 to 31 over ~7 hours:
+//
+//	denominator + (3x² - 2x³/xcap) * height / xcap²
+func messAntigravity(x *big.Int) *big.Int {
+	if x.Cmp(messXCap) > 0 {
+		x = messXCap
+	}
+	square := new(big.Int).Mul(x, x)
+	cube := new(big.Int).Mul(square, x)
+
+	out := new(big.Int).Mul(square, big.NewInt(3))
+	out.Sub(out, cube.Div(cube.Mul(cube, big.NewInt(2)), messXCap))
+	out.Mul(out, messHeight)
+	out.Div(out, new(big.Int).Mul(messXCap, messXCap))
+	return out.Add(out, messDenominator)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMESSAntigravity(t *testing.T) {
+	for _, tt := range []struct {
+		x    int64
+		want int64
+	}{
+		{0, 128},
+		{1, 128},
+		{1000, 145},
+		{12566, 2048},
+		{25132, 3968},
+		{100000, 3968},
+	} {
+		if got := messAntigravity(big.NewInt(tt.x)); got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("x=%d: got: %v, want: %d", tt.x, got, tt.want)
+		}
+	}
+}
+
+// Tests that side chains only slightly heavier than the canonical chain can't
+// reorg it with MESS enabled, while much heavier ones still can.
+func TestMESSRejectsReorg(t *testing.T) {
+	for _, tt := range []struct {
+		mode  ArtificialFinality
+		side  int
+		reorg bool
+	}{
+		{ArtificialFinalityOff, 102, true},
+		{ArtificialFinalityAuto, 102, true}, // not configured for the test chain
+		{ArtificialFinalityOn, 102, false},
+		{ArtificialFinalityOn, 120, true},
+	} {
+		db, blockchain, err := newCanonical(MakeChainConfig(), 0, true)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		blockchain.SetArtificialFinality(tt.mode)
+
+		canon := makeBlockChain(MakeChainConfig(), blockchain.Genesis(), 100, db, canonicalSeed)
+		if res := blockchain.InsertChain(canon); res.Error != nil {
+			t.Fatalf("failed to insert canonical chain: %v", res.Error)
+		}
+		side := makeBlockChain(MakeChainConfig(), blockchain.Genesis(), tt.side, db, forkSeed)
+		if res := blockchain.InsertChain(side); res.Error != nil {
+			t.Fatalf("failed to insert side chain: %v", res.Error)
+		}
+		want := canon[len(canon)-1]
+		if tt.reorg {
+			want = side[len(side)-1]
+		}
+		if head := blockchain.CurrentBlock(); head.Hash() != want.Hash() {
+			t.Errorf("mode %d, side chain of %d blocks: head mismatch: have #%v, want #%v", tt.mode, tt.side, head.Number(), want.Number())
+		}
+		if blockchain.GetTd(side[len(side)-1].Hash()) == nil {
+			t.Errorf("mode %d, side chain of %d blocks: side chain not stored", tt.mode, tt.side)
+		}
+	}
+}
@@ -14,6 +14,7 @@ var mlogTxPool = logger.MLogRegisterAvailable("txpool", mLogLinesTxPool)
 var mLogLinesBlockchain = []*logger.MLogT{
 	mlogBlockchainWriteBlock,
 	mlogBlockchainInsertBlocks,
+	mlogBlockchainRejectReorg,
 }
 
 var mLogLinesHeaderchain = []*logger.MLogT{
@@ -81,6 +82,22 @@ var mlogBlockchainReorgBlocks = &logger.MLogT{
 	},
 }
 
+var mlogBlockchainRejectReorg = &logger.MLogT{
+	Description: `Called when a reorg to a side chain is rejected by the artificial finality of MESS (ECBP-1100).
+REORG.DIFFICULTY_RATIO is the share of the difficulty required from the side chain that it reached.`,
+	Receiver: "BLOCKCHAIN",
+	Verb:     "REJECT",
+	Subject:  "REORG",
+	Details: []logger.MLogDetailT{
+		{Owner: "REORG", Key: "LAST_COMMON_HASH", Value: "STRING"},
+		{Owner: "REORG", Key: "LAST_COMMON_NUMBER", Value: "BIGINT"},
+		{Owner: "REORG", Key: "AGE", Value: "BIGINT"},
+		{Owner: "REORG", Key: "DIFFICULTY_RATIO", Value: "NUMBER"},
+		{Owner: "BLOCKS", Key: "CURRENT_HASH", Value: "STRING"},
+		{Owner: "BLOCKS", Key: "PROPOSED_HASH", Value: "STRING"},
+	},
+}
+
 // Headerchain
 var mlogHeaderchainWriteHeader = &logger.MLogT{
 	Description: `Called when a single header is written to the chain header database.
@@ -180,6 +180,7 @@ Loop:
 	totalUsedGas.Add(totalUsedGas, usedGas)
 
 	receipt := types.NewReceipt(statedb.IntermediateRoot(config.IsAtlantis(header.Number)).Bytes(), totalUsedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = new(big.Int).Set(totalUsedGas)
 	if vm.Failed() {
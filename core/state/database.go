@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/state/snapshot"
 	"github.com/eth-classic/go-ethereum/ethdb"
 	"github.com/eth-classic/go-ethereum/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -50,6 +51,11 @@ type Database interface {
 	ContractCodeSize(addrHash, codeHash common.Hash) (int, error)
 	// CopyTrie returns an independent copy of the given trie.
 	CopyTrie(Trie) Trie
+	// TrieDB retrieves the trie node database holding the committed state.
+	TrieDB() *trie.NodeDatabase
+	// Snapshots retrieves the flat state snapshots used to accelerate reads,
+	// or nil if they are disabled.
+	Snapshots() *snapshot.Tree
 }
 
 // Trie is a Ethereum Merkle Trie.
@@ -58,20 +64,31 @@ type Trie interface {
 	TryUpdate(key, value []byte) error
 	TryDelete(key []byte) error
 	CommitTo(trie.DatabaseWriter) (common.Hash, error)
+	CommitToWithCallback(trie.DatabaseWriter, trie.LeafCallback) (common.Hash, error)
 	Hash() common.Hash
 	NodeIterator(startKey []byte) trie.NodeIterator
 	GetKey([]byte) []byte // TODO(fjl): remove this when SecureTrie is removed
+	Prove(key []byte, fromLevel uint, proofDb trie.DatabaseWriter) error
 }
 
 // NewDatabase creates a backing store for state. The returned database is safe for
 // concurrent use and retains cached trie nodes in memory.
 func NewDatabase(db ethdb.Database) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	return &cachingDB{db: trie.NewNodeDatabase(db), codeSizeCache: csc}
+}
+
+// NewDatabaseWithSnapshots creates a backing store for state on top of an
+// existing trie node database. States with a layer in the given snapshot tree
+// read their accounts and storage from it instead of the tries.
+func NewDatabaseWithSnapshots(triedb *trie.NodeDatabase, snaps *snapshot.Tree) Database {
+	csc, _ := lru.New(codeSizeCacheSize)
+	return &cachingDB{db: triedb, snaps: snaps, codeSizeCache: csc}
 }
 
 type cachingDB struct {
-	db            ethdb.Database
+	db            *trie.NodeDatabase
+	snaps         *snapshot.Tree
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
@@ -109,6 +126,16 @@ func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
 	return trie.NewSecure(root, db.db, 0)
 }
 
+// TrieDB retrieves the trie node database holding the committed state.
+func (db *cachingDB) TrieDB() *trie.NodeDatabase {
+	return db.db
+}
+
+// Snapshots retrieves the flat state snapshots, if enabled.
+func (db *cachingDB) Snapshots() *snapshot.Tree {
+	return db.snaps
+}
+
 func (db *cachingDB) CopyTrie(t Trie) Trie {
 	switch t := t.(type) {
 	case cachedTrie:
@@ -146,7 +173,11 @@ type cachedTrie struct {
 }
 
 func (m cachedTrie) CommitTo(dbw trie.DatabaseWriter) (common.Hash, error) {
-	root, err := m.SecureTrie.CommitTo(dbw)
+	return m.CommitToWithCallback(dbw, nil)
+}
+
+func (m cachedTrie) CommitToWithCallback(dbw trie.DatabaseWriter, onleaf trie.LeafCallback) (common.Hash, error) {
+	root, err := m.SecureTrie.CommitToWithCallback(dbw, onleaf)
 	if err == nil {
 		m.db.pushTrie(m.SecureTrie)
 	}
@@ -0,0 +1,149 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/eth-classic/go-ethereum/common"
+)
+
+// bloomHashes is the number of bits set in the filter for every key. The keys
+// are 32 byte hashes, each 8 byte slice of which is used as a bit index.
+const bloomHashes = common.HashLength / 8
+
+// stateBloom is a bloom filter of the trie node and contract code hashes of a
+// state. Since the keys are already hashes, no further hashing is needed to
+// spread them over the filter.
+type stateBloom struct {
+	root common.Hash // State root the filter was generated for
+	bits []uint64    // Bit array of the filter
+}
+
+// newStateBloom creates an empty bloom filter of the given size in bytes.
+func newStateBloom(size uint64) *stateBloom {
+	if size < 8 {
+		size = 8
+	}
+	return &stateBloom{bits: make([]uint64, size/8)}
+}
+
+// add inserts a hash into the filter.
+func (b *stateBloom) add(hash common.Hash) {
+	for i := 0; i < bloomHashes; i++ {
+		bit := b.index(hash[:], i)
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// contains reports whether the key may be in the filter. False positives are
+// possible, false negatives are not.
+func (b *stateBloom) contains(key []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	for i := 0; i < bloomHashes; i++ {
+		bit := b.index(key, i)
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// index returns the i-th bit position of a key within the filter.
+func (b *stateBloom) index(key []byte, i int) uint64 {
+	return binary.BigEndian.Uint64(key[i*8:]) % uint64(len(b.bits)*64)
+}
+
+// writeBloom persists a bloom filter to a file. The filter is written to a
+// temporary file first and moved into place once complete, so a file at the
+// given path always holds a full filter.
+func writeBloom(path string, b *stateBloom) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(len(b.bits)))
+	if _, err := w.Write(b.root[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := w.Write(buf[:]); err != nil {
+		f.Close()
+		return err
+	}
+	for _, word := range b.bits {
+		binary.BigEndian.PutUint64(buf[:], word)
+		if _, err := w.Write(buf[:]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readBloom loads a bloom filter persisted by writeBloom.
+func readBloom(path string) (*stateBloom, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	b := new(stateBloom)
+	var buf [8]byte
+	if _, err := io.ReadFull(r, b.root[:]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	}
+	words := binary.BigEndian.Uint64(buf[:])
+	if info, err := f.Stat(); err != nil {
+		return nil, err
+	} else if words == 0 || uint64(info.Size()) != common.HashLength+8+words*8 {
+		return nil, errors.New("corrupt bloom filter file")
+	}
+	b.bits = make([]uint64, words)
+	for i := range b.bits {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		b.bits[i] = binary.BigEndian.Uint64(buf[:])
+	}
+	return b, nil
+}
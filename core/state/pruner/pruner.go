@@ -0,0 +1,252 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of the state stored in a chain
+// database, deleting all trie nodes and contract codes not belonging to a
+// single retained state.
+package pruner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// logInterval is the time between progress reports of long running steps.
+	logInterval = 8 * time.Second
+
+	// txMetaSuffix is appended to a transaction hash to key its lookup metadata.
+	txMetaSuffix = 0x01
+)
+
+// Stats reports the database entries removed, or to be removed, by pruning.
+type Stats struct {
+	Nodes uint64             // Number of trie nodes and contract codes deleted
+	Size  common.StorageSize // Total size of the deleted keys and values
+}
+
+// Pruner deletes every trie node and contract code from a chain database that
+// is not reachable from a retained state root.
+//
+// The live entries are recorded in a bloom filter, which is persisted before
+// anything is deleted. If pruning is interrupted, the filter is picked up
+// again by the next run and the deletion resumes where it left off. As the
+// filter admits false positives, a small fraction of stale entries survives.
+type Pruner struct {
+	db        *ethdb.LDBDatabase
+	bloomPath string
+	bloomSize uint64
+}
+
+// NewPruner creates a pruner for the given database. The bloom filter of the
+// live state, of bloomSize bytes, is persisted at bloomPath while pruning.
+func NewPruner(db *ethdb.LDBDatabase, bloomPath string, bloomSize uint64) *Pruner {
+	return &Pruner{
+		db:        db,
+		bloomPath: bloomPath,
+		bloomSize: bloomSize,
+	}
+}
+
+// Interrupted reports whether a previous pruning run was interrupted after it
+// started deleting entries.
+func (p *Pruner) Interrupted() bool {
+	_, err := os.Stat(p.bloomPath)
+	return err == nil
+}
+
+// Prune deletes all the trie nodes and contract codes not referenced by the
+// state with the given root. An interrupted previous run is resumed, keeping
+// the state it retained in addition to the given one.
+func (p *Pruner) Prune(root common.Hash) (*Stats, error) {
+	var bloom *stateBloom
+	if p.Interrupted() {
+		b, err := readBloom(p.bloomPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load bloom filter of interrupted pruning: %v", err)
+		}
+		glog.V(logger.Info).Infof("Resuming interrupted state pruning: root=%x", b.root)
+		bloom = b
+
+		// The chain may have progressed since the interruption, the new state
+		// must survive too.
+		if bloom.root != root {
+			if err := p.mark(bloom, root); err != nil {
+				return nil, err
+			}
+			bloom.root = root
+			if err := writeBloom(p.bloomPath, bloom); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		bloom = newStateBloom(p.bloomSize)
+		if err := p.mark(bloom, root); err != nil {
+			return nil, err
+		}
+		bloom.root = root
+		if err := writeBloom(p.bloomPath, bloom); err != nil {
+			return nil, err
+		}
+	}
+	// From here on historical states are gone, flag it for the chain
+	if err := core.WriteStatePruned(p.db); err != nil {
+		return nil, err
+	}
+	stats, err := p.sweep(bloom, false)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Compacting database")
+	start := time.Now()
+	if err := p.db.LDB().CompactRange(util.Range{}); err != nil {
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Compacted database: elapsed=%v", time.Since(start))
+
+	if err := os.Remove(p.bloomPath); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// DryRun reports what pruning down to the state with the given root would
+// delete, without modifying the database.
+func (p *Pruner) DryRun(root common.Hash) (*Stats, error) {
+	bloom := newStateBloom(p.bloomSize)
+	if err := p.mark(bloom, root); err != nil {
+		return nil, err
+	}
+	return p.sweep(bloom, true)
+}
+
+// mark adds the hashes of all the trie nodes and contract codes of a state to
+// the bloom filter.
+func (p *Pruner) mark(bloom *stateBloom, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(p.db))
+	if err != nil {
+		return fmt.Errorf("state %x not available: %v", root, err)
+	}
+	var (
+		nodes  uint64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	glog.V(logger.Info).Infof("Marking live state: root=%x", root)
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		// Embedded nodes are not stored separately, skip them
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		bloom.add(it.Hash)
+		nodes++
+
+		if time.Since(logged) > logInterval {
+			glog.V(logger.Info).Infof("Marking live state: nodes=%d elapsed=%v", nodes, time.Since(start))
+			logged = time.Now()
+		}
+	}
+	if it.Error != nil {
+		return fmt.Errorf("failed to iterate state %x: %v", root, it.Error)
+	}
+	glog.V(logger.Info).Infof("Marked live state: nodes=%d elapsed=%v", nodes, time.Since(start))
+	return nil
+}
+
+// sweep deletes, or only counts if dryrun is set, the trie nodes and contract
+// codes missing from the bloom filter. Both are stored under their 32 byte
+// hashes. The only other entries using such keys are transactions, which are
+// told apart by the lookup metadata stored right after them.
+func (p *Pruner) sweep(bloom *stateBloom, dryrun bool) (*Stats, error) {
+	var (
+		stats   = new(Stats)
+		batch   = new(leveldb.Batch)
+		size    int
+		pending []byte // Stale candidate, deleted unless followed by transaction metadata
+		pendlen int    // Size of the candidate's key and value
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	drop := func() error {
+		stats.Nodes++
+		stats.Size += common.StorageSize(pendlen)
+		if dryrun {
+			return nil
+		}
+		batch.Delete(pending)
+		if size += len(pending); size >= ethdb.IdealBatchSize {
+			if err := p.db.LDB().Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+			size = 0
+		}
+		return nil
+	}
+	it := p.db.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if pending != nil {
+			isTx := len(key) == len(pending)+1 && bytes.HasPrefix(key, pending) && key[len(pending)] == txMetaSuffix
+			if !isTx {
+				if err := drop(); err != nil {
+					return nil, err
+				}
+			}
+			pending = nil
+		}
+		if len(key) == common.HashLength && !bloom.contains(key) {
+			pending, pendlen = common.CopyBytes(key), len(key)+len(it.Value())
+		}
+		if time.Since(logged) > logInterval {
+			glog.V(logger.Info).Infof("Pruning state data: nodes=%d size=%v elapsed=%v", stats.Nodes, stats.Size, time.Since(start))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		if err := drop(); err != nil {
+			return nil, err
+		}
+	}
+	if batch.Len() > 0 {
+		if err := p.db.LDB().Write(batch, nil); err != nil {
+			return nil, err
+		}
+	}
+	if dryrun {
+		glog.V(logger.Info).Infof("Found prunable state data: nodes=%d size=%v elapsed=%v", stats.Nodes, stats.Size, time.Since(start))
+	} else {
+		glog.V(logger.Info).Infof("Pruned state data: nodes=%d size=%v elapsed=%v", stats.Nodes, stats.Size, time.Since(start))
+	}
+	return stats, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/ethdb"
+)
+
+// makeStates commits two consecutive states with balances, storage and code
+// into the database, returning their roots.
+func makeStates(t *testing.T, db ethdb.Database) (common.Hash, common.Hash) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	for i := byte(0); i < 50; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, big.NewInt(int64(i)+1))
+		if i%5 == 0 {
+			statedb.SetCode(addr, []byte{i, i, i})
+			for j := byte(0); j < 10; j++ {
+				statedb.SetState(addr, common.BytesToHash([]byte{j}), common.BytesToHash([]byte{i, j}))
+			}
+		}
+	}
+	root1, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit first state: %v", err)
+	}
+	for i := byte(0); i < 50; i += 5 {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, big.NewInt(1))
+		statedb.SetState(addr, common.BytesToHash([]byte{0}), common.BytesToHash([]byte{0xff}))
+	}
+	statedb.SetCode(common.BytesToAddress([]byte{1}), []byte{0xff})
+	root2, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit second state: %v", err)
+	}
+	return root1, root2
+}
+
+// checkState verifies that every node and code of a state is in the database.
+func checkState(db ethdb.Database, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+func newTestDatabase(t *testing.T) (*ethdb.LDBDatabase, string) {
+	dir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, dir
+}
+
+func TestPrune(t *testing.T) {
+	db, dir := newTestDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	root1, root2 := makeStates(t, db)
+	db.Put([]byte("LastBlock"), root2[:])
+
+	// Transactions are stored under their hashes too, they must survive
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{tx}, nil, nil)
+	if err := core.WriteTransactions(db, block); err != nil {
+		t.Fatalf("failed to write transactions: %v", err)
+	}
+
+	pruner := NewPruner(db, filepath.Join(dir, "statebloom"), 1024*1024)
+
+	// A dry run must report the stale entries without touching them
+	dry, err := pruner.DryRun(root2)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if dry.Nodes == 0 {
+		t.Fatal("dry run found nothing to prune")
+	}
+	if err := checkState(db, root1); err != nil {
+		t.Fatalf("dry run modified the database: %v", err)
+	}
+	if core.IsStatePruned(db) {
+		t.Fatal("dry run marked the database pruned")
+	}
+	// The actual pruning must delete exactly what the dry run reported
+	stats, err := pruner.Prune(root2)
+	if err != nil {
+		t.Fatalf("pruning failed: %v", err)
+	}
+	if *stats != *dry {
+		t.Errorf("pruned entries mismatch: have %+v, want %+v", stats, dry)
+	}
+	if err := checkState(db, root2); err != nil {
+		t.Errorf("retained state incomplete: %v", err)
+	}
+	if _, err := state.New(root1, state.NewDatabase(db)); err == nil {
+		t.Errorf("stale state %x not pruned", root1)
+	}
+	if _, err := db.Get([]byte("LastBlock")); err != nil {
+		t.Errorf("non-state entry deleted: %v", err)
+	}
+	if have, _, _, _ := core.GetTransaction(db, tx.Hash()); have == nil {
+		t.Errorf("transaction %x deleted", tx.Hash())
+	}
+	if !core.IsStatePruned(db) {
+		t.Error("database not marked pruned")
+	}
+	if pruner.Interrupted() {
+		t.Error("bloom filter left behind after pruning")
+	}
+}
+
+func TestPruneResume(t *testing.T) {
+	db, dir := newTestDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	root1, root2 := makeStates(t, db)
+
+	// Simulate a run retaining the first state which got interrupted
+	pruner := NewPruner(db, filepath.Join(dir, "statebloom"), 1024*1024)
+	bloom := newStateBloom(1024 * 1024)
+	if err := pruner.mark(bloom, root1); err != nil {
+		t.Fatalf("failed to mark state: %v", err)
+	}
+	bloom.root = root1
+	if err := writeBloom(pruner.bloomPath, bloom); err != nil {
+		t.Fatalf("failed to write bloom filter: %v", err)
+	}
+	if !pruner.Interrupted() {
+		t.Fatal("interrupted pruning not detected")
+	}
+	// Resuming with a newer head must retain both states
+	if _, err := pruner.Prune(root2); err != nil {
+		t.Fatalf("resumed pruning failed: %v", err)
+	}
+	if err := checkState(db, root1); err != nil {
+		t.Errorf("state of interrupted run incomplete: %v", err)
+	}
+	if err := checkState(db, root2); err != nil {
+		t.Errorf("new head state incomplete: %v", err)
+	}
+	if pruner.Interrupted() {
+		t.Error("bloom filter left behind after pruning")
+	}
+}
+
+func TestStateBloomPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bloom := newStateBloom(1024)
+	bloom.root = common.HexToHash("0x01")
+	for i := byte(0); i < 10; i++ {
+		bloom.add(common.BytesToHash([]byte{i, 1, 2, 3}))
+	}
+	path := filepath.Join(dir, "statebloom")
+	if err := writeBloom(path, bloom); err != nil {
+		t.Fatalf("failed to write bloom filter: %v", err)
+	}
+	loaded, err := readBloom(path)
+	if err != nil {
+		t.Fatalf("failed to read bloom filter: %v", err)
+	}
+	if loaded.root != bloom.root {
+		t.Errorf("root mismatch: have %x, want %x", loaded.root, bloom.root)
+	}
+	for i := byte(0); i < 10; i++ {
+		if key := common.BytesToHash([]byte{i, 1, 2, 3}); !loaded.contains(key[:]) {
+			t.Errorf("key %x missing from loaded filter", key)
+		}
+	}
+	// Truncated files must be rejected
+	blob, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, blob[:len(blob)-1], 0644)
+	if _, err := readBloom(path); err == nil {
+		t.Error("truncated bloom filter accepted")
+	}
+}
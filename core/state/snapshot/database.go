@@ -0,0 +1,97 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+var (
+	snapshotRootKey      = []byte("SnapshotRoot")      // State root of the disk layer
+	snapshotGeneratorKey = []byte("SnapshotGenerator") // Progress of the disk layer generation
+	snapshotJournalKey   = []byte("SnapshotJournal")   // Diff layers persisted across restarts
+
+	accountPrefix = []byte("snapshot-account-") // accountPrefix + account hash -> account RLP
+	storagePrefix = []byte("snapshot-storage-") // storagePrefix + account hash + slot hash -> slot RLP
+)
+
+// accountKey = accountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, accountPrefix...), hash[:]...)
+}
+
+// storageKey = storagePrefix + account hash + slot hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	key := append(append([]byte{}, storagePrefix...), accountHash[:]...)
+	return append(key, storageHash[:]...)
+}
+
+// readSnapshotRoot retrieves the state root of the persisted disk layer, or
+// the zero hash if there is no complete disk layer.
+func readSnapshotRoot(db ethdb.Database) common.Hash {
+	data, _ := db.Get(snapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// generatorStatus is the persisted progress of the disk layer generation.
+type generatorStatus struct {
+	Done   bool   // Whether the disk layer covers the whole state
+	Marker []byte // Hash of the last account generated, if not done
+}
+
+// readGenerator retrieves the progress of the disk layer generation.
+func readGenerator(db ethdb.Database) (*generatorStatus, error) {
+	data, err := db.Get(snapshotGeneratorKey)
+	if err != nil {
+		return nil, err
+	}
+	status := new(generatorStatus)
+	if err := rlp.DecodeBytes(data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// writeGenerator stores the progress of the disk layer generation, where a
+// nil marker means the generation is done.
+func writeGenerator(db ethdb.Putter, marker []byte) error {
+	status := &generatorStatus{Done: marker == nil, Marker: marker}
+	data, err := rlp.EncodeToBytes(status)
+	if err != nil {
+		return err
+	}
+	return db.Put(snapshotGeneratorKey, data)
+}
+
+// deletePrefix removes every entry starting with the given prefix from the
+// database.
+func deletePrefix(db ethdb.Database, prefix []byte) error {
+	it := db.(ethdb.Iteratee).NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if err := db.Delete(common.CopyBytes(it.Key())); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
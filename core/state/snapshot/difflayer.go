@@ -0,0 +1,167 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/eth-classic/go-ethereum/common"
+)
+
+// diffLayer is an in-memory snapshot layer holding the accounts and storage
+// slots changed by a single block on top of its parent layer. Entries not
+// changed by the block are looked up in the parent.
+type diffLayer struct {
+	parent snapshot    // Layer this one is based on, replaced when it is flattened
+	root   common.Hash // State root of the block
+	stale  bool        // Whether the layer was flattened or dropped
+
+	destructSet map[common.Hash]struct{}               // Accounts deleted by the block
+	accountData map[common.Hash][]byte                 // Accounts written by the block
+	storageData map[common.Hash]map[common.Hash][]byte // Storage slots written by the block, empty if deleted
+
+	lock sync.RWMutex
+}
+
+// newDiffLayer creates a new diff layer on top of an existing snapshot.
+func newDiffLayer(parent snapshot, root common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	if destructs == nil {
+		destructs = make(map[common.Hash]struct{})
+	}
+	if accounts == nil {
+		accounts = make(map[common.Hash][]byte)
+	}
+	if storage == nil {
+		storage = make(map[common.Hash]map[common.Hash][]byte)
+	}
+	return &diffLayer{
+		parent:      parent,
+		root:        root,
+		destructSet: destructs,
+		accountData: accounts,
+		storageData: storage,
+	}
+}
+
+// Root returns the state root of the layer.
+func (dl *diffLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent returns the layer this one is based on.
+func (dl *diffLayer) Parent() snapshot {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+// setParent rebases the layer on a new parent, after the old one has been
+// flattened into it.
+func (dl *diffLayer) setParent(parent snapshot) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.parent = parent
+}
+
+// base returns the disk layer the layer is ultimately based on.
+func (dl *diffLayer) base() *diskLayer {
+	layer := dl.Parent()
+	for {
+		switch l := layer.(type) {
+		case *diskLayer:
+			return l
+		case *diffLayer:
+			layer = l.Parent()
+		}
+	}
+}
+
+// Stale reports whether the layer was flattened or dropped.
+func (dl *diffLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale marks the layer as stale.
+func (dl *diffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// Account retrieves the account associated with a particular hash.
+func (dl *diffLayer) Account(hash common.Hash) (*Account, error) {
+	blob, err := dl.AccountRLP(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	return decodeAccount(blob)
+}
+
+// AccountRLP retrieves the RLP encoding of the account associated with a
+// particular hash, looking it up in the parent layers if the block didn't
+// change it.
+func (dl *diffLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if data, ok := dl.accountData[hash]; ok {
+		dl.lock.RUnlock()
+		return data, nil
+	}
+	if _, ok := dl.destructSet[hash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.AccountRLP(hash)
+}
+
+// Storage retrieves the RLP encoding of the storage slot associated with a
+// particular account hash and slot hash, looking it up in the parent layers
+// if the block didn't change it.
+func (dl *diffLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if storage, ok := dl.storageData[accountHash]; ok {
+		if data, ok := storage[storageHash]; ok {
+			dl.lock.RUnlock()
+			return data, nil
+		}
+	}
+	// The storage of a deleted account starts out empty
+	if _, ok := dl.destructSet[accountHash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.Storage(accountHash, storageHash)
+}
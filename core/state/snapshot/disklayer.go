@@ -0,0 +1,192 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// diskLayer is the snapshot layer persisted in the key-value store. While it
+// is being generated from the trie of its state, it only covers the accounts
+// up to the generation marker.
+type diskLayer struct {
+	diskdb ethdb.Database     // Key-value store holding the flat entries
+	triedb *trie.NodeDatabase // Trie node database to generate the layer from
+	root   common.Hash        // State root of the layer
+	stale  bool               // Whether the layer was flattened into a newer one
+
+	genMarker []byte             // Hash of the last account generated, nil when done
+	genAbort  chan chan struct{} // Channel to stop the running generator with
+	genDone   chan struct{}      // Channel closed when the running generator finishes
+
+	lock sync.RWMutex
+}
+
+// Root returns the state root of the layer.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil, as the disk layer is the bottom layer.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale reports whether the layer was flattened into a newer one.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale marks the layer as stale.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// generating reports whether the layer doesn't cover the whole state yet.
+func (dl *diskLayer) generating() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.genMarker != nil
+}
+
+// covered reports whether the entries of the given account are generated. It
+// must be called with the layer lock held.
+func (dl *diskLayer) covered(hash common.Hash) bool {
+	return dl.genMarker == nil || bytes.Compare(hash[:], dl.genMarker) <= 0
+}
+
+// Account retrieves the account associated with a particular hash.
+func (dl *diskLayer) Account(hash common.Hash) (*Account, error) {
+	blob, err := dl.AccountRLP(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	return decodeAccount(blob)
+}
+
+// AccountRLP retrieves the RLP encoding of the account associated with a
+// particular hash.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(hash) {
+		return nil, ErrNotCoveredYet
+	}
+	blob, _ := dl.diskdb.Get(accountKey(hash))
+	return blob, nil
+}
+
+// Storage retrieves the RLP encoding of the storage slot associated with a
+// particular account hash and slot hash.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(accountHash) {
+		return nil, ErrNotCoveredYet
+	}
+	blob, _ := dl.diskdb.Get(storageKey(accountHash, storageHash))
+	return blob, nil
+}
+
+// diffToDisk flattens the given diff layer, based on the given disk layer,
+// into a new disk layer. Entries not yet covered by a running generation are
+// skipped, as the generator picks them up from the new state. The generation
+// must be stopped while flattening.
+//
+// The returned layer is valid even if persisting it fails, in which case the
+// database doesn't hold a complete disk layer anymore.
+func diffToDisk(base *diskLayer, bottom *diffLayer) (*diskLayer, error) {
+	base.lock.Lock()
+	base.stale = true
+	marker := base.genMarker
+	base.lock.Unlock()
+
+	bottom.lock.Lock()
+	bottom.stale = true
+	bottom.lock.Unlock()
+
+	res := &diskLayer{
+		diskdb:    base.diskdb,
+		triedb:    base.triedb,
+		root:      bottom.root,
+		genMarker: marker,
+	}
+	// Drop the root first, so that an interrupted write is detected on restart
+	if err := base.diskdb.Delete(snapshotRootKey); err != nil {
+		return res, err
+	}
+	batch := base.diskdb.NewBatch()
+	for hash := range bottom.destructSet {
+		if !res.covered(hash) {
+			continue
+		}
+		if err := base.diskdb.Delete(accountKey(hash)); err != nil {
+			return res, err
+		}
+		if err := deletePrefix(base.diskdb, append(append([]byte{}, storagePrefix...), hash[:]...)); err != nil {
+			return res, err
+		}
+	}
+	for hash, data := range bottom.accountData {
+		if !res.covered(hash) {
+			continue
+		}
+		if err := batch.Put(accountKey(hash), data); err != nil {
+			return res, err
+		}
+	}
+	for accountHash, storage := range bottom.storageData {
+		if !res.covered(accountHash) {
+			continue
+		}
+		for storageHash, data := range storage {
+			var err error
+			if len(data) == 0 {
+				err = base.diskdb.Delete(storageKey(accountHash, storageHash))
+			} else {
+				err = batch.Put(storageKey(accountHash, storageHash), data)
+			}
+			if err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := batch.Put(snapshotRootKey, bottom.root[:]); err != nil {
+		return res, err
+	}
+	return res, batch.Write()
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// logInterval is the time between progress reports of the generation.
+const logInterval = 8 * time.Second
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// generateSnapshot creates a new disk layer for the given state root, which
+// is generated from the trie in the background after wiping the entries of
+// any previous snapshot.
+func generateSnapshot(diskdb ethdb.Database, triedb *trie.NodeDatabase, root common.Hash) *diskLayer {
+	batch := diskdb.NewBatch()
+	batch.Put(snapshotRootKey, root[:])
+	writeGenerator(batch, []byte{})
+	if err := batch.Write(); err != nil {
+		glog.V(logger.Error).Errorf("Failed to start state snapshot generation: %v", err)
+	}
+	diskdb.Delete(snapshotJournalKey)
+
+	base := &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		root:      root,
+		genMarker: []byte{},
+	}
+	base.startGeneration()
+	return base
+}
+
+// startGeneration starts generating the entries of the layer after its
+// generation marker in the background. The trie of the layer is kept alive in
+// the trie node database until the generator finishes.
+func (dl *diskLayer) startGeneration() {
+	dl.triedb.Reference(dl.root, common.Hash{})
+
+	dl.genAbort = make(chan chan struct{})
+	dl.genDone = make(chan struct{})
+	go dl.generate(dl.genAbort, dl.genDone)
+}
+
+// stopGeneration stops the generator of the layer, if any, and reports
+// whether the generation was still in progress.
+func (dl *diskLayer) stopGeneration() bool {
+	if dl.genAbort == nil {
+		return false
+	}
+	stop := make(chan struct{})
+	dl.genAbort <- stop
+	<-stop
+	dl.genAbort = nil
+
+	return dl.generating()
+}
+
+// generate runs the generation of the layer, closing done once it stops. The
+// generator then waits to be stopped through the abort channel, so that the
+// layer can be flattened or replaced with a single protocol.
+func (dl *diskLayer) generate(abort chan chan struct{}, done chan struct{}) {
+	stop, err := dl.generateState(abort)
+	if err != nil {
+		glog.V(logger.Error).Errorf("State snapshot generation failed: %v", err)
+	}
+	dl.triedb.Dereference(dl.root, common.Hash{})
+	close(done)
+
+	if stop == nil {
+		stop = <-abort
+	}
+	close(stop)
+}
+
+// generateState iterates the state trie from the generation marker, writing
+// the accounts and their storage slots into the disk layer. The marker is
+// advanced with every batch written. If a stop request is received through
+// the abort channel, it is returned after the progress is persisted.
+func (dl *diskLayer) generateState(abort chan chan struct{}) (chan struct{}, error) {
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	// Start out with a clean slate, dropping the entries of previous snapshots
+	if len(marker) == 0 {
+		for _, prefix := range [][]byte{accountPrefix, storagePrefix} {
+			if stop, err := dl.wipe(prefix, abort); stop != nil || err != nil {
+				return stop, err
+			}
+		}
+	}
+	start := nextKey(marker)
+	if len(marker) > 0 && start == nil {
+		return nil, dl.flush(dl.diskdb.NewBatch(), nil)
+	}
+	accTrie, err := trie.New(dl.root, dl.triedb)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		batch     = dl.diskdb.NewBatch()
+		accounts  uint64
+		slots     uint64
+		began     = time.Now()
+		logged    = time.Now()
+		firstSeen = false
+	)
+	it := trie.NewIterator(accTrie.NodeIterator(start))
+	for it.Next() {
+		select {
+		case stop := <-abort:
+			return stop, dl.flush(batch, marker)
+		default:
+		}
+		accountHash := common.BytesToHash(it.Key)
+		account, err := decodeAccount(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put(accountKey(accountHash), common.CopyBytes(it.Value))
+		accounts++
+
+		// An earlier failed run may have left part of the first storage behind
+		if !firstSeen {
+			if err := deletePrefix(dl.diskdb, append(append([]byte{}, storagePrefix...), accountHash[:]...)); err != nil {
+				return nil, err
+			}
+			firstSeen = true
+		}
+		if account.Root != emptyRoot {
+			storageTrie, err := trie.New(account.Root, dl.triedb)
+			if err != nil {
+				return nil, err
+			}
+			sit := trie.NewIterator(storageTrie.NodeIterator(nil))
+			for sit.Next() {
+				batch.Put(storageKey(accountHash, common.BytesToHash(sit.Key)), common.CopyBytes(sit.Value))
+				slots++
+
+				// Storage written ahead of the marker is not read until the account is done
+				if batch.ValueSize() > ethdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return nil, err
+					}
+					batch = dl.diskdb.NewBatch()
+				}
+			}
+			if sit.Err != nil {
+				return nil, sit.Err
+			}
+		}
+		marker = accountHash[:]
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := dl.flush(batch, marker); err != nil {
+				return nil, err
+			}
+			batch = dl.diskdb.NewBatch()
+		}
+		if time.Since(logged) > logInterval {
+			glog.V(logger.Info).Infof("Generating state snapshot: accounts=%d slots=%d marker=%x elapsed=%v", accounts, slots, marker, time.Since(began))
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	glog.V(logger.Info).Infof("Generated state snapshot: accounts=%d slots=%d elapsed=%v", accounts, slots, time.Since(began))
+	return nil, dl.flush(batch, nil)
+}
+
+// flush writes the batch along with the new generation marker, after which
+// the entries up to the marker are served from the layer.
+func (dl *diskLayer) flush(batch ethdb.Batch, marker []byte) error {
+	if err := writeGenerator(batch, marker); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	dl.lock.Lock()
+	dl.genMarker = marker
+	dl.lock.Unlock()
+	return nil
+}
+
+// wipe deletes every entry with the given prefix, returning early if a stop
+// request is received through the abort channel.
+func (dl *diskLayer) wipe(prefix []byte, abort chan chan struct{}) (chan struct{}, error) {
+	it := dl.diskdb.(ethdb.Iteratee).NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for deleted := 0; it.Next(); deleted++ {
+		if deleted%10000 == 0 {
+			select {
+			case stop := <-abort:
+				return stop, nil
+			default:
+			}
+		}
+		if err := dl.diskdb.Delete(common.CopyBytes(it.Key())); err != nil {
+			return nil, err
+		}
+	}
+	return nil, it.Error()
+}
+
+// nextKey returns the smallest key greater than the given one of the same
+// length, or nil if there is none.
+func nextKey(key []byte) []byte {
+	next := common.CopyBytes(key)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i]++; next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// testState is the content of a state, keyed by hashes like in the tries.
+type testState struct {
+	balances map[common.Hash]int64
+	storage  map[common.Hash]map[common.Hash][]byte
+}
+
+// newTestState creates a state of n accounts, every third of which has some
+// storage.
+func newTestState(n int) *testState {
+	s := &testState{
+		balances: make(map[common.Hash]int64),
+		storage:  make(map[common.Hash]map[common.Hash][]byte),
+	}
+	for i := 0; i < n; i++ {
+		hash := crypto.Keccak256Hash([]byte{byte(i), byte(i >> 8)})
+		s.balances[hash] = int64(i + 1)
+		if i%3 == 0 {
+			slots := make(map[common.Hash][]byte)
+			for j := 0; j < i%7+1; j++ {
+				val, _ := rlp.EncodeToBytes([]byte{byte(i), byte(j + 1)})
+				slots[crypto.Keccak256Hash([]byte{byte(j)})] = val
+			}
+			s.storage[hash] = slots
+		}
+	}
+	return s
+}
+
+// commit writes the tries of the state into the trie node database, returning
+// the state root and the RLP encodings of the accounts.
+func (s *testState) commit(triedb *trie.NodeDatabase) (common.Hash, map[common.Hash][]byte) {
+	accounts := make(map[common.Hash][]byte)
+	accTrie, _ := trie.New(common.Hash{}, triedb)
+	for hash, balance := range s.balances {
+		root := emptyRoot
+		if slots := s.storage[hash]; len(slots) > 0 {
+			storageTrie, _ := trie.New(common.Hash{}, triedb)
+			for key, val := range slots {
+				storageTrie.Update(key[:], val)
+			}
+			root, _ = storageTrie.CommitTo(triedb)
+		}
+		accounts[hash] = testAccount(balance, root)
+		accTrie.Update(hash[:], accounts[hash])
+	}
+	root, _ := accTrie.CommitTo(triedb)
+	return root, accounts
+}
+
+// checkState verifies that the snapshot holds exactly the given state.
+func checkState(t *testing.T, tree *Tree, diskdb *ethdb.MemDatabase, root common.Hash, s *testState, accounts map[common.Hash][]byte) {
+	snap := tree.Snapshot(root)
+	if snap == nil {
+		t.Fatalf("snapshot %x missing", root)
+	}
+	for hash, blob := range accounts {
+		checkAccount(t, snap, hash, blob)
+		for key, val := range s.storage[hash] {
+			checkStorage(t, snap, hash, key, val)
+		}
+	}
+	var entries int
+	for _, prefix := range [][]byte{accountPrefix, storagePrefix} {
+		it := diskdb.NewIteratorWithPrefix(prefix)
+		for it.Next() {
+			entries++
+		}
+		it.Release()
+	}
+	want := len(accounts)
+	for _, slots := range s.storage {
+		want += len(slots)
+	}
+	if entries != want {
+		t.Errorf("snapshot entry count mismatch: have %d, want %d", entries, want)
+	}
+}
+
+// Tests that the disk layer is generated from the tries of a state, replacing
+// the entries of an earlier snapshot.
+func TestGeneration(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := trie.NewNodeDatabase(diskdb)
+
+	// Leftovers of an older snapshot must be wiped
+	diskdb.Put(accountKey(common.Hash{0xff}), testAccount(1, emptyRoot))
+	diskdb.Put(storageKey(common.Hash{0xff}, common.Hash{0x01}), []byte{0x01})
+
+	s := newTestState(300)
+	root, accounts := s.commit(triedb)
+
+	tree, err := New(diskdb, triedb, root, false)
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	checkState(t, tree, diskdb, root, s, accounts)
+
+	status, err := readGenerator(diskdb)
+	if err != nil || !status.Done {
+		t.Errorf("generation not marked done: %v, %v", status, err)
+	}
+}
+
+// Tests that diffs flattened into a disk layer being generated only apply to
+// the generated accounts, with the generation resuming on the new state.
+func TestGenerationWithFlattening(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := trie.NewNodeDatabase(diskdb)
+
+	s := newTestState(300)
+	root, accounts := s.commit(triedb)
+
+	// Pretend the generation of the first state stopped halfway
+	var marker common.Hash
+	for hash := range accounts {
+		if marker == (common.Hash{}) || hash.Big().Cmp(marker.Big()) < 0 {
+			marker = hash
+		}
+	}
+	for hash := range accounts {
+		if hash.Big().Cmp(marker.Big()) > 0 && hash[0] < 0x80 {
+			marker = hash
+		}
+	}
+	for hash, blob := range accounts {
+		if hash.Big().Cmp(marker.Big()) <= 0 {
+			diskdb.Put(accountKey(hash), blob)
+			for key, val := range s.storage[hash] {
+				diskdb.Put(storageKey(hash, key), val)
+			}
+		}
+	}
+	diskdb.Put(snapshotRootKey, root[:])
+	writeGenerator(diskdb, marker[:])
+
+	tree := &Tree{diskdb: diskdb, triedb: triedb, layers: make(map[common.Hash]snapshot)}
+	base := &diskLayer{diskdb: diskdb, triedb: triedb, root: root, genMarker: marker[:]}
+	tree.layers[root] = base
+
+	// Change, delete and create accounts on both sides of the marker
+	var (
+		destructs = make(map[common.Hash]struct{})
+		changed   = make(map[common.Hash][]byte)
+		storage   = make(map[common.Hash]map[common.Hash][]byte)
+		count     int
+	)
+	for hash := range s.balances {
+		switch count++; count % 10 {
+		case 0:
+			destructs[hash] = struct{}{}
+			delete(s.balances, hash)
+			delete(s.storage, hash)
+		case 1:
+			s.balances[hash] += 1000
+		case 2:
+			slots := s.storage[hash]
+			if slots == nil {
+				slots = make(map[common.Hash][]byte)
+				s.storage[hash] = slots
+			}
+			val, _ := rlp.EncodeToBytes([]byte{0xee})
+			slots[common.Hash{0xee}] = val
+			storage[hash] = map[common.Hash][]byte{{0xee}: val}
+		}
+	}
+	for i := 0; i < 10; i++ {
+		s.balances[crypto.Keccak256Hash([]byte("new"), []byte{byte(i)})] = 5000
+	}
+	newRoot, newAccounts := s.commit(triedb)
+	for hash, blob := range newAccounts {
+		if old, ok := accounts[hash]; !ok || string(old) != string(blob) {
+			changed[hash] = blob
+		}
+	}
+	if err := tree.Update(newRoot, root, destructs, changed, storage); err != nil {
+		t.Fatalf("failed to add diff: %v", err)
+	}
+	base.startGeneration()
+	if err := tree.Cap(newRoot, 0); err != nil {
+		t.Fatalf("failed to flatten diff: %v", err)
+	}
+	if err := tree.waitGeneration(); err != nil {
+		t.Fatalf("generation failed: %v", err)
+	}
+	checkState(t, tree, diskdb, newRoot, s, newAccounts)
+}
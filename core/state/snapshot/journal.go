@@ -0,0 +1,177 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// journal is the persisted form of a chain of diff layers on top of the disk
+// layer, ordered from the bottom up.
+type journal struct {
+	Base   common.Hash // State root of the disk layer the diffs are based on
+	Layers []journalLayer
+}
+
+// journalLayer is the persisted form of a diff layer.
+type journalLayer struct {
+	Root      common.Hash
+	Destructs []common.Hash
+	Accounts  []journalAccount
+	Storage   []journalStorage
+}
+
+// journalAccount is an account entry of a persisted diff layer.
+type journalAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// journalStorage holds the storage entries of an account in a persisted diff
+// layer.
+type journalStorage struct {
+	Hash common.Hash
+	Keys []common.Hash
+	Vals [][]byte
+}
+
+// loadSnapshot loads the disk layer persisted in the database along with the
+// journalled diff layers on top of it, returning the topmost layer. It fails
+// if the layers don't lead up to the given state root.
+func loadSnapshot(diskdb ethdb.Database, triedb *trie.NodeDatabase, root common.Hash) (snapshot, error) {
+	baseRoot := readSnapshotRoot(diskdb)
+	if baseRoot == (common.Hash{}) {
+		return nil, errors.New("missing or corrupted snapshot")
+	}
+	status, err := readGenerator(diskdb)
+	if err != nil {
+		return nil, fmt.Errorf("missing generator progress: %v", err)
+	}
+	base := &diskLayer{
+		diskdb: diskdb,
+		triedb: triedb,
+		root:   baseRoot,
+	}
+	if !status.Done {
+		base.genMarker = append([]byte{}, status.Marker...)
+	}
+	head, err := loadJournal(diskdb, base)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Failed to load state snapshot journal, discarding diffs: %v", err)
+		head = base
+	}
+	if head.Root() != root {
+		return nil, fmt.Errorf("head doesn't match snapshot: have %#x, want %#x", head.Root(), root)
+	}
+	if base.genMarker != nil {
+		base.startGeneration()
+	}
+	return head, nil
+}
+
+// loadJournal loads the diff layers journalled on top of the given disk layer,
+// returning the topmost layer.
+func loadJournal(diskdb ethdb.Database, base *diskLayer) (snapshot, error) {
+	data, err := diskdb.Get(snapshotJournalKey)
+	if err != nil {
+		return base, nil
+	}
+	var j journal
+	if err := rlp.DecodeBytes(data, &j); err != nil {
+		return nil, err
+	}
+	if j.Base != base.root {
+		return nil, fmt.Errorf("journal based on %#x, disk layer is %#x", j.Base, base.root)
+	}
+	var head snapshot = base
+	for _, layer := range j.Layers {
+		destructs := make(map[common.Hash]struct{}, len(layer.Destructs))
+		for _, hash := range layer.Destructs {
+			destructs[hash] = struct{}{}
+		}
+		accounts := make(map[common.Hash][]byte, len(layer.Accounts))
+		for _, account := range layer.Accounts {
+			accounts[account.Hash] = account.Blob
+		}
+		storage := make(map[common.Hash]map[common.Hash][]byte, len(layer.Storage))
+		for _, entry := range layer.Storage {
+			if len(entry.Keys) != len(entry.Vals) {
+				return nil, fmt.Errorf("storage of %#x has %d keys and %d values", entry.Hash, len(entry.Keys), len(entry.Vals))
+			}
+			slots := make(map[common.Hash][]byte, len(entry.Keys))
+			for i, key := range entry.Keys {
+				slots[key] = entry.Vals[i]
+			}
+			storage[entry.Hash] = slots
+		}
+		head = newDiffLayer(head, layer.Root, destructs, accounts, storage)
+	}
+	return head, nil
+}
+
+// writeJournal persists the diff layers from the given layer down to the disk
+// layer.
+func writeJournal(diskdb ethdb.Database, head snapshot) error {
+	var diffs []*diffLayer
+	for layer := head; ; {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+		layer = diff.Parent()
+	}
+	j := journal{Base: head.Root()}
+	if len(diffs) > 0 {
+		j.Base = diffs[len(diffs)-1].base().root
+	}
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+		diff.lock.RLock()
+		layer := journalLayer{Root: diff.root}
+		for hash := range diff.destructSet {
+			layer.Destructs = append(layer.Destructs, hash)
+		}
+		for hash, blob := range diff.accountData {
+			layer.Accounts = append(layer.Accounts, journalAccount{Hash: hash, Blob: blob})
+		}
+		for hash, slots := range diff.storageData {
+			entry := journalStorage{Hash: hash}
+			for key, val := range slots {
+				entry.Keys = append(entry.Keys, key)
+				entry.Vals = append(entry.Vals, val)
+			}
+			layer.Storage = append(layer.Storage, entry)
+		}
+		diff.lock.RUnlock()
+		j.Layers = append(j.Layers, layer)
+	}
+	data, err := rlp.EncodeToBytes(&j)
+	if err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("Journalled state snapshot: layers=%d root=%x", len(diffs), head.Root())
+	return diskdb.Put(snapshotJournalKey, data)
+}
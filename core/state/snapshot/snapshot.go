@@ -0,0 +1,338 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat key-value view of the accounts and
+// storage slots of recent states, keyed by the hashes used in the tries.
+//
+// The state of the oldest block is persisted as the disk layer. Each newer
+// block adds an in-memory diff layer holding the entries changed by it on top
+// of its parent's layer, so the layers form a tree following the forks of the
+// chain. Capping the tree flattens the bottom diff layers of a chain into the
+// disk layer, dropping the layers of the other forks.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying layer
+	// was flattened or dropped while being read.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the requested entry
+	// is not yet covered by the disk layer being generated.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a layer is added on top of itself.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Account is the Ethereum consensus representation of an account, as stored
+// in the account trie and in the snapshot.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Snapshot represents the functionality supported by a snapshot layer, that
+// is the flat state of a single block.
+//
+// The accessors return an error if the layer can't answer the query, in which
+// case the caller has to fall back to the tries. A missing entry is reported
+// with a nil value and no error.
+type Snapshot interface {
+	// Root returns the state root the snapshot belongs to.
+	Root() common.Hash
+
+	// Account retrieves the account associated with a particular hash.
+	Account(hash common.Hash) (*Account, error)
+
+	// AccountRLP retrieves the RLP encoding of the account associated with a
+	// particular hash.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage retrieves the RLP encoding of the storage slot associated with a
+	// particular account hash and slot hash.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal interface implemented by the disk and diff layers.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the layer this one is based on, or nil for the disk layer.
+	Parent() snapshot
+
+	// Stale reports whether the layer was flattened or dropped, after which
+	// it must not be used anymore.
+	Stale() bool
+
+	// markStale marks the layer as stale.
+	markStale()
+}
+
+// Tree is the set of snapshot layers of the recent states, made of a single
+// persisted disk layer and the in-memory diff layers on top of it. It is safe
+// for concurrent use.
+type Tree struct {
+	diskdb ethdb.Database     // Key-value store holding the disk layer
+	triedb *trie.NodeDatabase // Trie node database to generate the disk layer from
+	layers map[common.Hash]snapshot
+	lock   sync.RWMutex
+}
+
+// New loads the snapshot tree persisted in the given database and ensures it
+// has a layer for the given head state root. If the persisted snapshot is
+// missing or doesn't match the head, the disk layer is regenerated from the
+// head state, in the background if async is set.
+//
+// The database needs to support iteration, see ethdb.Iteratee.
+func New(diskdb ethdb.Database, triedb *trie.NodeDatabase, root common.Hash, async bool) (*Tree, error) {
+	if _, ok := diskdb.(ethdb.Iteratee); !ok {
+		return nil, fmt.Errorf("database %T does not support iteration", diskdb)
+	}
+	t := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	head, err := loadSnapshot(diskdb, triedb, root)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Failed to load state snapshot, regenerating: %v", err)
+		t.Rebuild(root)
+	} else {
+		for layer := snapshot(head); layer != nil; layer = layer.Parent() {
+			t.layers[layer.Root()] = layer
+		}
+	}
+	if !async {
+		if err := t.waitGeneration(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// waitGeneration blocks until the disk layer is fully generated.
+func (t *Tree) waitGeneration() error {
+	t.lock.RLock()
+	base := t.disklayer()
+	t.lock.RUnlock()
+
+	if base == nil || base.genDone == nil {
+		return nil
+	}
+	<-base.genDone
+	base.lock.RLock()
+	defer base.lock.RUnlock()
+
+	if base.genMarker != nil {
+		return fmt.Errorf("snapshot generation failed at %x", base.genMarker)
+	}
+	return nil
+}
+
+// Snapshot retrieves the snapshot layer of the given state root, or nil if
+// the tree has no such layer.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new diff layer on top of the layer of the parent state root,
+// holding the changes made by a block: the hashes of the accounts deleted, and
+// the RLP encodings of the accounts and storage slots written, where an empty
+// storage value means a deleted slot. Accounts both deleted and written were
+// recreated. The given maps are owned by the snapshot afterwards.
+func (t *Tree) Update(root common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if root == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent snapshot [%#x] missing", parentRoot)
+	}
+	// The same state may be reached by several blocks, keep the first layer
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	t.layers[root] = newDiffLayer(parent, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap flattens the diff layers below the given state root into the disk
+// layer, keeping the given number of diff layers on top of it, so a cap of
+// zero flattens the layer of the root itself. Layers of other forks which
+// are no longer based on the disk layer are marked stale and dropped.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := layer.(*diffLayer)
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] is disk layer", root)
+	}
+	_, err := t.cap(diff, layers, true)
+	return err
+}
+
+// cap implements Cap with the tree lock held, restarting the generation of
+// the new disk layer if resume is set. If flattening fails, the partially
+// written disk layer is dropped and the snapshot is regenerated from the
+// state of the given layer.
+func (t *Tree) cap(diff *diffLayer, layers int, resume bool) (*diskLayer, error) {
+	// Collect the diff layers from the given one down to the disk layer
+	chain := []*diffLayer{diff}
+	for {
+		parent, ok := chain[len(chain)-1].Parent().(*diffLayer)
+		if !ok {
+			break
+		}
+		chain = append(chain, parent)
+	}
+	base := chain[len(chain)-1].Parent().(*diskLayer)
+	if len(chain) <= layers {
+		return base, nil
+	}
+	// Pause the generation while the diffs are written, as the generator
+	// would otherwise race with the flattening for the same entries
+	generating := base.stopGeneration()
+	for i := len(chain) - 1; i >= layers; i-- {
+		var err error
+		if base, err = diffToDisk(base, chain[i]); err != nil {
+			// The disk layer is inconsistent, it must not serve any reads
+			glog.V(logger.Error).Errorf("Failed to flatten state snapshot: %v", err)
+			base.markStale()
+			return t.rebuild(diff.root), err
+		}
+	}
+	if generating && resume {
+		base.startGeneration()
+	}
+	// Rebase the children of the last flattened layer on the new disk layer,
+	// then drop the layers not based on it anymore
+	for _, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok && !diff.Stale() {
+			if parent, ok := diff.Parent().(*diffLayer); ok && parent.root == base.root {
+				diff.setParent(base)
+			}
+		}
+	}
+	for root, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok && !diff.Stale() && diff.base() == base {
+			continue
+		}
+		layer.markStale()
+		delete(t.layers, root)
+	}
+	t.layers[base.root] = base
+	return base, nil
+}
+
+// disklayer returns the disk layer of the tree, or nil if there is none. It
+// must be called with the tree lock held.
+func (t *Tree) disklayer() *diskLayer {
+	for _, layer := range t.layers {
+		for ; layer.Parent() != nil; layer = layer.Parent() {
+		}
+		return layer.(*diskLayer)
+	}
+	return nil
+}
+
+// Rebuild drops all layers and regenerates the disk layer from the given
+// state root in the background. Until the generation has progressed, reads
+// fall back to the tries.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.rebuild(root)
+}
+
+// rebuild implements Rebuild with the tree lock held.
+func (t *Tree) rebuild(root common.Hash) *diskLayer {
+	if base := t.disklayer(); base != nil {
+		base.stopGeneration()
+	}
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	glog.V(logger.Info).Infof("Rebuilding state snapshot at root %x", root)
+	base := generateSnapshot(t.diskdb, t.triedb, root)
+	t.layers = map[common.Hash]snapshot{root: base}
+	return base
+}
+
+// Journal persists the diff layers from the given state root down to the
+// disk layer, so that they can be loaded on the next start. A disk layer
+// still being generated is advanced to the given root first, as the tries
+// of older states may not be persisted. The tree must not be modified after.
+func (t *Tree) Journal(root common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	if diff, ok := layer.(*diffLayer); ok {
+		if base := diff.base(); base.generating() {
+			var err error
+			if layer, err = t.cap(diff, 0, false); err != nil {
+				return err
+			}
+		}
+	}
+	if base, ok := layer.(*diskLayer); ok {
+		base.stopGeneration()
+	}
+	return writeJournal(t.diskdb, layer)
+}
+
+// decodeAccount decodes the RLP encoding of an account.
+func decodeAccount(blob []byte) (*Account, error) {
+	account := new(Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
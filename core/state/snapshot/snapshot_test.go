@@ -0,0 +1,300 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// testAccount encodes an account with the given balance and storage root.
+func testAccount(balance int64, root common.Hash) []byte {
+	blob, _ := rlp.EncodeToBytes(&Account{
+		Balance:  big.NewInt(balance),
+		Root:     root,
+		CodeHash: crypto.Keccak256(nil),
+	})
+	return blob
+}
+
+// newTestTree creates a snapshot tree with a fully generated disk layer at
+// the given root, holding the given accounts and storage.
+func newTestTree(root common.Hash, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) (*Tree, *ethdb.MemDatabase) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	for hash, blob := range accounts {
+		diskdb.Put(accountKey(hash), blob)
+	}
+	for hash, slots := range storage {
+		for key, val := range slots {
+			diskdb.Put(storageKey(hash, key), val)
+		}
+	}
+	diskdb.Put(snapshotRootKey, root[:])
+	writeGenerator(diskdb, nil)
+
+	tree, err := New(diskdb, trie.NewNodeDatabase(diskdb), root, false)
+	if err != nil {
+		panic(err)
+	}
+	return tree, diskdb
+}
+
+func checkAccount(t *testing.T, snap Snapshot, hash common.Hash, want []byte) {
+	have, err := snap.AccountRLP(hash)
+	if err != nil {
+		t.Fatalf("snapshot %x: failed to read account %x: %v", snap.Root(), hash, err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("snapshot %x: account %x mismatch: have %x, want %x", snap.Root(), hash, have, want)
+	}
+}
+
+func checkStorage(t *testing.T, snap Snapshot, account, slot common.Hash, want []byte) {
+	have, err := snap.Storage(account, slot)
+	if err != nil {
+		t.Fatalf("snapshot %x: failed to read slot %x of %x: %v", snap.Root(), slot, account, err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("snapshot %x: slot %x of %x mismatch: have %x, want %x", snap.Root(), slot, account, have, want)
+	}
+}
+
+// Tests that the diff layers answer queries with their own changes, falling
+// back to their parents for anything they didn't change.
+func TestDiffLayerLookups(t *testing.T) {
+	var (
+		base  = common.Hash{0x01}
+		acc1  = common.Hash{0xa1}
+		acc2  = common.Hash{0xa2}
+		acc3  = common.Hash{0xa3}
+		slot1 = common.Hash{0x51}
+		slot2 = common.Hash{0x52}
+	)
+	tree, _ := newTestTree(base,
+		map[common.Hash][]byte{acc1: testAccount(1, common.Hash{}), acc2: testAccount(2, common.Hash{})},
+		map[common.Hash]map[common.Hash][]byte{acc2: {slot1: {0x01}, slot2: {0x02}}},
+	)
+	// Change an account in the first block, delete another with storage
+	if err := tree.Update(common.Hash{0x02}, base,
+		map[common.Hash]struct{}{acc2: {}},
+		map[common.Hash][]byte{acc1: testAccount(10, common.Hash{})},
+		nil,
+	); err != nil {
+		t.Fatalf("failed to add first diff: %v", err)
+	}
+	// Recreate the deleted account in the second block, with a single slot
+	if err := tree.Update(common.Hash{0x03}, common.Hash{0x02},
+		nil,
+		map[common.Hash][]byte{acc2: testAccount(20, common.Hash{}), acc3: testAccount(30, common.Hash{})},
+		map[common.Hash]map[common.Hash][]byte{acc2: {slot2: {0x20}}},
+	); err != nil {
+		t.Fatalf("failed to add second diff: %v", err)
+	}
+	snap := tree.Snapshot(common.Hash{0x03})
+	checkAccount(t, snap, acc1, testAccount(10, common.Hash{}))
+	checkAccount(t, snap, acc2, testAccount(20, common.Hash{}))
+	checkAccount(t, snap, acc3, testAccount(30, common.Hash{}))
+	checkStorage(t, snap, acc2, slot1, nil)
+	checkStorage(t, snap, acc2, slot2, []byte{0x20})
+
+	snap = tree.Snapshot(common.Hash{0x02})
+	checkAccount(t, snap, acc2, nil)
+	checkAccount(t, snap, acc3, nil)
+	checkStorage(t, snap, acc2, slot1, nil)
+
+	snap = tree.Snapshot(base)
+	checkAccount(t, snap, acc1, testAccount(1, common.Hash{}))
+	checkStorage(t, snap, acc2, slot1, []byte{0x01})
+
+	if err := tree.Update(common.Hash{0x04}, common.Hash{0x04}, nil, nil, nil); err != errSnapshotCycle {
+		t.Errorf("cyclic update error mismatch: have %v, want %v", err, errSnapshotCycle)
+	}
+	if err := tree.Update(common.Hash{0x05}, common.Hash{0xff}, nil, nil, nil); err == nil {
+		t.Errorf("update on missing parent succeeded")
+	}
+}
+
+// Tests that capping the tree flattens the bottom diff layers into the disk
+// layer and drops the layers of forks no longer based on it.
+func TestCapFlattensAndDropsForks(t *testing.T) {
+	var (
+		base  = common.Hash{0x01}
+		acc1  = common.Hash{0xa1}
+		acc2  = common.Hash{0xa2}
+		slot1 = common.Hash{0x51}
+		slot2 = common.Hash{0x52}
+	)
+	tree, diskdb := newTestTree(base,
+		map[common.Hash][]byte{acc1: testAccount(1, common.Hash{}), acc2: testAccount(2, common.Hash{})},
+		map[common.Hash]map[common.Hash][]byte{acc2: {slot1: {0x01}, slot2: {0x02}}},
+	)
+	// Chain: base <- 0x02 <- 0x03 <- 0x04, fork: base <- 0x12, 0x02 <- 0x13
+	tree.Update(common.Hash{0x02}, base, map[common.Hash]struct{}{acc2: {}}, map[common.Hash][]byte{acc1: testAccount(10, common.Hash{})}, nil)
+	tree.Update(common.Hash{0x03}, common.Hash{0x02}, nil, map[common.Hash][]byte{acc2: testAccount(20, common.Hash{})}, map[common.Hash]map[common.Hash][]byte{acc2: {slot2: {0x20}}})
+	tree.Update(common.Hash{0x04}, common.Hash{0x03}, nil, map[common.Hash][]byte{acc1: testAccount(40, common.Hash{})}, map[common.Hash]map[common.Hash][]byte{acc2: {slot2: nil}})
+	tree.Update(common.Hash{0x12}, base, nil, map[common.Hash][]byte{acc1: testAccount(12, common.Hash{})}, nil)
+	tree.Update(common.Hash{0x13}, common.Hash{0x02}, nil, map[common.Hash][]byte{acc1: testAccount(13, common.Hash{})}, nil)
+
+	fork := tree.Snapshot(common.Hash{0x12})
+	sibling := tree.Snapshot(common.Hash{0x13})
+
+	// Keeping three layers is a no-op
+	if err := tree.Cap(common.Hash{0x04}, 3); err != nil {
+		t.Fatalf("failed to cap to 3 layers: %v", err)
+	}
+	if len(tree.layers) != 6 {
+		t.Fatalf("layer count mismatch after no-op cap: have %d, want 6", len(tree.layers))
+	}
+	// Keeping two layers flattens the first block, dropping the fork on the base
+	if err := tree.Cap(common.Hash{0x04}, 2); err != nil {
+		t.Fatalf("failed to cap to 2 layers: %v", err)
+	}
+	if len(tree.layers) != 4 {
+		t.Errorf("layer count mismatch after cap: have %d, want 4", len(tree.layers))
+	}
+	if tree.Snapshot(common.Hash{0x12}) != nil || !fork.(snapshot).Stale() {
+		t.Errorf("fork on the old disk layer not dropped")
+	}
+	if _, err := fork.AccountRLP(acc1); err != ErrSnapshotStale {
+		t.Errorf("stale fork read error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	if tree.Snapshot(common.Hash{0x13}) == nil || sibling.(snapshot).Stale() {
+		t.Errorf("fork on the new disk layer dropped")
+	}
+	if root := readSnapshotRoot(diskdb); root != (common.Hash{0x02}) {
+		t.Errorf("persisted root mismatch: have %x, want %x", root, common.Hash{0x02})
+	}
+	if blob, _ := diskdb.Get(accountKey(acc2)); blob != nil {
+		t.Errorf("deleted account still persisted: %x", blob)
+	}
+	if blob, _ := diskdb.Get(storageKey(acc2, slot1)); blob != nil {
+		t.Errorf("storage of deleted account still persisted: %x", blob)
+	}
+	// Flattening everything leaves only the disk layer
+	if err := tree.Cap(common.Hash{0x04}, 0); err != nil {
+		t.Fatalf("failed to cap to 0 layers: %v", err)
+	}
+	if len(tree.layers) != 1 {
+		t.Errorf("layer count mismatch after full cap: have %d, want 1", len(tree.layers))
+	}
+	snap := tree.Snapshot(common.Hash{0x04})
+	if _, ok := snap.(*diskLayer); !ok {
+		t.Fatalf("head not flattened into disk layer: %T", snap)
+	}
+	checkAccount(t, snap, acc1, testAccount(40, common.Hash{}))
+	checkAccount(t, snap, acc2, testAccount(20, common.Hash{}))
+	checkStorage(t, snap, acc2, slot1, nil)
+	checkStorage(t, snap, acc2, slot2, nil)
+}
+
+// failingDatabase is a memory database whose deletions fail while fail is set.
+type failingDatabase struct {
+	*ethdb.MemDatabase
+	fail bool
+}
+
+func (db *failingDatabase) Delete(key []byte) error {
+	if db.fail {
+		return errors.New("delete failed")
+	}
+	return db.MemDatabase.Delete(key)
+}
+
+// Tests that a failed flattening doesn't leave the tree serving reads from a
+// partially written disk layer, but regenerates it from the capped state.
+func TestCapFailureRebuilds(t *testing.T) {
+	var (
+		base = common.Hash{0x01}
+		acc1 = common.Hash{0xa1}
+	)
+	memdb, _ := ethdb.NewMemDatabase()
+	memdb.Put(accountKey(acc1), testAccount(1, common.Hash{}))
+	memdb.Put(snapshotRootKey, base[:])
+	writeGenerator(memdb, nil)
+
+	diskdb := &failingDatabase{MemDatabase: memdb}
+	tree, err := New(diskdb, trie.NewNodeDatabase(diskdb), base, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	tree.Update(common.Hash{0x02}, base, nil, map[common.Hash][]byte{acc1: testAccount(2, common.Hash{})}, nil)
+	tree.Update(common.Hash{0x03}, common.Hash{0x02}, nil, map[common.Hash][]byte{acc1: testAccount(3, common.Hash{})}, nil)
+
+	disk := tree.Snapshot(base)
+	diff := tree.Snapshot(common.Hash{0x02})
+
+	diskdb.fail = true
+	if err := tree.Cap(common.Hash{0x03}, 1); err == nil {
+		t.Fatalf("cap succeeded despite failing database")
+	}
+	diskdb.fail = false
+
+	if !disk.(snapshot).Stale() || !diff.(snapshot).Stale() {
+		t.Errorf("layers of the failed cap not marked stale")
+	}
+	if tree.Snapshot(base) != nil || tree.Snapshot(common.Hash{0x02}) != nil {
+		t.Errorf("layers of the failed cap still served")
+	}
+	if len(tree.layers) != 1 {
+		t.Errorf("layer count mismatch after failed cap: have %d, want 1", len(tree.layers))
+	}
+	if _, ok := tree.Snapshot(common.Hash{0x03}).(*diskLayer); !ok {
+		t.Errorf("snapshot not rebuilt at the capped root")
+	}
+}
+
+// Tests that journalled diff layers are loaded again on the next start, and
+// that a journal not leading up to the head is discarded.
+func TestJournal(t *testing.T) {
+	var (
+		base = common.Hash{0x01}
+		acc1 = common.Hash{0xa1}
+		slot = common.Hash{0x51}
+	)
+	tree, diskdb := newTestTree(base, map[common.Hash][]byte{acc1: testAccount(1, common.Hash{})}, nil)
+	tree.Update(common.Hash{0x02}, base, nil, map[common.Hash][]byte{acc1: testAccount(2, common.Hash{})}, map[common.Hash]map[common.Hash][]byte{acc1: {slot: {0x02}}})
+	tree.Update(common.Hash{0x03}, common.Hash{0x02}, map[common.Hash]struct{}{acc1: {}}, nil, nil)
+
+	if err := tree.Journal(common.Hash{0x03}); err != nil {
+		t.Fatalf("failed to journal: %v", err)
+	}
+	triedb := trie.NewNodeDatabase(diskdb)
+	loaded, err := New(diskdb, triedb, common.Hash{0x03}, false)
+	if err != nil {
+		t.Fatalf("failed to load journalled tree: %v", err)
+	}
+	if len(loaded.layers) != 3 {
+		t.Fatalf("layer count mismatch: have %d, want 3", len(loaded.layers))
+	}
+	checkAccount(t, loaded.Snapshot(common.Hash{0x02}), acc1, testAccount(2, common.Hash{}))
+	checkStorage(t, loaded.Snapshot(common.Hash{0x02}), acc1, slot, []byte{0x02})
+	checkAccount(t, loaded.Snapshot(common.Hash{0x03}), acc1, nil)
+	checkStorage(t, loaded.Snapshot(common.Hash{0x03}), acc1, slot, nil)
+
+	// A different head requires regenerating the snapshot
+	if _, err := loadSnapshot(diskdb, triedb, common.Hash{0x04}); err == nil {
+		t.Errorf("snapshot loaded for mismatching head")
+	}
+}
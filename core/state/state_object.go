@@ -83,6 +83,7 @@ type StateObject struct {
 
 	cachedStorage Storage // Storage entry cache to avoid duplicate reads
 	dirtyStorage  Storage // Storage entries that need to be flushed to disk
+	fakeStorage   Storage // Storage replacing the trie contents, set by callers for debugging
 
 	// Cache flags.
 	// When an object is marked suicided it will be delete from the trie
@@ -129,11 +130,13 @@ func (c *StateObject) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, c.data)
 }
 
-// setError remembers the first non-nil error it is called with.
+// setError remembers the first non-nil error it is called with, also
+// reporting it to the owning state.
 func (self *StateObject) setError(err error) {
 	if self.dbErr == nil {
 		self.dbErr = err
 	}
+	self.db.setError(err)
 }
 
 func (self *StateObject) markSuicided() {
@@ -165,15 +168,52 @@ func (c *StateObject) getTrie(db Database) Trie {
 
 // GetState returns a value in account storage.
 func (self *StateObject) GetState(db Database, key common.Hash) common.Hash {
+	// If the fake storage is set, only look up the state there
+	if self.fakeStorage != nil {
+		return self.fakeStorage[key]
+	}
 	value, exists := self.cachedStorage[key]
 	if exists {
 		return value
 	}
-	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
-	if err != nil {
-		self.setError(err)
-		return common.Hash{}
+	value = self.GetCommittedState(db, key)
+	if (value != common.Hash{}) {
+		self.cachedStorage[key] = value
+	}
+	return value
+}
+
+// GetCommittedState returns a value in account storage as of the last
+// finalised state, ignoring any uncommitted modifications.
+func (self *StateObject) GetCommittedState(db Database, key common.Hash) common.Hash {
+	// If the fake storage is set, only look up the state there
+	if self.fakeStorage != nil {
+		return self.fakeStorage[key]
+	}
+	var value common.Hash
+	if _, dirty := self.dirtyStorage[key]; !dirty {
+		// Entries not written since the last flush are cached as committed.
+		if cached, exists := self.cachedStorage[key]; exists {
+			return cached
+		}
+	}
+	// Load from the snapshot if it covers the slot, else from the trie.
+	var (
+		enc []byte
+		err error
+	)
+	if self.db.snap != nil {
+		// The storage of a deleted or recreated account starts out empty
+		if _, destructed := self.db.snapDestructs[self.addrHash]; destructed {
+			return common.Hash{}
+		}
+		enc, err = self.db.snap.Storage(self.addrHash, crypto.Keccak256Hash(key[:]))
+	}
+	if self.db.snap == nil || err != nil {
+		if enc, err = self.getTrie(db).TryGet(key[:]); err != nil {
+			self.setError(err)
+			return common.Hash{}
+		}
 	}
 	if len(enc) > 0 {
 		_, content, _, err := rlp.Split(enc)
@@ -182,9 +222,6 @@ func (self *StateObject) GetState(db Database, key common.Hash) common.Hash {
 		}
 		value.SetBytes(content)
 	}
-	if (value != common.Hash{}) {
-		self.cachedStorage[key] = value
-	}
 	return value
 }
 
@@ -199,22 +236,55 @@ func (self *StateObject) SetState(db Database, key, value common.Hash) {
 }
 
 func (self *StateObject) setState(key, value common.Hash) {
+	// If the fake storage is set, put the temporary state update here.
+	if self.fakeStorage != nil {
+		self.fakeStorage[key] = value
+		return
+	}
 	self.cachedStorage[key] = value
 	self.dirtyStorage[key] = value
 }
 
+// SetStorage replaces the entire storage for the specified account with given
+// storage. This function should only be used for debugging.
+func (self *StateObject) SetStorage(storage map[common.Hash]common.Hash) {
+	self.db.journal.append(storageReplaceChange{
+		account: &self.address,
+		prev:    self.fakeStorage,
+	})
+	self.fakeStorage = make(Storage, len(storage))
+	for key, value := range storage {
+		self.fakeStorage[key] = value
+	}
+}
+
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *StateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
+
+	// Record the changes for the snapshot diff layer of the state
+	var storage map[common.Hash][]byte
+	if self.db.snap != nil {
+		if storage = self.db.snapStorage[self.addrHash]; storage == nil {
+			storage = make(map[common.Hash][]byte)
+			self.db.snapStorage[self.addrHash] = storage
+		}
+	}
 	for key, value := range self.dirtyStorage {
 		delete(self.dirtyStorage, key)
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			if storage != nil {
+				storage[crypto.Keccak256Hash(key[:])] = nil
+			}
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		if storage != nil {
+			storage[crypto.Keccak256Hash(key[:])] = v
+		}
 	}
 	return tr
 }
@@ -308,6 +378,9 @@ func (self *StateObject) deepCopy(db *StateDB) *StateObject {
 	stateObject.code = self.code
 	stateObject.dirtyStorage = self.dirtyStorage.Copy()
 	stateObject.cachedStorage = self.dirtyStorage.Copy()
+	if self.fakeStorage != nil {
+		stateObject.fakeStorage = self.fakeStorage.Copy()
+	}
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
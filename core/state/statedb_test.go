@@ -29,6 +29,7 @@ import (
 	"testing/quick"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/state/snapshot"
 	"github.com/eth-classic/go-ethereum/core/vm"
 	"github.com/eth-classic/go-ethereum/ethdb"
 	"gopkg.in/check.v1"
@@ -182,6 +183,39 @@ func TestCopyOfCopy(t *testing.T) {
 	}
 }
 
+// Tests that SetStorage replaces the entire storage of an account, that the
+// replacement survives copies and that later writes are reverted by snapshots.
+func TestSetStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.HexToAddress("aaaa")
+	a, b := common.HexToHash("01"), common.HexToHash("02")
+
+	sdb.SetState(addr, a, common.HexToHash("11"))
+	root, _ := sdb.CommitTo(db, false)
+	sdb, _ = New(root, NewDatabase(db))
+
+	sdb.SetStorage(addr, map[common.Hash]common.Hash{b: common.HexToHash("22")})
+	if got := sdb.GetState(addr, a); got != (common.Hash{}) {
+		t.Errorf("replaced slot: have %x, want empty", got)
+	}
+	if got, want := sdb.GetState(addr, b), common.HexToHash("22"); got != want {
+		t.Errorf("overridden slot: have %x, want %x", got, want)
+	}
+	cpy := sdb.Copy()
+
+	snap := sdb.Snapshot()
+	sdb.SetState(addr, b, common.HexToHash("33"))
+	if got, want := cpy.GetState(addr, b), common.HexToHash("22"); got != want {
+		t.Errorf("copied slot: have %x, want %x", got, want)
+	}
+	sdb.RevertToSnapshot(snap)
+	if got, want := sdb.GetState(addr, b), common.HexToHash("22"); got != want {
+		t.Errorf("reverted slot: have %x, want %x", got, want)
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -291,6 +325,20 @@ func newTestAction(addr common.Address, r *rand.Rand) testAction {
 			},
 			args: make([]int64, 1),
 		},
+		{
+			name: "AddAddressToAccessList",
+			fn: func(a testAction, s *StateDB) {
+				s.AddAddressToAccessList(addr)
+			},
+		},
+		{
+			name: "AddSlotToAccessList",
+			fn: func(a testAction, s *StateDB) {
+				s.AddSlotToAccessList(addr,
+					common.Hash{byte(a.args[0])})
+			},
+			args: make([]int64, 1),
+		},
 	}
 	action := actions[r.Intn(len(actions))]
 	var nameargs []string
@@ -396,6 +444,16 @@ func (test *snapshotTest) checkEqual(state, checkstate *StateDB) error {
 		checkeq("GetCode", state.GetCode(addr), checkstate.GetCode(addr))
 		checkeq("GetCodeHash", state.GetCodeHash(addr), checkstate.GetCodeHash(addr))
 		checkeq("GetCodeSize", state.GetCodeSize(addr), checkstate.GetCodeSize(addr))
+		checkeq("AddressInAccessList", state.AddressInAccessList(addr), checkstate.AddressInAccessList(addr))
+		for _, al := range []*accessList{state.accessList, checkstate.accessList} {
+			if idx, ok := al.addresses[addr]; ok && idx >= 0 {
+				for slot := range al.slots[idx] {
+					_, have := state.SlotInAccessList(addr, slot)
+					_, want := checkstate.SlotInAccessList(addr, slot)
+					checkeq("SlotInAccessList("+slot.Hex()+")", have, want)
+				}
+			}
+		}
 		// Check storage.
 		if obj := state.getStateObject(addr); obj != nil {
 			state.ForEachStorage(addr, func(key, val common.Hash) bool {
@@ -437,3 +495,268 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+func TestStateDBAccessList(t *testing.T) {
+	// Some helpers
+	addr := func(a string) common.Address {
+		return common.HexToAddress(a)
+	}
+	slot := func(a string) common.Hash {
+		return common.HexToHash(a)
+	}
+
+	mem, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(mem))
+
+	verifyAddrs := func(astrings ...string) {
+		t.Helper()
+		// convert to common.Address form
+		var addresses []common.Address
+		var addressMap = make(map[common.Address]struct{})
+		for _, astring := range astrings {
+			address := addr(astring)
+			addresses = append(addresses, address)
+			addressMap[address] = struct{}{}
+		}
+		// Check that the given addresses are in the access list
+		for _, address := range addresses {
+			if !state.AddressInAccessList(address) {
+				t.Fatalf("expected %x to be in access list", address)
+			}
+		}
+		// Check that only the expected addresses are present in the acesslist
+		for address := range state.accessList.addresses {
+			if _, exist := addressMap[address]; !exist {
+				t.Fatalf("extra address %x in access list", address)
+			}
+		}
+	}
+	verifySlots := func(addrString string, slotStrings ...string) {
+		if !state.AddressInAccessList(addr(addrString)) {
+			t.Fatalf("scope missing address/slots %v", addrString)
+		}
+		var address = addr(addrString)
+		// convert to common.Hash form
+		var slots []common.Hash
+		var slotMap = make(map[common.Hash]struct{})
+		for _, slotString := range slotStrings {
+			s := slot(slotString)
+			slots = append(slots, s)
+			slotMap[s] = struct{}{}
+		}
+		// Check that the expected items are in the access list
+		for i, s := range slots {
+			if _, slotPresent := state.SlotInAccessList(address, s); !slotPresent {
+				t.Fatalf("input %d: scope missing slot %v (address %v)", i, s, addrString)
+			}
+		}
+		// Check that no extra elements are in the access list
+		index := state.accessList.addresses[address]
+		if index >= 0 {
+			stateSlots := state.accessList.slots[index]
+			for s := range stateSlots {
+				if _, slotPresent := slotMap[s]; !slotPresent {
+					t.Fatalf("scope has extra slot %v (address %v)", s, addrString)
+				}
+			}
+		}
+	}
+
+	state.AddAddressToAccessList(addr("aa"))          // 1
+	state.AddSlotToAccessList(addr("bb"), slot("01")) // 2,3
+	state.AddSlotToAccessList(addr("bb"), slot("02")) // 4
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+
+	// Make a copy
+	stateCopy1 := state.Copy()
+	if exp, got := 4, state.journal.length(); exp != got {
+		t.Fatalf("journal length mismatch: have %d, want %d", got, exp)
+	}
+
+	// same again, should cause no journal entries
+	state.AddSlotToAccessList(addr("bb"), slot("01"))
+	state.AddSlotToAccessList(addr("bb"), slot("02"))
+	state.AddAddressToAccessList(addr("aa"))
+	if exp, got := 4, state.journal.length(); exp != got {
+		t.Fatalf("journal length mismatch: have %d, want %d", got, exp)
+	}
+	// some new ones
+	state.AddSlotToAccessList(addr("bb"), slot("03")) // 5
+	state.AddSlotToAccessList(addr("aa"), slot("01")) // 6
+	state.AddSlotToAccessList(addr("cc"), slot("01")) // 7,8
+	state.AddAddressToAccessList(addr("cc"))
+	if exp, got := 8, state.journal.length(); exp != got {
+		t.Fatalf("journal length mismatch: have %d, want %d", got, exp)
+	}
+
+	verifyAddrs("aa", "bb", "cc")
+	verifySlots("aa", "01")
+	verifySlots("bb", "01", "02", "03")
+	verifySlots("cc", "01")
+
+	// now start rolling back changes
+	state.journal.revert(state, 7)
+	if _, ok := state.SlotInAccessList(addr("cc"), slot("01")); ok {
+		t.Fatalf("slot present, expected missing")
+	}
+	verifyAddrs("aa", "bb", "cc")
+	verifySlots("aa", "01")
+	verifySlots("bb", "01", "02", "03")
+
+	state.journal.revert(state, 6)
+	if state.AddressInAccessList(addr("cc")) {
+		t.Fatalf("addr present, expected missing")
+	}
+	verifyAddrs("aa", "bb")
+	verifySlots("aa", "01")
+	verifySlots("bb", "01", "02", "03")
+
+	state.journal.revert(state, 5)
+	if _, ok := state.SlotInAccessList(addr("aa"), slot("01")); ok {
+		t.Fatalf("slot present, expected missing")
+	}
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02", "03")
+
+	state.journal.revert(state, 4)
+	if _, ok := state.SlotInAccessList(addr("bb"), slot("03")); ok {
+		t.Fatalf("slot present, expected missing")
+	}
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+
+	state.journal.revert(state, 3)
+	if _, ok := state.SlotInAccessList(addr("bb"), slot("02")); ok {
+		t.Fatalf("slot present, expected missing")
+	}
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01")
+
+	state.journal.revert(state, 2)
+	if _, ok := state.SlotInAccessList(addr("bb"), slot("01")); ok {
+		t.Fatalf("slot present, expected missing")
+	}
+	verifyAddrs("aa", "bb")
+
+	state.journal.revert(state, 1)
+	if state.AddressInAccessList(addr("bb")) {
+		t.Fatalf("addr present, expected missing")
+	}
+	verifyAddrs("aa")
+
+	state.journal.revert(state, 0)
+	if state.AddressInAccessList(addr("aa")) {
+		t.Fatalf("addr present, expected missing")
+	}
+	if got, exp := len(state.accessList.addresses), 0; got != exp {
+		t.Fatalf("expected empty, got %d", got)
+	}
+	if got, exp := len(state.accessList.slots), 0; got != exp {
+		t.Fatalf("expected empty, got %d", got)
+	}
+	// Check the copy
+	// Make a copy
+	state = stateCopy1
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+	if got, exp := len(state.accessList.addresses), 2; got != exp {
+		t.Fatalf("expected empty, got %d", got)
+	}
+	if got, exp := len(state.accessList.slots), 1; got != exp {
+		t.Fatalf("expected empty, got %d", got)
+	}
+}
+
+// TestPrepareAccessList tests that preparing the access list of a transaction
+// drops the entries of the previous one.
+func TestPrepareAccessList(t *testing.T) {
+	mem, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(mem))
+
+	var (
+		sender     = common.HexToAddress("0xaa")
+		dst        = common.HexToAddress("0xbb")
+		precompile = common.HexToAddress("0x01")
+		other      = common.HexToAddress("0xcc")
+	)
+	state.AddSlotToAccessList(other, common.Hash{})
+	state.PrepareAccessList(sender, &dst, []common.Address{precompile})
+
+	for _, addr := range []common.Address{sender, dst, precompile} {
+		if !state.AddressInAccessList(addr) {
+			t.Errorf("expected %x to be in access list", addr)
+		}
+	}
+	if state.AddressInAccessList(other) {
+		t.Errorf("expected %x not to be in access list", other)
+	}
+}
+
+// Tests that states opened on a snapshotted root read through the snapshot,
+// and that committing them adds their changes as a new snapshot layer.
+func TestSnapshotReads(t *testing.T) {
+	mem, _ := ethdb.NewMemDatabase()
+	plain := NewDatabase(mem)
+	state, _ := New(common.Hash{}, plain)
+
+	var (
+		addr1 = common.HexToAddress("0x01")
+		addr2 = common.HexToAddress("0x02")
+		key   = common.HexToHash("0x11")
+		val   = common.HexToHash("0x22")
+	)
+	state.AddBalance(addr1, big.NewInt(10))
+	state.SetState(addr1, key, val)
+	state.AddBalance(addr2, big.NewInt(20))
+	state.SetState(addr2, key, val)
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	plain.TrieDB().Reference(root, common.Hash{}) // pinned like the chain does
+	snaps, err := snapshot.New(mem, plain.TrieDB(), root, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	db := NewDatabaseWithSnapshots(plain.TrieDB(), snaps)
+
+	if state, err = New(root, db); err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	if state.snap == nil {
+		t.Fatalf("state not opened on snapshot")
+	}
+	if balance := state.GetBalance(addr1); balance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 10", balance)
+	}
+	if have := state.GetState(addr1, key); have != val {
+		t.Errorf("storage mismatch: have %x, want %x", have, val)
+	}
+	// Change one account and destroy the other
+	state.AddBalance(addr1, big.NewInt(5))
+	state.SetState(addr1, key, common.Hash{})
+	state.Suicide(addr2)
+	newRoot, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if snaps.Snapshot(newRoot) == nil {
+		t.Fatalf("snapshot layer of committed state missing")
+	}
+	state, _ = New(newRoot, db)
+	if balance := state.GetBalance(addr1); balance.Cmp(big.NewInt(15)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 15", balance)
+	}
+	if have := state.GetState(addr1, key); have != (common.Hash{}) {
+		t.Errorf("deleted storage mismatch: have %x, want empty", have)
+	}
+	if state.Exist(addr2) {
+		t.Errorf("destroyed account still exists")
+	}
+	// Recreating the destroyed account must not resurrect its storage
+	state.CreateAccount(addr2)
+	if have := state.GetState(addr2, key); have != (common.Hash{}) {
+		t.Errorf("storage of recreated account mismatch: have %x, want empty", have)
+	}
+}
@@ -45,7 +45,8 @@ var (
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *ChainConfig
-	bc     *BlockChain
+	bc     *BlockChain  // Canonical block chain, nil for stateless execution
+	chain  ChainContext // Source of the headers accessed during execution
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -53,6 +54,7 @@ func NewStateProcessor(config *ChainConfig, bc *BlockChain) *StateProcessor {
 	return &StateProcessor{
 		config: config,
 		bc:     bc,
+		chain:  bc,
 	}
 }
 
@@ -85,7 +87,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 		}
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
 		if UseSputnikVM != "true" {
-			receipt, logs, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
+			receipt, logs, _, err := ApplyTransaction(p.config, p.chain, gp, statedb, header, tx, totalUsedGas)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -93,6 +95,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 			allLogs = append(allLogs, logs...)
 			continue
 		}
+		if p.bc == nil {
+			return nil, nil, nil, errors.New("stateless execution is not supported by the multi-VM processor")
+		}
 		receipt, logs, _, err := ApplyMultiVmTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
 		if err != nil {
 			return nil, nil, nil, err
@@ -110,7 +115,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 //
 // ApplyTransactions returns the generated receipts and vm logs during the
 // execution of the state transition phase.
-func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
+func ApplyTransaction(config *ChainConfig, bc ChainContext, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
 	tx.SetSigner(config.GetSigner(header.Number))
 
 	_, gas, failed, err := ApplyMessage(NewEnv(statedb, config, bc, tx, header), tx, gp)
@@ -128,6 +133,7 @@ func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb
 
 	usedGas.Add(usedGas, gas)
 	receipt := types.NewReceipt(root, usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = new(big.Int).Set(gas)
 	if MessageCreatesContract(tx) {
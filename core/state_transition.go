@@ -21,6 +21,7 @@ import (
 	"math/big"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
 	"github.com/eth-classic/go-ethereum/core/vm"
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
@@ -31,6 +32,9 @@ var (
 	TxGasContractCreation        = big.NewInt(53000) // Per transaction that creates a contract. NOTE: Not payable on data of calls between transactions.
 	TxDataZeroGas                = big.NewInt(4)     // Per byte of data attached to a transaction that equals zero. NOTE: Not payable on data of calls between transactions.
 	TxDataNonZeroGas             = big.NewInt(68)    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
+	TxDataNonZeroGasEIP2028      = big.NewInt(16)    // Per byte of non zero data attached to a transaction after Phoenix (EIP-2028)
+	TxAccessListAddressGas       = big.NewInt(2400)  // Per address specified in an EIP-2930 access list
+	TxAccessListStorageKeyGas    = big.NewInt(1900)  // Per storage key specified in an EIP-2930 access list
 	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for gas")
 )
 
@@ -59,6 +63,7 @@ type StateTransition struct {
 	value         *big.Int
 	data          []byte
 	state         vm.Database
+	vmerr         error // error returned by the EVM execution, if any
 
 	env vm.Environment
 }
@@ -74,6 +79,7 @@ type Message interface {
 
 	Nonce() uint64
 	Data() []byte
+	AccessList() types.AccessList
 }
 
 func MessageCreatesContract(msg Message) bool {
@@ -81,8 +87,8 @@ func MessageCreatesContract(msg Message) bool {
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message
-// with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
+// with the given data and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, homestead, phoenix bool) *big.Int {
 	igas := new(big.Int)
 	if contractCreation && homestead {
 		igas.Set(TxGasContractCreation)
@@ -97,12 +103,22 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
 			}
 		}
 		m := big.NewInt(nz)
-		m.Mul(m, TxDataNonZeroGas)
+		if phoenix {
+			m.Mul(m, TxDataNonZeroGasEIP2028)
+		} else {
+			m.Mul(m, TxDataNonZeroGas)
+		}
 		igas.Add(igas, m)
 		m.SetInt64(int64(len(data)) - nz)
 		m.Mul(m, TxDataZeroGas)
 		igas.Add(igas, m)
 	}
+	if len(accessList) > 0 {
+		m := big.NewInt(int64(len(accessList)))
+		igas.Add(igas, m.Mul(m, TxAccessListAddressGas))
+		m.SetInt64(int64(accessList.StorageKeys()))
+		igas.Add(igas, m.Mul(m, TxAccessListStorageKeyGas))
+	}
 	return igas
 }
 
@@ -219,15 +235,29 @@ func (st *StateTransition) TransitionDb() (ret []byte, gas *big.Int, failed bool
 		sender = st.state.GetAccount(address)
 	}
 	homestead := st.env.RuleSet().IsHomestead(st.env.BlockNumber())
+	phoenix := st.env.RuleSet().IsPhoenix(st.env.BlockNumber())
 	contractCreation := MessageCreatesContract(msg)
 	// Pay intrinsic gas
-	if err = st.useGas(IntrinsicGas(st.data, contractCreation, homestead)); err != nil {
+	if err = st.useGas(IntrinsicGas(st.data, msg.AccessList(), contractCreation, homestead, phoenix)); err != nil {
 		return nil, nil, false, InvalidTxError(err)
 	}
 
+	// Warm the accounts and storage slots accessed by default and those listed
+	// in the transaction's access list (EIP-2929, EIP-2930).
+	if st.env.RuleSet().IsEIP2929(st.env.BlockNumber()) {
+		st.state.PrepareAccessList(address, msg.To(), vm.ActivePrecompileAddresses(st.env.RuleSet(), st.env.BlockNumber()))
+		for _, tuple := range msg.AccessList() {
+			st.state.AddAddressToAccessList(tuple.Address)
+			for _, key := range tuple.StorageKeys {
+				st.state.AddSlotToAccessList(tuple.Address, key)
+			}
+		}
+	}
+
 	vmenv := st.env
 	//var addr common.Address
 	var vmerr error
+	defer func() { st.vmerr = vmerr }()
 	if contractCreation {
 		ret, _, vmerr = vmenv.Create(sender, st.data, st.gas, st.gasPrice, st.value)
 
@@ -262,6 +292,12 @@ func (st *StateTransition) TransitionDb() (ret []byte, gas *big.Int, failed bool
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// Reverted reports whether the executed message was stopped by the REVERT opcode,
+// in which case the data returned by TransitionDb holds the revert reason.
+func (st *StateTransition) Reverted() bool {
+	return st.vmerr == vm.ErrRevert
+}
+
 func (st *StateTransition) refundGas() {
 	// Return eth for remaining gas to the sender account,
 	// exchanged at the original rate.
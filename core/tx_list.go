@@ -0,0 +1,162 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"container/heap"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+)
+
+// priceHeap is a heap.Interface implementation over transactions for retrieving
+// price-sorted transactions to discard when the pool fills up.
+type priceHeap []*types.Transaction
+
+func (h priceHeap) Len() int      { return len(h) }
+func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h priceHeap) Less(i, j int) bool {
+	// Sort primarily by price, returning the cheaper one
+	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	// If the prices match, stabilize via nonces (high nonce is worse)
+	return h[i].Nonce() > h[j].Nonce()
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	*h = append(*h, x.(*types.Transaction))
+}
+
+func (h *priceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// txPricedList is a price-sorted heap to allow operating on transactions pool
+// contents in a price-incrementing way. Removals are lazy: removed transactions
+// stay in the heap as stale entries until they surface or the heap is rebuilt.
+type txPricedList struct {
+	all    map[common.Hash]*types.Transaction // Live transactions tracked by the list
+	items  *priceHeap                         // Heap of prices of all the stored transactions
+	stales int                                // Number of stale price points to (re-heap trigger)
+}
+
+// newTxPricedList creates a new price-sorted transaction heap.
+func newTxPricedList() *txPricedList {
+	return &txPricedList{
+		all:   make(map[common.Hash]*types.Transaction),
+		items: new(priceHeap),
+	}
+}
+
+// Len returns the number of live transactions tracked by the list.
+func (l *txPricedList) Len() int {
+	return len(l.all)
+}
+
+// Put inserts a new transaction into the heap. Known transactions are ignored.
+func (l *txPricedList) Put(tx *types.Transaction) {
+	hash := tx.Hash()
+	if _, ok := l.all[hash]; ok {
+		return
+	}
+	l.all[hash] = tx
+	heap.Push(l.items, tx)
+}
+
+// Removed notifies the priced transaction list that a transaction was removed
+// from the pool. Once the number of stale entries grows past a quarter of the
+// heap, the heap is rebuilt from the live transactions.
+func (l *txPricedList) Removed(hash common.Hash) {
+	if _, ok := l.all[hash]; !ok {
+		return
+	}
+	delete(l.all, hash)
+
+	l.stales++
+	if l.stales <= len(*l.items)/4 {
+		return
+	}
+	reheap := make(priceHeap, 0, len(l.all))
+	for _, tx := range l.all {
+		reheap = append(reheap, tx)
+	}
+	l.stales, l.items = 0, &reheap
+	heap.Init(l.items)
+}
+
+// Underpriced checks whether a transaction is cheaper than (or as cheap as) the
+// lowest priced transaction currently being tracked. Local transactions are
+// never considered underpriced.
+func (l *txPricedList) Underpriced(tx *types.Transaction, local func(*types.Transaction) bool) bool {
+	if local(tx) {
+		return false
+	}
+	// Discard stale price points if found at the heap start
+	for len(*l.items) > 0 {
+		head := (*l.items)[0]
+		if _, ok := l.all[head.Hash()]; !ok {
+			l.stales--
+			heap.Pop(l.items)
+			continue
+		}
+		break
+	}
+	if len(*l.items) == 0 {
+		return false
+	}
+	cheapest := (*l.items)[0]
+	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+}
+
+// Discard finds a number of most underpriced transactions, removes them from
+// the priced list and returns them for further removal from the entire pool.
+// Local transactions are skipped and kept in the list.
+func (l *txPricedList) Discard(count int, local func(*types.Transaction) bool) types.Transactions {
+	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
+	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
+
+	for len(*l.items) > 0 && count > 0 {
+		// Discard stale transactions if found during cleanup
+		tx := heap.Pop(l.items).(*types.Transaction)
+		hash := tx.Hash()
+		if _, ok := l.all[hash]; !ok {
+			l.stales--
+			continue
+		}
+		// Non stale transaction found, discard unless local
+		if local(tx) {
+			save = append(save, tx)
+		} else {
+			delete(l.all, hash)
+			drop = append(drop, tx)
+			count--
+		}
+	}
+	for _, tx := range save {
+		heap.Push(l.items, tx)
+	}
+	return drop
+}
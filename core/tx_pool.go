@@ -17,6 +17,7 @@
 package core
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
 	"math/big"
@@ -43,11 +44,78 @@ var (
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
 	ErrNegativeValue      = errors.New("Negative value")
+	ErrUnderpriced        = errors.New("Transaction underpriced")
+	ErrReplaceUnderpriced = errors.New("Replacement transaction underpriced")
 )
 
-const (
-	maxQueued = 64 // max limit of queued txs per address
-)
+// evictionInterval is the time between checks for expired queued transactions.
+var evictionInterval = time.Minute
+
+// TxPoolConfig are the configuration parameters of the transaction pool.
+type TxPoolConfig struct {
+	PriceBump uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
+
+	AccountSlots uint64 // Minimum number of executable transaction slots guaranteed per account
+	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
+	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
+	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+
+	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Journal   string        // Journal of local transactions to survive node restarts (empty disables)
+	Rejournal time.Duration // Time interval to regenerate the local transaction journal
+}
+
+// DefaultTxPoolConfig contains the default configurations for the transaction
+// pool.
+var DefaultTxPoolConfig = TxPoolConfig{
+	PriceBump: 10,
+
+	AccountSlots: 16,
+	GlobalSlots:  4096,
+	AccountQueue: 64,
+	GlobalQueue:  1024,
+
+	Lifetime: 3 * time.Hour,
+
+	Journal:   "transactions.rlp",
+	Rejournal: time.Hour,
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *TxPoolConfig) sanitize() TxPoolConfig {
+	conf := *config
+	if conf.PriceBump < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool price bump: %d, updated to %d", conf.PriceBump, DefaultTxPoolConfig.PriceBump)
+		conf.PriceBump = DefaultTxPoolConfig.PriceBump
+	}
+	if conf.AccountSlots < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool account slots: %d, updated to %d", conf.AccountSlots, DefaultTxPoolConfig.AccountSlots)
+		conf.AccountSlots = DefaultTxPoolConfig.AccountSlots
+	}
+	if conf.GlobalSlots < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool global slots: %d, updated to %d", conf.GlobalSlots, DefaultTxPoolConfig.GlobalSlots)
+		conf.GlobalSlots = DefaultTxPoolConfig.GlobalSlots
+	}
+	if conf.AccountQueue < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool account queue: %d, updated to %d", conf.AccountQueue, DefaultTxPoolConfig.AccountQueue)
+		conf.AccountQueue = DefaultTxPoolConfig.AccountQueue
+	}
+	if conf.GlobalQueue < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool global queue: %d, updated to %d", conf.GlobalQueue, DefaultTxPoolConfig.GlobalQueue)
+		conf.GlobalQueue = DefaultTxPoolConfig.GlobalQueue
+	}
+	if conf.Lifetime < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool lifetime: %v, updated to %v", conf.Lifetime, DefaultTxPoolConfig.Lifetime)
+		conf.Lifetime = DefaultTxPoolConfig.Lifetime
+	}
+	if conf.Rejournal < time.Second {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool journal time: %v, updated to %v", conf.Rejournal, time.Second)
+		conf.Rejournal = time.Second
+	}
+	return conf
+}
 
 type stateFn func() (*state.StateDB, error)
 
@@ -58,8 +126,14 @@ type stateFn func() (*state.StateDB, error)
 // The pool separates processable transactions (which can be applied to the
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
+//
+// The pool is bounded by its TxPoolConfig: once full, the cheapest remote
+// transactions are evicted to make room for better paying ones, and a pending
+// or queued transaction can only be replaced by one paying a sufficiently
+// higher gas price.
 type TxPool struct {
 	config       *ChainConfig
+	txconfig     TxPoolConfig
 	signer       types.Signer
 	currentState stateFn // The state function which will allow us to do some pre checks
 	pendingState *state.ManagedState
@@ -68,32 +142,58 @@ type TxPool struct {
 	eventMux     *event.TypeMux
 	events       event.Subscription
 	localTx      *txSet
+	localSenders map[common.Address]struct{} // senders of local transactions, local for as long as the pool runs
 	mu           sync.RWMutex
 	pending      map[common.Hash]*types.Transaction // processable transactions
 	queue        map[common.Address]map[common.Hash]*types.Transaction
 
-	wg sync.WaitGroup // for shutdown sync
+	pendingNonces map[common.Address]map[uint64]*types.Transaction // processable transactions by sender and nonce
+	beats         map[common.Address]time.Time                     // last heartbeat from each account with queued transactions
+	priced        *txPricedList                                    // all transactions sorted by price
+
+	journal       *txJournal // journal of local transactions to back up to disk
+	journalLoaded bool       // whether the journal was reloaded into the pool yet
+
+	wg   sync.WaitGroup // for shutdown sync
+	quit chan struct{}
 
 	homestead bool
+	phoenix   bool
+	eip2718   bool
 }
 
-func NewTxPool(config *ChainConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
+func NewTxPool(config *ChainConfig, txconfig TxPoolConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
 	pool := &TxPool{
-		config:       config,
-		signer:       types.NewChainIdSigner(config.GetChainID()),
-		pending:      make(map[common.Hash]*types.Transaction),
-		queue:        make(map[common.Address]map[common.Hash]*types.Transaction),
-		eventMux:     eventMux,
-		currentState: currentStateFn,
-		gasLimit:     gasLimitFn,
-		minGasPrice:  new(big.Int),
-		pendingState: nil,
-		localTx:      newTxSet(),
-		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
-	}
-
-	pool.wg.Add(1)
+		config:        config,
+		txconfig:      txconfig.sanitize(),
+		signer:        types.NewChainIdSigner(config.GetChainID()),
+		pending:       make(map[common.Hash]*types.Transaction),
+		queue:         make(map[common.Address]map[common.Hash]*types.Transaction),
+		pendingNonces: make(map[common.Address]map[uint64]*types.Transaction),
+		beats:         make(map[common.Address]time.Time),
+		priced:        newTxPricedList(),
+		eventMux:      eventMux,
+		currentState:  currentStateFn,
+		gasLimit:      gasLimitFn,
+		minGasPrice:   new(big.Int),
+		pendingState:  nil,
+		localTx:       newTxSet(),
+		localSenders:  make(map[common.Address]struct{}),
+		events:        eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
+		quit:          make(chan struct{}),
+	}
+	if pool.txconfig.Journal != "" {
+		// Open the journal right away so that local transactions added before
+		// the first chain head (when the old journal is reloaded) are kept too
+		pool.journal = newTxJournal(pool.txconfig.Journal)
+		if err := pool.journal.open(); err != nil {
+			glog.V(logger.Warn).Infof("Failed to open local tx journal: %v", err)
+		}
+	}
+
+	pool.wg.Add(2)
 	go pool.eventLoop()
+	go pool.maintenanceLoop()
 
 	return pool
 }
@@ -111,8 +211,18 @@ func (pool *TxPool) eventLoop() {
 			if ev.Block != nil && pool.config.IsHomestead(ev.Block.Number()) {
 				pool.homestead = true
 			}
+			if ev.Block != nil && pool.config.IsPhoenix(ev.Block.Number()) {
+				pool.phoenix = true
+			}
+			if ev.Block != nil && pool.config.IsEIP2718(ev.Block.Number()) && !pool.eip2718 {
+				pool.eip2718 = true
+				pool.signer = types.NewAccessListSigner(pool.config.GetChainID())
+			}
 
 			pool.resetState()
+			if ev.Block != nil && pool.journal != nil && !pool.journalLoaded {
+				pool.loadJournal()
+			}
 			pool.mu.Unlock()
 		case GasPriceChanged:
 			pool.mu.Lock()
@@ -124,6 +234,130 @@ func (pool *TxPool) eventLoop() {
 	}
 }
 
+// maintenanceLoop periodically drops the queued transactions of accounts that
+// have been inactive for longer than the configured lifetime, and regenerates
+// the local transaction journal.
+func (pool *TxPool) maintenanceLoop() {
+	defer pool.wg.Done()
+
+	evict := time.NewTicker(evictionInterval)
+	defer evict.Stop()
+
+	journal := time.NewTicker(pool.txconfig.Rejournal)
+	defer journal.Stop()
+
+	for {
+		select {
+		case <-evict.C:
+			pool.mu.Lock()
+			pool.expireQueue()
+			pool.mu.Unlock()
+		case <-journal.C:
+			pool.mu.Lock()
+			if pool.journal != nil && pool.journalLoaded {
+				if err := pool.journal.rotate(pool.locals()); err != nil {
+					glog.V(logger.Warn).Infof("Failed to rotate local tx journal: %v", err)
+				}
+			}
+			pool.mu.Unlock()
+		case <-pool.quit:
+			return
+		}
+	}
+}
+
+// loadJournal reloads the local transactions journaled by a previous run into
+// the pool, re-validating them against the current state, and starts a fresh
+// journal with the ones that were accepted. It is deferred until the first chain
+// head is known, so transactions are checked against the active fork rules.
+func (pool *TxPool) loadJournal() {
+	pool.journalLoaded = true
+
+	err := pool.journal.load(func(tx *types.Transaction) error {
+		pool.markLocal(tx)
+		return pool.add(tx)
+	})
+	if err != nil {
+		glog.V(logger.Warn).Infof("Failed to load local tx journal: %v", err)
+	}
+	pool.checkQueue()
+
+	if err := pool.journal.rotate(pool.locals()); err != nil {
+		glog.V(logger.Warn).Infof("Failed to rotate local tx journal: %v", err)
+	}
+}
+
+// markLocal marks a transaction and its sender as local. Any transaction of a
+// local sender is treated as local too, so the mark doesn't expire.
+func (pool *TxPool) markLocal(tx *types.Transaction) {
+	pool.localTx.add(tx.Hash())
+	if from, err := types.Sender(pool.signer, tx); err == nil {
+		pool.localSenders[from] = struct{}{}
+	}
+}
+
+// isLocal reports whether a transaction was marked local, or was sent from an
+// account which sent local transactions before.
+func (pool *TxPool) isLocal(tx *types.Transaction) bool {
+	if pool.localTx.contains(tx.Hash()) {
+		return true
+	}
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return false
+	}
+	_, ok := pool.localSenders[from]
+	return ok
+}
+
+// locals returns all local transactions currently in the pool, ordered by nonce.
+func (pool *TxPool) locals() types.Transactions {
+	var txs types.Transactions
+	for _, tx := range pool.pending {
+		if pool.isLocal(tx) {
+			txs = append(txs, tx)
+		}
+	}
+	for _, queued := range pool.queue {
+		for _, tx := range queued {
+			if pool.isLocal(tx) {
+				txs = append(txs, tx)
+			}
+		}
+	}
+	sort.Sort(types.TxByNonce(txs))
+	return txs
+}
+
+// expireQueue removes all remote queued transactions of accounts whose last
+// heartbeat is older than the configured lifetime.
+func (pool *TxPool) expireQueue() {
+	for addr, beat := range pool.beats {
+		txs, ok := pool.queue[addr]
+		if !ok {
+			delete(pool.beats, addr)
+			continue
+		}
+		if time.Since(beat) <= pool.txconfig.Lifetime {
+			continue
+		}
+		for hash, tx := range txs {
+			if pool.isLocal(tx) {
+				continue
+			}
+			if glog.V(logger.Debug) {
+				glog.Infof("Queued tx %x expired", hash[:4])
+			}
+			delete(txs, hash)
+			pool.priced.Removed(hash)
+		}
+		if len(txs) == 0 {
+			delete(pool.queue, addr)
+			delete(pool.beats, addr)
+		}
+	}
+}
+
 func (pool *TxPool) lockedReset() {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
@@ -168,7 +402,12 @@ func (pool *TxPool) resetState() {
 
 func (pool *TxPool) Stop() {
 	pool.events.Unsubscribe()
+	close(pool.quit)
 	pool.wg.Wait()
+
+	if pool.journal != nil {
+		pool.journal.close()
+	}
 	glog.V(logger.Info).Infoln("Transaction pool stopped")
 }
 
@@ -190,6 +429,11 @@ func (pool *TxPool) Stats() (pending int, queued int) {
 	return
 }
 
+// Config returns the sanitized limits the transaction pool operates with.
+func (pool *TxPool) Config() TxPoolConfig {
+	return pool.txconfig
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and nonce.
 func (pool *TxPool) Content() (map[common.Address]map[uint64][]*types.Transaction, map[common.Address]map[uint64][]*types.Transaction) {
@@ -225,13 +469,13 @@ func (pool *TxPool) Content() (map[common.Address]map[uint64][]*types.Transactio
 func (pool *TxPool) SetLocal(tx *types.Transaction) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
-	pool.localTx.add(tx.Hash())
+	pool.markLocal(tx)
 }
 
 // validateTx checks whether a transaction is valid according
 // to the consensus rules.
 func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
-	local := pool.localTx.contains(tx.Hash())
+	local := pool.isLocal(tx)
 	defer func() {
 		mlogTxPoolValidateTx.AssignDetails(
 			tx.Hash().Hex(),
@@ -244,6 +488,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 			e,
 		).Send(mlogTxPool)
 	}()
+	// Reject typed transactions until EIP-2718 is enabled
+	if tx.Type() != types.LegacyTxType && !pool.eip2718 {
+		e = types.ErrTxTypeNotSupported
+		return
+	}
 	// Drop transactions under our own minimal accepted gas price
 	if !local && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
 		e = ErrCheap
@@ -297,7 +546,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 		return
 	}
 
-	intrGas := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
+	intrGas := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, pool.homestead, pool.phoenix)
 	if tx.Gas().Cmp(intrGas) < 0 {
 		e = ErrIntrinsicGas
 		return
@@ -316,8 +565,53 @@ func (self *TxPool) add(tx *types.Transaction) error {
 	if err != nil {
 		return err
 	}
+	sender, _ := types.Sender(self.signer, tx) // already validated
+	if self.queue[sender][hash] != nil {
+		return fmt.Errorf("Known transaction (%x)", hash[:4])
+	}
+	// If the transaction replaces an existing one with the same nonce, it
+	// must pay a high enough price bump over the old one
+	old := self.nonceTx(sender, tx.Nonce())
+	if old != nil {
+		threshold := new(big.Int).Mul(old.GasPrice(), big.NewInt(100+int64(self.txconfig.PriceBump)))
+		threshold.Div(threshold, big.NewInt(100))
+		if old.GasPrice().Cmp(tx.GasPrice()) >= 0 || threshold.Cmp(tx.GasPrice()) > 0 {
+			return ErrReplaceUnderpriced
+		}
+	}
+	// If the transaction pool is full, discard underpriced transactions. A
+	// replacement doesn't grow the pool, so it needs no room made.
+	if capacity := int(self.txconfig.GlobalSlots + self.txconfig.GlobalQueue); old == nil && !self.isLocal(tx) && self.priced.Len() >= capacity {
+		// If the new transaction is underpriced, don't accept it
+		if self.priced.Underpriced(tx, self.isLocal) {
+			if glog.V(logger.Debug) {
+				glog.Infof("Discarding underpriced tx %x (price %v)", hash[:4], tx.GasPrice())
+			}
+			return ErrUnderpriced
+		}
+		// New transaction is better than our worse ones, make room for it
+		for _, drop := range self.priced.Discard(self.priced.Len()-capacity+1, self.isLocal) {
+			if glog.V(logger.Debug) {
+				glog.Infof("Discarding freshly underpriced tx %x (price %v)", drop.Hash().Bytes()[:4], drop.GasPrice())
+			}
+			self.evictTx(drop)
+		}
+	}
+	if old != nil {
+		if glog.V(logger.Debug) {
+			glog.Infof("Replacing tx %x with %x (nonce %d)", old.Hash().Bytes()[:4], hash[:4], tx.Nonce())
+		}
+		self.removeTx(old.Hash())
+	}
 	self.queueTx(hash, tx)
 
+	// Back up local transactions to disk to survive restarts
+	if self.journal != nil && self.isLocal(tx) {
+		if err := self.journal.insert(tx); err != nil && err != errNoActiveJournal {
+			glog.V(logger.Warn).Infof("Failed to journal local tx %x: %v", hash[:4], err)
+		}
+	}
+
 	var toName, toLogName string
 	if to := tx.To(); to != nil {
 		toName = common.Bytes2Hex(to[:4])
@@ -353,6 +647,22 @@ func (self *TxPool) queueTx(hash common.Hash, tx *types.Transaction) {
 		self.queue[from] = make(map[common.Hash]*types.Transaction)
 	}
 	self.queue[from][hash] = tx
+	self.beats[from] = time.Now()
+	self.priced.Put(tx)
+}
+
+// nonceTx returns the pending or queued transaction of an account with the
+// given nonce, or nil if there is none.
+func (pool *TxPool) nonceTx(addr common.Address, nonce uint64) *types.Transaction {
+	if tx := pool.pendingNonces[addr][nonce]; tx != nil {
+		return tx
+	}
+	for _, tx := range pool.queue[addr] {
+		if tx.Nonce() == nonce {
+			return tx
+		}
+	}
+	return nil
 }
 
 // addTx will add a transaction to the pending (processable queue) list of transactions
@@ -364,10 +674,17 @@ func (pool *TxPool) addTx(hash common.Hash, addr common.Address, tx *types.Trans
 
 	if _, ok := pool.pending[hash]; !ok {
 		pool.pending[hash] = tx
+		if pool.pendingNonces[addr] == nil {
+			pool.pendingNonces[addr] = make(map[uint64]*types.Transaction)
+		}
+		pool.pendingNonces[addr][tx.Nonce()] = tx
+		pool.priced.Put(tx)
 
-		// Increment the nonce on the pending state. This can only happen if
-		// the nonce is +1 to the previous one.
-		pool.pendingState.SetNonce(addr, tx.Nonce()+1)
+		// Increment the nonce on the pending state. A replacement of a pending
+		// transaction below the highest one must not lower it.
+		if pool.pendingState.GetNonce(addr) <= tx.Nonce() {
+			pool.pendingState.SetNonce(addr, tx.Nonce()+1)
+		}
 		// Notify the subscribers. This event is posted in a goroutine
 		// because it's possible that somewhere during the post "Remove transaction"
 		// gets called which will then wait for the global tx pool lock and deadlock.
@@ -476,8 +793,11 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 }
 
 func (pool *TxPool) removeTx(hash common.Hash) {
+	pool.priced.Removed(hash)
 	// delete from pending pool
-	delete(pool.pending, hash)
+	if tx, ok := pool.pending[hash]; ok {
+		pool.dropPending(hash, tx)
+	}
 	// delete from queue
 	for address, txs := range pool.queue {
 		if _, ok := txs[hash]; ok {
@@ -492,6 +812,41 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	}
 }
 
+// dropPending removes a transaction from the pending set and its nonce index.
+func (pool *TxPool) dropPending(hash common.Hash, tx *types.Transaction) {
+	delete(pool.pending, hash)
+
+	addr, _ := tx.From()
+	if nonces := pool.pendingNonces[addr]; nonces[tx.Nonce()] == tx {
+		delete(nonces, tx.Nonce())
+		if len(nonces) == 0 {
+			delete(pool.pendingNonces, addr)
+		}
+	}
+}
+
+// evictTx removes a transaction from the pool to make room for others. If the
+// transaction was pending, all subsequent pending transactions of the account
+// become unexecutable and are moved back into the future queue.
+func (pool *TxPool) evictTx(tx *types.Transaction) {
+	hash := tx.Hash()
+	if _, ok := pool.pending[hash]; !ok {
+		pool.removeTx(hash)
+		return
+	}
+	addr, _ := tx.From()
+	pool.removeTx(hash)
+	for nonce, next := range pool.pendingNonces[addr] {
+		if nonce > tx.Nonce() {
+			pool.queueTx(next.Hash(), next)
+			pool.dropPending(next.Hash(), next)
+		}
+	}
+	if pool.pendingState != nil && pool.pendingState.GetNonce(addr) > tx.Nonce() {
+		pool.pendingState.SetNonce(addr, tx.Nonce())
+	}
+}
+
 // checkQueue moves transactions that have become processable to main pool.
 func (pool *TxPool) checkQueue() {
 	// init delayed since tx pool could have been started before any state sync
@@ -520,6 +875,7 @@ func (pool *TxPool) checkQueue() {
 					glog.Infof("removed tx (%v) from pool queue: low tx nonce or out of funds\n", tx)
 				}
 				delete(txs, hash)
+				pool.priced.Removed(hash)
 				continue
 			}
 			// Collect the remaining transactions for the next pass.
@@ -531,12 +887,13 @@ func (pool *TxPool) checkQueue() {
 		for i, entry := range promote {
 			// If we reached a gap in the nonces, enforce transaction limit and stop
 			if entry.Nonce() > guessedNonce {
-				if len(promote)-i > maxQueued {
+				if limit := int(pool.txconfig.AccountQueue); len(promote)-i > limit {
 					if glog.V(logger.Debug) {
 						glog.Infof("Queued tx limit exceeded for %s. Tx %s removed\n", common.PP(address[:]), common.PP(entry.hash[:]))
 					}
-					for _, drop := range promote[i+maxQueued:] {
+					for _, drop := range promote[i+limit:] {
 						delete(txs, drop.hash)
+						pool.priced.Removed(drop.hash)
 					}
 				}
 				break
@@ -554,6 +911,101 @@ func (pool *TxPool) checkQueue() {
 			delete(pool.queue, address)
 		}
 	}
+	pool.enforceLimits()
+}
+
+// enforceLimits trims the pool down to its global capacity. If there are more
+// pending transactions than global slots, the accounts exceeding their own slot
+// allowance are cut back starting with the largest; if there are more queued
+// transactions than the global queue allows, the queues of the longest inactive
+// accounts are dropped first.
+func (pool *TxPool) enforceLimits() {
+	if pending := uint64(len(pool.pending)); pending > pool.txconfig.GlobalSlots {
+		// Gather the accounts above their allowance, highest nonces first
+		offenders := make(accountsByPending, 0)
+		for addr, nonces := range pool.pendingNonces {
+			if uint64(len(nonces)) <= pool.txconfig.AccountSlots {
+				continue
+			}
+			txs := make(types.Transactions, 0, len(nonces))
+			for _, tx := range nonces {
+				txs = append(txs, tx)
+			}
+			sort.Sort(sort.Reverse(types.TxByNonce(txs)))
+			offenders = append(offenders, &accountPending{addr, txs})
+		}
+		heap.Init(&offenders)
+
+		// Repeatedly cut the last transaction of the largest offender
+		for pending > pool.txconfig.GlobalSlots && offenders.Len() > 0 {
+			offender := offenders[0]
+			last := offender.txs[0]
+			if pool.isLocal(last) {
+				heap.Pop(&offenders)
+				continue
+			}
+			if glog.V(logger.Debug) {
+				glog.Infof("Pending tx limit exceeded for %s. Tx %x removed\n", common.PP(offender.address[:]), last.Hash().Bytes()[:4])
+			}
+			// The highest nonce has no successors to postpone, so drop it directly
+			pool.dropPending(last.Hash(), last)
+			pool.priced.Removed(last.Hash())
+			if pool.pendingState != nil && pool.pendingState.GetNonce(offender.address) > last.Nonce() {
+				pool.pendingState.SetNonce(offender.address, last.Nonce())
+			}
+			pending--
+
+			if offender.txs = offender.txs[1:]; uint64(len(offender.txs)) > pool.txconfig.AccountSlots {
+				heap.Fix(&offenders, 0)
+			} else {
+				heap.Pop(&offenders)
+			}
+		}
+	}
+
+	queued := uint64(0)
+	for _, txs := range pool.queue {
+		queued += uint64(len(txs))
+	}
+	if queued <= pool.txconfig.GlobalQueue {
+		return
+	}
+	// Sort all accounts with queued transactions by heartbeat, oldest first
+	addresses := make(addressesByHeartbeat, 0, len(pool.queue))
+	for addr := range pool.queue {
+		addresses = append(addresses, addressByHeartbeat{addr, pool.beats[addr]})
+	}
+	sort.Sort(addresses)
+
+	for _, entry := range addresses {
+		if queued <= pool.txconfig.GlobalQueue {
+			break
+		}
+		txs := pool.queue[entry.address]
+
+		// Drop the highest nonces first so the remainder stays promotable
+		drops := make(types.Transactions, 0, len(txs))
+		for _, tx := range txs {
+			if !pool.isLocal(tx) {
+				drops = append(drops, tx)
+			}
+		}
+		sort.Sort(sort.Reverse(types.TxByNonce(drops)))
+		for _, tx := range drops {
+			if queued <= pool.txconfig.GlobalQueue {
+				break
+			}
+			if glog.V(logger.Debug) {
+				glog.Infof("Queue limit exceeded for %s. Tx %x removed\n", common.PP(entry.address[:]), tx.Hash().Bytes()[:4])
+			}
+			delete(txs, tx.Hash())
+			pool.priced.Removed(tx.Hash())
+			queued--
+		}
+		if len(txs) == 0 {
+			delete(pool.queue, entry.address)
+		}
+	}
 }
 
 // validatePool removes invalid and processed transactions from the main pool.
@@ -586,7 +1038,8 @@ func (pool *TxPool) validatePool() {
 			if glog.V(logger.Core) {
 				glog.Infof("removed tx (%v) from pool: low tx nonce or out of funds\n", tx)
 			}
-			delete(pool.pending, hash)
+			pool.dropPending(hash, tx)
+			pool.priced.Removed(hash)
 
 			// Track the smallest invalid nonce to postpone subsequent transactions
 			if !past {
@@ -605,7 +1058,7 @@ func (pool *TxPool) validatePool() {
 					glog.Infof("postponed tx (%v) due to introduced gap\n", tx)
 				}
 				pool.queueTx(hash, tx)
-				delete(pool.pending, hash)
+				pool.dropPending(hash, tx)
 			}
 		}
 	}
@@ -623,6 +1076,44 @@ func (q txQueue) Len() int           { return len(q) }
 func (q txQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
 func (q txQueue) Less(i, j int) bool { return q[i].Nonce() < q[j].Nonce() }
 
+// addressByHeartbeat is an account address tagged with its last activity timestamp.
+type addressByHeartbeat struct {
+	address   common.Address
+	heartbeat time.Time
+}
+
+type addressesByHeartbeat []addressByHeartbeat
+
+func (a addressesByHeartbeat) Len() int           { return len(a) }
+func (a addressesByHeartbeat) Less(i, j int) bool { return a[i].heartbeat.Before(a[j].heartbeat) }
+func (a addressesByHeartbeat) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// accountPending is an account's pending transactions, ordered by descending nonce.
+type accountPending struct {
+	address common.Address
+	txs     types.Transactions
+}
+
+// accountsByPending is a heap.Interface implementation over accounts for
+// retrieving the one with the most pending transactions.
+type accountsByPending []*accountPending
+
+func (a accountsByPending) Len() int           { return len(a) }
+func (a accountsByPending) Less(i, j int) bool { return len(a[i].txs) > len(a[j].txs) }
+func (a accountsByPending) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+func (a *accountsByPending) Push(x interface{}) {
+	*a = append(*a, x.(*accountPending))
+}
+
+func (a *accountsByPending) Pop() interface{} {
+	old := *a
+	n := len(old)
+	x := old[n-1]
+	*a = old[0 : n-1]
+	return x
+}
+
 // txSet represents a set of transaction hashes in which entries
 //  are automatically dropped after txSetDuration time
 type txSet struct {
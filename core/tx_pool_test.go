@@ -18,8 +18,12 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core/state"
@@ -30,17 +34,28 @@ import (
 )
 
 func transaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
-	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, big.NewInt(1), nil).SignECDSA(key)
+	return pricedTransaction(nonce, gaslimit, big.NewInt(1), key)
+}
+
+func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, gasprice, nil).SignECDSA(key)
 	return tx
 }
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(DefaultTxPoolConfig)
+}
+
+func setupTxPoolWithConfig(config TxPoolConfig) (*TxPool, *ecdsa.PrivateKey) {
+	// Keep the pool from journaling into the working directory
+	config.Journal = ""
+
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 
 	var m event.TypeMux
 	key, _ := crypto.GenerateKey()
-	newPool := NewTxPool(testChainConfig(), &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	newPool := NewTxPool(testChainConfig(), config, &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	newPool.lockedReset()
 	return newPool, key
 }
@@ -89,6 +104,34 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+func TestTypedTransactions(t *testing.T) {
+	pool, key := setupTxPool()
+
+	accessList := types.AccessList{{Address: common.Address{1}, StorageKeys: []common.Hash{{1}}}}
+	newTx := func(gas int64) *types.Transaction {
+		tx, _ := types.NewAccessListTransaction(pool.config.GetChainID(), 0, &common.Address{}, big.NewInt(100), big.NewInt(gas), big.NewInt(1), nil, accessList).SignECDSA(key)
+		return tx
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(0xffffffffffffff))
+
+	if err := pool.Add(newTx(100000)); err != types.ErrTxTypeNotSupported {
+		t.Error("expected", types.ErrTxTypeNotSupported, "got", err)
+	}
+
+	pool.eip2718 = true
+	pool.signer = types.NewAccessListSigner(pool.config.GetChainID())
+
+	// intrinsic gas is 21000 + 2400 per address + 1900 per storage key
+	if err := pool.Add(newTx(25299)); err != ErrIntrinsicGas {
+		t.Error("expected", ErrIntrinsicGas, "got", err)
+	}
+	if err := pool.Add(newTx(25300)); err != nil {
+		t.Error("didn't expect error", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	pool, key := setupTxPool()
 	tx := transaction(0, big.NewInt(100), key)
@@ -216,16 +259,27 @@ func TestTransactionDoubleNonce(t *testing.T) {
 
 	tx := transaction(0, big.NewInt(100000), key)
 	tx2 := transaction(0, big.NewInt(1000000), key)
+	tx3 := pricedTransaction(0, big.NewInt(1000000), big.NewInt(2), key)
 	if err := pool.add(tx); err != nil {
 		t.Error("didn't expect error", err)
 	}
-	if err := pool.add(tx2); err != nil {
+	pool.checkQueue()
+	if err := pool.add(tx2); err != ErrReplaceUnderpriced {
+		t.Errorf("same priced replacement: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.add(tx3); err != nil {
 		t.Error("didn't expect error", err)
 	}
 
 	pool.checkQueue()
-	if len(pool.pending) != 2 {
-		t.Error("expected 2 pending txs. Got", len(pool.pending))
+	if len(pool.pending) != 1 {
+		t.Error("expected 1 pending tx. Got", len(pool.pending))
+	}
+	if pool.pending[tx3.Hash()] == nil {
+		t.Error("expected higher priced replacement to be pending")
+	}
+	if pool.priced.Len() != 1 {
+		t.Errorf("priced list size mismatch: have %d, want %d", pool.priced.Len(), 1)
 	}
 }
 
@@ -410,20 +464,20 @@ func TestTransactionQueueLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(1); i <= maxQueued+5; i++ {
+	for i := uint64(1); i <= DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
 		if len(pool.pending) != 0 {
 			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, len(pool.pending), 0)
 		}
-		if i <= maxQueued {
+		if i <= DefaultTxPoolConfig.AccountQueue {
 			if len(pool.queue[account]) != int(i) {
 				t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, len(pool.queue[account]), i)
 			}
 		} else {
-			if len(pool.queue[account]) != maxQueued {
-				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, len(pool.queue[account]), maxQueued)
+			if len(pool.queue[account]) != int(DefaultTxPoolConfig.AccountQueue) {
+				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, len(pool.queue[account]), int(DefaultTxPoolConfig.AccountQueue))
 			}
 		}
 	}
@@ -441,7 +495,7 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -466,7 +520,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state1, _ := pool1.currentState()
 	state1.AddBalance(account1, big.NewInt(1000000))
 
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool1.Add(transaction(origin+i, big.NewInt(100000), key1)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -478,7 +532,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state2.AddBalance(account2, big.NewInt(1000000))
 
 	txns := []*types.Transaction{}
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		txns = append(txns, transaction(origin+i, big.NewInt(100000), key2))
 	}
 	pool2.AddTransactions(txns)
@@ -492,6 +546,373 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	}
 }
 
+// Tests that pending and queued transactions can only be replaced by another one
+// paying at least the configured price bump more.
+func TestTransactionReplacement(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000000))
+
+	// Replace a pending and a queued transaction, checking the bump threshold
+	for _, nonce := range []uint64{0, 2} {
+		if err := pool.Add(pricedTransaction(nonce, big.NewInt(100000), big.NewInt(100), key)); err != nil {
+			t.Fatalf("nonce %d: failed to add original transaction: %v", nonce, err)
+		}
+		if err := pool.Add(pricedTransaction(nonce, big.NewInt(100001), big.NewInt(109), key)); err != ErrReplaceUnderpriced {
+			t.Fatalf("nonce %d: underpriced replacement error mismatch: have %v, want %v", nonce, err, ErrReplaceUnderpriced)
+		}
+		replacement := pricedTransaction(nonce, big.NewInt(100000), big.NewInt(110), key)
+		if err := pool.Add(replacement); err != nil {
+			t.Fatalf("nonce %d: failed to replace transaction: %v", nonce, err)
+		}
+		if pool.GetTransaction(replacement.Hash()) == nil {
+			t.Fatalf("nonce %d: replacement transaction missing", nonce)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Errorf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
+	}
+}
+
+// Tests that replacing a pending transaction below the highest pending nonce
+// keeps the pending nonce of the account, so new transactions don't reuse it.
+func TestTransactionReplacementMiddleNonce(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000000))
+
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.Add(pricedTransaction(nonce, big.NewInt(100000), big.NewInt(100), key)); err != nil {
+			t.Fatalf("nonce %d: failed to add transaction: %v", nonce, err)
+		}
+	}
+	replacement := pricedTransaction(1, big.NewInt(100000), big.NewInt(110), key)
+	if err := pool.Add(replacement); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if pool.pending[replacement.Hash()] == nil {
+		t.Errorf("replacement transaction not pending")
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 0 {
+		t.Errorf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 3, 0)
+	}
+	if nonce := pool.State().GetNonce(account); nonce != 3 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 3)
+	}
+}
+
+// Tests that replacing a transaction in a full pool doesn't evict any other
+// transaction, whether the replacement is accepted or not.
+func TestTransactionReplacementFullPool(t *testing.T) {
+	config := DefaultTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+
+	pool, key := setupTxPoolWithConfig(config)
+	other, _ := crypto.GenerateKey()
+
+	state, _ := pool.currentState()
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		state.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000))
+	}
+	// Fill the pool with the cheapest transaction belonging to another account
+	txs := types.Transactions{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), other),
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(100), key),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(100), key),
+		pricedTransaction(2, big.NewInt(100000), big.NewInt(100), key),
+	}
+	for i, tx := range txs {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.Add(pricedTransaction(1, big.NewInt(100000), big.NewInt(101), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("underpriced replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if pool.GetTransaction(txs[0].Hash()) == nil {
+		t.Errorf("rejected replacement evicted another transaction")
+	}
+	if err := pool.Add(pricedTransaction(1, big.NewInt(100000), big.NewInt(110), key)); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if pool.GetTransaction(txs[0].Hash()) == nil {
+		t.Errorf("accepted replacement evicted another transaction")
+	}
+	if pending, queued := pool.Stats(); pending+queued != len(txs) {
+		t.Errorf("pool size mismatch: have %d, want %d", pending+queued, len(txs))
+	}
+}
+
+// Tests that if the transaction count belonging to multiple accounts go above
+// the global pending limit, the largest accounts are cut back to their slots.
+func TestTransactionPendingGlobalLimiting(t *testing.T) {
+	config := DefaultTxPoolConfig
+	config.AccountSlots = 4
+	config.GlobalSlots = 12
+
+	pool, _ := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Generate transactions with an uneven distribution across the accounts
+	txs := types.Transactions{}
+	for i, count := range []uint64{2, 6, 10} {
+		for nonce := uint64(0); nonce < count; nonce++ {
+			txs = append(txs, transaction(nonce, big.NewInt(100000), keys[i]))
+		}
+	}
+	pool.AddTransactions(txs)
+
+	if pending := uint64(len(pool.pending)); pending != config.GlobalSlots {
+		t.Fatalf("pending transactions overflow allowance: %d > %d", pending, config.GlobalSlots)
+	}
+	// The small account must be left alone, the others trimmed evenly
+	for i, want := range []int{2, 5, 5} {
+		addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+		if have := len(pool.pendingNonces[addr]); have != want {
+			t.Errorf("account %d: pending count mismatch: have %d, want %d", i, have, want)
+		}
+		if nonce := pool.pendingState.GetNonce(addr); nonce != uint64(want) {
+			t.Errorf("account %d: pending nonce mismatch: have %d, want %d", i, nonce, want)
+		}
+	}
+}
+
+// Tests that if the transaction count belonging to multiple accounts go above
+// the global queue limit, the least recently active accounts are dropped first.
+func TestTransactionQueueGlobalLimiting(t *testing.T) {
+	config := DefaultTxPoolConfig
+	config.GlobalQueue = 10
+
+	pool, _ := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Queue up a batch of future transactions per account, one account at a time
+	for _, key := range keys {
+		for nonce := uint64(1); nonce <= 5; nonce++ {
+			if err := pool.Add(transaction(nonce, big.NewInt(100000), key)); err != nil {
+				t.Fatalf("failed to add transaction: %v", err)
+			}
+		}
+	}
+	if _, queued := pool.Stats(); uint64(queued) != config.GlobalQueue {
+		t.Fatalf("queued transactions mismatch: have %d, want %d", queued, config.GlobalQueue)
+	}
+	if pool.priced.Len() != int(config.GlobalQueue) {
+		t.Errorf("priced list size mismatch: have %d, want %d", pool.priced.Len(), config.GlobalQueue)
+	}
+	// The oldest account must have been dropped to make room for the newer ones
+	if txs := pool.queue[crypto.PubkeyToAddress(keys[0].PublicKey)]; len(txs) != 0 {
+		t.Errorf("oldest account queue not dropped: %d transactions left", len(txs))
+	}
+}
+
+// Tests that queued transactions of inactive accounts are dropped after the
+// configured lifetime, unless they are local.
+func TestTransactionQueueTimeLimiting(t *testing.T) {
+	config := DefaultTxPoolConfig
+	config.Lifetime = time.Second
+
+	pool, key := setupTxPoolWithConfig(config)
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000000))
+
+	remoteKey, _ := crypto.GenerateKey()
+	remoteAccount := crypto.PubkeyToAddress(remoteKey.PublicKey)
+	state.AddBalance(remoteAccount, big.NewInt(1000000000))
+
+	local := transaction(1, big.NewInt(100000), key)
+	remote := transaction(1, big.NewInt(100000), remoteKey)
+	pool.SetLocal(local)
+	if err := pool.Add(local); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.Add(remote); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.mu.Lock()
+	pool.expireQueue()
+	pool.mu.Unlock()
+	if _, queued := pool.Stats(); queued != 2 {
+		t.Fatalf("queued transactions mismatch before expiry: have %d, want %d", queued, 2)
+	}
+	pool.mu.Lock()
+	pool.beats[account] = time.Now().Add(-2 * config.Lifetime)
+	pool.beats[remoteAccount] = time.Now().Add(-2 * config.Lifetime)
+	pool.expireQueue()
+	pool.mu.Unlock()
+
+	if pool.GetTransaction(remote.Hash()) != nil {
+		t.Errorf("expired remote transaction still queued")
+	}
+	if pool.GetTransaction(local.Hash()) == nil {
+		t.Errorf("local transaction expired")
+	}
+}
+
+// Tests that when the pool is full, cheaper transactions are rejected and more
+// expensive ones evict the cheapest remote transactions.
+func TestTransactionPoolUnderpricing(t *testing.T) {
+	config := DefaultTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+
+	pool, _ := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Fill the pool with pending and queued transactions
+	txs := types.Transactions{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(2), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(2), keys[1]),
+	}
+	for _, tx := range txs {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	local := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[2])
+	pool.SetLocal(local)
+	if err := pool.Add(local); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	// Ensure that adding an underpriced remote transaction fails
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(1), keys[1])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Ensure that adding a better transaction evicts the cheapest remote one,
+	// demoting the pending transactions after it
+	better := pricedTransaction(0, big.NewInt(100000), big.NewInt(3), keys[1])
+	if err := pool.Add(better); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if pool.GetTransaction(txs[0].Hash()) != nil {
+		t.Errorf("cheapest transaction not evicted")
+	}
+	if pool.pending[txs[1].Hash()] != nil {
+		t.Errorf("transaction after evicted nonce still pending")
+	}
+	if pool.pending[better.Hash()] == nil || pool.pending[txs[2].Hash()] == nil {
+		t.Errorf("well priced transaction not promoted")
+	}
+	if pool.GetTransaction(local.Hash()) == nil {
+		t.Errorf("local transaction evicted")
+	}
+	if size := pool.priced.Len(); uint64(size) != config.GlobalSlots+config.GlobalQueue {
+		t.Errorf("pool size mismatch: have %d, want %d", size, config.GlobalSlots+config.GlobalQueue)
+	}
+}
+
+// Tests that local transactions are journaled to disk and reloaded into a new
+// pool on restart, re-validated against the current state.
+func TestTransactionJournaling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	stateFn := func() (*state.StateDB, error) { return statedb, nil }
+	gasLimitFn := func() *big.Int { return big.NewInt(1000000) }
+
+	newPool := func() *TxPool {
+		pool := NewTxPool(testChainConfig(), config, new(event.TypeMux), stateFn, gasLimitFn)
+		pool.mu.Lock()
+		pool.loadJournal()
+		pool.mu.Unlock()
+		return pool
+	}
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	statedb.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	statedb.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a pending and a queued local transaction, and a remote one
+	pool := newPool()
+	txs := types.Transactions{
+		transaction(0, big.NewInt(100000), local),
+		transaction(2, big.NewInt(100000), local),
+	}
+	for _, tx := range txs {
+		pool.SetLocal(tx)
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	if err := pool.Add(transaction(0, big.NewInt(100000), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	// Local transactions must stay local after their hashes expire
+	pool.mu.Lock()
+	pool.localTx = newTxSet()
+	if err := pool.journal.rotate(pool.locals()); err != nil {
+		t.Fatalf("failed to rotate journal: %v", err)
+	}
+	pool.mu.Unlock()
+	pool.Stop()
+
+	// Restart the pool and ensure only the local transactions were restored
+	pool = newPool()
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("restored pool size mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
+	}
+	for _, tx := range txs {
+		if pool.GetTransaction(tx.Hash()) == nil {
+			t.Errorf("local transaction %x not restored", tx.Hash())
+		}
+	}
+	pool.Stop()
+
+	// Include the pending transaction and ensure it is not restored again
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1)
+	pool = newPool()
+	if pending, queued := pool.Stats(); pending != 0 || queued != 1 {
+		t.Fatalf("revalidated pool size mismatch: have %d/%d, want %d/%d", pending, queued, 0, 1)
+	}
+	pool.Stop()
+
+	// Local transactions added before the journal is reloaded must be kept too
+	pool = NewTxPool(testChainConfig(), config, new(event.TypeMux), stateFn, gasLimitFn)
+	pool.resetState()
+	early := transaction(1, big.NewInt(100000), local)
+	pool.SetLocal(early)
+	if err := pool.Add(early); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	pool.Stop()
+
+	pool = newPool()
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("early local pool size mismatch: have %d/%d, want %d/%d", pending, queued, 2, 0)
+	}
+	pool.Stop()
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkValidatePool100(b *testing.B)   { benchmarkValidatePool(b, 100) }
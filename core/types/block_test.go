@@ -18,6 +18,8 @@ package types
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"math/big"
 	"reflect"
 	"testing"
@@ -64,3 +66,57 @@ func TestBlockEncoding(t *testing.T) {
 		t.Errorf("encoded block mismatch:\ngot:  %x\nwant: %x", ourBlockEnc, blockEnc)
 	}
 }
+
+func TestHeaderJSON(t *testing.T) {
+	h := &Header{
+		ParentHash:  common.HexToHash("83cafc574e1f51ba9dc0568fc617a08ea2429fb384059c972f13b19fa1c8dd55"),
+		UncleHash:   EmptyUncleHash,
+		Coinbase:    common.HexToAddress("8888f1f195afa192cfee860698584c030f4c9db1"),
+		Root:        common.HexToHash("ef1552a40b7165c3cd773806b9e0c165b75356e0314bf0706f279c729f51e017"),
+		TxHash:      EmptyRootHash,
+		ReceiptHash: EmptyRootHash,
+		Bloom:       BytesToBloom([]byte{1, 2, 3}),
+		Difficulty:  big.NewInt(131072),
+		Number:      big.NewInt(1),
+		GasLimit:    big.NewInt(3141592),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(1426516743),
+		Extra:       []byte("extra"),
+		MixDigest:   common.HexToHash("bd4472abb6659ebe3ee06ee4d7b72a00a9f4d001caca51342001075469aff498"),
+		Nonce:       EncodeNonce(0xa13a5a8c8f2bb1c4),
+	}
+	// Same field layout as the eth_getBlockByNumber response
+	enc, err := json.Marshal(map[string]interface{}{
+		"number":           fmt.Sprintf("%#x", h.Number),
+		"hash":             h.Hash(),
+		"parentHash":       h.ParentHash,
+		"nonce":            h.Nonce,
+		"mixHash":          h.MixDigest,
+		"sha3Uncles":       h.UncleHash,
+		"logsBloom":        h.Bloom,
+		"stateRoot":        h.Root,
+		"miner":            h.Coinbase,
+		"difficulty":       fmt.Sprintf("%#x", h.Difficulty),
+		"extraData":        fmt.Sprintf("0x%x", h.Extra),
+		"gasLimit":         fmt.Sprintf("%#x", h.GasLimit),
+		"gasUsed":          fmt.Sprintf("%#x", h.GasUsed),
+		"timestamp":        fmt.Sprintf("%#x", h.Time),
+		"transactionsRoot": h.TxHash,
+		"receiptsRoot":     h.ReceiptHash,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Header
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal("JSON decode error: ", err)
+	}
+	// The hash covers all header fields
+	if dec.Hash() != h.Hash() {
+		t.Errorf("hash mismatch: got %x, want %x", dec.Hash(), h.Hash())
+	}
+
+	if err := json.Unmarshal([]byte(`{"parentHash":"0x83cafc574e1f51ba9dc0568fc617a08ea2429fb384059c972f13b19fa1c8dd55"}`), &dec); err != errMissingHeaderFields {
+		t.Errorf("expected %v, got %v", errMissingHeaderFields, err)
+	}
+}
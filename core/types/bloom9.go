@@ -19,8 +19,10 @@ package types
 import (
 	"fmt"
 	"math/big"
+	"reflect"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
 	"github.com/eth-classic/go-ethereum/core/vm"
 	"github.com/eth-classic/go-ethereum/crypto"
 )
@@ -57,6 +59,11 @@ func (b Bloom) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`"%#x"`, b.Bytes())), nil
 }
 
+// UnmarshalJSON parses a bloom filter in its 0x-prefixed hex form.
+func (b *Bloom) UnmarshalJSON(input []byte) error {
+	return hexutil.UnmarshalFixedJSON(reflect.TypeOf(Bloom{}), input, b[:])
+}
+
 func CreateBloom(receipts Receipts) Bloom {
 	bin := new(big.Int)
 	for _, receipt := range receipts {
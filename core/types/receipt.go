@@ -18,11 +18,14 @@ package types
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
 	"github.com/eth-classic/go-ethereum/core/vm"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
@@ -30,6 +33,9 @@ import (
 var (
 	receiptStatusFailedRLP     = []byte{}
 	receiptStatusSuccessfulRLP = []byte{0x01}
+
+	errEmptyTypedReceipt    = errors.New("empty typed receipt bytes")
+	errMissingReceiptFields = errors.New("missing required receipt fields")
 )
 
 type ReceiptStatus byte
@@ -43,6 +49,7 @@ const (
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
+	Type              uint8 // EIP-2718 type of the transaction
 	PostState         []byte
 	CumulativeGasUsed *big.Int
 	Bloom             Bloom
@@ -78,29 +85,96 @@ type storedReceiptRLPWithStatus struct {
 	Status            ReceiptStatus
 }
 
+// storedReceiptRLPWithType is the storage encoding of a receipt of a typed
+// transaction.
+type storedReceiptRLPWithType struct {
+	PostState         []byte
+	CumulativeGasUsed *big.Int
+	Bloom             Bloom
+	TxHash            common.Hash
+	ContractAddress   common.Address
+	Logs              []*vm.LogForStorage
+	GasUsed           *big.Int
+	Status            ReceiptStatus
+	Type              uint8
+}
+
+// receiptRLP is the consensus encoding of a receipt.
+type receiptRLP struct {
+	PostStateOrStatus []byte
+	CumulativeGasUsed *big.Int
+	Bloom             Bloom
+	Logs              vm.Logs
+}
+
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
 func NewReceipt(root []byte, cumulativeGasUsed *big.Int) *Receipt {
 	return &Receipt{PostState: common.CopyBytes(root), CumulativeGasUsed: new(big.Int).Set(cumulativeGasUsed), Status: TxStatusUnknown}
 }
 
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
-// into an RLP stream.
+// into an RLP stream. Receipts of typed transactions are encoded as an RLP
+// string holding their EIP-2718 envelope.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs})
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type == LegacyTxType {
+		return rlp.Encode(w, data)
+	}
+	enc, err := r.encodeTyped(data)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
+}
+
+// MarshalBinary returns the consensus encoding of the receipt: the RLP list
+// for legacy receipts, and the EIP-2718 envelope (type || payload) for typed
+// ones. It's the encoding used for the receipt trie.
+func (r *Receipt) MarshalBinary() ([]byte, error) {
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type == LegacyTxType {
+		return rlp.EncodeToBytes(data)
+	}
+	return r.encodeTyped(data)
+}
+
+func (r *Receipt) encodeTyped(data *receiptRLP) ([]byte, error) {
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{r.Type}, payload...), nil
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
 // from an RLP stream.
 func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
-	var receipt struct {
-		PostStateOrStatus []byte
-		CumulativeGasUsed *big.Int
-		Bloom             Bloom
-		Logs              vm.Logs
-	}
-	if err := s.Decode(&receipt); err != nil {
+	kind, _, err := s.Kind()
+	if err != nil {
 		return err
 	}
+	var receipt receiptRLP
+	if kind == rlp.String {
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return errEmptyTypedReceipt
+		}
+		if b[0] != AccessListTxType {
+			return ErrTxTypeNotSupported
+		}
+		if err := rlp.DecodeBytes(b[1:], &receipt); err != nil {
+			return err
+		}
+		r.Type = b[0]
+	} else {
+		if err := s.Decode(&receipt); err != nil {
+			return err
+		}
+		r.Type = LegacyTxType
+	}
 
 	if err := r.setStatus(receipt.PostStateOrStatus); err != nil {
 		return err
@@ -143,6 +217,50 @@ func (r *Receipt) RlpEncode() []byte {
 	return bytes
 }
 
+// receiptJSON is the JSON-RPC representation of a receipt, as returned by
+// eth_getTransactionReceipt.
+type receiptJSON struct {
+	Type              *hexutil.Uint64 `json:"type"`
+	PostState         string          `json:"root"`
+	Status            *hexutil.Uint64 `json:"status"`
+	CumulativeGasUsed *hexutil.Big    `json:"cumulativeGasUsed"`
+	GasUsed           *hexutil.Big    `json:"gasUsed"`
+	Logs              vm.Logs         `json:"logs"`
+	TxHash            *common.Hash    `json:"transactionHash"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+}
+
+// UnmarshalJSON decodes a receipt from its JSON-RPC representation. The bloom
+// filter is not part of it and is recomputed from the logs.
+func (r *Receipt) UnmarshalJSON(input []byte) error {
+	var dec receiptJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.CumulativeGasUsed == nil || dec.GasUsed == nil || dec.TxHash == nil {
+		return errMissingReceiptFields
+	}
+	r.Type = LegacyTxType
+	if dec.Type != nil {
+		r.Type = uint8(*dec.Type)
+	}
+	r.PostState = common.FromHex(dec.PostState)
+	r.Status = TxStatusUnknown
+	if dec.Status != nil {
+		r.Status = ReceiptStatus(*dec.Status)
+	}
+	r.CumulativeGasUsed = dec.CumulativeGasUsed.ToInt()
+	r.GasUsed = dec.GasUsed.ToInt()
+	r.Logs = dec.Logs
+	r.TxHash = *dec.TxHash
+	r.ContractAddress = common.Address{}
+	if dec.ContractAddress != nil {
+		r.ContractAddress = *dec.ContractAddress
+	}
+	r.Bloom = CreateBloom(Receipts{r})
+	return nil
+}
+
 // String implements the Stringer interface.
 func (r *Receipt) String() string {
 	return fmt.Sprintf("receipt{med=%x cgas=%v bloom=%x logs=%v}", r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs)
@@ -159,6 +277,19 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 	for i, log := range r.Logs {
 		logs[i] = (*vm.LogForStorage)(log)
 	}
+	if r.Type != LegacyTxType {
+		return rlp.Encode(w, &storedReceiptRLPWithType{
+			PostState:         r.PostState,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			Logs:              logs,
+			Bloom:             r.Bloom,
+			TxHash:            r.TxHash,
+			ContractAddress:   r.ContractAddress,
+			GasUsed:           r.GasUsed,
+			Status:            r.Status,
+			Type:              r.Type,
+		})
+	}
 	receiptToStore := &storedReceiptRLPWithStatus{
 		PostState:         r.PostState,
 		CumulativeGasUsed: r.CumulativeGasUsed,
@@ -184,6 +315,9 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	if err := decodeStoredReceiptRLPWithStatus(r, raw); err == nil {
 		return nil
 	}
+	if err := decodeStoredReceiptRLPWithType(r, raw); err == nil {
+		return nil
+	}
 
 	return decodeStoredReceiptRLP(r, raw)
 }
@@ -248,15 +382,40 @@ func decodeStoredReceiptRLPWithStatus(r *ReceiptForStorage, raw []byte) error {
 	return nil
 }
 
+// Decode with status and transaction type fields included in storage
+func decodeStoredReceiptRLPWithType(r *ReceiptForStorage, raw []byte) error {
+	var receipt storedReceiptRLPWithType
+	if err := rlp.DecodeBytes(raw, &receipt); err != nil {
+		return err
+	}
+
+	r.Type = receipt.Type
+	r.PostState = receipt.PostState
+	r.Status = receipt.Status
+	r.CumulativeGasUsed = receipt.CumulativeGasUsed
+	r.Bloom = receipt.Bloom
+	r.TxHash = receipt.TxHash
+	r.ContractAddress = receipt.ContractAddress
+	r.GasUsed = receipt.GasUsed
+
+	r.Logs = make(vm.Logs, len(receipt.Logs))
+	for i, log := range receipt.Logs {
+		r.Logs[i] = (*vm.Log)(log)
+	}
+
+	return nil
+}
+
 // Receipts is a wrapper around a Receipt array to implement types.DerivableList.
 type Receipts []*Receipt
 
 // Len returns the number of receipts in this list.
 func (r Receipts) Len() int { return len(r) }
 
-// GetRlp returns the RLP encoding of one receipt from the list.
+// GetRlp returns the RLP encoding of one receipt from the list, or the
+// EIP-2718 envelope for receipts of typed transactions.
 func (r Receipts) GetRlp(i int) []byte {
-	bytes, err := rlp.EncodeToBytes(r[i])
+	bytes, err := r[i].MarshalBinary()
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,81 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/vm"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+func testReceipt(typ uint8) *Receipt {
+	receipt := NewReceipt(nil, big.NewInt(21000))
+	receipt.Type = typ
+	receipt.Status = TxSuccess
+	receipt.Logs = vm.Logs{{Address: common.Address{1}, Topics: []common.Hash{{2}}, Data: []byte{3}}}
+	receipt.Bloom = CreateBloom(Receipts{receipt})
+	receipt.TxHash = common.Hash{4}
+	receipt.GasUsed = big.NewInt(21000)
+	return receipt
+}
+
+func TestTypedReceiptEncoding(t *testing.T) {
+	legacy, typed := testReceipt(LegacyTxType), testReceipt(AccessListTxType)
+
+	legacyEnc, _ := legacy.MarshalBinary()
+	typedEnc, _ := typed.MarshalBinary()
+	if typedEnc[0] != AccessListTxType || !bytes.Equal(typedEnc[1:], legacyEnc) {
+		t.Fatalf("typed receipt encoding mismatch: %x", typedEnc)
+	}
+	if enc := (Receipts{legacy, typed}).GetRlp(1); !bytes.Equal(enc, typedEnc) {
+		t.Errorf("receipt trie encoding mismatch: %x", enc)
+	}
+	for _, receipt := range []*Receipt{legacy, typed} {
+		enc, err := rlp.EncodeToBytes(receipt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := new(Receipt)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("type %d: decode error: %v", receipt.Type, err)
+		}
+		if dec.Type != receipt.Type || dec.Status != TxSuccess || dec.CumulativeGasUsed.Cmp(receipt.CumulativeGasUsed) != 0 || len(dec.Logs) != 1 {
+			t.Errorf("type %d: decoded receipt mismatch: %v", receipt.Type, dec)
+		}
+	}
+}
+
+func TestTypedReceiptStorage(t *testing.T) {
+	for _, typ := range []uint8{LegacyTxType, AccessListTxType} {
+		receipt := testReceipt(typ)
+		enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := new(ReceiptForStorage)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("type %d: decode error: %v", typ, err)
+		}
+		if dec.Type != typ || dec.Status != TxSuccess || dec.TxHash != receipt.TxHash || dec.GasUsed.Cmp(receipt.GasUsed) != 0 {
+			t.Errorf("type %d: stored receipt mismatch: %v", typ, (*Receipt)(dec))
+		}
+	}
+}
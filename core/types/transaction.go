@@ -19,22 +19,43 @@ package types
 import (
 	"container/heap"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"sort"
+	"strings"
 	"sync/atomic"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
 
-var ErrInvalidSig = errors.New("invalid v, r, s values")
+var (
+	ErrInvalidSig         = errors.New("invalid v, r, s values")
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+	errEmptyTypedTx       = errors.New("empty typed transaction bytes")
+	errMissingTxFields    = errors.New("missing required transaction fields")
+	errTxHashMismatch     = errors.New("transaction hash does not match its contents")
+)
+
+// Transaction types, as defined by EIP-2718.
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01 // EIP-2930
+)
 
 type Transaction struct {
 	signer Signer
+	typ    byte
 	data   txdata
+
+	// Typed transaction fields, only used if typ != LegacyTxType
+	chainId    *big.Int
+	accessList AccessList
+
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -50,6 +71,37 @@ type txdata struct {
 	V, R, S         *big.Int // signature
 }
 
+// accessListTxdata is the payload of an EIP-2930 access list transaction.
+// Unlike legacy transactions, V holds the signature y parity (0 or 1).
+type accessListTxdata struct {
+	ChainId         *big.Int
+	AccountNonce    uint64
+	Price, GasLimit *big.Int
+	Recipient       *common.Address `rlp:"nil"` // nil means contract creation
+	Amount          *big.Int
+	Payload         []byte
+	AccessList      AccessList
+	V, R, S         *big.Int // signature
+}
+
+// AccessTuple is the element type of an access list.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessList is an EIP-2930 access list.
+type AccessList []AccessTuple
+
+// StorageKeys returns the total number of storage keys in the access list.
+func (al AccessList) StorageKeys() int {
+	sum := 0
+	for _, tuple := range al {
+		sum += len(tuple.StorageKeys)
+	}
+	return sum
+}
+
 func NewContractCreation(nonce uint64, amount, gasLimit, gasPrice *big.Int, data []byte) *Transaction {
 	if len(data) > 0 {
 		data = common.CopyBytes(data)
@@ -98,25 +150,162 @@ func NewTransaction(nonce uint64, to common.Address, amount, gasLimit, gasPrice
 	return &Transaction{signer: BasicSigner{}, data: d}
 }
 
+// NewAccessListTransaction creates an unsigned EIP-2930 access list transaction
+// for the given chain. A nil recipient means contract creation.
+func NewAccessListTransaction(chainId *big.Int, nonce uint64, to *common.Address, amount, gasLimit, gasPrice *big.Int, data []byte, accessList AccessList) *Transaction {
+	tx := NewContractCreation(nonce, new(big.Int), new(big.Int), new(big.Int), data)
+	if to != nil {
+		recipient := *to
+		tx.data.Recipient = &recipient
+	}
+	if amount != nil {
+		tx.data.Amount.Set(amount)
+	}
+	if gasLimit != nil {
+		tx.data.GasLimit.Set(gasLimit)
+	}
+	if gasPrice != nil {
+		tx.data.Price.Set(gasPrice)
+	}
+	tx.typ = AccessListTxType
+	tx.chainId = new(big.Int).Set(chainId)
+	tx.accessList = make(AccessList, len(accessList))
+	copy(tx.accessList, accessList)
+	tx.signer = NewAccessListSigner(chainId)
+	return tx
+}
+
 func (tx *Transaction) SetSigner(s Signer) {
 	tx.signer = s
 }
 
 // ChainId returns which chain id this transaction was signed for (if at all)
 func (tx *Transaction) ChainId() *big.Int {
+	if tx.typ != LegacyTxType {
+		return new(big.Int).Set(tx.chainId)
+	}
 	return deriveChainId(tx.data.V)
 }
 
 // Protected returns whether the transaction is protected from replay protection
 func (tx *Transaction) Protected() bool {
+	if tx.typ != LegacyTxType {
+		return true
+	}
 	return isProtectedV(tx.data.V)
 }
 
+// Type returns the EIP-2718 transaction type.
+func (tx *Transaction) Type() uint8 { return tx.typ }
+
+// AccessList returns the EIP-2930 access list of the transaction, which is
+// empty for legacy transactions.
+func (tx *Transaction) AccessList() AccessList { return tx.accessList }
+
+// EncodeRLP implements rlp.Encoder. Typed transactions are encoded as an RLP
+// string holding their EIP-2718 envelope.
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.typ == LegacyTxType {
+		return rlp.Encode(w, &tx.data)
+	}
+	enc, err := tx.encodeTyped()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
+}
+
+// MarshalBinary returns the canonical encoding of the transaction: the RLP
+// list for legacy transactions, and the EIP-2718 envelope (type || payload)
+// for typed transactions.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.typ == LegacyTxType {
+		return rlp.EncodeToBytes(&tx.data)
+	}
+	return tx.encodeTyped()
+}
+
+// UnmarshalBinary decodes the canonical encoding of a transaction, as returned
+// by MarshalBinary.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > 0x7f {
+		// RLP list, it's a legacy transaction
+		return rlp.DecodeBytes(b, tx)
+	}
+	return tx.decodeTyped(b)
 }
 
-// DeriveSigner makes a *best* guess about which signer to use.
+// encodeTyped returns the EIP-2718 envelope of a typed transaction.
+func (tx *Transaction) encodeTyped() ([]byte, error) {
+	payload, err := rlp.EncodeToBytes(tx.typedData())
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.typ}, payload...), nil
+}
+
+// typedData returns the RLP payload of a typed transaction.
+func (tx *Transaction) typedData() *accessListTxdata {
+	return &accessListTxdata{
+		ChainId:      tx.chainId,
+		AccountNonce: tx.data.AccountNonce,
+		Price:        tx.data.Price,
+		GasLimit:     tx.data.GasLimit,
+		Recipient:    tx.data.Recipient,
+		Amount:       tx.data.Amount,
+		Payload:      tx.data.Payload,
+		AccessList:   tx.accessList,
+		V:            tx.data.V,
+		R:            tx.data.R,
+		S:            tx.data.S,
+	}
+}
+
+// decodeTyped decodes the EIP-2718 envelope of a typed transaction.
+func (tx *Transaction) decodeTyped(b []byte) error {
+	if len(b) == 0 {
+		return errEmptyTypedTx
+	}
+	if b[0] != AccessListTxType {
+		return ErrTxTypeNotSupported
+	}
+	var d accessListTxdata
+	if err := rlp.DecodeBytes(b[1:], &d); err != nil {
+		return err
+	}
+	tx.typ = b[0]
+	tx.chainId = d.ChainId
+	tx.accessList = d.AccessList
+	tx.data = txdata{
+		AccountNonce: d.AccountNonce,
+		Price:        d.Price,
+		GasLimit:     d.GasLimit,
+		Recipient:    d.Recipient,
+		Amount:       d.Amount,
+		Payload:      d.Payload,
+		V:            d.V,
+		R:            d.R,
+		S:            d.S,
+	}
+	tx.signer = NewAccessListSigner(d.ChainId)
+	tx.size.Store(common.StorageSize(len(b)))
+	return nil
+}
+
+// DeriveSigner makes a *best* guess about which signer to use for the given
+// transaction, based on its type and signature.
+func DeriveSigner(tx *Transaction) Signer {
+	if tx.typ != LegacyTxType {
+		return NewAccessListSigner(tx.chainId)
+	}
+	if tx.data.V == nil {
+		return BasicSigner{}
+	}
+	return deriveSigner(tx.data.V)
+}
+
+// deriveSigner makes a *best* guess about which signer to use for a legacy
+// transaction.
 func deriveSigner(V *big.Int) Signer {
 	if V.Sign() != 0 && isProtectedV(V) {
 		return NewChainIdSigner(deriveChainId(V))
@@ -125,9 +314,22 @@ func deriveSigner(V *big.Int) Signer {
 	}
 }
 
+// DecodeRLP implements rlp.Decoder, accepting both legacy transactions and
+// RLP strings holding the EIP-2718 envelope of a typed transaction.
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == rlp.String {
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		return tx.decodeTyped(b)
+	}
+	tx.typ = LegacyTxType
+	err = s.Decode(&tx.data)
 	if err == nil {
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
 	}
@@ -139,6 +341,84 @@ func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
 	return err
 }
 
+// txJSON is the JSON-RPC representation of a transaction, as returned by
+// eth_getTransactionByHash and friends.
+type txJSON struct {
+	Type       *hexutil.Uint64 `json:"type"`
+	ChainId    *jsonBig        `json:"chainId"`
+	Nonce      *hexutil.Uint64 `json:"nonce"`
+	Price      *hexutil.Big    `json:"gasPrice"`
+	GasLimit   *hexutil.Big    `json:"gas"`
+	Recipient  *common.Address `json:"to"`
+	Amount     *hexutil.Big    `json:"value"`
+	Payload    *hexutil.Bytes  `json:"input"`
+	AccessList *AccessList     `json:"accessList"`
+	V          *hexutil.Big    `json:"v"`
+	R          *hexutil.Big    `json:"r"`
+	S          *hexutil.Big    `json:"s"`
+	Hash       *common.Hash    `json:"hash"`
+}
+
+// jsonBig is a big integer given either as a JSON number or as a hex or
+// decimal string. The chain id is served as a plain number by this node.
+type jsonBig big.Int
+
+func (b *jsonBig) UnmarshalJSON(input []byte) error {
+	text := strings.Trim(string(input), `"`)
+	if _, ok := (*big.Int)(b).SetString(text, 0); !ok {
+		return fmt.Errorf("invalid number %s", input)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a signed transaction from its JSON-RPC representation.
+// If the hash is included, it is checked against the decoded transaction.
+func (tx *Transaction) UnmarshalJSON(input []byte) error {
+	var dec txJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Nonce == nil || dec.Price == nil || dec.GasLimit == nil || dec.Amount == nil ||
+		dec.Payload == nil || dec.V == nil || dec.R == nil || dec.S == nil {
+		return errMissingTxFields
+	}
+	tx.data = txdata{
+		AccountNonce: uint64(*dec.Nonce),
+		Price:        dec.Price.ToInt(),
+		GasLimit:     dec.GasLimit.ToInt(),
+		Recipient:    dec.Recipient,
+		Amount:       dec.Amount.ToInt(),
+		Payload:      *dec.Payload,
+		V:            dec.V.ToInt(),
+		R:            dec.R.ToInt(),
+		S:            dec.S.ToInt(),
+	}
+	tx.typ = LegacyTxType
+	if dec.Type != nil {
+		tx.typ = byte(*dec.Type)
+	}
+	switch tx.typ {
+	case LegacyTxType:
+		tx.signer = deriveSigner(tx.data.V)
+	case AccessListTxType:
+		if dec.ChainId == nil {
+			return errMissingTxFields
+		}
+		tx.chainId = (*big.Int)(dec.ChainId)
+		tx.accessList = AccessList{}
+		if dec.AccessList != nil {
+			tx.accessList = *dec.AccessList
+		}
+		tx.signer = NewAccessListSigner(tx.chainId)
+	default:
+		return ErrTxTypeNotSupported
+	}
+	if dec.Hash != nil && tx.Hash() != *dec.Hash {
+		return errTxHashMismatch
+	}
+	return nil
+}
+
 func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.data.Payload) }
 func (tx *Transaction) Gas() *big.Int      { return new(big.Int).Set(tx.data.GasLimit) }
 func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.data.Price) }
@@ -160,7 +440,12 @@ func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	var v common.Hash
+	if tx.typ == LegacyTxType {
+		v = rlpHash(tx)
+	} else {
+		v = prefixedRlpHash(tx.typ, tx.typedData())
+	}
 	tx.hash.Store(v)
 	return v
 }
@@ -176,7 +461,12 @@ func (tx *Transaction) Size() common.StorageSize {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.data)
+	if tx.typ == LegacyTxType {
+		rlp.Encode(&c, &tx.data)
+	} else {
+		c.Write([]byte{tx.typ})
+		rlp.Encode(&c, tx.typedData())
+	}
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -226,7 +516,7 @@ func (tx *Transaction) String() string {
 	} else {
 		to = fmt.Sprintf("%x", tx.data.Recipient[:])
 	}
-	enc, _ := rlp.EncodeToBytes(&tx.data)
+	enc, _ := tx.MarshalBinary()
 	return fmt.Sprintf(`
 	TX(%x)
 	Contract: %v
@@ -267,9 +557,10 @@ func (s Transactions) Len() int { return len(s) }
 // Swap swaps the i'th and the j'th element in s
 func (s Transactions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
-// GetRlp implements Rlpable and returns the i'th element of s in rlp
+// GetRlp implements Rlpable and returns the i'th element of s in rlp, or
+// the EIP-2718 envelope for typed transactions.
 func (s Transactions) GetRlp(i int) []byte {
-	enc, _ := rlp.EncodeToBytes(s[i])
+	enc, _ := s[i].MarshalBinary()
 	return enc
 }
 
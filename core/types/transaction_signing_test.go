@@ -161,3 +161,36 @@ func TestChainIdSigner_Equal(t *testing.T) {
 		t.Errorf("unexpected: s: %v, s2: %v", s, s261)
 	}
 }
+
+func TestAccessListSignerLegacy(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewAccessListSigner(big.NewInt(61))
+
+	// Legacy transactions are signed and verified like with ChainIdSigner
+	tx := NewTransaction(0, addr, new(big.Int), new(big.Int), new(big.Int), nil)
+	tx, err := tx.WithSigner(signer).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Hash(tx) != NewChainIdSigner(big.NewInt(61)).Hash(tx) {
+		t.Errorf("legacy sig hash mismatch")
+	}
+	if !tx.Protected() || tx.ChainId().Cmp(big.NewInt(61)) != 0 {
+		t.Errorf("expected protected transaction for chain 61, got %v", tx.ChainId())
+	}
+	for _, s := range []Signer{signer, NewChainIdSigner(big.NewInt(61))} {
+		if from, err := Sender(s, tx); err != nil || from != addr {
+			t.Errorf("%T: sender mismatch: have %x (%v), want %x", s, from, err, addr)
+		}
+	}
+	if v, _, _ := SignatureValues(signer, tx); v != 27 && v != 28 {
+		t.Errorf("unexpected normalised V: %d", v)
+	}
+
+	if signer.Equal(NewChainIdSigner(big.NewInt(61))) {
+		t.Errorf("access list signer shouldn't equal chain id signer")
+	}
+	if !signer.Equal(NewAccessListSigner(big.NewInt(61))) || signer.Equal(NewAccessListSigner(big.NewInt(62))) {
+		t.Errorf("unexpected access list signer equality")
+	}
+}
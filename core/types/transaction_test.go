@@ -19,6 +19,8 @@ package types
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
 	"math/big"
 	"testing"
 
@@ -48,6 +50,23 @@ var (
 	).WithSignature(
 		common.Hex2Bytes("98ff921201554726367d2be8c804a7ff89ccf285ebc57dff8ae4c44b9c19ac4a8887321be575c8095f789dd4c743dfe42c1820f9231f98a962b210e3ac2452a301"),
 	)
+
+	testAddr = common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+
+	emptyAccessListTx = NewAccessListTransaction(
+		big.NewInt(1),
+		3,
+		&testAddr,
+		big.NewInt(10),
+		big.NewInt(25000),
+		big.NewInt(1),
+		common.FromHex("5544"),
+		nil,
+	)
+
+	signedAccessListTx, _ = emptyAccessListTx.WithSignature(
+		common.Hex2Bytes("c9519f4f2b30335884581971573fadf60c6204f59a911df35ee8a540456b266032f1e8e2c5dd761f9e4f88f41c8310aeaba26a8bfcdacfedfa12ec3862d3752101"),
+	)
 )
 
 func TestTransactionSigHash(t *testing.T) {
@@ -70,6 +89,166 @@ func TestTransactionEncode(t *testing.T) {
 	}
 }
 
+func TestAccessListTransactionSigHash(t *testing.T) {
+	want := common.HexToHash("49b486f0ec0a60dfbbca2d30cb07c9e8ffb2a2ff41f29a1ab6737475f6ff69f3")
+	if h := emptyAccessListTx.SigHash(); h != want {
+		t.Errorf("empty access list transaction sig hash mismatch, got %x", h)
+	}
+	if h := signedAccessListTx.SigHash(); h != want {
+		t.Errorf("signed access list transaction sig hash mismatch, got %x", h)
+	}
+}
+
+func TestAccessListTransactionEncode(t *testing.T) {
+	txb, err := signedAccessListTx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	should := common.FromHex("01f8630103018261a894b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544c001a0c9519f4f2b30335884581971573fadf60c6204f59a911df35ee8a540456b2660a032f1e8e2c5dd761f9e4f88f41c8310aeaba26a8bfcdacfedfa12ec3862d37521")
+	if !bytes.Equal(txb, should) {
+		t.Errorf("encoded binary mismatch, got %x", txb)
+	}
+	if h := signedAccessListTx.Hash(); h != crypto.Keccak256Hash(should) {
+		t.Errorf("transaction hash mismatch, got %x", h)
+	}
+	// Within RLP streams typed transactions are wrapped in a string.
+	txb, err = rlp.EncodeToBytes(signedAccessListTx)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if !bytes.Equal(txb, append([]byte{0xb8, 0x66}, should...)) {
+		t.Errorf("encoded RLP mismatch, got %x", txb)
+	}
+}
+
+func TestAccessListTransactionDecode(t *testing.T) {
+	accessList := AccessList{
+		{Address: testAddr, StorageKeys: []common.Hash{{1}, {2}}},
+		{Address: common.Address{1}},
+	}
+	key, addr := defaultTestKey()
+	tx, err := NewAccessListTransaction(big.NewInt(61), 1, nil, big.NewInt(1), big.NewInt(100000), big.NewInt(1), []byte{1, 2}, accessList).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// decode both the canonical encoding and the RLP stream encoding
+	binTx := new(Transaction)
+	if err := binTx.UnmarshalBinary(enc); err != nil {
+		t.Fatalf("binary decode error: %v", err)
+	}
+	rlpEnc, _ := rlp.EncodeToBytes(tx)
+	rlpTx, err := decodeTx(rlpEnc)
+	if err != nil {
+		t.Fatalf("RLP decode error: %v", err)
+	}
+	for _, dec := range []*Transaction{binTx, rlpTx} {
+		if dec.Type() != AccessListTxType {
+			t.Errorf("type mismatch: have %d, want %d", dec.Type(), AccessListTxType)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("hash mismatch: have %x, want %x", dec.Hash(), tx.Hash())
+		}
+		if dec.ChainId().Cmp(big.NewInt(61)) != 0 || !dec.Protected() {
+			t.Errorf("chain id mismatch: have %v", dec.ChainId())
+		}
+		if dec.To() != nil {
+			t.Errorf("expected contract creation")
+		}
+		if len(dec.AccessList()) != 2 || dec.AccessList().StorageKeys() != 2 {
+			t.Errorf("access list mismatch: %v", dec.AccessList())
+		}
+		if from, err := dec.From(); err != nil || from != addr {
+			t.Errorf("sender mismatch: have %x (%v), want %x", from, err, addr)
+		}
+	}
+	// The legacy signers don't support typed transactions
+	for _, signer := range []Signer{BasicSigner{}, NewChainIdSigner(big.NewInt(61))} {
+		if _, err := Sender(signer, binTx); err != ErrTxTypeNotSupported {
+			t.Errorf("%T: expected %v, got %v", signer, ErrTxTypeNotSupported, err)
+		}
+	}
+	if _, err := Sender(NewAccessListSigner(big.NewInt(62)), binTx); err != ErrInvalidChainId {
+		t.Errorf("expected %v, got %v", ErrInvalidChainId, err)
+	}
+	if err := new(Transaction).UnmarshalBinary([]byte{0x02, 0xc0}); err != ErrTxTypeNotSupported {
+		t.Errorf("expected %v for unknown type, got %v", ErrTxTypeNotSupported, err)
+	}
+}
+
+// rpcTxJSON mimics the JSON-RPC representation of a transaction.
+func rpcTxJSON(tx *Transaction) map[string]interface{} {
+	v, r, s := tx.RawSignatureValues()
+	fields := map[string]interface{}{
+		"type":     fmt.Sprintf("%#x", tx.Type()),
+		"nonce":    fmt.Sprintf("%#x", tx.Nonce()),
+		"gasPrice": fmt.Sprintf("%#x", tx.GasPrice()),
+		"gas":      fmt.Sprintf("%#x", tx.Gas()),
+		"to":       tx.To(),
+		"value":    fmt.Sprintf("%#x", tx.Value()),
+		"input":    fmt.Sprintf("0x%x", tx.Data()),
+		"v":        fmt.Sprintf("%#x", v),
+		"r":        fmt.Sprintf("%#x", r),
+		"s":        fmt.Sprintf("%#x", s),
+		"hash":     tx.Hash(),
+	}
+	if tx.Protected() {
+		fields["chainId"] = tx.ChainId()
+	}
+	if tx.Type() != LegacyTxType {
+		fields["accessList"] = tx.AccessList()
+	}
+	return fields
+}
+
+func TestTransactionJSON(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.Address{0xaa}
+	protectedTx, err := NewTransaction(1, to, big.NewInt(10), big.NewInt(21000), big.NewInt(1), nil).WithSigner(NewChainIdSigner(big.NewInt(61))).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessList := AccessList{{Address: testAddr, StorageKeys: []common.Hash{{1}}}}
+	accessListTx, err := NewAccessListTransaction(big.NewInt(61), 2, &to, big.NewInt(1), big.NewInt(30000), big.NewInt(1), []byte{1, 2}, accessList).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creationTx, err := NewContractCreation(3, big.NewInt(0), big.NewInt(100000), big.NewInt(1), []byte{0x60, 0x00}).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tx := range []*Transaction{rightvrsTx, protectedTx, accessListTx, creationTx} {
+		enc, err := json.Marshal(rpcTxJSON(tx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := new(Transaction)
+		if err := json.Unmarshal(enc, dec); err != nil {
+			t.Fatalf("tx %d: decode error: %v", i, err)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("tx %d: hash mismatch: have %x, want %x", i, dec.Hash(), tx.Hash())
+		}
+		if i > 0 {
+			if from, err := Sender(DeriveSigner(dec), dec); err != nil || from != addr {
+				t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, addr)
+			}
+		}
+	}
+
+	// A hash that doesn't match the contents is rejected
+	fields := rpcTxJSON(protectedTx)
+	fields["hash"] = common.Hash{1}
+	enc, _ := json.Marshal(fields)
+	if err := json.Unmarshal(enc, new(Transaction)); err != errTxHashMismatch {
+		t.Errorf("expected %v, got %v", errTxHashMismatch, err)
+	}
+}
+
 func decodeTx(data []byte) (*Transaction, error) {
 	var tx Transaction
 	return &tx, rlp.Decode(bytes.NewReader(data), &tx)
@@ -0,0 +1,113 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/accounts/abi"
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+)
+
+// CallFrame is a single call frame of the call tree reconstructed by the
+// CallTracer. Nested frames are listed in Calls in execution order.
+type CallFrame struct {
+	Type         string         `json:"type"`
+	From         common.Address `json:"from"`
+	To           common.Address `json:"to"`
+	Value        *hexutil.Big   `json:"value,omitempty"`
+	Gas          *hexutil.Big   `json:"gas"`
+	GasUsed      *hexutil.Big   `json:"gasUsed"`
+	Input        hexutil.Bytes  `json:"input"`
+	Output       hexutil.Bytes  `json:"output,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	RevertReason string         `json:"revertReason,omitempty"`
+	Calls        []*CallFrame   `json:"calls,omitempty"`
+}
+
+// CallTracer is a Tracer reconstructing the tree of call frames (including
+// contract creations) of a transaction, e.g. to extract internal value
+// transfers. It doesn't capture any per-opcode state.
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame // frames entered but not yet left, innermost last
+}
+
+// NewCallTracer returns a new call tree tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CaptureState is a no-op for the call tracer.
+func (t *CallTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int) {
+}
+
+// CaptureFault is a no-op for the call tracer, failures are reported on frame
+// exit.
+func (t *CallTracer) CaptureFault(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+}
+
+// CaptureEnter opens a new call frame nested in the current one.
+func (t *CallTracer) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	frame := &CallFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    to,
+		Gas:   (*hexutil.Big)(new(big.Int).Set(gas)),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	if len(t.stack) == 0 {
+		t.root = frame
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+	t.stack = append(t.stack, frame)
+}
+
+// CaptureExit closes the current call frame.
+func (t *CallTracer) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+	if len(t.stack) == 0 {
+		return
+	}
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	frame.GasUsed = (*hexutil.Big)(new(big.Int).Set(gasUsed))
+	frame.Output = common.CopyBytes(output)
+	if err != nil {
+		frame.Error = err.Error()
+		if err == ErrRevert {
+			if reason, unpackErr := abi.UnpackRevert(output); unpackErr == nil {
+				frame.RevertReason = reason
+			}
+		} else {
+			// Failed frames other than reverts don't return any data.
+			frame.Output = nil
+		}
+	}
+}
+
+// Result returns the outermost call frame, or nil if no frame was entered
+// (e.g. the transaction failed before execution started).
+func (t *CallTracer) Result() *CallFrame {
+	return t.root
+}
@@ -17,11 +17,15 @@
 package vm
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"math/big"
+	"sort"
 
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/crypto/blake2b"
 	"github.com/eth-classic/go-ethereum/crypto/bn256"
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
@@ -55,6 +59,11 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+
+	Bn256AddGasPhoenix             uint64 = 150   // Gas needed for an elliptic curve addition since Phoenix (EIP-1108)
+	Bn256ScalarMulGasPhoenix       uint64 = 6000  // Gas needed for an elliptic curve scalar multiplication since Phoenix (EIP-1108)
+	Bn256PairingBaseGasPhoenix     uint64 = 45000 // Base price for an elliptic curve pairing check since Phoenix (EIP-1108)
+	Bn256PairingPerPointGasPhoenix uint64 = 34000 // Per-point price for an elliptic curve pairing check since Phoenix (EIP-1108)
 )
 
 // PrecompiledAccount represents a native ethereum contract
@@ -82,6 +91,46 @@ var PrecompiledAtlantis = func() map[string]*PrecompiledAccount {
 	}
 	return precompiles
 }()
+var PrecompiledPhoenix = func() map[string]*PrecompiledAccount {
+	precompiles := make(map[string]*PrecompiledAccount)
+	for _, set := range []map[string]*PrecompiledAccount{
+		PrecompiledContracts(),
+		PrecompiledContractsAtlantis(),
+		PrecompiledContractsPhoenix(),
+	} {
+		for k, c := range set {
+			precompiles[k] = c
+		}
+	}
+	return precompiles
+}()
+
+// ActivePrecompiles returns the set of precompiled contracts enabled by the
+// rule set at the given block number.
+func ActivePrecompiles(ruleset RuleSet, num *big.Int) map[string]*PrecompiledAccount {
+	switch {
+	case ruleset.IsPhoenix(num):
+		return PrecompiledPhoenix
+	case ruleset.IsAtlantis(num):
+		return PrecompiledAtlantis
+	default:
+		return PrecompiledPreAtlantis
+	}
+}
+
+// ActivePrecompileAddresses returns the addresses of the precompiled contracts
+// enabled by the rule set at the given block number, in ascending order.
+func ActivePrecompileAddresses(ruleset RuleSet, num *big.Int) []common.Address {
+	precompiles := ActivePrecompiles(ruleset, num)
+	addrs := make([]common.Address, 0, len(precompiles))
+	for k := range precompiles {
+		addrs = append(addrs, common.BytesToAddress([]byte(k)))
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
 
 // PrecompiledContractsPreAtlantis returns the default set of precompiled ethereum
 // contracts defined by the ethereum yellow paper pre-Atlantis.
@@ -202,6 +251,40 @@ func PrecompiledContractsAtlantis() map[string]*PrecompiledAccount {
 	}
 }
 
+// PrecompiledContractsPhoenix returns the set of precompiled contracts
+// introduced or repriced in Phoenix.
+func PrecompiledContractsPhoenix() map[string]*PrecompiledAccount {
+	return map[string]*PrecompiledAccount{
+		// bn256Add, repriced by EIP-1108
+		string(common.LeftPadBytes([]byte{6}, 20)): {func(in []byte) *big.Int {
+			return new(big.Int).SetUint64(Bn256AddGasPhoenix)
+		}, bn256Add},
+
+		// bn256ScalarMul, repriced by EIP-1108
+		string(common.LeftPadBytes([]byte{7}, 20)): {func(in []byte) *big.Int {
+			return new(big.Int).SetUint64(Bn256ScalarMulGasPhoenix)
+		}, bn256ScalarMul},
+
+		// bn256Pairing, repriced by EIP-1108
+		string(common.LeftPadBytes([]byte{8}, 20)): {func(in []byte) *big.Int {
+			n := new(big.Int).SetUint64(Bn256PairingBaseGasPhoenix)
+			p := big.NewInt(int64(len(in) / 192))
+			p.Mul(p, new(big.Int).SetUint64(Bn256PairingPerPointGasPhoenix))
+			return n.Add(n, p)
+		}, bn256Pairing},
+
+		// blake2F (EIP-152)
+		string(common.LeftPadBytes([]byte{9}, 20)): {func(in []byte) *big.Int {
+			// The gas cost is the number of rounds. Malformed input is
+			// rejected by the contract itself.
+			if len(in) != blake2FInputLength {
+				return new(big.Int)
+			}
+			return new(big.Int).SetUint64(uint64(binary.BigEndian.Uint32(in[0:4])))
+		}, blake2F},
+	}
+}
+
 func sha256Func(in []byte) ([]byte, error) {
 	return crypto.Sha256(in), nil
 }
@@ -355,3 +438,53 @@ func bn256Pairing(in []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+const (
+	blake2FInputLength        = 213
+	blake2FFinalBlockBytes    = byte(1)
+	blake2FNonFinalBlockBytes = byte(0)
+)
+
+var (
+	errBlake2FInvalidInputLength = errors.New("invalid input length")
+	errBlake2FInvalidFinalFlag   = errors.New("invalid final flag")
+)
+
+// blake2F runs the BLAKE2b compression function on the EIP-152 encoded input:
+// rounds (4 bytes, big endian), h (64 bytes), m (128 bytes), t (16 bytes),
+// all little endian words, followed by the final block flag (1 byte).
+func blake2F(in []byte) ([]byte, error) {
+	if len(in) != blake2FInputLength {
+		return nil, errBlake2FInvalidInputLength
+	}
+	if in[212] != blake2FNonFinalBlockBytes && in[212] != blake2FFinalBlockBytes {
+		return nil, errBlake2FInvalidFinalFlag
+	}
+	var (
+		rounds = binary.BigEndian.Uint32(in[0:4])
+		final  = in[212] == blake2FFinalBlockBytes
+
+		h [8]uint64
+		m [16]uint64
+		t [2]uint64
+	)
+	for i := 0; i < 8; i++ {
+		offset := 4 + i*8
+		h[i] = binary.LittleEndian.Uint64(in[offset : offset+8])
+	}
+	for i := 0; i < 16; i++ {
+		offset := 68 + i*8
+		m[i] = binary.LittleEndian.Uint64(in[offset : offset+8])
+	}
+	t[0] = binary.LittleEndian.Uint64(in[196:204])
+	t[1] = binary.LittleEndian.Uint64(in[204:212])
+
+	blake2b.F(&h, m, t, final, rounds)
+
+	output := make([]byte, 64)
+	for i := 0; i < 8; i++ {
+		offset := i * 8
+		binary.LittleEndian.PutUint64(output[offset:offset+8], h[i])
+	}
+	return output, nil
+}
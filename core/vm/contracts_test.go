@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/eth-classic/go-ethereum/common"
@@ -28,3 +29,87 @@ func TestPrecompiledEcRecover(t *testing.T) {
 
 	testEcRecover(test, t)
 }
+
+var blake2FInput = "48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b616263000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000003000000000000000000000000000000"
+
+// Test vectors from EIP-152.
+var blake2FTests = []precompiledTest{
+	{
+		input:    "00000000" + blake2FInput + "01",
+		expected: "08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b",
+		gas:      0,
+	},
+	{
+		input:    "0000000c" + blake2FInput + "01",
+		expected: "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		gas:      12,
+	},
+	{
+		input:    "0000000c" + blake2FInput + "00",
+		expected: "75ab69d3190a562c51aef8d88f1c2775876944407270c42c9844252c26d2875298743e7f6d5ea2f2d3e8d226039cd31b4e426ac4f2d3d666a610c2116fde4735",
+		gas:      12,
+	},
+	{
+		input:    "00000001" + blake2FInput + "01",
+		expected: "b63a380cb2897d521994a85234ee2c181b5f844d2c624c002677e9703449d2fba551b3a8333bcdf5f2f7e08993d53923de3d64fcc68c034e717b9293fed7a421",
+		gas:      1,
+	},
+}
+
+func TestPrecompiledBlake2F(t *testing.T) {
+	p := PrecompiledPhoenix[string(common.LeftPadBytes([]byte{9}, 20))]
+	for i, test := range blake2FTests {
+		in := common.Hex2Bytes(test.input)
+		if gas := p.Gas(in); gas.Uint64() != test.gas {
+			t.Errorf("test %d: gas mismatch: have %v, want %v", i, gas, test.gas)
+		}
+		if res, err := p.Call(in); err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if common.Bytes2Hex(res) != test.expected {
+			t.Errorf("test %d: expected %v, got %v", i, test.expected, common.Bytes2Hex(res))
+		}
+	}
+}
+
+func TestPrecompiledBlake2FFailure(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"", errBlake2FInvalidInputLength},
+		{"00000c" + blake2FInput + "01", errBlake2FInvalidInputLength},
+		{"000000000c" + blake2FInput + "01", errBlake2FInvalidInputLength},
+		{"0000000c" + blake2FInput + "02", errBlake2FInvalidFinalFlag},
+	}
+	for i, test := range tests {
+		if _, err := blake2F(common.Hex2Bytes(test.input)); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestPhoenixPrecompiles(t *testing.T) {
+	rules := ruleSet{big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(10)}
+	blake2FAddr := string(common.LeftPadBytes([]byte{9}, 20))
+	pairingInput := make([]byte, 2*192)
+
+	for _, test := range []struct {
+		number            int64
+		blake2F           bool
+		add, mul, pairing uint64
+	}{
+		{9, false, Bn256AddGas, Bn256ScalarMulGas, Bn256PairingBaseGas + 2*Bn256PairingPerPointGas},
+		{10, true, Bn256AddGasPhoenix, Bn256ScalarMulGasPhoenix, Bn256PairingBaseGasPhoenix + 2*Bn256PairingPerPointGasPhoenix},
+	} {
+		precompiles := ActivePrecompiles(rules, big.NewInt(test.number))
+		if (precompiles[blake2FAddr] != nil) != test.blake2F {
+			t.Errorf("block %d: blake2F presence mismatch: want %v", test.number, test.blake2F)
+		}
+		for addr, want := range map[byte]uint64{6: test.add, 7: test.mul, 8: test.pairing} {
+			p := precompiles[string(common.LeftPadBytes([]byte{addr}, 20))]
+			if gas := p.Gas(pairingInput); gas.Uint64() != want {
+				t.Errorf("block %d, precompile %d: gas mismatch: have %v, want %v", test.number, addr, gas, want)
+			}
+		}
+	}
+}
@@ -28,6 +28,12 @@ type RuleSet interface {
 	IsHomestead(*big.Int) bool
 	IsAtlantis(*big.Int) bool
 	IsAgharta(*big.Int) bool
+	IsPhoenix(*big.Int) bool
+	// IsEIP2929 reports whether the EIP-2929 warm/cold state access gas
+	// costs are in effect.
+	IsEIP2929(*big.Int) bool
+	// GetChainID returns the chain id exposed by the CHAINID opcode.
+	GetChainID() *big.Int
 	// GasTable returns the gas prices for this phase, which is based on
 	// block number passed in.
 	GasTable(*big.Int) *GasTable
@@ -94,6 +100,8 @@ type Vm interface {
 	// and return the contract execution return bytes or an error if it
 	// failed.
 	Run(c *Contract, in []byte, readOnly bool) ([]byte, error)
+	// Tracer returns the tracer notified of the execution, or nil.
+	Tracer() Tracer
 }
 
 // Database is a EVM database for full state querying.
@@ -113,8 +121,10 @@ type Database interface {
 	SetCode(common.Address, []byte)
 
 	AddRefund(*big.Int)
+	SubRefund(*big.Int)
 	GetRefund() *big.Int
 
+	GetCommittedState(common.Address, common.Hash) common.Hash
 	GetState(common.Address, common.Hash) common.Hash
 	SetState(common.Address, common.Hash, common.Hash)
 
@@ -125,6 +135,18 @@ type Database interface {
 	// Notably this should also return true for suicided accounts.
 	Exist(common.Address) bool
 	Empty(common.Address) bool
+
+	// PrepareAccessList resets the transaction's access list and adds the
+	// sender, destination and precompiles to it (EIP-2929).
+	PrepareAccessList(sender common.Address, dest *common.Address, precompiles []common.Address)
+	AddressInAccessList(addr common.Address) bool
+	SlotInAccessList(addr common.Address, slot common.Hash) (addressOk bool, slotOk bool)
+	// AddAddressToAccessList adds the given address to the access list. This
+	// operation is safe to perform even if the feature/fork is not active yet.
+	AddAddressToAccessList(addr common.Address)
+	// AddSlotToAccessList adds the given (address,slot) to the access list. This
+	// operation is safe to perform even if the feature/fork is not active yet.
+	AddSlotToAccessList(addr common.Address, slot common.Hash)
 }
 
 // Account represents a contract or basic ethereum account.
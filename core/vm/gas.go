@@ -169,6 +169,8 @@ var _baseCheck = map[OpCode]req{
 	CALLDATASIZE:   {0, GasQuickStep, 1},
 	DIFFICULTY:     {0, GasQuickStep, 1},
 	GASLIMIT:       {0, GasQuickStep, 1},
+	CHAINID:        {0, GasQuickStep, 1},
+	SELFBALANCE:    {0, GasFastStep, 1},
 	POP:            {1, GasQuickStep, 0},
 	PC:             {0, GasQuickStep, 1},
 	MSIZE:          {0, GasQuickStep, 1},
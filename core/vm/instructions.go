@@ -470,6 +470,17 @@ func opGasLimit(pc *uint64, env Environment, contract *Contract, memory *Memory,
 	return nil, nil
 }
 
+func opChainID(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	stack.push(new(big.Int).Set(env.RuleSet().GetChainID()))
+	return nil, nil
+}
+
+func opSelfBalance(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	balance := env.Db().GetBalance(contract.Address())
+	stack.push(new(big.Int).Set(balance))
+	return nil, nil
+}
+
 func opPop(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	stack.pop()
 	return nil, nil
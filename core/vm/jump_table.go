@@ -94,6 +94,17 @@ func newJumpTable(ruleset RuleSet, blockNumber *big.Int) vmJumpTable {
 		}
 	}
 
+	if ruleset.IsPhoenix(blockNumber) {
+		jumpTable[CHAINID] = jumpPtr{
+			fn:    opChainID,
+			valid: true,
+		}
+		jumpTable[SELFBALANCE] = jumpPtr{
+			fn:    opSelfBalance,
+			valid: true,
+		}
+	}
+
 	return jumpTable
 }
 
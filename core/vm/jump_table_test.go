@@ -25,6 +25,7 @@ type ruleSet struct {
 	hs *big.Int
 	at *big.Int
 	ag *big.Int
+	ph *big.Int
 }
 
 func (r ruleSet) IsHomestead(n *big.Int) bool { return n.Cmp(r.hs) >= 0 }
@@ -33,6 +34,12 @@ func (r ruleSet) IsAtlantis(n *big.Int) bool { return n.Cmp(r.at) >= 0 }
 
 func (r ruleSet) IsAgharta(n *big.Int) bool { return n.Cmp(r.ag) >= 0 }
 
+func (r ruleSet) IsPhoenix(n *big.Int) bool { return n.Cmp(r.ph) >= 0 }
+
+func (r ruleSet) IsEIP2929(n *big.Int) bool { return false }
+
+func (r ruleSet) GetChainID() *big.Int { return big.NewInt(61) }
+
 func (r ruleSet) GasTable(*big.Int) *GasTable {
 	return &GasTable{
 		ExtcodeSize: big.NewInt(20),
@@ -46,15 +53,28 @@ func (r ruleSet) GasTable(*big.Int) *GasTable {
 }
 
 func TestInit(t *testing.T) {
-	jumpTable := newJumpTable(ruleSet{big.NewInt(1), big.NewInt(1), big.NewInt(1)}, big.NewInt(0))
+	jumpTable := newJumpTable(ruleSet{big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1)}, big.NewInt(0))
 	if jumpTable[DELEGATECALL].valid {
 		t.Error("Expected DELEGATECALL not to be present")
 	}
 
 	for _, n := range []int64{1, 2, 100} {
-		jumpTable := newJumpTable(ruleSet{big.NewInt(1), big.NewInt(1), big.NewInt(1)}, big.NewInt(n))
+		jumpTable := newJumpTable(ruleSet{big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1)}, big.NewInt(n))
 		if !jumpTable[DELEGATECALL].valid {
 			t.Error("Expected DELEGATECALL to be present for block", n)
 		}
 	}
 }
+
+func TestPhoenixInstructions(t *testing.T) {
+	rules := ruleSet{big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(10)}
+
+	jumpTable := newJumpTable(rules, big.NewInt(9))
+	if jumpTable[CHAINID].valid || jumpTable[SELFBALANCE].valid {
+		t.Error("Expected CHAINID and SELFBALANCE not to be present before Phoenix")
+	}
+	jumpTable = newJumpTable(rules, big.NewInt(10))
+	if !jumpTable[CHAINID].valid || !jumpTable[SELFBALANCE].valid {
+		t.Error("Expected CHAINID and SELFBALANCE to be present from Phoenix")
+	}
+}
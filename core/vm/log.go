@@ -18,10 +18,12 @@ package vm
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
 
@@ -79,6 +81,45 @@ func (r *Log) MarshalJSON() ([]byte, error) {
 	return json.Marshal(fields)
 }
 
+var errMissingLogFields = errors.New("missing required log fields")
+
+// UnmarshalJSON decodes a log from the representation produced by MarshalJSON.
+func (r *Log) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		Address     *common.Address `json:"address"`
+		Topics      []common.Hash   `json:"topics"`
+		Data        *hexutil.Bytes  `json:"data"`
+		BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+		TxHash      *common.Hash    `json:"transactionHash"`
+		TxIndex     *hexutil.Uint   `json:"transactionIndex"`
+		BlockHash   *common.Hash    `json:"blockHash"`
+		Index       *hexutil.Uint   `json:"logIndex"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Address == nil || dec.Data == nil {
+		return errMissingLogFields
+	}
+	*r = Log{Address: *dec.Address, Topics: dec.Topics, Data: *dec.Data}
+	if dec.BlockNumber != nil {
+		r.BlockNumber = uint64(*dec.BlockNumber)
+	}
+	if dec.TxHash != nil {
+		r.TxHash = *dec.TxHash
+	}
+	if dec.TxIndex != nil {
+		r.TxIndex = uint(*dec.TxIndex)
+	}
+	if dec.BlockHash != nil {
+		r.BlockHash = *dec.BlockHash
+	}
+	if dec.Index != nil {
+		r.Index = uint(*dec.Index)
+	}
+	return nil
+}
+
 type Logs []*Log
 
 // LogForStorage is a wrapper around a Log that flattens and parses the entire
@@ -0,0 +1,226 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/common"
+)
+
+// Storage represents a contract's storage.
+type Storage map[common.Hash]common.Hash
+
+// Copy duplicates the current storage.
+func (self Storage) Copy() Storage {
+	cpy := make(Storage)
+	for key, value := range self {
+		cpy[key] = value
+	}
+
+	return cpy
+}
+
+// Config are the configuration options for the EVM.
+type Config struct {
+	// Tracer, if set, is notified of every executed opcode as well as
+	// of every call frame entered and left by the EVM.
+	Tracer Tracer
+}
+
+// Tracer is used to collect execution traces from an EVM transaction
+// execution. CaptureState is called for each step of the VM with the
+// current VM state, CaptureFault when a step fails, and CaptureEnter and
+// CaptureExit around every call frame, including the outermost one.
+// Note that reference types are actual VM data structures; make copies
+// if you need to retain them beyond the current call.
+type Tracer interface {
+	CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int)
+	CaptureFault(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error)
+	// CaptureEnter is called when a call frame of the given type (CALL,
+	// CALLCODE, DELEGATECALL, STATICCALL, CREATE or CREATE2) is entered.
+	// The value is nil for frame types that cannot transfer value.
+	CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int)
+	// CaptureExit is called when the most recently entered frame returns.
+	CaptureExit(output []byte, gasUsed *big.Int, err error)
+}
+
+// LogConfig are the configuration options for structured logger the EVM
+type LogConfig struct {
+	DisableMemory  bool // disable memory capture
+	DisableStack   bool // disable stack capture
+	DisableStorage bool // disable storage capture
+	FullStorage    bool // show full storage (slow)
+	Limit          int  // maximum length of output, but zero means unlimited
+}
+
+// StructLog is emitted to the EVM each cycle and lists information about the current internal state
+// prior to the execution of the statement.
+type StructLog struct {
+	Pc      uint64
+	Op      OpCode
+	Gas     *big.Int
+	GasCost *big.Int
+	Memory  []byte
+	Stack   []*big.Int
+	Storage map[common.Hash]common.Hash
+	Depth   int
+	Err     error
+}
+
+// OpName formats the operand name in a human-readable format.
+func (s *StructLog) OpName() string {
+	return s.Op.String()
+}
+
+// ErrorString formats the log's error as a string.
+func (s *StructLog) ErrorString() string {
+	if s.Err != nil {
+		return s.Err.Error()
+	}
+	return ""
+}
+
+// StructLogger is an EVM state logger and implements Tracer.
+//
+// StructLogger can capture state based on the given Log configuration and also keeps
+// a track record of modified storage which is used in reporting snapshots of the
+// contract their storage.
+type StructLogger struct {
+	cfg LogConfig
+
+	logs          []StructLog
+	changedValues map[common.Address]Storage
+}
+
+// NewStructLogger returns a new logger
+func NewStructLogger(cfg *LogConfig) *StructLogger {
+	logger := &StructLogger{
+		changedValues: make(map[common.Address]Storage),
+	}
+	if cfg != nil {
+		logger.cfg = *cfg
+	}
+	return logger
+}
+
+// CaptureState logs a new structured log message and pushes it out to the environment
+//
+// CaptureState also tracks SSTORE ops to track dirty values.
+func (l *StructLogger) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int) {
+	// check if already accumulated the specified number of logs
+	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
+		return
+	}
+
+	// initialise new changed values storage container for this contract
+	// if not present.
+	if l.changedValues[contract.Address()] == nil {
+		l.changedValues[contract.Address()] = make(Storage)
+	}
+
+	// capture SSTORE opcodes and determine the changed value and store
+	// it in the local storage container. The stack has already been
+	// validated for the operation at this point.
+	if op == SSTORE && len(stack) >= 2 {
+		var (
+			value   = common.BigToHash(stack[len(stack)-2])
+			address = common.BigToHash(stack[len(stack)-1])
+		)
+		l.changedValues[contract.Address()][address] = value
+	}
+
+	// copy a snapshot of the current memory state to a new buffer
+	var mem []byte
+	if !l.cfg.DisableMemory {
+		mem = make([]byte, len(memory.Data()))
+		copy(mem, memory.Data())
+	}
+
+	// copy a snapshot of the current stack state to a new buffer
+	var stck []*big.Int
+	if !l.cfg.DisableStack {
+		stck = make([]*big.Int, len(stack))
+		for i, item := range stack {
+			stck[i] = new(big.Int).Set(item)
+		}
+	}
+
+	// Copy the storage based on the settings specified in the log config. If full storage
+	// is disabled (default) we can use the simple Storage.Copy method, otherwise we use
+	// the state object to query for all values (slow process).
+	var storage Storage
+	if !l.cfg.DisableStorage {
+		if l.cfg.FullStorage {
+			storage = make(Storage)
+			// Get the contract account and loop over each storage entry. This may involve looping over
+			// the trie and is a very expensive process.
+			env.Db().GetAccount(contract.Address()).ForEachStorage(func(key, value common.Hash) bool {
+				storage[key] = value
+				// Return true, indicating we'd like to continue.
+				return true
+			})
+			for key, value := range l.changedValues[contract.Address()] {
+				storage[key] = value
+			}
+		} else {
+			// copy a snapshot of the current storage to a new container.
+			storage = l.changedValues[contract.Address()].Copy()
+		}
+	}
+	// create a new snapshot of the EVM.
+	log := StructLog{pc, op, new(big.Int).Set(gas), copyBig(cost), mem, stck, storage, depth, nil}
+
+	l.logs = append(l.logs, log)
+}
+
+// CaptureFault records the error of a failing step. If the step was already
+// captured by CaptureState the error is attached to that entry, otherwise a
+// new entry is appended.
+func (l *StructLogger) CaptureFault(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int, err error) {
+	if n := len(l.logs); n > 0 {
+		if last := &l.logs[n-1]; last.Pc == pc && last.Depth == depth && last.Op == op && last.Err == nil {
+			last.Err = err
+			return
+		}
+	}
+	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
+		return
+	}
+	l.logs = append(l.logs, StructLog{Pc: pc, Op: op, Gas: new(big.Int).Set(gas), GasCost: copyBig(cost), Depth: depth, Err: err})
+}
+
+// CaptureEnter is a no-op for the struct logger; call frames are reflected
+// by the depth of the individual steps.
+func (l *StructLogger) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+}
+
+// CaptureExit is a no-op for the struct logger.
+func (l *StructLogger) CaptureExit(output []byte, gasUsed *big.Int, err error) {}
+
+// StructLogs returns a list of captured log entries
+func (l *StructLogger) StructLogs() []StructLog {
+	return l.logs
+}
+
+// copyBig returns a copy of n, or nil if n is nil.
+func copyBig(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
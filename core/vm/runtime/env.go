@@ -57,7 +57,7 @@ func NewEnv(cfg *Config, state *state.StateDB) vm.Environment {
 		difficulty: cfg.Difficulty,
 		gasLimit:   cfg.GasLimit,
 	}
-	env.evm = vm.New(env)
+	env.evm = vm.NewWithConfig(env, vm.Config{Tracer: cfg.Tracer})
 
 	return env
 }
@@ -34,16 +34,19 @@ type ruleSet struct{}
 func (ruleSet) IsHomestead(*big.Int) bool { return true }
 func (ruleSet) IsAtlantis(*big.Int) bool  { return true }
 func (ruleSet) IsAgharta(*big.Int) bool   { return true }
+func (ruleSet) IsPhoenix(*big.Int) bool   { return true }
+func (ruleSet) IsEIP2929(*big.Int) bool   { return false }
+func (ruleSet) GetChainID() *big.Int      { return big.NewInt(1) }
 func (ruleSet) GasTable(*big.Int) *vm.GasTable {
 
-	// IsAgharta will always return true
-	// just have gastable default to returning the Agharta GasTable
+	// IsPhoenix will always return true
+	// just have gastable default to returning the Phoenix GasTable
 	return &vm.GasTable{
 		ExtcodeSize:     big.NewInt(700),
 		ExtcodeCopy:     big.NewInt(700),
-		ExtcodeHash:     big.NewInt(400),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
+		ExtcodeHash:     big.NewInt(700),
+		Balance:         big.NewInt(700),
+		SLoad:           big.NewInt(800),
 		Calls:           big.NewInt(700),
 		Suicide:         big.NewInt(5000),
 		ExpByte:         big.NewInt(50),
@@ -65,6 +68,7 @@ type Config struct {
 	Value       *big.Int
 	DisableJit  bool // "disable" so it's enabled by default
 	Debug       bool
+	Tracer      vm.Tracer // optional tracer notified of the execution
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
@@ -125,6 +129,11 @@ func Execute(code, input []byte, cfg *Config) ([]byte, *state.StateDB, error) {
 	// set the receiver's (the executing contract) code for execution.
 	receiver.SetCode(crypto.Keccak256Hash(code), code)
 
+	if cfg.RuleSet.IsEIP2929(vmenv.BlockNumber()) {
+		to := receiver.Address()
+		cfg.State.PrepareAccessList(cfg.Origin, &to, vm.ActivePrecompileAddresses(cfg.RuleSet, vmenv.BlockNumber()))
+	}
+
 	// Call the code with the given configuration.
 	ret, err := vmenv.Call(
 		sender,
@@ -149,6 +158,9 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, error) {
 	vmenv := NewEnv(cfg, cfg.State)
 
 	sender := cfg.State.GetOrNewStateObject(cfg.Origin)
+	if cfg.RuleSet.IsEIP2929(vmenv.BlockNumber()) {
+		cfg.State.PrepareAccessList(cfg.Origin, &address, vm.ActivePrecompileAddresses(cfg.RuleSet, vmenv.BlockNumber()))
+	}
 	// Call the code with the given configuration.
 	ret, err := vmenv.Call(
 		sender,
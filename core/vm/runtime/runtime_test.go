@@ -117,6 +117,185 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestStructLogger(t *testing.T) {
+	logger := vm.NewStructLogger(nil)
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 0x2a,
+		byte(vm.PUSH1), 0x01,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x00,
+		byte(vm.MLOAD),
+		byte(vm.STOP),
+	}, nil, &Config{Tracer: logger})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	logs := logger.StructLogs()
+	if len(logs) != 6 {
+		t.Fatalf("expected 6 logs, got %d", len(logs))
+	}
+	for i, op := range []vm.OpCode{vm.PUSH1, vm.PUSH1, vm.SSTORE, vm.PUSH1, vm.MLOAD, vm.STOP} {
+		if logs[i].Op != op {
+			t.Errorf("log %d: expected op %v, got %v", i, op, logs[i].Op)
+		}
+		if logs[i].Depth != 1 {
+			t.Errorf("log %d: expected depth 1, got %d", i, logs[i].Depth)
+		}
+	}
+	if len(logs[2].Stack) != 2 {
+		t.Errorf("expected 2 stack items before SSTORE, got %d", len(logs[2].Stack))
+	}
+	if v := logs[2].Storage[common.BigToHash(big.NewInt(1))]; v != common.BigToHash(big.NewInt(0x2a)) {
+		t.Errorf("expected storage diff 0x2a at slot 1, got %x", v)
+	}
+	if logs[4].GasCost.Cmp(big.NewInt(6)) != 0 {
+		t.Errorf("expected MLOAD to cost 6 gas including memory expansion, got %v", logs[4].GasCost)
+	}
+	if len(logs[5].Memory) != 32 {
+		t.Errorf("expected 32 bytes of memory after MLOAD, got %d", len(logs[5].Memory))
+	}
+}
+
+func TestStructLoggerConfig(t *testing.T) {
+	logger := vm.NewStructLogger(&vm.LogConfig{DisableMemory: true, DisableStack: true, DisableStorage: true})
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 0x2a,
+		byte(vm.PUSH1), 0x01,
+		byte(vm.SSTORE),
+		byte(vm.JUMP),
+	}, nil, &Config{Tracer: logger})
+	if err == nil {
+		t.Fatal("expected stack underflow error")
+	}
+
+	logs := logger.StructLogs()
+	if len(logs) != 4 {
+		t.Fatalf("expected 4 logs, got %d", len(logs))
+	}
+	for i, log := range logs {
+		if log.Stack != nil || log.Memory != nil || log.Storage != nil {
+			t.Errorf("log %d: expected no stack, memory and storage captures", i)
+		}
+	}
+	if last := logs[3]; last.Op != vm.JUMP || last.Err == nil {
+		t.Errorf("expected failing JUMP to be logged with its error, got %v (%v)", last.Op, last.Err)
+	}
+}
+
+// frameTracer records the call frames reported to it.
+type frameTracer struct {
+	enters []vm.OpCode
+	exits  []error
+}
+
+func (f *frameTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int) {
+}
+func (f *frameTracer) CaptureFault(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int, err error) {
+}
+func (f *frameTracer) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	f.enters = append(f.enters, typ)
+}
+func (f *frameTracer) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+	f.exits = append(f.exits, err)
+}
+
+func TestTracerCallFrames(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// callee reverts unconditionally
+	callee := common.HexToAddress("0x0b")
+	statedb.SetCode(callee, []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.REVERT),
+	})
+	// caller invokes the callee with a STATICCALL and stops
+	caller := common.HexToAddress("0x0a")
+	statedb.SetCode(caller, []byte{
+		byte(vm.PUSH1), 0, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0x0b, // address
+		byte(vm.GAS),
+		byte(vm.STATICCALL),
+		byte(vm.STOP),
+	})
+
+	tracer := new(frameTracer)
+	if _, err := Call(caller, nil, &Config{State: statedb, Tracer: tracer}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if len(tracer.enters) != 2 || tracer.enters[0] != vm.CALL || tracer.enters[1] != vm.STATICCALL {
+		t.Fatalf("expected CALL and STATICCALL frames, got %v", tracer.enters)
+	}
+	if len(tracer.exits) != 2 || tracer.exits[0] != vm.ErrRevert || tracer.exits[1] != nil {
+		t.Fatalf("expected inner frame to revert and outer to succeed, got %v", tracer.exits)
+	}
+}
+
+func TestCallTracer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// callee reverts with the reason "boom", copying the abi-encoded
+	// Error(string) payload from the end of its code
+	reason := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"626f6f6d00000000000000000000000000000000000000000000000000000000")
+	callee := common.HexToAddress("0x0b")
+	statedb.SetCode(callee, append([]byte{
+		byte(vm.PUSH1), byte(len(reason)),
+		byte(vm.PUSH1), 12,
+		byte(vm.PUSH1), 0,
+		byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(reason)),
+		byte(vm.PUSH1), 0,
+		byte(vm.REVERT),
+	}, reason...))
+	// caller invokes the callee with a plain CALL and stops
+	caller := common.HexToAddress("0x0a")
+	statedb.SetCode(caller, []byte{
+		byte(vm.PUSH1), 0, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0b, // address
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.STOP),
+	})
+
+	tracer := vm.NewCallTracer()
+	if _, err := Call(caller, nil, &Config{State: statedb, Tracer: tracer, GasLimit: big.NewInt(100000)}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	root := tracer.Result()
+	if root == nil {
+		t.Fatal("expected a call frame")
+	}
+	if root.Type != "CALL" || root.To != caller || root.Error != "" {
+		t.Errorf("unexpected outer frame: %+v", root)
+	}
+	if len(root.Calls) != 1 {
+		t.Fatalf("expected 1 nested call, got %d", len(root.Calls))
+	}
+	inner := root.Calls[0]
+	if inner.Type != "CALL" || inner.From != caller || inner.To != callee {
+		t.Errorf("unexpected inner frame: %+v", inner)
+	}
+	if inner.Error != vm.ErrRevert.Error() || inner.RevertReason != "boom" {
+		t.Errorf("expected inner frame to revert with reason boom, got %q (%q)", inner.Error, inner.RevertReason)
+	}
+	if inner.GasUsed.ToInt().Sign() == 0 || inner.GasUsed.ToInt().Cmp(root.GasUsed.ToInt()) >= 0 {
+		t.Errorf("inner gas used %v not within outer gas used %v", inner.GasUsed, root.GasUsed)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -149,3 +328,128 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestPhoenixOpcodes(t *testing.T) {
+	for _, test := range []struct {
+		op   vm.OpCode
+		want *big.Int
+	}{
+		{vm.CHAINID, ruleSet{}.GetChainID()},
+		{vm.SELFBALANCE, big.NewInt(42)},
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		address := common.HexToAddress("0x0a")
+		statedb.AddBalance(address, big.NewInt(42))
+		statedb.SetCode(address, []byte{
+			byte(test.op),
+			byte(vm.PUSH1), 0,
+			byte(vm.MSTORE),
+			byte(vm.PUSH1), 32,
+			byte(vm.PUSH1), 0,
+			byte(vm.RETURN),
+		})
+		ret, err := Call(address, nil, &Config{State: statedb})
+		if err != nil {
+			t.Fatalf("%v: didn't expect error: %v", test.op, err)
+		}
+		if have := new(big.Int).SetBytes(ret); have.Cmp(test.want) != 0 {
+			t.Errorf("%v: have %v, want %v", test.op, have, test.want)
+		}
+	}
+}
+
+// Test cases from EIP-2200.
+func TestEIP2200(t *testing.T) {
+	for i, test := range []struct {
+		original byte
+		code     string
+		used     int64
+		refund   int64
+	}{
+		{0, "0x60006000556000600055", 1612, 0},
+		{0, "0x60006000556001600055", 20812, 0},
+		{0, "0x60016000556000600055", 20812, 19200},
+		{0, "0x60016000556002600055", 20812, 0},
+		{0, "0x60016000556001600055", 20812, 0},
+		{1, "0x60006000556000600055", 5812, 15000},
+		{1, "0x60006000556001600055", 5812, 4200},
+		{1, "0x60006000556002600055", 5812, 0},
+		{1, "0x60026000556000600055", 5812, 15000},
+		{1, "0x60026000556003600055", 5812, 0},
+		{1, "0x60026000556001600055", 5812, 4200},
+		{1, "0x60026000556002600055", 5812, 0},
+		{1, "0x60016000556000600055", 5812, 15000},
+		{1, "0x60016000556002600055", 5812, 0},
+		{1, "0x60016000556001600055", 1612, 0},
+		{0, "0x600160005560006000556001600055", 40818, 19200},
+		{1, "0x600060005560016000556000600055", 10818, 19200},
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		address := common.HexToAddress("0x0a")
+		statedb.SetCode(address, common.FromHex(test.code))
+		statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{test.original}))
+		statedb.IntermediateRoot(true) // commit the original value
+
+		tracer := vm.NewCallTracer()
+		if _, err := Call(address, nil, &Config{State: statedb, Tracer: tracer, GasLimit: big.NewInt(100000)}); err != nil {
+			t.Fatalf("test %d: didn't expect error: %v", i, err)
+		}
+		if used := tracer.Result().GasUsed.ToInt(); used.Int64() != test.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, test.used)
+		}
+		if refund := statedb.GetRefund(); refund.Int64() != test.refund {
+			t.Errorf("test %d: gas refund mismatch: have %v, want %v", i, refund, test.refund)
+		}
+	}
+}
+
+// eip2929RuleSet enables the EIP-2929 state access gas costs.
+type eip2929RuleSet struct{ ruleSet }
+
+func (eip2929RuleSet) IsEIP2929(*big.Int) bool { return true }
+
+// Test warm/cold state access costs of EIP-2929.
+func TestEIP2929(t *testing.T) {
+	for i, test := range []struct {
+		original byte
+		code     string
+		used     int64
+		refund   int64
+	}{
+		{0, "0x60005450", 2105, 0},                             // cold SLOAD
+		{0, "0x6000546000545050", 2210, 0},                     // cold, then warm SLOAD
+		{0, "0x60ff3150", 2605, 0},                             // BALANCE of a cold account
+		{0, "0x60ff315060ff3150", 2710, 0},                     // BALANCE of a cold, then warm account
+		{0, "0x303150", 104, 0},                                // BALANCE of the warm destination
+		{0, "0x60013150", 105, 0},                              // BALANCE of a warm precompile
+		{0, "0x60ff3b50", 2605, 0},                             // EXTCODESIZE of a cold account
+		{0, "0x60ff3f50", 2605, 0},                             // EXTCODEHASH of a cold account
+		{0, "0x60006000556000600055", 2312, 0},                 // SSTORE noop on a cold slot
+		{0, "0x60016000556000600055", 22212, 19900},            // SSTORE create, then reset
+		{1, "0x60006000556001600055", 5112, 2800},              // SSTORE delete, then reset
+		{1, "0x60026000556003600055", 5112, 0},                 // SSTORE modify twice
+		{0, "0x600054506001600055", 22111, 0},                  // SLOAD warms the slot for SSTORE
+		{0, "0x6000600060006000600060ff62fffffff150", 2623, 0}, // CALL of a cold account
+		{0, "0x60006000600060006000600162fffffff150", 3123, 0}, // CALL of a warm precompile
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		address := common.HexToAddress("0x0a")
+		statedb.SetCode(address, common.FromHex(test.code))
+		statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{test.original}))
+		statedb.IntermediateRoot(true) // commit the original value
+
+		tracer := vm.NewCallTracer()
+		if _, err := Call(address, nil, &Config{RuleSet: eip2929RuleSet{}, State: statedb, Tracer: tracer, GasLimit: big.NewInt(100000)}); err != nil {
+			t.Fatalf("test %d: didn't expect error: %v", i, err)
+		}
+		if used := tracer.Result().GasUsed.ToInt(); used.Int64() != test.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, test.used)
+		}
+		if refund := statedb.GetRefund(); refund.Int64() != test.refund {
+			t.Errorf("test %d: gas refund mismatch: have %v, want %v", i, refund, test.refund)
+		}
+	}
+}
@@ -46,6 +46,7 @@ type VirtualMachine interface {
 // configuration.
 type EVM struct {
 	env       Environment
+	cfg       Config
 	jumpTable vmJumpTable
 	gasTable  GasTable
 	readOnly  bool
@@ -53,13 +54,26 @@ type EVM struct {
 
 // New returns a new instance of the EVM.
 func New(env Environment) *EVM {
+	return NewWithConfig(env, Config{})
+}
+
+// NewWithConfig returns a new instance of the EVM using the given
+// configuration, e.g. to attach a Tracer.
+func NewWithConfig(env Environment, cfg Config) *EVM {
 	return &EVM{
 		env:       env,
+		cfg:       cfg,
 		jumpTable: newJumpTable(env.RuleSet(), env.BlockNumber()),
 		gasTable:  *env.RuleSet().GasTable(env.BlockNumber()),
 	}
 }
 
+// Tracer returns the tracer attached to the EVM, or nil if execution
+// isn't being traced.
+func (evm *EVM) Tracer() Tracer {
+	return evm.cfg.Tracer
+}
+
 // Run loops and evaluates the contract's code with the given input data
 func (evm *EVM) Run(contract *Contract, input []byte, readOnly bool) (ret []byte, err error) {
 	evm.env.SetDepth(evm.env.Depth() + 1)
@@ -77,16 +91,9 @@ func (evm *EVM) Run(contract *Contract, input []byte, readOnly bool) (ret []byte
 	evm.env.SetReturnData(nil)
 
 	if contract.CodeAddr != nil {
-		if evm.env.RuleSet().IsAtlantis(evm.env.BlockNumber()) {
-			if p := PrecompiledAtlantis[contract.CodeAddr.Str()]; p != nil {
-				return evm.RunPrecompiled(p, input, contract)
-			}
-		} else {
-			if p := PrecompiledPreAtlantis[contract.CodeAddr.Str()]; p != nil {
-				return evm.RunPrecompiled(p, input, contract)
-			}
+		if p := ActivePrecompiles(evm.env.RuleSet(), evm.env.BlockNumber())[contract.CodeAddr.Str()]; p != nil {
+			return evm.RunPrecompiled(p, input, contract)
 		}
-
 	}
 
 	// Don't bother with the execution if there's no code.
@@ -118,6 +125,14 @@ func (evm *EVM) Run(contract *Contract, input []byte, readOnly bool) (ret []byte
 	)
 	contract.Input = input
 
+	if tracer := evm.cfg.Tracer; tracer != nil {
+		defer func() {
+			if err != nil && err != ErrRevert {
+				tracer.CaptureFault(evm.env, pc, op, contract.Gas, cost, mem, stack.Data(), contract, evm.env.Depth(), err)
+			}
+		}()
+	}
+
 	if glog.V(logger.Debug) {
 		glog.Infof("running byte VM %x\n", codehash[:4])
 		tstart := time.Now()
@@ -135,6 +150,9 @@ func (evm *EVM) Run(contract *Contract, input []byte, readOnly bool) (ret []byte
 		if err != nil {
 			return nil, err
 		}
+		if evm.cfg.Tracer != nil {
+			evm.cfg.Tracer.CaptureState(evm.env, pc, op, contract.Gas, cost, mem, stack.Data(), contract, evm.env.Depth())
+		}
 
 		// If the operation is valid, enforce and write restrictions
 		if evm.readOnly && isAtlantis {
@@ -179,6 +197,138 @@ func (evm *EVM) Run(contract *Contract, input []byte, readOnly bool) (ret []byte
 	}
 }
 
+var (
+	sstoreSentryGasEIP2200    = big.NewInt(2300)  // Minimum gas required to be present for an SSTORE call, not consumed
+	sstoreInitGasEIP2200      = big.NewInt(20000) // Once per SSTORE operation from clean zero to non-zero
+	sstoreCleanGasEIP2200     = big.NewInt(5000)  // Once per SSTORE operation from clean non-zero to something else
+	sstoreClearsRefundEIP2200 = big.NewInt(15000) // Once per SSTORE operation for clearing an originally existing storage slot
+)
+
+// gasSStoreEIP2200 calculates the net gas metered cost of SSTORE as defined by
+// EIP-2200, adjusting the refund counter on the way. The cost of a no-op
+// store is the SLOAD cost of the gas table in effect.
+//
+// The operation fails if the remaining gas doesn't exceed the call stipend,
+// preventing reentrancy through value transfers.
+func gasSStoreEIP2200(gasTable *GasTable, statedb Database, contract *Contract, stack *stack) (*big.Int, error) {
+	if contract.Gas.Cmp(sstoreSentryGasEIP2200) <= 0 {
+		return nil, OutOfGasError
+	}
+	var (
+		key     = common.BigToHash(stack.back(0))
+		value   = common.BigToHash(stack.back(1))
+		current = statedb.GetState(contract.Address(), key)
+	)
+	if current == value { // noop (1)
+		return gasTable.SLoad, nil
+	}
+	original := statedb.GetCommittedState(contract.Address(), key)
+	if original == current {
+		if common.EmptyHash(original) { // create slot (2.1.1)
+			return sstoreInitGasEIP2200, nil
+		}
+		if common.EmptyHash(value) { // delete slot (2.1.2b)
+			statedb.AddRefund(sstoreClearsRefundEIP2200)
+		}
+		return sstoreCleanGasEIP2200, nil // write existing slot (2.1.2)
+	}
+	if !common.EmptyHash(original) {
+		if common.EmptyHash(current) { // recreate slot (2.2.1.1)
+			statedb.SubRefund(sstoreClearsRefundEIP2200)
+		} else if common.EmptyHash(value) { // delete slot (2.2.1.2)
+			statedb.AddRefund(sstoreClearsRefundEIP2200)
+		}
+	}
+	if original == value {
+		if common.EmptyHash(original) { // reset to original inexistent slot (2.2.2.1)
+			statedb.AddRefund(new(big.Int).Sub(sstoreInitGasEIP2200, gasTable.SLoad))
+		} else { // reset to original existing slot (2.2.2.2)
+			statedb.AddRefund(new(big.Int).Sub(sstoreCleanGasEIP2200, gasTable.SLoad))
+		}
+	}
+	return gasTable.SLoad, nil // dirty update (2.2)
+}
+
+var (
+	coldAccountAccessCostEIP2929 = big.NewInt(2600) // Cost of the first access of an account in a transaction
+	coldSloadCostEIP2929         = big.NewInt(2100) // Cost of the first access of a storage slot in a transaction
+	warmStorageReadCostEIP2929   = big.NewInt(100)  // Cost of accessing a warm account or storage slot
+
+	// sstoreResetGasEIP2929 is the EIP-2200 clean write cost, reduced by the
+	// cold SLOAD cost which is charged separately.
+	sstoreResetGasEIP2929 = new(big.Int).Sub(sstoreCleanGasEIP2200, coldSloadCostEIP2929)
+)
+
+// accessAddressGasEIP2929 returns the cost of accessing the given account under
+// EIP-2929, adding it to the access list if it was cold.
+func accessAddressGasEIP2929(statedb Database, addr common.Address) *big.Int {
+	if statedb.AddressInAccessList(addr) {
+		return warmStorageReadCostEIP2929
+	}
+	statedb.AddAddressToAccessList(addr)
+	return coldAccountAccessCostEIP2929
+}
+
+// gasSLoadEIP2929 returns the cost of SLOAD under EIP-2929, adding the slot to
+// the access list if it was cold.
+func gasSLoadEIP2929(statedb Database, contract *Contract, stack *stack) *big.Int {
+	slot := common.BigToHash(stack.back(0))
+	if _, slotPresent := statedb.SlotInAccessList(contract.Address(), slot); slotPresent {
+		return warmStorageReadCostEIP2929
+	}
+	statedb.AddSlotToAccessList(contract.Address(), slot)
+	return coldSloadCostEIP2929
+}
+
+// gasSStoreEIP2929 calculates the net gas metered cost of SSTORE as defined by
+// EIP-2200 with the repricings of EIP-2929: an access of a cold slot costs an
+// additional cold SLOAD, and warm reads replace the SLOAD cost of the gas table.
+func gasSStoreEIP2929(statedb Database, contract *Contract, stack *stack) (*big.Int, error) {
+	if contract.Gas.Cmp(sstoreSentryGasEIP2200) <= 0 {
+		return nil, OutOfGasError
+	}
+	var (
+		key     = common.BigToHash(stack.back(0))
+		value   = common.BigToHash(stack.back(1))
+		current = statedb.GetState(contract.Address(), key)
+		cost    = new(big.Int)
+	)
+	// Check slot presence in the access list
+	if _, slotPresent := statedb.SlotInAccessList(contract.Address(), key); !slotPresent {
+		cost.Set(coldSloadCostEIP2929)
+		// If the caller cannot afford the cost, this change will be rolled back
+		statedb.AddSlotToAccessList(contract.Address(), key)
+	}
+	if current == value { // noop (1)
+		return cost.Add(cost, warmStorageReadCostEIP2929), nil
+	}
+	original := statedb.GetCommittedState(contract.Address(), key)
+	if original == current {
+		if common.EmptyHash(original) { // create slot (2.1.1)
+			return cost.Add(cost, sstoreInitGasEIP2200), nil
+		}
+		if common.EmptyHash(value) { // delete slot (2.1.2b)
+			statedb.AddRefund(sstoreClearsRefundEIP2200)
+		}
+		return cost.Add(cost, sstoreResetGasEIP2929), nil // write existing slot (2.1.2)
+	}
+	if !common.EmptyHash(original) {
+		if common.EmptyHash(current) { // recreate slot (2.2.1.1)
+			statedb.SubRefund(sstoreClearsRefundEIP2200)
+		} else if common.EmptyHash(value) { // delete slot (2.2.1.2)
+			statedb.AddRefund(sstoreClearsRefundEIP2200)
+		}
+	}
+	if original == value {
+		if common.EmptyHash(original) { // reset to original inexistent slot (2.2.2.1)
+			statedb.AddRefund(new(big.Int).Sub(sstoreInitGasEIP2200, warmStorageReadCostEIP2929))
+		} else { // reset to original existing slot (2.2.2.2)
+			statedb.AddRefund(new(big.Int).Sub(sstoreResetGasEIP2929, warmStorageReadCostEIP2929))
+		}
+	}
+	return cost.Add(cost, warmStorageReadCostEIP2929), nil // dirty update (2.2)
+}
+
 // calculateGasAndSize calculates the required given the opcode and stack items calculates the new memorysize for
 // the operation. This does not reduce gas or resizes the memory.
 func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract, caller ContractRef, op OpCode, statedb Database, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
@@ -186,6 +336,7 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		gas                 = new(big.Int)
 		newMemSize *big.Int = new(big.Int)
 		isAtlantis          = env.RuleSet().IsAtlantis(env.BlockNumber())
+		isEIP2929           = env.RuleSet().IsEIP2929(env.BlockNumber())
 	)
 	err := baseCheck(op, stack, gas)
 	if err != nil {
@@ -219,18 +370,40 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 			}
 		}
 
+		// EIP-2929: a cold beneficiary is charged on top of the suicide cost
+		if isEIP2929 && !statedb.AddressInAccessList(address) {
+			statedb.AddAddressToAccessList(address)
+			gas.Add(gas, coldAccountAccessCostEIP2929)
+		}
+
 		if !statedb.HasSuicided(contract.Address()) {
 			statedb.AddRefund(big.NewInt(24000))
 		}
 	case EXTCODESIZE:
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(0))))
+			break
+		}
 		gas.Set(gasTable.ExtcodeSize)
 	case EXTCODEHASH:
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(0))))
+			break
+		}
 		if gasTable.ExtcodeHash != nil {
 			gas.Set(gasTable.ExtcodeHash)
 		}
 	case BALANCE:
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(0))))
+			break
+		}
 		gas.Set(gasTable.Balance)
 	case SLOAD:
+		if isEIP2929 {
+			gas.Set(gasSLoadEIP2929(statedb, contract, stack))
+			break
+		}
 		gas.Set(gasTable.SLoad)
 	case SWAP1, SWAP2, SWAP3, SWAP4, SWAP5, SWAP6, SWAP7, SWAP8, SWAP9, SWAP10, SWAP11, SWAP12, SWAP13, SWAP14, SWAP15, SWAP16:
 		n := int(op - SWAP1 + 2)
@@ -274,6 +447,23 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 			return nil, nil, err
 		}
 
+		if isEIP2929 {
+			g, err := gasSStoreEIP2929(statedb, contract, stack)
+			if err != nil {
+				return nil, nil, err
+			}
+			gas.Set(g)
+			break
+		}
+		if env.RuleSet().IsPhoenix(env.BlockNumber()) {
+			g, err := gasSStoreEIP2200(gasTable, statedb, contract, stack)
+			if err != nil {
+				return nil, nil, err
+			}
+			gas.Set(g)
+			break
+		}
+
 		var g *big.Int
 		y, x := stack.back(1), stack.back(0)
 		val := statedb.GetState(contract.Address(), common.BigToHash(x))
@@ -328,7 +518,11 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 
 		quadMemGas(mem, newMemSize, gas)
 	case EXTCODECOPY:
-		gas.Set(gasTable.ExtcodeCopy)
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(0))))
+		} else {
+			gas.Set(gasTable.ExtcodeCopy)
+		}
 
 		newMemSize = calcMemSize(stack.back(1), stack.back(3))
 
@@ -347,7 +541,11 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		gas.Add(gas, words.Mul(words, params.Sha3WordGas))
 		quadMemGas(mem, newMemSize, gas)
 	case CALL, CALLCODE:
-		gas.Set(gasTable.Calls)
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(1))))
+		} else {
+			gas.Set(gasTable.Calls)
+		}
 
 		if op == CALL {
 			address := common.BigToAddress(stack.back(1))
@@ -381,7 +579,11 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		gas.Add(gas, cg)
 
 	case DELEGATECALL:
-		gas.Set(gasTable.Calls)
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(1))))
+		} else {
+			gas.Set(gasTable.Calls)
+		}
 
 		x := calcMemSize(stack.back(4), stack.back(5))
 		y := calcMemSize(stack.back(2), stack.back(3))
@@ -399,7 +601,11 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		stack.data[stack.len()-1] = cg
 		gas.Add(gas, cg)
 	case STATICCALL:
-		gas.Set(gasTable.Calls)
+		if isEIP2929 {
+			gas.Set(accessAddressGasEIP2929(statedb, common.BigToAddress(stack.back(1))))
+		} else {
+			gas.Set(gasTable.Calls)
+		}
 
 		x := calcMemSize(stack.back(4), stack.back(5))
 		y := calcMemSize(stack.back(2), stack.back(3))
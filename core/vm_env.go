@@ -25,14 +25,22 @@ import (
 	"github.com/eth-classic/go-ethereum/core/vm"
 )
 
+// ChainContext supports retrieving headers from the current blockchain, which
+// is all the VM env needs from it during execution. It is implemented by the
+// BlockChain, and by the headers of a Witness for stateless execution.
+type ChainContext interface {
+	// GetHeader returns the header with the given hash, or nil if not found.
+	GetHeader(hash common.Hash) *types.Header
+}
+
 // GetHashFn returns a function for which the VM env can query block hashes through
 // up to the limit defined by the Yellow Paper and uses the given block chain
 // to query for information.
-func GetHashFn(ref common.Hash, chain *BlockChain) func(n uint64) common.Hash {
+func GetHashFn(ref common.Hash, chain ChainContext) func(n uint64) common.Hash {
 	return func(n uint64) common.Hash {
-		for block := chain.GetBlock(ref); block != nil; block = chain.GetBlock(block.ParentHash()) {
-			if block.NumberU64() == n {
-				return block.Hash()
+		for header := chain.GetHeader(ref); header != nil; header = chain.GetHeader(header.ParentHash) {
+			if header.Number.Uint64() == n {
+				return header.Hash()
 			}
 		}
 
@@ -49,11 +57,17 @@ type VMEnv struct {
 	msg         Message // Message applied
 
 	header    *types.Header            // Header information
-	chain     *BlockChain              // Blockchain handle
+	chain     ChainContext             // Blockchain handle
 	getHashFn func(uint64) common.Hash // getHashFn callback is used to retrieve block hashes
 }
 
-func NewEnv(state *state.StateDB, chainConfig *ChainConfig, chain *BlockChain, msg Message, header *types.Header) *VMEnv {
+func NewEnv(state *state.StateDB, chainConfig *ChainConfig, chain ChainContext, msg Message, header *types.Header) *VMEnv {
+	return NewEnvWithConfig(state, chainConfig, chain, msg, header, vm.Config{})
+}
+
+// NewEnvWithConfig returns a new VM environment whose EVM is configured with
+// the given options, e.g. to trace the execution of msg.
+func NewEnvWithConfig(state *state.StateDB, chainConfig *ChainConfig, chain ChainContext, msg Message, header *types.Header, cfg vm.Config) *VMEnv {
 	env := &VMEnv{
 		chainConfig: chainConfig,
 		chain:       chain,
@@ -63,7 +77,7 @@ func NewEnv(state *state.StateDB, chainConfig *ChainConfig, chain *BlockChain, m
 		getHashFn:   GetHashFn(header.ParentHash, chain),
 	}
 
-	env.evm = vm.New(env)
+	env.evm = vm.NewWithConfig(env, cfg)
 	return env
 }
 
@@ -0,0 +1,243 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+var errNoWitnessParent = errors.New("witness has no parent header")
+
+// Witness is the data needed to execute a block without access to the state
+// database: the state trie nodes and contract codes read while processing the
+// block, and the headers of the ancestors it accesses.
+type Witness struct {
+	// Headers holds the parent header first, followed by the ancestors
+	// accessed through the BLOCKHASH opcode.
+	Headers []*types.Header
+	// State holds the trie nodes and contract codes accessed while processing
+	// the block, each stored in the database under its hash.
+	State [][]byte
+}
+
+// witnessJSON is the JSON representation of a Witness, with RLP encoded headers.
+type witnessJSON struct {
+	Headers []hexutil.Bytes `json:"headers"`
+	State   []hexutil.Bytes `json:"state"`
+}
+
+// MarshalJSON encodes the witness with hex encoded headers and state entries.
+func (w *Witness) MarshalJSON() ([]byte, error) {
+	enc := witnessJSON{
+		Headers: make([]hexutil.Bytes, len(w.Headers)),
+		State:   make([]hexutil.Bytes, len(w.State)),
+	}
+	for i, header := range w.Headers {
+		blob, err := rlp.EncodeToBytes(header)
+		if err != nil {
+			return nil, err
+		}
+		enc.Headers[i] = blob
+	}
+	for i, blob := range w.State {
+		enc.State[i] = blob
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a witness from its JSON representation.
+func (w *Witness) UnmarshalJSON(input []byte) error {
+	var dec witnessJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	w.Headers = make([]*types.Header, len(dec.Headers))
+	for i, blob := range dec.Headers {
+		w.Headers[i] = new(types.Header)
+		if err := rlp.DecodeBytes(blob, w.Headers[i]); err != nil {
+			return fmt.Errorf("invalid witness header %d: %v", i, err)
+		}
+	}
+	w.State = make([][]byte, len(dec.State))
+	for i, blob := range dec.State {
+		w.State[i] = blob
+	}
+	return nil
+}
+
+// witnessHeaders is the ChainContext of a stateless block execution, serving
+// the headers of a witness.
+type witnessHeaders struct {
+	headers map[common.Hash]*types.Header
+	missing bool // Whether a header not in the witness was requested
+}
+
+func newWitnessHeaders(witness *Witness) *witnessHeaders {
+	c := &witnessHeaders{headers: make(map[common.Hash]*types.Header, len(witness.Headers))}
+	for _, header := range witness.Headers {
+		c.headers[header.Hash()] = header
+	}
+	return c
+}
+
+func (c *witnessHeaders) GetHeader(hash common.Hash) *types.Header {
+	header, ok := c.headers[hash]
+	if !ok {
+		c.missing = true
+	}
+	return header
+}
+
+// witnessDatabase wraps a database, reading through source and recording every
+// value read.
+type witnessDatabase struct {
+	ethdb.Database
+	source trie.DatabaseReader
+	lock   sync.Mutex
+	reads  map[string][]byte
+}
+
+func (db *witnessDatabase) Get(key []byte) ([]byte, error) {
+	value, err := db.source.Get(key)
+	if err == nil {
+		db.lock.Lock()
+		db.reads[string(key)] = common.CopyBytes(value)
+		db.lock.Unlock()
+	}
+	return value, err
+}
+
+// witnessChain wraps a ChainContext, recording every header retrieved from it.
+type witnessChain struct {
+	ChainContext
+	headers map[common.Hash]*types.Header
+}
+
+func (c *witnessChain) GetHeader(hash common.Hash) *types.Header {
+	header := c.ChainContext.GetHeader(hash)
+	if header != nil {
+		c.headers[hash] = header
+	}
+	return header
+}
+
+// ExecutionWitness processes the given block on top of its parent's state,
+// recording the state and headers accessed into a witness which is enough to
+// verify the block with VerifyWitness.
+func (bc *BlockChain) ExecutionWitness(block *types.Block) (*Witness, error) {
+	parent := bc.GetHeader(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	// Read through the chain's trie node database, which holds recent states
+	triedb := bc.stateDatabase.TrieDB()
+	db := &witnessDatabase{Database: bc.chainDb, source: triedb, reads: make(map[string][]byte)}
+	statedb, err := state.New(parent.Root, state.NewDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+	chain := &witnessChain{ChainContext: bc, headers: make(map[common.Hash]*types.Header)}
+	processor := &StateProcessor{config: bc.config, bc: bc, chain: chain}
+
+	receipts, _, usedGas, err := processor.Process(block, statedb)
+	if err != nil {
+		return nil, err
+	}
+	// Validating the state computes the post-state root, recording the trie
+	// nodes needed to hash the modified tries
+	if err := validateState(bc.config, block, statedb, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	// Assemble the witness, sorting the recorded data for deterministic output
+	witness := &Witness{Headers: []*types.Header{parent}}
+	delete(chain.headers, parent.Hash())
+	for _, header := range chain.headers {
+		witness.Headers = append(witness.Headers, header)
+	}
+	sort.Slice(witness.Headers[1:], func(i, j int) bool {
+		return witness.Headers[i+1].Number.Cmp(witness.Headers[j+1].Number) > 0
+	})
+	for _, value := range db.reads {
+		witness.State = append(witness.State, value)
+	}
+	sort.Slice(witness.State, func(i, j int) bool {
+		return bytes.Compare(witness.State[i], witness.State[j]) < 0
+	})
+	return witness, nil
+}
+
+// VerifyWitness executes the given block using only the state and headers in
+// the witness, checking that it reproduces the gas used, receipts and state
+// root of the block. The parent header in the witness must be the block's
+// parent; it is up to the caller to establish that it is canonical.
+func VerifyWitness(config *ChainConfig, block *types.Block, witness *Witness) error {
+	if len(witness.Headers) == 0 {
+		return errNoWitnessParent
+	}
+	parent := witness.Headers[0]
+	if parent.Hash() != block.ParentHash() {
+		return fmt.Errorf("witness parent %x doesn't match block parent %x", parent.Hash(), block.ParentHash())
+	}
+	if parent.Number.Uint64()+1 != block.NumberU64() {
+		return fmt.Errorf("witness parent #%v doesn't precede block #%v", parent.Number, block.Number())
+	}
+	// Every state entry is looked up by its hash, any other data is unreachable
+	db, _ := ethdb.NewMemDatabase()
+	for _, value := range witness.State {
+		db.Put(crypto.Keccak256(value), value)
+	}
+	statedb, err := state.New(parent.Root, state.NewDatabase(db))
+	if err != nil {
+		return fmt.Errorf("incomplete witness: %v", err)
+	}
+	chain := newWitnessHeaders(witness)
+	processor := &StateProcessor{config: config, chain: chain}
+
+	receipts, _, usedGas, err := processor.Process(block, statedb)
+	if err != nil {
+		return err
+	}
+	if err := validateState(config, block, statedb, receipts, usedGas); err != nil {
+		return err
+	}
+	// Reads of data missing from the witness yield empty values, so an
+	// incomplete witness may still reproduce the expected state root
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("incomplete witness: %v", err)
+	}
+	if chain.missing {
+		return errors.New("incomplete witness: missing ancestor header")
+	}
+	return nil
+}
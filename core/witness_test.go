@@ -0,0 +1,132 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/event"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+func TestExecutionWitness(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		db, _  = ethdb.NewMemDatabase()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewChainIdSigner(big.NewInt(63))
+		config = MakeDiehardChainConfig()
+
+		// Stores 1 in slot 0 on creation, deploying code storing its input in slot 0
+		initCode = common.FromHex("60016000556007601160003960076000f360003560005500")
+		contract = crypto.CreateAddress(addr, 0)
+	)
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000000)})
+	blocks, _ := GenerateChain(config, genesis, db, 2, func(i int, gen *BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0:
+			tx = types.NewContractCreation(gen.TxNonce(addr), new(big.Int), big.NewInt(100000), new(big.Int), initCode)
+		case 1:
+			tx = types.NewTransaction(gen.TxNonce(addr), contract, new(big.Int), big.NewInt(100000), new(big.Int), common.LeftPadBytes([]byte{2}, 32))
+			signed, err := tx.WithSigner(signer).SignECDSA(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gen.AddTx(signed)
+			tx = types.NewTransaction(gen.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, nil, nil)
+		}
+		signed, err := tx.WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(signed)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	block := blocks[1]
+	statedb, err := blockchain.StateAt(block.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetState(contract, common.Hash{}); have != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("contract storage mismatch: have %x, want 2", have)
+	}
+
+	witness, err := blockchain.ExecutionWitness(block)
+	if err != nil {
+		t.Fatalf("failed to create witness: %v", err)
+	}
+	if len(witness.Headers) != 1 || witness.Headers[0].Hash() != blocks[0].Hash() {
+		t.Fatalf("witness headers mismatch: have %d, want only the parent", len(witness.Headers))
+	}
+	if err := VerifyWitness(config, block, witness); err != nil {
+		t.Fatalf("failed to verify witness: %v", err)
+	}
+
+	// The witness should survive both encodings
+	blob, err := json.Marshal(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(Witness)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to decode JSON witness: %v", err)
+	}
+	if err := VerifyWitness(config, block, dec); err != nil {
+		t.Errorf("failed to verify JSON witness: %v", err)
+	}
+	blob, err = rlp.EncodeToBytes(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec = new(Witness)
+	if err := rlp.DecodeBytes(blob, dec); err != nil {
+		t.Fatalf("failed to decode RLP witness: %v", err)
+	}
+	if err := VerifyWitness(config, block, dec); err != nil {
+		t.Errorf("failed to verify RLP witness: %v", err)
+	}
+
+	// Dropping any state entry or the parent header should fail verification
+	for i := range witness.State {
+		partial := &Witness{Headers: witness.Headers}
+		partial.State = append(partial.State, witness.State[:i]...)
+		partial.State = append(partial.State, witness.State[i+1:]...)
+		if err := VerifyWitness(config, block, partial); err == nil {
+			t.Errorf("verified witness without state entry %d", i)
+		}
+	}
+	if err := VerifyWitness(config, block, &Witness{State: witness.State}); err != errNoWitnessParent {
+		t.Errorf("error mismatch for witness without headers: have %v, want %v", err, errNoWitnessParent)
+	}
+	wrong := &Witness{Headers: []*types.Header{genesis.Header()}, State: witness.State}
+	if err := VerifyWitness(config, block, wrong); err == nil {
+		t.Error("verified witness with wrong parent header")
+	}
+}
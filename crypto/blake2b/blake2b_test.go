@@ -0,0 +1,67 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package blake2b
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// sum512 hashes a single-block message using F, for comparison against a
+// complete BLAKE2b-512 implementation.
+func sum512(msg []byte, rounds uint32) []byte {
+	if len(msg) > 128 {
+		panic("message exceeds a single block")
+	}
+	h := iv
+	h[0] ^= 0x01010000 | 64 // fanout 1, depth 1, no key, 64 byte digest
+
+	var (
+		block [128]byte
+		m     [16]uint64
+	)
+	copy(block[:], msg)
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(block[i*8:])
+	}
+	F(&h, m, [2]uint64{uint64(len(msg)), 0}, true, rounds)
+
+	out := make([]byte, 64)
+	for i, w := range h {
+		binary.LittleEndian.PutUint64(out[i*8:], w)
+	}
+	return out
+}
+
+func TestF(t *testing.T) {
+	// Test vector from RFC 7693, appendix A.
+	want, _ := hex.DecodeString("ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923")
+	if have := sum512([]byte("abc"), 12); !bytes.Equal(have, want) {
+		t.Errorf("abc: have %x, want %x", have, want)
+	}
+	for _, size := range []int{0, 1, 63, 64, 127, 128} {
+		msg := bytes.Repeat([]byte{0xa5}, size)
+		want := blake2b.Sum512(msg)
+		if have := sum512(msg, 12); !bytes.Equal(have, want[:]) {
+			t.Errorf("size %d: have %x, want %x", size, have, want)
+		}
+	}
+}
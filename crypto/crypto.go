@@ -17,6 +17,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -52,6 +53,14 @@ func Keccak256Hash(data ...[]byte) (h common.Hash) {
 	return h
 }
 
+func Keccak512(data ...[]byte) []byte {
+	d := sha3.NewKeccak512()
+	for _, b := range data {
+		d.Write(b)
+	}
+	return d.Sum(nil)
+}
+
 // Deprecated: For backward compatibility as other packages depend on these
 func Sha3(data ...[]byte) []byte          { return Keccak256(data...) }
 func Sha3Hash(data ...[]byte) common.Hash { return Keccak256Hash(data...) }
@@ -201,6 +210,63 @@ func Sign(hash []byte, prv *ecdsa.PrivateKey) (sig []byte, err error) {
 	return
 }
 
+// VerifySignature checks that the given public key created the 64 byte
+// [R || S] signature of the hash. Signatures carrying a recovery id are also
+// accepted, the id is ignored.
+func VerifySignature(pubkey, hash, sig []byte) bool {
+	if len(sig) != 64 && len(sig) != 65 {
+		return false
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if !ValidateSignatureValues(0, r, s, true) {
+		return false
+	}
+	// Recover with both recovery ids, one of them yields the signer
+	rsv := make([]byte, 65)
+	copy(rsv, sig[:64])
+	for v := byte(0); v < 2; v++ {
+		rsv[64] = v
+		if pub, err := Ecrecover(hash, rsv); err == nil && bytes.Equal(pub, pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompressPubkey encodes a public key to the 33-byte compressed format.
+func CompressPubkey(pubkey *ecdsa.PublicKey) []byte {
+	out := make([]byte, 33)
+	out[0] = byte(0x02 | pubkey.Y.Bit(0))
+	xb := pubkey.X.Bytes()
+	copy(out[33-len(xb):], xb)
+	return out
+}
+
+// DecompressPubkey parses a public key in the 33-byte compressed format.
+func DecompressPubkey(pubkey []byte) (*ecdsa.PublicKey, error) {
+	if len(pubkey) != 33 || (pubkey[0] != 0x02 && pubkey[0] != 0x03) {
+		return nil, errors.New("invalid compressed public key")
+	}
+	curve := secp256k1.S256()
+	x := new(big.Int).SetBytes(pubkey[1:])
+	if x.Cmp(curve.P) >= 0 {
+		return nil, errors.New("invalid compressed public key")
+	}
+	// y² = x³ + 7, and since p ≡ 3 mod 4 the root is (x³ + 7)^((p+1)/4)
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	y.Add(y, curve.B)
+	y.Mod(y, curve.P)
+	y.Exp(y, new(big.Int).Rsh(new(big.Int).Add(curve.P, big.NewInt(1)), 2), curve.P)
+	if y.Bit(0) != uint(pubkey[0]&1) {
+		y.Sub(curve.P, y)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("invalid compressed public key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
 func Encrypt(pub *ecdsa.PublicKey, message []byte) ([]byte, error) {
 	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), message, nil, nil)
 }
@@ -67,6 +67,12 @@ func TestSha3Hash(t *testing.T) {
 	checkhash(t, "Sha3-256-array", func(in []byte) []byte { h := Keccak256Hash(in); return h[:] }, msg, exp)
 }
 
+func TestKeccak512(t *testing.T) {
+	msg := []byte("abc")
+	exp, _ := hex.DecodeString("18587dc2ea106b9a1563e32b3312421ca164c7f1f07bc922a9c83d77cea3a1e5d0c69910739025372dc14ac9642629379540c17e2a65b19d77aa511a9d00bb96")
+	checkhash(t, "Keccak-512", func(in []byte) []byte { return Keccak512(in) }, msg, exp)
+}
+
 func TestSha256(t *testing.T) {
 	msg := []byte("abc")
 	exp, _ := hex.DecodeString("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
@@ -131,6 +137,50 @@ func TestSign(t *testing.T) {
 
 }
 
+func TestVerifySignature(t *testing.T) {
+	key, _ := HexToECDSA(testPrivHex)
+	pub := FromECDSAPub(&key.PublicKey)
+
+	msg := Keccak256([]byte("foo"))
+	sig, err := Sign(msg, key)
+	if err != nil {
+		t.Fatalf("Sign error: %s", err)
+	}
+	if !VerifySignature(pub, msg, sig[:64]) {
+		t.Errorf("can't verify signature with key")
+	}
+	if VerifySignature(pub, Keccak256([]byte("bar")), sig[:64]) {
+		t.Errorf("signature valid with wrong hash")
+	}
+	other, _ := GenerateKey()
+	if VerifySignature(FromECDSAPub(&other.PublicKey), msg, sig[:64]) {
+		t.Errorf("signature valid with wrong public key")
+	}
+	if VerifySignature(pub, msg, sig[:63]) {
+		t.Errorf("signature valid even though it's incomplete")
+	}
+}
+
+func TestPubkeyCompression(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		key, _ := GenerateKey()
+		compressed := CompressPubkey(&key.PublicKey)
+		if len(compressed) != 33 {
+			t.Fatalf("wrong compressed length: %d", len(compressed))
+		}
+		pub, err := DecompressPubkey(compressed)
+		if err != nil {
+			t.Fatalf("can't decompress: %v", err)
+		}
+		if pub.X.Cmp(key.PublicKey.X) != 0 || pub.Y.Cmp(key.PublicKey.Y) != 0 {
+			t.Fatalf("decompressed key mismatch: have %x, want %x", FromECDSAPub(pub), FromECDSAPub(&key.PublicKey))
+		}
+	}
+	if _, err := DecompressPubkey(common.Hex2Bytes("04aa")); err == nil {
+		t.Errorf("no error for invalid key")
+	}
+}
+
 func TestInvalidSign(t *testing.T) {
 	_, err := Sign(make([]byte, 1), nil)
 	if err == nil {
@@ -28,11 +28,12 @@ import (
 	"math/big"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/eth-classic/ethash"
 	"github.com/eth-classic/go-ethereum/accounts"
+	"github.com/eth-classic/go-ethereum/accounts/abi"
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/common/compiler"
 	"github.com/eth-classic/go-ethereum/common/hexutil"
@@ -48,6 +49,7 @@ import (
 	ethMetrics "github.com/eth-classic/go-ethereum/metrics"
 	"github.com/eth-classic/go-ethereum/miner"
 	"github.com/eth-classic/go-ethereum/p2p"
+	"github.com/eth-classic/go-ethereum/pow/etchash"
 	"github.com/eth-classic/go-ethereum/rlp"
 	"github.com/eth-classic/go-ethereum/rpc"
 )
@@ -75,7 +77,7 @@ func blockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber)
 // returns the state and containing block for the given block number, capable of
 // handling two special states: rpc.LatestBlockNumber and rpc.PendingBlockNumber.
 // It returns nil when no block or state could be found.
-func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber, chainDb ethdb.Database) (*state.StateDB, *types.Block, error) {
+func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber) (*state.StateDB, *types.Block, error) {
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block, state := m.Pending()
@@ -86,7 +88,7 @@ func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.Bloc
 	if block == nil {
 		return nil, nil, nil
 	}
-	stateDb, err := state.New(block.Root(), state.NewDatabase(chainDb))
+	stateDb, err := bc.StateAt(block.Root())
 	return stateDb, block, err
 }
 
@@ -196,7 +198,7 @@ type PublicMinerAPI struct {
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
 func NewPublicMinerAPI(e *Ethereum) *PublicMinerAPI {
-	agent := miner.NewRemoteAgent()
+	agent := miner.NewRemoteAgent(e.chainConfig.GetEthashECIP1099Transition())
 	e.Miner().Register(agent)
 
 	return &PublicMinerAPI{e, agent}
@@ -308,7 +310,7 @@ func (s *PrivateMinerAPI) SetExtra(b hexutil.Bytes) bool {
 
 // MakeDAG creates the new DAG for the given block number
 func (s *PrivateMinerAPI) MakeDAG(blockNr rpc.BlockNumber) (bool, error) {
-	if err := ethash.MakeDAG(uint64(blockNr.Int64()), ""); err != nil {
+	if err := etchash.MakeDAG(uint64(blockNr.Int64()), "", s.e.chainConfig.GetEthashECIP1099Transition()); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -357,23 +359,28 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string][]*RPCTrans
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
+// Status returns the number of pending and queued transaction in the pool, along
+// with the global limits the pool trims them to.
 func (s *PublicTxPoolAPI) Status() map[string]*rpc.HexNumber {
 	pending, queue := s.e.TxPool().Stats()
+	config := s.e.TxPool().Config()
 	return map[string]*rpc.HexNumber{
-		"pending": rpc.NewHexNumber(pending),
-		"queued":  rpc.NewHexNumber(queue),
+		"pending":      rpc.NewHexNumber(pending),
+		"queued":       rpc.NewHexNumber(queue),
+		"pendingLimit": rpc.NewHexNumber(config.GlobalSlots),
+		"queuedLimit":  rpc.NewHexNumber(config.GlobalQueue),
 	}
 }
 
 // Inspect retrieves the content of the transaction pool and flattens it into an
-// easily inspectable list.
-func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string][]string {
+// easily inspectable list, along with the limits the pool enforces on it.
+func (s *PublicTxPoolAPI) Inspect() map[string]interface{} {
 	content := map[string]map[string]map[string][]string{
 		"pending": make(map[string]map[string][]string),
 		"queued":  make(map[string]map[string][]string),
 	}
 	pending, queue := s.e.TxPool().Content()
+	config := s.e.TxPool().Config()
 
 	// Define a formatter to flatten a transaction into a string
 	var format = func(tx *types.Transaction) string {
@@ -404,7 +411,16 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string][]string {
 		}
 		content["queued"][account.Hex()] = dump
 	}
-	return content
+	return map[string]interface{}{
+		"pending": content["pending"],
+		"queued":  content["queued"],
+		"limits": map[string]string{
+			"pending":   fmt.Sprintf("%d slots, %d guaranteed per account", config.GlobalSlots, config.AccountSlots),
+			"queued":    fmt.Sprintf("%d slots, %d per account", config.GlobalQueue, config.AccountQueue),
+			"priceBump": fmt.Sprintf("%d%%", config.PriceBump),
+			"lifetime":  config.Lifetime.String(),
+		},
+	}
 }
 
 // PublicAccountAPI provides an API to access accounts managed by this node.
@@ -527,11 +543,9 @@ func (s *PrivateAccountAPI) SendTransaction(args SendTxArgs, passwd string) (com
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
+	tx, err := newTransaction(s.bc.Config(), s.bc.CurrentBlock().Number(), args.Nonce.Uint64(), args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
+	if err != nil {
+		return common.Hash{}, err
 	}
 
 	tx.SetSigner(s.bc.Config().GetSigner(s.bc.CurrentBlock().Number()))
@@ -608,7 +622,7 @@ func (s *PublicBlockChainAPI) BlockNumber() *big.Int {
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (s *PublicBlockChainAPI) GetBalance(address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
-	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -727,7 +741,7 @@ func (s *PublicBlockChainAPI) NewBlocks(ctx context.Context, args NewBlocksArgs)
 
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockNumber) (string, error) {
-	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if state == nil || err != nil {
 		return "", err
 	}
@@ -742,13 +756,96 @@ func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockN
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
 func (s *PublicBlockChainAPI) GetStorageAt(address common.Address, key string, blockNr rpc.BlockNumber) (string, error) {
-	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if state == nil || err != nil {
 		return "0x", err
 	}
 	return state.GetState(address, common.HexToHash(key)).Hex(), nil
 }
 
+// AccountResult is the result of eth_getProof: the fields of an account along
+// with the merkle proofs of the account and of the requested storage slots.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the value and merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// GetProof returns the account and storage values of the specified account
+// including the merkle proofs against the state root of the given block, as
+// specified by EIP-1186.
+func (s *PublicBlockChainAPI) GetProof(address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	// Non-existent accounts are proven empty, report them as such
+	codeHash, storageHash := state.GetCodeHash(address), state.GetStorageRoot(address)
+	if !state.Exist(address) {
+		codeHash, storageHash = crypto.Keccak256Hash(nil), types.EmptyRootHash
+	}
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, hexKey := range storageKeys {
+		key, err := decodeStorageKey(hexKey)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := state.GetStorageProof(address, key)
+		if err != nil {
+			return nil, err
+		}
+		value := state.GetState(address, key).Big()
+		storageProof[i] = StorageResult{Key: hexKey, Value: (*hexutil.Big)(value), Proof: toHexSlice(proof)}
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, nil
+}
+
+// decodeStorageKey parses a hex encoded storage key. Keys shorter than a hash,
+// including ones of odd length such as "0x1", are left padded with zeroes.
+func decodeStorageKey(s string) (common.Hash, error) {
+	raw := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(raw)%2 == 1 {
+		raw = "0" + raw
+	}
+	key, err := hex.DecodeString(raw)
+	if err != nil || len(key) > common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid storage key %q", s)
+	}
+	return common.BytesToHash(key), nil
+}
+
+// toHexSlice creates a slice of hex-encoded strings from a slice of byte slices.
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
+
 // callmsg is the message type used for call transactions.
 type callmsg struct {
 	from          *state.StateObject
@@ -756,6 +853,7 @@ type callmsg struct {
 	gas, gasPrice *big.Int
 	value         *big.Int
 	data          []byte
+	accessList    types.AccessList
 }
 
 // accessor boilerplate to implement core.Message
@@ -767,38 +865,114 @@ func (m callmsg) GasPrice() *big.Int                    { return m.gasPrice }
 func (m callmsg) Gas() *big.Int                         { return m.gas }
 func (m callmsg) Value() *big.Int                       { return m.value }
 func (m callmsg) Data() []byte                          { return m.data }
+func (m callmsg) AccessList() types.AccessList          { return m.accessList }
 
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *rpc.HexNumber  `json:"gas"`
-	GasPrice *rpc.HexNumber  `json:"gasPrice"`
-	Value    rpc.HexNumber   `json:"value"`
-	Data     string          `json:"data"`
+	From       common.Address    `json:"from"`
+	To         *common.Address   `json:"to"`
+	Gas        *rpc.HexNumber    `json:"gas"`
+	GasPrice   *rpc.HexNumber    `json:"gasPrice"`
+	Value      rpc.HexNumber     `json:"value"`
+	Data       string            `json:"data"`
+	AccessList *types.AccessList `json:"accessList"`
+}
+
+// OverrideAccount specifies the fields of an account to override before a call
+// is executed. State replaces the entire storage of the account, while StateDiff
+// only replaces the given slots; the two can't be set at the same time.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   **hexutil.Big                `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// StateOverride is the set of accounts to override before a call is executed.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in the given state.
+func (diff *StateOverride) Apply(stateDb *state.StateDB) error {
+	if diff == nil {
+		return nil
+	}
+	for addr, account := range *diff {
+		if account.Nonce != nil {
+			stateDb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			stateDb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			stateDb.SetBalance(addr, (*big.Int)(*account.Balance))
+		}
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		if account.State != nil {
+			stateDb.SetStorage(addr, *account.State)
+		}
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				stateDb.SetState(addr, key, value)
+			}
+		}
+	}
+	return nil
+}
+
+// hasBalance reports whether the balance of the given account is overridden.
+func (diff *StateOverride) hasBalance(addr common.Address) bool {
+	if diff == nil {
+		return false
+	}
+	account, ok := (*diff)[addr]
+	return ok && account.Balance != nil
+}
+
+// callResult is the outcome of a message executed by applyCall.
+type callResult struct {
+	ret      []byte   // data returned by the execution, or the revert reason
+	gasUsed  *big.Int // gas used by the execution, including refunds
+	failed   bool     // whether the execution failed (e.g. out of gas or reverted)
+	reverted bool     // whether the execution was stopped by the REVERT opcode
+}
+
+// callSender returns the account a call is executed from, defaulting to the
+// first managed account, or the zero address if there is none.
+func (s *PublicBlockChainAPI) callSender(from common.Address) common.Address {
+	if from != (common.Address{}) {
+		return from
+	}
+	if accounts := s.am.Accounts(); len(accounts) > 0 {
+		return accounts[0].Address
+	}
+	return common.Address{}
 }
 
-func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (*callResult, error) {
 	// Fetch the state associated with the block number
-	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if stateDb == nil || err != nil {
-		return "0x", nil, err
+		return nil, err
 	}
 	stateDb = stateDb.Copy()
+	if err := overrides.Apply(stateDb); err != nil {
+		return nil, err
+	}
+	return s.applyCall(stateDb, block.Header(), args, overrides)
+}
 
+// applyCall executes the call described by args on top of the given state,
+// which is modified in the process. The sender is funded to pay for any call,
+// unless its balance is overridden.
+func (s *PublicBlockChainAPI) applyCall(stateDb *state.StateDB, header *types.Header, args CallArgs, overrides *StateOverride) (*callResult, error) {
 	// Retrieve the account state object to interact with
-	var from *state.StateObject
-	if args.From == (common.Address{}) {
-		accounts := s.am.Accounts()
-		if len(accounts) == 0 {
-			from = stateDb.GetOrNewStateObject(common.Address{})
-		} else {
-			from = stateDb.GetOrNewStateObject(accounts[0].Address)
-		}
-	} else {
-		from = stateDb.GetOrNewStateObject(args.From)
+	from := stateDb.GetOrNewStateObject(s.callSender(args.From))
+	if !overrides.hasBalance(from.Address()) {
+		from.SetBalance(common.MaxBig)
 	}
-	from.SetBalance(common.MaxBig)
 
 	// Assemble the CALL invocation
 	msg := callmsg{
@@ -809,6 +983,9 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 		value:    args.Value.BigInt(),
 		data:     common.FromHex(args.Data),
 	}
+	if args.AccessList != nil {
+		msg.accessList = *args.AccessList
+	}
 	if msg.gas == nil {
 		msg.gas = big.NewInt(50000000)
 	}
@@ -817,27 +994,140 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 	}
 
 	// Execute the call and return
-	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, header)
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
-	res, requiredGas, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
-	if len(res) == 0 { // backwards compatibility
-		return "0x", requiredGas, err
-	}
-	return common.ToHex(res), requiredGas, err
+	st := core.NewStateTransition(vmenv, msg, gp)
+	res, gas, failed, err := st.TransitionDb()
+	return &callResult{ret: res, gasUsed: gas, failed: failed, reverted: st.Reverted()}, err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(args CallArgs, blockNr rpc.BlockNumber) (string, error) {
-	result, _, err := s.doCall(args, blockNr)
-	return result, err
+// Accounts in the optional overrides are modified on a copy of the state before the call.
+// A reverted call returns an error carrying the revert data.
+func (s *PublicBlockChainAPI) Call(args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (string, error) {
+	result, err := s.doCall(args, blockNr, overrides)
+	if err == nil && result != nil && result.reverted {
+		return "", newRevertError(result.ret)
+	}
+	if result == nil || len(result.ret) == 0 { // backwards compatibility
+		return "0x", err
+	}
+	return common.ToHex(result.ret), err
+}
+
+// revertError is an error carrying the data of a reverted execution, returned
+// to RPC clients in the data field of the error.
+type revertError struct {
+	reason string // revert reason, if it is an abi-encoded Error(string) or Panic(uint256)
+	data   []byte // raw revert data
+}
+
+// newRevertError creates a revertError from the data returned by a reverted
+// execution, decoding the revert reason or panic code if there is one.
+func newRevertError(data []byte) *revertError {
+	reason, _ := abi.UnpackRevert(data)
+	return &revertError{reason: reason, data: data}
+}
+
+func (e *revertError) Error() string {
+	if e.reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.reason
+}
+
+// Code returns the JSON-RPC error code of reverted executions.
+func (e *revertError) Code() int {
+	return 3
 }
 
-// EstimateGas returns an estimate of the amount of gas needed to execute the given transaction.
-func (s *PublicBlockChainAPI) EstimateGas(args CallArgs) (*rpc.HexNumber, error) {
-	_, gas, err := s.doCall(args, rpc.PendingBlockNumber)
-	return rpc.NewHexNumber(gas), err
+// ErrorData returns the hex encoded revert data.
+func (e *revertError) ErrorData() interface{} {
+	return common.ToHex(e.data)
+}
+
+// EstimateGas returns the lowest gas limit at which the given transaction executes
+// successfully against the state of the given block, the pending one by default.
+// The search is capped by the gas limit of the call (if given) or of the block, and
+// by the gas the sender can pay for at the given gas price. If the execution fails
+// at the cap, the failure is returned instead; a revert returns its data. Accounts
+// in the optional overrides are modified before the estimation.
+func (s *PublicBlockChainAPI) EstimateGas(args CallArgs, blockNr *rpc.BlockNumber, overrides *StateOverride) (*rpc.HexNumber, error) {
+	number := rpc.PendingBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, number)
+	if err != nil {
+		return nil, err
+	}
+	if stateDb == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	stateDb = stateDb.Copy()
+	if err := overrides.Apply(stateDb); err != nil {
+		return nil, err
+	}
+	// Determine the highest gas limit that can be used during the estimation
+	lo, hi := core.TxGas.Uint64()-1, block.GasLimit().Uint64()
+	if gas := args.Gas.BigInt(); gas != nil && gas.Cmp(core.TxGas) >= 0 && gas.IsUint64() {
+		hi = gas.Uint64()
+	}
+	// Cap the limit by what the sender can afford, if a gas price was given
+	if price := args.GasPrice.BigInt(); price != nil && price.Sign() > 0 {
+		available := new(big.Int).Set(stateDb.GetBalance(s.callSender(args.From)))
+		if value := args.Value.BigInt(); value != nil {
+			if available.Cmp(value) < 0 {
+				return nil, core.ErrInsufficientFunds
+			}
+			available.Sub(available, value)
+		}
+		if allowance := available.Div(available, price); allowance.Cmp(new(big.Int).SetUint64(hi)) < 0 {
+			glog.V(logger.Debug).Infof("Gas estimation capped by limited funds: %v", allowance)
+			hi = allowance.Uint64()
+		}
+	}
+	gasCap := hi
+
+	// executable runs the call with the given gas limit, reporting whether it failed
+	executable := func(gas uint64) (bool, *callResult, error) {
+		args.Gas = rpc.NewHexNumber(gas)
+		res, err := s.applyCall(stateDb.Copy(), block.Header(), args, overrides)
+		if err != nil {
+			return true, nil, err
+		}
+		return res.failed, res, nil
+	}
+	// Binary search the lowest gas limit at which the execution succeeds
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		failed, _, err := executable(mid)
+		// Invalid transactions (e.g. intrinsic gas too low) need a higher limit
+		if err != nil && !core.IsInvalidTxErr(err) {
+			return nil, err
+		}
+		if failed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	// Reject the transaction as invalid if it still fails at the highest allowance
+	if hi == gasCap {
+		failed, res, err := executable(hi)
+		if err != nil {
+			return nil, err
+		}
+		if failed {
+			if res.reverted {
+				return nil, newRevertError(res.ret)
+			}
+			return nil, fmt.Errorf("gas required exceeds allowance (%d)", gasCap)
+		}
+	}
+	return rpc.NewHexNumber(hi), nil
 }
 
 // rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
@@ -902,22 +1192,34 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *rpc.HexNumber  `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              *rpc.HexNumber  `json:"gas"`
-	GasPrice         *rpc.HexNumber  `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            string          `json:"input"`
-	Nonce            *rpc.HexNumber  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex *rpc.HexNumber  `json:"transactionIndex"`
-	Value            *rpc.HexNumber  `json:"value"`
-	ReplayProtected  bool            `json:"replayProtected"`
-	ChainId          *big.Int        `json:"chainId,omitempty"`
-	V                *rpc.HexNumber  `json:"v"`
-	R                *rpc.HexNumber  `json:"r"`
-	S                *rpc.HexNumber  `json:"s"`
+	BlockHash        common.Hash       `json:"blockHash"`
+	BlockNumber      *rpc.HexNumber    `json:"blockNumber"`
+	From             common.Address    `json:"from"`
+	Gas              *rpc.HexNumber    `json:"gas"`
+	GasPrice         *rpc.HexNumber    `json:"gasPrice"`
+	Hash             common.Hash       `json:"hash"`
+	Input            string            `json:"input"`
+	Nonce            *rpc.HexNumber    `json:"nonce"`
+	To               *common.Address   `json:"to"`
+	TransactionIndex *rpc.HexNumber    `json:"transactionIndex"`
+	Value            *rpc.HexNumber    `json:"value"`
+	ReplayProtected  bool              `json:"replayProtected"`
+	ChainId          *big.Int          `json:"chainId,omitempty"`
+	Type             *rpc.HexNumber    `json:"type"`
+	AccessList       *types.AccessList `json:"accessList,omitempty"`
+	V                *rpc.HexNumber    `json:"v"`
+	R                *rpc.HexNumber    `json:"r"`
+	S                *rpc.HexNumber    `json:"s"`
+}
+
+// rpcAccessList returns the access list of a typed transaction for the RPC
+// representation, or nil for legacy transactions.
+func rpcAccessList(tx *types.Transaction) *types.AccessList {
+	if tx.Type() == types.LegacyTxType {
+		return nil
+	}
+	al := tx.AccessList()
+	return &al
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
@@ -930,6 +1232,7 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 		protected = true
 		chainId = tx.ChainId()
 	}
+	v, r, s := tx.RawSignatureValues()
 
 	return &RPCTransaction{
 		From:            from,
@@ -942,6 +1245,11 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 		Value:           rpc.NewHexNumber(tx.Value()),
 		ReplayProtected: protected,
 		ChainId:         chainId,
+		Type:            rpc.NewHexNumber(tx.Type()),
+		AccessList:      rpcAccessList(tx),
+		V:               rpc.NewHexNumber(v),
+		R:               rpc.NewHexNumber(r),
+		S:               rpc.NewHexNumber(s),
 	}
 }
 
@@ -949,15 +1257,13 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransaction, error) {
 	if txIndex >= 0 && txIndex < len(b.Transactions()) {
 		tx := b.Transactions()[txIndex]
-		var signer types.Signer = types.BasicSigner{}
 		var protected bool
 		var chainId *big.Int
 		if tx.Protected() {
-			signer = types.NewChainIdSigner(tx.ChainId())
 			protected = true
 			chainId = tx.ChainId()
 		}
-		from, _ := types.Sender(signer, tx)
+		from, _ := types.Sender(types.DeriveSigner(tx), tx)
 
 		v, r, s := tx.RawSignatureValues()
 
@@ -975,6 +1281,8 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 			Value:            rpc.NewHexNumber(tx.Value()),
 			ReplayProtected:  protected,
 			ChainId:          chainId,
+			Type:             rpc.NewHexNumber(tx.Type()),
+			AccessList:       rpcAccessList(tx),
 			V:                rpc.NewHexNumber(v),
 			R:                rpc.NewHexNumber(r),
 			S:                rpc.NewHexNumber(s),
@@ -1098,7 +1406,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(blockHash c
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
 func (s *PublicTransactionPoolAPI) GetTransactionCount(address common.Address, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
-	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -1202,13 +1510,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 		receipt = receipts[index]
 	}
 
-	var signer types.Signer = types.BasicSigner{}
-	if tx.Protected() {
-		signer = types.NewChainIdSigner(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(types.DeriveSigner(tx), tx)
 
 	fields := map[string]interface{}{
+		"type":              rpc.NewHexNumber(tx.Type()),
 		"root":              common.Bytes2Hex(receipt.PostState),
 		"blockHash":         txBlock,
 		"blockNumber":       rpc.NewHexNumber(blockIndex),
@@ -1253,13 +1558,30 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *rpc.HexNumber  `json:"gas"`
-	GasPrice *rpc.HexNumber  `json:"gasPrice"`
-	Value    *rpc.HexNumber  `json:"value"`
-	Data     string          `json:"data"`
-	Nonce    *rpc.HexNumber  `json:"nonce"`
+	From       common.Address    `json:"from"`
+	To         *common.Address   `json:"to"`
+	Gas        *rpc.HexNumber    `json:"gas"`
+	GasPrice   *rpc.HexNumber    `json:"gasPrice"`
+	Value      *rpc.HexNumber    `json:"value"`
+	Data       string            `json:"data"`
+	Nonce      *rpc.HexNumber    `json:"nonce"`
+	AccessList *types.AccessList `json:"accessList"`
+}
+
+// newTransaction creates an unsigned transaction from the arguments of the
+// send and sign methods. If an access list is given, an EIP-2930 transaction
+// is created, which requires EIP-2718 to be enabled at the given block.
+func newTransaction(config *core.ChainConfig, number *big.Int, nonce uint64, to *common.Address, value, gas, gasPrice *big.Int, data []byte, accessList *types.AccessList) (*types.Transaction, error) {
+	if accessList != nil {
+		if !config.IsEIP2718(number) {
+			return nil, types.ErrTxTypeNotSupported
+		}
+		return types.NewAccessListTransaction(config.GetChainID(), nonce, to, value, gas, gasPrice, data, *accessList), nil
+	}
+	if to == nil {
+		return types.NewContractCreation(nonce, value, gas, gasPrice, data), nil
+	}
+	return types.NewTransaction(nonce, *to, value, gas, gasPrice, data), nil
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
@@ -1313,11 +1635,9 @@ func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
+	tx, err := newTransaction(s.bc.Config(), s.bc.CurrentBlock().Number(), args.Nonce.Uint64(), args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
+	if err != nil {
+		return common.Hash{}, err
 	}
 
 	signer := s.bc.Config().GetSigner(s.bc.CurrentBlock().Number())
@@ -1335,7 +1655,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(encodedTx string) (string, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
+	if err := tx.UnmarshalBinary(common.FromHex(encodedTx)); err != nil {
 		return "", err
 	}
 
@@ -1412,13 +1732,14 @@ func (s *PublicBlockChainAPI) Sign(addr common.Address, data hexutil.Bytes) (hex
 
 // SignTransactionArgs represents the arguments to sign a transaction.
 type SignTransactionArgs struct {
-	From     common.Address
-	To       *common.Address
-	Nonce    *rpc.HexNumber
-	Value    *rpc.HexNumber
-	Gas      *rpc.HexNumber
-	GasPrice *rpc.HexNumber
-	Data     string
+	From       common.Address
+	To         *common.Address
+	Nonce      *rpc.HexNumber
+	Value      *rpc.HexNumber
+	Gas        *rpc.HexNumber
+	GasPrice   *rpc.HexNumber
+	Data       string
+	AccessList *types.AccessList
 
 	BlockNumber int64
 }
@@ -1494,11 +1815,7 @@ type SignTransactionResult struct {
 }
 
 func newTx(t *types.Transaction) *Tx {
-	var signer types.Signer = types.BasicSigner{}
-	if t.Protected() {
-		signer = types.NewChainIdSigner(t.ChainId())
-	}
-	from, _ := types.Sender(signer, t)
+	from, _ := types.Sender(types.DeriveSigner(t), t)
 	return &Tx{
 		tx:       t,
 		To:       t.To(),
@@ -1533,11 +1850,9 @@ func (s *PublicTransactionPoolAPI) SignTransaction(args SignTransactionArgs) (*S
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
+	tx, err := newTransaction(s.bc.Config(), s.bc.CurrentBlock().Number(), args.Nonce.Uint64(), args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
+	if err != nil {
+		return nil, err
 	}
 
 	signedTx, err := s.sign(args.From, tx)
@@ -1545,7 +1860,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(args SignTransactionArgs) (*S
 		return nil, err
 	}
 
-	data, err := rlp.EncodeToBytes(signedTx)
+	data, err := signedTx.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -1559,11 +1874,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() []*RPCTransaction {
 	pending := s.txPool.GetTransactions()
 	transactions := make([]*RPCTransaction, 0, len(pending))
 	for _, tx := range pending {
-		var signer types.Signer = types.BasicSigner{}
-		if tx.Protected() {
-			signer = types.NewChainIdSigner(tx.ChainId())
-		}
-		from, _ := types.Sender(signer, tx)
+		from, _ := types.Sender(types.DeriveSigner(tx), tx)
 		if s.am.HasAddress(from) {
 			transactions = append(transactions, newRPCPendingTransaction(tx))
 		}
@@ -1602,11 +1913,7 @@ func (s *PublicTransactionPoolAPI) Resend(tx Tx, gasPrice, gasLimit *rpc.HexNumb
 
 	pending := s.txPool.GetTransactions()
 	for _, p := range pending {
-		var signer types.Signer = types.BasicSigner{}
-		if p.Protected() {
-			signer = types.NewChainIdSigner(p.ChainId())
-		}
-
+		signer := types.DeriveSigner(p)
 		if pFrom, err := types.Sender(signer, p); err == nil && pFrom == tx.From && signer.Hash(p) == signer.Hash(tx.tx) {
 			if gasPrice == nil {
 				gasPrice = rpc.NewHexNumber(tx.tx.GasPrice())
@@ -1888,6 +2195,29 @@ func (api *PublicDebugAPI) GetBlockRlp(number uint64) (string, error) {
 	return fmt.Sprintf("%x", encoded), nil
 }
 
+// ExecutionWitness retrieves the state and headers needed to execute a single
+// block without access to the state database.
+func (api *PublicDebugAPI) ExecutionWitness(number uint64) (*core.Witness, error) {
+	block := api.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return api.eth.BlockChain().ExecutionWitness(block)
+}
+
+// ExecutionWitnessRlp retrieves the RLP encoded execution witness of a single block.
+func (api *PublicDebugAPI) ExecutionWitnessRlp(number uint64) (string, error) {
+	witness, err := api.ExecutionWitness(number)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := rlp.EncodeToBytes(witness)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", encoded), nil
+}
+
 // PrintBlock retrieves a block and returns its pretty printed form.
 func (api *PublicDebugAPI) PrintBlock(number uint64) (string, error) {
 	block := api.eth.BlockChain().GetBlockByNumber(number)
@@ -1903,7 +2233,7 @@ func (api *PublicDebugAPI) SeedHash(number uint64) (string, error) {
 	if block == nil {
 		return "", fmt.Errorf("block #%d not found", number)
 	}
-	hash, err := ethash.GetSeedHash(number)
+	hash, err := etchash.SeedHash(number, api.eth.chainConfig.GetEthashECIP1099Transition())
 	if err != nil {
 		return "", err
 	}
@@ -2027,18 +2357,92 @@ func (api *PublicDebugAPI) Vmodule(s string) (string, error) {
 // while replaying a transaction in debug mode as well as the amount of
 // gas used and the return value
 type ExecutionResult struct {
-	Gas         *big.Int `json:"gas"`
-	ReturnValue string   `json:"returnValue"`
+	Gas          *big.Int       `json:"gas"`
+	Failed       bool           `json:"failed"`
+	ReturnValue  string         `json:"returnValue"`
+	RevertReason string         `json:"revertReason,omitempty"`
+	StructLogs   []StructLogRes `json:"structLogs"`
+}
+
+// newExecutionResult assembles the result of a message traced by the struct
+// logger, decoding the revert reason if the execution was reverted.
+func newExecutionResult(st *core.StateTransition, ret []byte, gas *big.Int, failed bool, logs []vm.StructLog) *ExecutionResult {
+	result := &ExecutionResult{
+		Gas:         gas,
+		Failed:      failed,
+		ReturnValue: fmt.Sprintf("%x", ret),
+		StructLogs:  formatLogs(logs),
+	}
+	if st.Reverted() {
+		result.RevertReason, _ = abi.UnpackRevert(ret)
+	}
+	return result
+}
+
+// StructLogRes stores a structured log emitted by the EVM while replaying a
+// transaction in debug mode
+type StructLogRes struct {
+	Pc      uint64             `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     *big.Int           `json:"gas"`
+	GasCost *big.Int           `json:"gasCost"`
+	Depth   int                `json:"depth"`
+	Error   string             `json:"error,omitempty"`
+	Stack   *[]string          `json:"stack,omitempty"`
+	Memory  *[]string          `json:"memory,omitempty"`
+	Storage *map[string]string `json:"storage,omitempty"`
+}
+
+// formatLogs formats EVM returned structured logs for json output
+func formatLogs(structLogs []vm.StructLog) []StructLogRes {
+	formatted := make([]StructLogRes, len(structLogs))
+	for index, trace := range structLogs {
+		formatted[index] = StructLogRes{
+			Pc:      trace.Pc,
+			Op:      trace.OpName(),
+			Gas:     trace.Gas,
+			GasCost: trace.GasCost,
+			Depth:   trace.Depth,
+			Error:   trace.ErrorString(),
+		}
+		if trace.Stack != nil {
+			stack := make([]string, len(trace.Stack))
+			for i, stackValue := range trace.Stack {
+				stack[i] = fmt.Sprintf("%x", common.LeftPadBytes(stackValue.Bytes(), 32))
+			}
+			formatted[index].Stack = &stack
+		}
+		if trace.Memory != nil {
+			memory := make([]string, 0, (len(trace.Memory)+31)/32)
+			for i := 0; i+32 <= len(trace.Memory); i += 32 {
+				memory = append(memory, fmt.Sprintf("%x", trace.Memory[i:i+32]))
+			}
+			formatted[index].Memory = &memory
+		}
+		if trace.Storage != nil {
+			storage := make(map[string]string)
+			for i, storageValue := range trace.Storage {
+				storage[fmt.Sprintf("%x", i)] = fmt.Sprintf("%x", storageValue)
+			}
+			formatted[index].Storage = &storage
+		}
+	}
+	return formatted
 }
 
-// TraceCall executes a call and returns the amount of gas and optionally returned values.
-func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+// TraceCall executes a call and returns the amount of gas, the returned values
+// and the structured logs created during the execution of the EVM. Accounts in
+// the optional overrides are modified on a copy of the state before the call.
+func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber, config *vm.LogConfig, overrides *StateOverride) (*ExecutionResult, error) {
 	// Fetch the state associated with the block number
-	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr)
 	if stateDb == nil || err != nil {
 		return nil, err
 	}
 	stateDb = stateDb.Copy()
+	if err := overrides.Apply(stateDb); err != nil {
+		return nil, err
+	}
 
 	// Retrieve the account state object to interact with
 	var from *state.StateObject
@@ -2052,7 +2456,9 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 	} else {
 		from = stateDb.GetOrNewStateObject(args.From)
 	}
-	from.SetBalance(common.MaxBig)
+	if !overrides.hasBalance(from.Address()) {
+		from.SetBalance(common.MaxBig)
+	}
 
 	// Assemble the CALL invocation
 	msg := callmsg{
@@ -2063,6 +2469,9 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 		value:    args.Value.BigInt(),
 		data:     common.FromHex(args.Data),
 	}
+	if args.AccessList != nil {
+		msg.accessList = *args.AccessList
+	}
 	if msg.gas.Sign() == 0 {
 		msg.gas = big.NewInt(50000000)
 	}
@@ -2071,39 +2480,162 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 	}
 
 	// Execute the call and return
-	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+	logger := vm.NewStructLogger(config)
+	vmenv := core.NewEnvWithConfig(stateDb, s.config, s.bc, msg, block.Header(), vm.Config{Tracer: logger})
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
-	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+	st := core.NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb()
+	if err != nil {
+		return nil, err
+	}
+	return newExecutionResult(st, ret, gas, failed, logger.StructLogs()), nil
 }
 
-// TraceTransaction returns the amount of gas and execution result of the given transaction.
-func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash) (*ExecutionResult, error) {
-	var result *ExecutionResult
+// callTracerName selects the call tree tracer in a TraceConfig.
+const callTracerName = "callTracer"
+
+// TraceConfig holds extra parameters to trace functions.
+type TraceConfig struct {
+	*vm.LogConfig
+	// Tracer selects the tracer to use: the struct logger if empty, or
+	// "callTracer" for the tree of call frames.
+	Tracer *string `json:"tracer"`
+}
+
+// TraceTransaction returns the amount of gas, the execution result and the
+// structured logs created during the execution of the given transaction.
+// The optional config disables capturing the stack, memory or storage of
+// every step, or selects the call tracer returning the transaction's tree
+// of call frames instead.
+func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash, config *TraceConfig) (interface{}, error) {
 	tx, blockHash, _, txIndex := core.GetTransaction(s.eth.ChainDb(), txHash)
 	if tx == nil {
-		return result, fmt.Errorf("tx '%x' not found", txHash)
+		return nil, fmt.Errorf("tx '%x' not found", txHash)
 	}
 
-	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex))
+	var (
+		tracer    vm.Tracer
+		logConfig *vm.LogConfig
+	)
+	if config != nil {
+		logConfig = config.LogConfig
+	}
+	switch {
+	case config == nil || config.Tracer == nil || *config.Tracer == "":
+		tracer = vm.NewStructLogger(logConfig)
+	case *config.Tracer == callTracerName:
+		tracer = vm.NewCallTracer()
+	default:
+		return nil, fmt.Errorf("unknown tracer %q", *config.Tracer)
+	}
+	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex), vm.Config{Tracer: tracer})
 	if err != nil {
 		return nil, err
 	}
 
 	gp := new(core.GasPool).AddGas(tx.Gas())
-	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+	st := core.NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb()
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+
+	switch tracer := tracer.(type) {
+	case *vm.CallTracer:
+		return callTraceResult(tracer, msg, gas), nil
+	case *vm.StructLogger:
+		return newExecutionResult(st, ret, gas, failed, tracer.StructLogs()), nil
+	default:
+		return nil, fmt.Errorf("unsupported tracer type %T", tracer)
+	}
+}
+
+// callTraceResult returns the call tree collected by tracer while executing
+// msg. The outermost frame is amended to account for the whole transaction's
+// gas, i.e. including the intrinsic gas and refunds.
+func callTraceResult(tracer *vm.CallTracer, msg core.Message, gasUsed *big.Int) *vm.CallFrame {
+	root := tracer.Result()
+	if root != nil {
+		root.Gas = (*hexutil.Big)(new(big.Int).Set(msg.Gas()))
+		root.GasUsed = (*hexutil.Big)(new(big.Int).Set(gasUsed))
+	}
+	return root
+}
+
+// TxTraceResult is the call tree of a single transaction of a traced block.
+type TxTraceResult struct {
+	TxHash common.Hash   `json:"txHash"`
+	Result *vm.CallFrame `json:"result"`
+}
+
+// TraceBlockByNumber returns the tree of call frames of every transaction in
+// the block with the given number, which may also be the latest or the
+// pending block.
+func (s *PublicDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber) ([]*TxTraceResult, error) {
+	if blockNr < 0 && blockNr != rpc.LatestBlockNumber && blockNr != rpc.PendingBlockNumber {
+		return nil, fmt.Errorf("invalid block number %d", blockNr)
+	}
+	block := blockByNumber(s.eth.Miner(), s.eth.BlockChain(), blockNr)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return s.traceBlock(block)
+}
+
+// TraceBlockByHash returns the tree of call frames of every transaction in
+// the block with the given hash.
+func (s *PublicDebugAPI) TraceBlockByHash(blockHash common.Hash) ([]*TxTraceResult, error) {
+	block := s.eth.BlockChain().GetBlock(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	return s.traceBlock(block)
+}
+
+// traceBlock replays all transactions of the given block on top of its
+// parent's state, collecting the call tree of each of them.
+func (s *PublicDebugAPI) traceBlock(block *types.Block) ([]*TxTraceResult, error) {
+	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		config  = s.eth.chainConfig
+		header  = block.Header()
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		results = make([]*TxTraceResult, 0, len(block.Transactions()))
+	)
+	for i, tx := range block.Transactions() {
+		tx.SetSigner(config.GetSigner(header.Number))
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+
+		tracer := vm.NewCallTracer()
+		vmenv := core.NewEnvWithConfig(statedb, config, s.eth.BlockChain(), tx, header, vm.Config{Tracer: tracer})
+		_, gas, _, err := core.ApplyMessage(vmenv, tx, gp)
+		if err != nil {
+			return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		// Mirror the intermediate state handling of the state processor
+		if config.IsAtlantis(header.Number) {
+			statedb.Finalise(true)
+		} else {
+			statedb.IntermediateRoot(false)
+		}
+		results = append(results, &TxTraceResult{TxHash: tx.Hash(), Result: callTraceResult(tracer, tx, gas)})
+	}
+	return results, nil
 }
 
 // computeTxEnv returns the execution environment of a certain transaction.
-func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, *core.VMEnv, error) {
+// The environment of the requested transaction is configured with cfg, the
+// preceding transactions of the block are replayed without it.
+func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, cfg vm.Config) (core.Message, *core.VMEnv, error) {
 
 	// Create the parent state.
 	block := s.eth.BlockChain().GetBlock(blockHash)
@@ -2136,25 +2668,31 @@ func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.
 		}
 
 		msg := callmsg{
-			from:     from,
-			to:       tx.To(),
-			gas:      tx.Gas(),
-			gasPrice: tx.GasPrice(),
-			value:    tx.Value(),
-			data:     tx.Data(),
+			from:       from,
+			to:         tx.To(),
+			gas:        tx.Gas(),
+			gasPrice:   tx.GasPrice(),
+			value:      tx.Value(),
+			data:       tx.Data(),
+			accessList: tx.AccessList(),
 		}
 
-		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header())
 		if idx == txIndex {
-			return msg, vmenv, nil
+			return msg, core.NewEnvWithConfig(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header(), cfg), nil
 		}
+		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header())
 
 		gp := new(core.GasPool).AddGas(tx.Gas())
 		_, _, _, err := core.ApplyMessage(vmenv, msg, gp)
 		if err != nil {
 			return nil, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 		}
-		statedb.Finalise(true)
+		// Mirror the intermediate state handling of the state processor
+		if s.eth.chainConfig.IsAtlantis(block.Number()) {
+			statedb.Finalise(true)
+		} else {
+			statedb.IntermediateRoot(false)
+		}
 	}
 	return nil, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
 }
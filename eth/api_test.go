@@ -0,0 +1,98 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/event"
+	"github.com/eth-classic/go-ethereum/rpc"
+)
+
+// Tests that storage keys of eth_getProof are accepted in their short forms.
+func TestDecodeStorageKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  common.Hash
+		fail  bool
+	}{
+		{input: "0x0", want: common.Hash{}},
+		{input: "0x1", want: common.BigToHash(common.Big1)},
+		{input: "0x0100", want: common.HexToHash("0x0100")},
+		{input: "0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563", want: common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563")},
+		{input: "0xzz", fail: true},
+		{input: "0x01290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563", fail: true},
+	}
+	for _, test := range tests {
+		key, err := decodeStorageKey(test.input)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%s: expected error, got %x", test.input, key)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+		} else if key != test.want {
+			t.Errorf("%s: key mismatch: have %x, want %x", test.input, key, test.want)
+		}
+	}
+}
+
+// Tests that a balance override of the sender is seen by the called code,
+// instead of the call funding the sender with an unlimited balance.
+func TestCallSenderBalanceOverride(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	core.WriteGenesisBlockForTesting(db)
+	config := &core.ChainConfig{Forks: []*core.Fork{{Name: "Homestead", Block: big.NewInt(0)}}}
+	blockchain, err := core.NewBlockChain(db, config, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &PublicBlockChainAPI{config: config, bc: blockchain}
+
+	var (
+		sender   = common.HexToAddress("0x1000")
+		contract = common.HexToAddress("0x2000")
+		// CALLER BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		code    = hexutil.Bytes(common.FromHex("0x333160005260206000f3"))
+		balance = (*hexutil.Big)(big.NewInt(12345))
+	)
+	args := CallArgs{From: sender, To: &contract, Gas: rpc.NewHexNumber(100000), GasPrice: rpc.NewHexNumber(0)}
+
+	tests := []struct {
+		overrides StateOverride
+		want      *big.Int
+	}{
+		{StateOverride{contract: {Code: &code}}, common.MaxBig},
+		{StateOverride{contract: {Code: &code}, sender: {Balance: &balance}}, big.NewInt(12345)},
+	}
+	for i, test := range tests {
+		res, err := api.Call(args, rpc.LatestBlockNumber, &test.overrides)
+		if err != nil {
+			t.Fatalf("test %d: call failed: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(common.FromHex(res)); have.Cmp(test.want) != 0 {
+			t.Errorf("test %d: caller balance mismatch: have %v, want %v", i, have, test.want)
+		}
+	}
+}
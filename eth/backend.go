@@ -45,16 +45,16 @@ import (
 	"github.com/eth-classic/go-ethereum/miner"
 	"github.com/eth-classic/go-ethereum/node"
 	"github.com/eth-classic/go-ethereum/p2p"
+	"github.com/eth-classic/go-ethereum/pow"
+	"github.com/eth-classic/go-ethereum/pow/etchash"
 	"github.com/eth-classic/go-ethereum/rlp"
 	"github.com/eth-classic/go-ethereum/rpc"
 )
 
 const (
-	epochLength    = 30000
 	ethashRevision = 23
 
 	autoDAGcheckInterval = 10 * time.Hour
-	autoDAGepochHeight   = epochLength / 2
 )
 
 type Config struct {
@@ -69,6 +69,11 @@ type Config struct {
 	SkipBcVersionCheck bool // e.g. blockchain export
 	DatabaseCache      int
 	DatabaseHandles    int
+	NoPruning          bool   // Whether to keep the states of all blocks rather than garbage collect them
+	Snapshot           bool   // Whether to maintain flat snapshots of the recent states
+	FreezerThreshold   uint64 // Number of recent blocks kept out of the ancient store (0 = disabled)
+
+	ArtificialFinality core.ArtificialFinality // Whether MESS (ECBP-1100) is applied to reorgs
 
 	NatSpec   bool
 	DocRoot   string
@@ -82,6 +87,8 @@ type Config struct {
 	MinerThreads   int
 	SolcPath       string
 
+	TxPool core.TxPoolConfig
+
 	UseAddrTxIndex bool
 
 	GpoMinGasPrice          *big.Int
@@ -111,7 +118,7 @@ type Ethereum struct {
 	txMu            sync.Mutex
 	blockchain      *core.BlockChain
 	accountManager  *accounts.Manager
-	pow             *ethash.Ethash
+	pow             pow.PoW
 	protocolManager *ProtocolManager
 	SolcPath        string
 	solc            *compiler.Solidity
@@ -149,6 +156,15 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err := upgradeChainDatabase(chainDb); err != nil {
 		return nil, err
 	}
+	// Once enabled, the ancient store must always be opened with the database
+	if dir := ctx.ResolvePath("chaindata"); dir != "" {
+		ancient := filepath.Join(dir, "ancient")
+		if config.FreezerThreshold > 0 || common.FileExist(ancient) {
+			if chainDb, err = core.NewDatabaseWithFreezer(chainDb, ancient); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if err := addMipmapBloomBins(chainDb); err != nil {
 		return nil, err
 	}
@@ -217,19 +233,24 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
 		httpclient:              httpclient.New(config.DocRoot),
 	}
+	// etchash behaves exactly like ethash until the ECIP-1099 transition block
+	transition := config.ChainConfig.GetEthashECIP1099Transition()
+	if transition != nil {
+		glog.V(logger.Info).Infof("Consensus: etchash, ECIP-1099 epoch length from block %v", transition)
+	}
 	switch {
 	case config.PowTest:
 		glog.V(logger.Info).Infof("Consensus: ethash used in test mode")
-		eth.pow, err = ethash.NewForTesting()
+		eth.pow, err = etchash.NewForTesting(transition)
 		if err != nil {
 			return nil, err
 		}
 	case config.PowShared:
 		glog.V(logger.Info).Infof("Consensus: ethash used in shared mode")
-		eth.pow = ethash.NewShared()
+		eth.pow = etchash.NewShared(transition)
 
 	default:
-		eth.pow = ethash.New()
+		eth.pow = etchash.New(transition)
 	}
 
 	// Initialize indexes db if enabled
@@ -289,6 +310,27 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		return nil, err
 	}
+	// Garbage collect the states of old blocks, unless running an archive node
+	if !config.NoPruning {
+		if err := eth.blockchain.SetCacheConfig(core.DefaultCacheConfig); err != nil {
+			return nil, err
+		}
+	}
+	// Accelerate state reads with flat snapshots of the recent states
+	if config.Snapshot {
+		if err := eth.blockchain.EnableSnapshots(); err != nil {
+			return nil, err
+		}
+	}
+	// Move the immutable part of the chain into the ancient store
+	if config.FreezerThreshold > 0 {
+		if err := eth.blockchain.SetFreezerThreshold(config.FreezerThreshold); err != nil {
+			return nil, err
+		}
+	}
+	// Override the chain config on whether deep reorgs are protected against
+	eth.blockchain.SetArtificialFinality(config.ArtificialFinality)
+
 	// Configure enabled atxi for blockchain
 	if config.UseAddrTxIndex {
 		eth.blockchain.SetAtxi(&core.AtxiT{
@@ -298,7 +340,10 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 
 	eth.gpo = NewGasPriceOracle(eth)
 
-	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
+	if config.TxPool.Journal != "" {
+		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
+	}
+	newPool := core.NewTxPool(eth.chainConfig, config.TxPool, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
 	eth.txPool = newPool
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, uint64(config.NetworkId), eth.eventMux, eth.txPool, eth.pow, eth.blockchain, chainDb); err != nil {
@@ -444,6 +489,9 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 		s.StartAutoDAG()
 	}
 	s.protocolManager.Start(s.config.MaxPeers)
+	if ln := srvr.LocalNode(); ln != nil {
+		s.protocolManager.startEthEntryUpdate(ln)
+	}
 	s.netRPCService = NewPublicNetAPI(srvr, s.NetVersion())
 	return nil
 }
@@ -473,20 +521,22 @@ func (s *Ethereum) WaitForShutdown() {
 
 // StartAutoDAG() spawns a go routine that checks the DAG every autoDAGcheckInterval
 // by default that is 10 times per epoch
-// in epoch n, if we past autoDAGepochHeight within-epoch blocks,
-// it calls ethash.MakeDAG  to pregenerate the DAG for the next epoch n+1
+// in epoch n, if we past half of the epoch's blocks,
+// it calls etchash.MakeDAG  to pregenerate the DAG for the next epoch n+1
 // if it does not exist yet as well as remove the DAG for epoch n-1
 // the loop quits if autodagquit channel is closed, it can safely restart and
 // stop any number of times.
+// Epochs are 30000 blocks long, or 60000 blocks from the ECIP-1099 transition on.
 // For any more sophisticated pattern of DAG generation, use CLI subcommand
 // makedag
 func (self *Ethereum) StartAutoDAG() {
 	if self.autodagquit != nil {
 		return // already started
 	}
+	transition := self.chainConfig.GetEthashECIP1099Transition()
 	go func() {
 		glog.V(logger.Info).Infof("Automatic pregeneration of ethash DAG ON (ethash dir: %s)", ethash.DefaultDir)
-		var nextEpoch uint64
+		var nextEpochStart uint64
 		timer := time.After(0)
 		self.autodagquit = make(chan bool)
 		for {
@@ -494,26 +544,29 @@ func (self *Ethereum) StartAutoDAG() {
 			case <-timer:
 				glog.V(logger.Info).Infof("checking DAG (ethash dir: %s)", ethash.DefaultDir)
 				currentBlock := self.BlockChain().CurrentBlock().NumberU64()
-				thisEpoch := currentBlock / epochLength
-				if nextEpoch <= thisEpoch {
-					if currentBlock%epochLength > autoDAGepochHeight {
-						if thisEpoch > 0 {
-							previousDag, previousDagFull := dagFiles(thisEpoch - 1)
+				epochLength := etchash.EpochLength(currentBlock, transition)
+				thisEpochStart := currentBlock - currentBlock%epochLength
+				if nextEpochStart <= thisEpochStart {
+					if currentBlock%epochLength > epochLength/2 {
+						if thisEpochStart > 0 {
+							previousEpochStart := thisEpochStart - 1
+							previousEpochStart -= previousEpochStart % etchash.EpochLength(previousEpochStart, transition)
+							previousDag, previousDagFull := dagFiles(previousEpochStart, transition)
 							os.Remove(filepath.Join(ethash.DefaultDir, previousDag))
 							os.Remove(filepath.Join(ethash.DefaultDir, previousDagFull))
-							glog.V(logger.Info).Infof("removed DAG for epoch %d (%s)", thisEpoch-1, previousDag)
+							glog.V(logger.Info).Infof("removed DAG for block %d (%s)", previousEpochStart, previousDag)
 						}
-						nextEpoch = thisEpoch + 1
-						dag, _ := dagFiles(nextEpoch)
-						if _, err := os.Stat(dag); os.IsNotExist(err) {
-							glog.V(logger.Info).Infof("Pregenerating DAG for epoch %d (%s)", nextEpoch, dag)
-							err := ethash.MakeDAG(nextEpoch*epochLength, "") // "" -> ethash.DefaultDir
+						nextEpochStart = thisEpochStart + epochLength
+						dag, _ := dagFiles(nextEpochStart, transition)
+						if _, err := os.Stat(filepath.Join(ethash.DefaultDir, dag)); os.IsNotExist(err) {
+							glog.V(logger.Info).Infof("Pregenerating DAG for block %d (%s)", nextEpochStart, dag)
+							err := etchash.MakeDAG(nextEpochStart, "", transition) // "" -> ethash.DefaultDir
 							if err != nil {
-								glog.V(logger.Error).Infof("Error generating DAG for epoch %d (%s)", nextEpoch, dag)
+								glog.V(logger.Error).Infof("Error generating DAG for block %d (%s)", nextEpochStart, dag)
 								return
 							}
 						} else {
-							glog.V(logger.Error).Infof("DAG for epoch %d (%s)", nextEpoch, dag)
+							glog.V(logger.Error).Infof("DAG for block %d (%s)", nextEpochStart, dag)
 						}
 					}
 				}
@@ -555,10 +608,11 @@ func (self *Ethereum) SetSolc(solcPath string) (*compiler.Solidity, error) {
 	return self.Solc()
 }
 
-// dagFiles(epoch) returns the two alternative DAG filenames (not a path)
+// dagFiles(block, transition) returns the two alternative DAG filenames (not a path)
+// of the epoch the given block belongs to
 // 1) <revision>-<hex(seedhash[8])> 2) full-R<revision>-<hex(seedhash[8])>
-func dagFiles(epoch uint64) (string, string) {
-	seedHash, _ := ethash.GetSeedHash(epoch * epochLength)
+func dagFiles(block uint64, transition *big.Int) (string, string) {
+	seedHash, _ := etchash.SeedHash(block, transition)
 	dag := fmt.Sprintf("full-R%d-%x", ethashRevision, seedHash[:8])
 	return dag, "full-R" + dag
 }
@@ -21,7 +21,6 @@ import (
 
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core/types"
-	"github.com/eth-classic/go-ethereum/rlp"
 	"github.com/eth-classic/go-ethereum/rpc"
 )
 
@@ -73,7 +72,7 @@ func (b *ContractBackend) ContractCall(contract common.Address, data []byte, pen
 		block = rpc.PendingBlockNumber
 	}
 	// Execute the call and convert the output back to Go types
-	out, err := b.bcapi.Call(args, block)
+	out, err := b.bcapi.Call(args, block, nil)
 	return common.FromHex(out), err
 }
 
@@ -101,14 +100,14 @@ func (b *ContractBackend) EstimateGasLimit(sender common.Address, contract *comm
 		To:    contract,
 		Value: *rpc.NewHexNumber(value),
 		Data:  common.ToHex(data),
-	})
+	}, nil, nil)
 	return out.BigInt(), err
 }
 
 // SendTransaction implements bind.ContractTransactor injects the transaction
 // into the pending pool for execution.
 func (b *ContractBackend) SendTransaction(tx *types.Transaction) error {
-	raw, _ := rlp.EncodeToBytes(tx)
+	raw, _ := tx.MarshalBinary()
 	_, err := b.txapi.SendRawTransaction(common.ToHex(raw))
 	return err
 }
@@ -647,7 +647,8 @@ func (d *Downloader) fetchHeight(p *peer) (*types.Header, error) {
 
 	// Request the advertised remote head block and wait for the response
 	head, _ := p.currentHead()
-	go p.getRelHeaders(head, 1, 0, false)
+	id := p.newRequestID()
+	go p.getRelHeaders(id, head, 1, 0, false)
 
 	// After waits for the duration to elapse and then sends the current time on the returned channel.
 	// It is equivalent to NewTimer(d).C.
@@ -667,6 +668,11 @@ func (d *Downloader) fetchHeight(p *peer) (*types.Header, error) {
 				glog.V(logger.Debug).Infof("Received headers from incorrect peer(%s)", packet.PeerId())
 				break
 			}
+			// Discard late responses to previous requests
+			if packet.RequestId() != id {
+				glog.V(logger.Debug).Infof("%v: received headers for stale request %d", p, packet.RequestId())
+				break
+			}
 			// Make sure the peer actually gave something valid
 			headers := packet.(*headerPack).headers
 			if len(headers) != 1 {
@@ -714,7 +720,8 @@ func (d *Downloader) findAncestor(p *peer, height uint64) (uint64, error) {
 	if count > limit {
 		count = limit
 	}
-	go p.getAbsHeaders(uint64(from), count, 15, false)
+	id := p.newRequestID()
+	go p.getAbsHeaders(id, uint64(from), count, 15, false)
 
 	// Wait for the remote response to the head fetch
 	number, hash := uint64(0), common.Hash{}
@@ -728,11 +735,15 @@ func (d *Downloader) findAncestor(p *peer, height uint64) (uint64, error) {
 			return 0, errCancelHeaderFetch
 
 		case packet := <-d.headerCh:
-			// Discard anything not from the origin peer
+			// Discard anything not from the origin peer or for a previous request
 			if packet.PeerId() != p.id {
 				glog.V(logger.Debug).Warnln("Received headers from incorrect peer", "peer", packet.PeerId())
 				break
 			}
+			if packet.RequestId() != id {
+				glog.V(logger.Debug).Warnln("Received headers for stale request", "peer", packet.PeerId(), "id", packet.RequestId())
+				break
+			}
 			// Make sure the peer actually gave something valid
 			headers := packet.(*headerPack).headers
 			if len(headers) == 0 {
@@ -796,7 +807,8 @@ func (d *Downloader) findAncestor(p *peer, height uint64) (uint64, error) {
 		ttl := d.requestTTL()
 		timeout := time.After(ttl)
 
-		go p.getAbsHeaders(uint64(check), 1, 0, false)
+		id := p.newRequestID()
+		go p.getAbsHeaders(id, uint64(check), 1, 0, false)
 
 		// Wait until a reply arrives to this request
 		for arrived := false; !arrived; {
@@ -805,11 +817,15 @@ func (d *Downloader) findAncestor(p *peer, height uint64) (uint64, error) {
 				return 0, errCancelHeaderFetch
 
 			case packer := <-d.headerCh:
-				// Discard anything not from the origin peer
+				// Discard anything not from the origin peer or for a previous request
 				if packer.PeerId() != p.id {
 					glog.V(logger.Debug).Warnln("Received headers from incorrect peer", "peer", packer.PeerId())
 					break
 				}
+				if packer.RequestId() != id {
+					glog.V(logger.Debug).Warnln("Received headers for stale request", "peer", packer.PeerId(), "id", packer.RequestId())
+					break
+				}
 				// Make sure the peer actually gave something valid
 				headers := packer.(*headerPack).headers
 				if len(headers) != 1 {
@@ -868,19 +884,23 @@ func (d *Downloader) fetchHeaders(p *peer, from uint64, pivot uint64) error {
 	<-timeout.C                 // timeout channel should be initially empty
 	defer timeout.Stop()
 
-	var ttl time.Duration
+	var (
+		ttl time.Duration
+		id  uint64 // request id of the last skeleton fetch request
+	)
 	getHeaders := func(from uint64) {
 		request = time.Now()
+		id = p.newRequestID()
 
 		ttl = d.requestTTL()
 		timeout.Reset(ttl)
 
 		if skeleton {
 			glog.V(logger.Detail).Infof("Fetching skeleton headers, count=%v from=%v", MaxHeaderFetch, from)
-			go p.getAbsHeaders(from+uint64(MaxHeaderFetch)-1, MaxSkeletonSize, MaxHeaderFetch-1, false)
+			go p.getAbsHeaders(id, from+uint64(MaxHeaderFetch)-1, MaxSkeletonSize, MaxHeaderFetch-1, false)
 		} else {
 			glog.V(logger.Detail).Infof("Fetching full headers, count=%v from=%v", MaxHeaderFetch, from)
-			go p.getAbsHeaders(from, MaxHeaderFetch, 0, false)
+			go p.getAbsHeaders(id, from, MaxHeaderFetch, 0, false)
 		}
 	}
 	// Start pulling the header chain skeleton until all is done
@@ -897,6 +917,10 @@ func (d *Downloader) fetchHeaders(p *peer, from uint64, pivot uint64) error {
 				glog.V(logger.Debug).Warnln("Received skeleton from incorrect peer", "peer", packet.PeerId())
 				break
 			}
+			if packet.RequestId() != id {
+				glog.V(logger.Debug).Warnln("Received skeleton for stale request", "peer", packet.PeerId(), "id", packet.RequestId())
+				break
+			}
 			metrics.DLHeaderTimer.UpdateSince(request)
 			timeout.Stop()
 
@@ -990,16 +1014,16 @@ func (d *Downloader) fillHeaderSkeleton(from uint64, skeleton []*types.Header) (
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*headerPack)
-			return d.queue.DeliverHeaders(pack.peerId, pack.headers, d.headerProcCh)
+			return d.queue.DeliverHeaders(pack.peerId, pack.reqId, pack.headers, d.headerProcCh)
 		}
-		expire   = func() map[string]int { return d.queue.ExpireHeaders(d.requestTTL()) }
+		expire   = func() map[pendKey]int { return d.queue.ExpireHeaders(d.requestTTL()) }
 		throttle = func() bool { return false }
 		reserve  = func(p *peer, count int) (*fetchRequest, bool, error) {
 			return d.queue.ReserveHeaders(p, count), false, nil
 		}
-		fetch    = func(p *peer, req *fetchRequest) error { return p.FetchHeaders(req.From, MaxHeaderFetch) }
+		fetch    = func(p *peer, req *fetchRequest) error { return p.FetchHeaders(req.ID, req.From, MaxHeaderFetch) }
 		capacity = func(p *peer) int { return p.HeaderCapacity(d.requestRTT()) }
-		setIdle  = func(p *peer, accepted int) { p.SetHeadersIdle(accepted) }
+		setIdle  = func(p *peer, id uint64, accepted int) { p.SetHeadersIdle(id, accepted) }
 	)
 	err := d.fetchParts(errCancelHeaderFetch, d.headerCh, deliver, d.queue.headerContCh, expire,
 		d.queue.PendingHeaders, d.queue.InFlightHeaders, throttle, reserve,
@@ -1020,12 +1044,12 @@ func (d *Downloader) fetchBodies(from uint64) error {
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*bodyPack)
-			return d.queue.DeliverBodies(pack.peerId, pack.transactions, pack.uncles)
+			return d.queue.DeliverBodies(pack.peerId, pack.reqId, pack.transactions, pack.uncles)
 		}
-		expire   = func() map[string]int { return d.queue.ExpireBodies(d.requestTTL()) }
+		expire   = func() map[pendKey]int { return d.queue.ExpireBodies(d.requestTTL()) }
 		fetch    = func(p *peer, req *fetchRequest) error { return p.FetchBodies(req) }
 		capacity = func(p *peer) int { return p.BlockCapacity(d.requestRTT()) }
-		setIdle  = func(p *peer, accepted int) { p.SetBodiesIdle(accepted) }
+		setIdle  = func(p *peer, id uint64, accepted int) { p.SetBodiesIdle(id, accepted) }
 	)
 	err := d.fetchParts(errCancelBodyFetch, d.bodyCh, deliver, d.bodyWakeCh, expire,
 		d.queue.PendingBlocks, d.queue.InFlightBlocks, d.queue.ShouldThrottleBlocks, d.queue.ReserveBodies,
@@ -1044,12 +1068,12 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*receiptPack)
-			return d.queue.DeliverReceipts(pack.peerId, pack.receipts)
+			return d.queue.DeliverReceipts(pack.peerId, pack.reqId, pack.receipts)
 		}
-		expire   = func() map[string]int { return d.queue.ExpireReceipts(d.requestTTL()) }
+		expire   = func() map[pendKey]int { return d.queue.ExpireReceipts(d.requestTTL()) }
 		fetch    = func(p *peer, req *fetchRequest) error { return p.FetchReceipts(req) }
 		capacity = func(p *peer) int { return p.ReceiptCapacity(d.requestRTT()) }
-		setIdle  = func(p *peer, accepted int) { p.SetReceiptsIdle(accepted) }
+		setIdle  = func(p *peer, id uint64, accepted int) { p.SetReceiptsIdle(id, accepted) }
 	)
 	err := d.fetchParts(errCancelReceiptFetch, d.receiptCh, deliver, d.receiptWakeCh, expire,
 		d.queue.PendingReceipts, d.queue.InFlightReceipts, d.queue.ShouldThrottleReceipts, d.queue.ReserveReceipts,
@@ -1072,7 +1096,7 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 //  - deliveryCh:  channel from which to retrieve downloaded data packets (merged from all concurrent peers)
 //  - deliver:     processing callback to deliver data packets into type specific download queues (usually within `queue`)
 //  - wakeCh:      notification channel for waking the fetcher when new tasks are available (or sync completed)
-//  - expire:      task callback method to abort requests that took too long and return the faulty peers (traffic shaping)
+//  - expire:      task callback method to abort requests that took too long and return the faulty requests (traffic shaping)
 //  - pending:     task callback for the number of requests still needing download (detect completion/non-completability)
 //  - inFlight:    task callback for the number of in-progress requests (wait for all active downloads to finish)
 //  - throttle:    task callback to check if the processing queue is full and activate throttling (bound memory use)
@@ -1082,12 +1106,12 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 //  - cancel:      task callback to abort an in-flight download request and allow rescheduling it (in case of lost peer)
 //  - capacity:    network callback to retrieve the estimated type-specific bandwidth capacity of a peer (traffic shaping)
 //  - idle:        network callback to retrieve the currently (type specific) idle peers that can be assigned tasks
-//  - setIdle:     network callback to set a peer back to idle and update its estimated capacity (traffic shaping)
+//  - setIdle:     network callback to finish a request of a peer and update its estimated capacity (traffic shaping)
 //  - kind:        textual label of the type being downloaded to display in log mesages
 func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliver func(dataPack) (int, error), wakeCh chan bool,
-	expire func() map[string]int, pending func() int, inFlight func() bool, throttle func() bool, reserve func(*peer, int) (*fetchRequest, bool, error),
+	expire func() map[pendKey]int, pending func() int, inFlight func() bool, throttle func() bool, reserve func(*peer, int) (*fetchRequest, bool, error),
 	fetchHook func([]*types.Header), fetch func(*peer, *fetchRequest) error, cancel func(*fetchRequest), capacity func(*peer) int,
-	idle func() ([]*peer, int), setIdle func(*peer, int), kind string) error {
+	idle func() ([]*peer, int), setIdle func(*peer, uint64, int), kind string) error {
 
 	// Create a ticker to detect expired retrieval tasks
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -1112,10 +1136,10 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 					return err
 				}
 				// Unless a peer delivered something completely else than requested (usually
-				// caused by a timed out request which came through in the end), set it to
-				// idle. If the delivery's stale, the peer should have already been idled.
+				// caused by a timed out request which came through in the end), finish the
+				// request. If the delivery's stale, the request should have already been.
 				if err != errStaleDelivery {
-					setIdle(peer, accepted)
+					setIdle(peer, packet.RequestId(), accepted)
 				}
 				// Issue a log to the user to see what's going on
 				switch {
@@ -1157,8 +1181,8 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				return errNoPeers
 			}
 			// Check for fetch request timeouts and demote the responsible peers
-			for pid, fails := range expire() {
-				if peer := d.peers.Peer(pid); peer != nil {
+			for key, fails := range expire() {
+				if peer := d.peers.Peer(key.peer); peer != nil {
 					// If a lot of retrieval elements expired, we might have overestimated the remote peer or perhaps
 					// ourselves. Only reset to minimal throughput but don't drop just yet. If even the minimal times
 					// out that sync wise we need to get rid of the peer.
@@ -1168,10 +1192,10 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 					// how response times reacts, to it always requests one more than the minimum (i.e. min 2).
 					if fails > 2 {
 						glog.V(logger.Detail).Infoln("Data delivery timed out", "type", kind)
-						setIdle(peer, 0)
+						setIdle(peer, key.id, 0)
 					} else {
 						glog.V(logger.Detail).Infoln("Stalling delivery, dropping", "type", kind)
-						d.dropPeer(pid)
+						d.dropPeer(key.peer)
 					}
 				}
 			}
@@ -1183,51 +1207,58 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				}
 				break
 			}
-			// Send a download request to all idle peers, until throttled
+			// Send download requests to all idle peers, until throttled. Peers serving
+			// several requests at once stay idle, so keep going while requests are sent.
 			progressed, throttled, running := false, false, inFlight()
 			idles, total := idle()
 
-			for _, peer := range idles {
-				// Short circuit if throttling activated
-				if throttle() {
-					throttled = true
-					break
+			for peers := idles; len(peers) > 0 && !throttled; peers, _ = idle() {
+				issued := false
+				for _, peer := range peers {
+					// Short circuit if throttling activated
+					if throttle() {
+						throttled = true
+						break
+					}
+					// Short circuit if there is no more available task.
+					if pending() == 0 {
+						break
+					}
+					// Reserve a chunk of fetches for a peer. A nil can mean either that
+					// no more headers are available, or that the peer is known not to
+					// have them.
+					request, progress, err := reserve(peer, capacity(peer))
+					if err != nil {
+						return err
+					}
+					if progress {
+						progressed = true
+					}
+					if request == nil {
+						continue
+					}
+					if request.From > 0 {
+						glog.V(logger.Detail).Infoln("Requesting new batch of data", "type", kind, "from", request.From)
+					} else {
+						glog.V(logger.Detail).Infoln("Requesting new batch of data", "type", kind, "count", len(request.Headers), "from", request.Headers[0].Number)
+					}
+					// Fetch the chunk and make sure any errors return the hashes to the queue
+					if fetchHook != nil {
+						fetchHook(request.Headers)
+					}
+					if err := fetch(peer, request); err != nil {
+						// Although we could try and make an attempt to fix this, this error really
+						// means that we've double allocated a fetch task to a peer. If that is the
+						// case, the internal state of the downloader and the queue is very wrong so
+						// better hard crash and note the error instead of silently accumulating into
+						// a much bigger issue.
+						panic(fmt.Sprintf("%v: %s fetch assignment failed", peer, kind))
+					}
+					running, issued = true, true
 				}
-				// Short circuit if there is no more available task.
-				if pending() == 0 {
+				if !issued {
 					break
 				}
-				// Reserve a chunk of fetches for a peer. A nil can mean either that
-				// no more headers are available, or that the peer is known not to
-				// have them.
-				request, progress, err := reserve(peer, capacity(peer))
-				if err != nil {
-					return err
-				}
-				if progress {
-					progressed = true
-				}
-				if request == nil {
-					continue
-				}
-				if request.From > 0 {
-					glog.V(logger.Detail).Infoln("Requesting new batch of data", "type", kind, "from", request.From)
-				} else {
-					glog.V(logger.Detail).Infoln("Requesting new batch of data", "type", kind, "count", len(request.Headers), "from", request.Headers[0].Number)
-				}
-				// Fetch the chunk and make sure any errors return the hashes to the queue
-				if fetchHook != nil {
-					fetchHook(request.Headers)
-				}
-				if err := fetch(peer, request); err != nil {
-					// Although we could try and make an attempt to fix this, this error really
-					// means that we've double allocated a fetch task to a peer. If that is the
-					// case, the internal state of the downloader and the queue is very wrong so
-					// better hard crash and note the error instead of silently accumulating into
-					// a much bigger issue.
-					panic(fmt.Sprintf("%v: %s fetch assignment failed", peer, kind))
-				}
-				running = true
 			}
 			// Make sure that we have peers available for fetching. If all peers have been tried
 			// and all failed throw an error
@@ -1619,23 +1650,23 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 
 // DeliverHeaders injects a new batch of block headers received from a remote
 // node into the download schedule.
-func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) (err error) {
-	return d.deliver(id, d.headerCh, &headerPack{id, headers}, metrics.DLHeaders.Mark, metrics.DLHeaderDrops.Mark)
+func (d *Downloader) DeliverHeaders(id string, reqID uint64, headers []*types.Header) (err error) {
+	return d.deliver(id, d.headerCh, &headerPack{id, reqID, headers}, metrics.DLHeaders.Mark, metrics.DLHeaderDrops.Mark)
 }
 
 // DeliverBodies injects a new batch of block bodies received from a remote node.
-func (d *Downloader) DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
-	return d.deliver(id, d.bodyCh, &bodyPack{id, transactions, uncles}, metrics.DLBodies.Mark, metrics.DLBodyDrops.Mark)
+func (d *Downloader) DeliverBodies(id string, reqID uint64, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
+	return d.deliver(id, d.bodyCh, &bodyPack{id, reqID, transactions, uncles}, metrics.DLBodies.Mark, metrics.DLBodyDrops.Mark)
 }
 
 // DeliverReceipts injects a new batch of receipts received from a remote node.
-func (d *Downloader) DeliverReceipts(id string, receipts [][]*types.Receipt) (err error) {
-	return d.deliver(id, d.receiptCh, &receiptPack{id, receipts}, metrics.DLReceipts.Mark, metrics.DLReceiptDrops.Mark)
+func (d *Downloader) DeliverReceipts(id string, reqID uint64, receipts [][]*types.Receipt) (err error) {
+	return d.deliver(id, d.receiptCh, &receiptPack{id, reqID, receipts}, metrics.DLReceipts.Mark, metrics.DLReceiptDrops.Mark)
 }
 
 // DeliverNodeData injects a new batch of node state data received from a remote node.
-func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
-	return d.deliver(id, d.stateCh, &statePack{id, data}, metrics.DLStates.Mark, metrics.DLStateDrops.Mark)
+func (d *Downloader) DeliverNodeData(id string, reqID uint64, data [][]byte) (err error) {
+	return d.deliver(id, d.stateCh, &statePack{id, reqID, data}, metrics.DLStates.Mark, metrics.DLStateDrops.Mark)
 }
 
 // deliver injects a new batch of data received from a remote node.
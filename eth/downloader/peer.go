@@ -24,9 +24,9 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
 	"sort"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/eth-classic/go-ethereum/common"
@@ -36,19 +36,21 @@ import (
 )
 
 const (
-	maxLackingHashes  = 4096 // Maximum number of entries allowed on the list or lacking items
-	measurementImpact = 0.1  // The impact a single measurement has on a peer's final throughput value.
+	maxLackingHashes      = 4096 // Maximum number of entries allowed on the list or lacking items
+	measurementImpact     = 0.1  // The impact a single measurement has on a peer's final throughput value.
+	maxConcurrentRequests = 3    // Maximum number of in-flight requests of a kind to an eth/66 peer
 )
 
 // Head hash and total difficulty retriever for
 type currentHeadRetrievalFn func() (common.Hash, *big.Int)
 
-// Block header and body fetchers belonging to eth/62 and above
-type relativeHeaderFetcherFn func(common.Hash, int, int, bool) error
-type absoluteHeaderFetcherFn func(uint64, int, int, bool) error
-type blockBodyFetcherFn func([]common.Hash) error
-type receiptFetcherFn func([]common.Hash) error
-type stateFetcherFn func([]common.Hash) error
+// Block header and body fetchers belonging to eth/62 and above. The first
+// parameter is the request id, which peers before eth/66 ignore.
+type relativeHeaderFetcherFn func(uint64, common.Hash, int, int, bool) error
+type absoluteHeaderFetcherFn func(uint64, uint64, int, int, bool) error
+type blockBodyFetcherFn func(uint64, []common.Hash) error
+type receiptFetcherFn func(uint64, []common.Hash) error
+type stateFetcherFn func(uint64, []common.Hash) error
 
 var (
 	errAlreadyFetching   = errors.New("already fetching blocks from peer")
@@ -60,11 +62,6 @@ var (
 type peer struct {
 	id string // Unique identifier of the peer
 
-	headerIdle  int32 // Current header activity state of the peer (idle = 0, active = 1)
-	blockIdle   int32 // Current block activity state of the peer (idle = 0, active = 1)
-	receiptIdle int32 // Current receipt activity state of the peer (idle = 0, active = 1)
-	stateIdle   int32 // Current node data activity state of the peer (idle = 0, active = 1)
-
 	headerThroughput  float64 // Number of headers measured to be retrievable per second
 	blockThroughput   float64 // Number of blocks (bodies) measured to be retrievable per second
 	receiptThroughput float64 // Number of receipts measured to be retrievable per second
@@ -72,10 +69,10 @@ type peer struct {
 
 	rtt time.Duration // Request round trip time to track responsiveness (QoS)
 
-	headerStarted  time.Time // Time instance when the last header fetch was started
-	blockStarted   time.Time // Time instance when the last block (body) fetch was started
-	receiptStarted time.Time // Time instance when the last receipt fetch was started
-	stateStarted   time.Time // Time instance when the last node data fetch was started
+	headerStarted  map[uint64]time.Time // Start times of the in-flight header fetches by request id
+	blockStarted   map[uint64]time.Time // Start times of the in-flight block (body) fetches by request id
+	receiptStarted map[uint64]time.Time // Start times of the in-flight receipt fetches by request id
+	stateStarted   map[uint64]time.Time // Start times of the in-flight node data fetches by request id
 
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 
@@ -102,6 +99,11 @@ func newPeer(id string, version int, name string, currentHead currentHeadRetriev
 		id:      id,
 		lacking: make(map[common.Hash]struct{}),
 
+		headerStarted:  make(map[uint64]time.Time),
+		blockStarted:   make(map[uint64]time.Time),
+		receiptStarted: make(map[uint64]time.Time),
+		stateStarted:   make(map[uint64]time.Time),
+
 		currentHead:    currentHead,
 		getRelHeaders:  getRelHeaders,
 		getAbsHeaders:  getAbsHeaders,
@@ -120,10 +122,11 @@ func (p *peer) Reset() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	atomic.StoreInt32(&p.headerIdle, 0)
-	atomic.StoreInt32(&p.blockIdle, 0)
-	atomic.StoreInt32(&p.receiptIdle, 0)
-	atomic.StoreInt32(&p.stateIdle, 0)
+	for _, started := range []map[uint64]time.Time{p.headerStarted, p.blockStarted, p.receiptStarted, p.stateStarted} {
+		for id := range started {
+			delete(started, id)
+		}
+	}
 
 	p.headerThroughput = 0
 	p.blockThroughput = 0
@@ -133,20 +136,46 @@ func (p *peer) Reset() {
 	p.lacking = make(map[common.Hash]struct{})
 }
 
+// RequestID generates an id for the next request to a peer of the given
+// protocol version. Peers before eth/66 can't tag their responses, so all
+// their requests share the zero id.
+func RequestID(version int) uint64 {
+	if version < 66 {
+		return 0
+	}
+	id := rand.Uint64()
+	for id == 0 {
+		id = rand.Uint64()
+	}
+	return id
+}
+
+// newRequestID generates an id for the next request to the peer.
+func (p *peer) newRequestID() uint64 {
+	return RequestID(p.version)
+}
+
+// maxRequests returns the number of requests of a kind the peer may be serving
+// at the same time.
+func (p *peer) maxRequests() int {
+	if p.version < 66 {
+		return 1
+	}
+	return maxConcurrentRequests
+}
+
 // FetchHeaders sends a header retrieval request to the remote peer.
-func (p *peer) FetchHeaders(from uint64, count int) error {
+func (p *peer) FetchHeaders(id uint64, from uint64, count int) error {
 	// Sanity check the protocol version
 	if p.version < 62 {
 		panic(fmt.Sprintf("header fetch [eth/62+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.headerIdle, 0, 1) {
-		return errAlreadyFetching
+	if err := p.markFetching(id, p.headerStarted); err != nil {
+		return err
 	}
-	p.headerStarted = time.Now()
-
 	// Issue the header retrieval request (absolut upwards without gaps)
-	go p.getAbsHeaders(from, count, 0, false)
+	go p.getAbsHeaders(id, from, count, 0, false)
 
 	return nil
 }
@@ -158,17 +187,15 @@ func (p *peer) FetchBodies(request *fetchRequest) error {
 		panic(fmt.Sprintf("body fetch [eth/62+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.blockIdle, 0, 1) {
-		return errAlreadyFetching
+	if err := p.markFetching(request.ID, p.blockStarted); err != nil {
+		return err
 	}
-	p.blockStarted = time.Now()
-
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.getBlockBodies(hashes)
+	go p.getBlockBodies(request.ID, hashes)
 
 	return nil
 }
@@ -180,87 +207,101 @@ func (p *peer) FetchReceipts(request *fetchRequest) error {
 		panic(fmt.Sprintf("body fetch [eth/63+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.receiptIdle, 0, 1) {
-		return errAlreadyFetching
+	if err := p.markFetching(request.ID, p.receiptStarted); err != nil {
+		return err
 	}
-	p.receiptStarted = time.Now()
-
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.getReceipts(hashes)
+	go p.getReceipts(request.ID, hashes)
 
 	return nil
 }
 
 // FetchNodeData sends a node state data retrieval request to the remote peer.
-func (p *peer) FetchNodeData(hashes []common.Hash) error {
+func (p *peer) FetchNodeData(id uint64, hashes []common.Hash) error {
 	// Sanity check the protocol version
 	if p.version < 63 {
 		panic(fmt.Sprintf("node data fetch [eth/63+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
-		return errAlreadyFetching
+	if err := p.markFetching(id, p.stateStarted); err != nil {
+		return err
 	}
-	p.stateStarted = time.Now()
-	go p.getNodeData(hashes)
+	go p.getNodeData(id, hashes)
 	return nil
 }
 
-// SetHeadersIdle sets the peer to idle, allowing it to execute new header retrieval
-// requests. Its estimated header retrieval throughput is updated with that measured
-// just now.
-func (p *peer) SetHeadersIdle(delivered int) {
-	p.setIdle(p.headerStarted, delivered, &p.headerThroughput, &p.headerIdle)
+// markFetching records the start of a request in the given set of in-flight
+// requests, unless the peer can't take any more of them.
+func (p *peer) markFetching(id uint64, started map[uint64]time.Time) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := started[id]; ok || len(started) >= p.maxRequests() {
+		return errAlreadyFetching
+	}
+	started[id] = time.Now()
+	return nil
 }
 
-// SetBlocksIdle sets the peer to idle, allowing it to execute new block retrieval
-// requests. Its estimated block retrieval throughput is updated with that measured
-// just now.
-func (p *peer) SetBlocksIdle(delivered int) {
-	p.setIdle(p.blockStarted, delivered, &p.blockThroughput, &p.blockIdle)
+// SetHeadersIdle marks the header request with the given id as finished,
+// allowing the peer to execute new header retrieval requests. Its estimated
+// header retrieval throughput is updated with that measured just now.
+func (p *peer) SetHeadersIdle(id uint64, delivered int) {
+	p.setIdle(id, delivered, p.headerStarted, &p.headerThroughput)
 }
 
-// SetBodiesIdle sets the peer to idle, allowing it to execute block body retrieval
-// requests. Its estimated body retrieval throughput is updated with that measured
-// just now.
-func (p *peer) SetBodiesIdle(delivered int) {
-	p.setIdle(p.blockStarted, delivered, &p.blockThroughput, &p.blockIdle)
+// SetBlocksIdle marks the block request with the given id as finished, allowing
+// the peer to execute new block retrieval requests. Its estimated block
+// retrieval throughput is updated with that measured just now.
+func (p *peer) SetBlocksIdle(id uint64, delivered int) {
+	p.setIdle(id, delivered, p.blockStarted, &p.blockThroughput)
 }
 
-// SetReceiptsIdle sets the peer to idle, allowing it to execute new receipt
-// retrieval requests. Its estimated receipt retrieval throughput is updated
-// with that measured just now.
-func (p *peer) SetReceiptsIdle(delivered int) {
-	p.setIdle(p.receiptStarted, delivered, &p.receiptThroughput, &p.receiptIdle)
+// SetBodiesIdle marks the body request with the given id as finished, allowing
+// the peer to execute block body retrieval requests. Its estimated body
+// retrieval throughput is updated with that measured just now.
+func (p *peer) SetBodiesIdle(id uint64, delivered int) {
+	p.setIdle(id, delivered, p.blockStarted, &p.blockThroughput)
 }
 
-// SetNodeDataIdle sets the peer to idle, allowing it to execute new state trie
-// data retrieval requests. Its estimated state retrieval throughput is updated
-// with that measured just now.
-func (p *peer) SetNodeDataIdle(delivered int) {
-	p.setIdle(p.stateStarted, delivered, &p.stateThroughput, &p.stateIdle)
+// SetReceiptsIdle marks the receipt request with the given id as finished,
+// allowing the peer to execute new receipt retrieval requests. Its estimated
+// receipt retrieval throughput is updated with that measured just now.
+func (p *peer) SetReceiptsIdle(id uint64, delivered int) {
+	p.setIdle(id, delivered, p.receiptStarted, &p.receiptThroughput)
 }
 
-// setIdle sets the peer to idle, allowing it to execute new retrieval requests.
-// Its estimated retrieval throughput is updated with that measured just now.
-func (p *peer) setIdle(started time.Time, delivered int, throughput *float64, idle *int32) {
-	// Irrelevant of the scaling, make sure the peer ends up idle
-	defer atomic.StoreInt32(idle, 0)
+// SetNodeDataIdle marks the node data request with the given id as finished,
+// allowing the peer to execute new state trie data retrieval requests. Its
+// estimated state retrieval throughput is updated with that measured just now.
+func (p *peer) SetNodeDataIdle(id uint64, delivered int) {
+	p.setIdle(id, delivered, p.stateStarted, &p.stateThroughput)
+}
 
+// setIdle marks a request as finished, allowing the peer to execute new
+// retrieval requests. Its estimated retrieval throughput is updated with that
+// measured just now. Requests that already finished are ignored.
+func (p *peer) setIdle(id uint64, delivered int, started map[uint64]time.Time, throughput *float64) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	start, ok := started[id]
+	if !ok {
+		return
+	}
+	delete(started, id)
+
 	// If nothing was delivered (hard timeout / unavailable data), reduce throughput to minimum
 	if delivered == 0 {
 		*throughput = 0
 		return
 	}
 	// Otherwise update the throughput with a new measurement
-	elapsed := time.Since(started) + 1 // +1 (ns) to ensure non-zero divisor
+	elapsed := time.Since(start) + 1 // +1 (ns) to ensure non-zero divisor
 	measured := float64(delivered) / (float64(elapsed) / float64(time.Second))
 
 	*throughput = (1-measurementImpact)*(*throughput) + measurementImpact*measured
@@ -272,6 +313,15 @@ func (p *peer) setIdle(started time.Time, delivered int, throughput *float64, id
 		"miss", len(p.lacking), "rtt", p.rtt)
 }
 
+// idle checks whether the peer can take another request next to the given
+// in-flight ones.
+func (p *peer) idle(started map[uint64]time.Time) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(started) < p.maxRequests()
+}
+
 // HeaderCapacity retrieves the peers header download allowance based on its
 // previously discovered throughput.
 func (p *peer) HeaderCapacity(targetRTT time.Duration) int {
@@ -474,7 +524,7 @@ func (ps *peerSet) AllPeers() []*peer {
 // active peer set, ordered by their reputation.
 func (ps *peerSet) BlockIdlePeers() ([]*peer, int) {
 	idle := func(p *peer) bool {
-		return atomic.LoadInt32(&p.blockIdle) == 0
+		return p.idle(p.blockStarted)
 	}
 	throughput := func(p *peer) float64 {
 		p.lock.RLock()
@@ -488,56 +538,56 @@ func (ps *peerSet) BlockIdlePeers() ([]*peer, int) {
 // within the active peer set, ordered by their reputation.
 func (ps *peerSet) HeaderIdlePeers() ([]*peer, int) {
 	idle := func(p *peer) bool {
-		return atomic.LoadInt32(&p.headerIdle) == 0
+		return p.idle(p.headerStarted)
 	}
 	throughput := func(p *peer) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.headerThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 66, idle, throughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
 // the active peer set, ordered by their reputation.
 func (ps *peerSet) BodyIdlePeers() ([]*peer, int) {
 	idle := func(p *peer) bool {
-		return atomic.LoadInt32(&p.blockIdle) == 0
+		return p.idle(p.blockStarted)
 	}
 	throughput := func(p *peer) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.blockThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 66, idle, throughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
 // within the active peer set, ordered by their reputation.
 func (ps *peerSet) ReceiptIdlePeers() ([]*peer, int) {
 	idle := func(p *peer) bool {
-		return atomic.LoadInt32(&p.receiptIdle) == 0
+		return p.idle(p.receiptStarted)
 	}
 	throughput := func(p *peer) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.receiptThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 66, idle, throughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
 // peers within the active peer set, ordered by their reputation.
 func (ps *peerSet) NodeDataIdlePeers() ([]*peer, int) {
 	idle := func(p *peer) bool {
-		return atomic.LoadInt32(&p.stateIdle) == 0
+		return p.idle(p.stateStarted)
 	}
 	throughput := func(p *peer) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.stateThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 66, idle, throughput)
 }
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
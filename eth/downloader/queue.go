@@ -48,11 +48,24 @@ var (
 // fetchRequest is a currently running data retrieval operation.
 type fetchRequest struct {
 	Peer    *peer           // Peer to which the request was sent
+	ID      uint64          // Request id to match the response with (zero before eth/66)
 	From    uint64          // [eth/62] Requested chain element index (used for skeleton fills only)
 	Headers []*types.Header // [eth/62] Requested headers, sorted by request order
 	Time    time.Time       // Time when the request was made
 }
 
+// pendKey identifies an in-flight request by the peer it was sent to and its
+// request id.
+type pendKey struct {
+	peer string
+	id   uint64
+}
+
+// key returns the pending pool key of the request.
+func (r *fetchRequest) key() pendKey {
+	return pendKey{r.Peer.id, r.ID}
+}
+
 // fetchResult is a struct collecting partial results from data fetchers until
 // all outstanding pieces complete and the result as a whole can be processed.
 type fetchResult struct {
@@ -74,7 +87,7 @@ type queue struct {
 	headerTaskPool  map[uint64]*types.Header       // [eth/62] Pending header retrieval tasks, mapping starting indexes to skeleton headers
 	headerTaskQueue *prque.Prque                   // [eth/62] Priority queue of the skeleton indexes to fetch the filling headers for
 	headerPeerMiss  map[string]map[uint64]struct{} // [eth/62] Set of per-peer header batches known to be unavailable
-	headerPendPool  map[string]*fetchRequest       // [eth/62] Currently pending header retrieval operations
+	headerPendPool  map[pendKey]*fetchRequest      // [eth/62] Currently pending header retrieval operations
 	headerResults   []*types.Header                // [eth/62] Result cache accumulating the completed headers
 	headerProced    int                            // [eth/62] Number of headers already processed from the results
 	headerOffset    uint64                         // [eth/62] Number of the first header in the result cache
@@ -83,12 +96,12 @@ type queue struct {
 	// All data retrievals below are based on an already assembles header chain
 	blockTaskPool  map[common.Hash]*types.Header // [eth/62] Pending block (body) retrieval tasks, mapping hashes to headers
 	blockTaskQueue *prque.Prque                  // [eth/62] Priority queue of the headers to fetch the blocks (bodies) for
-	blockPendPool  map[string]*fetchRequest      // [eth/62] Currently pending block (body) retrieval operations
+	blockPendPool  map[pendKey]*fetchRequest     // [eth/62] Currently pending block (body) retrieval operations
 	blockDonePool  map[common.Hash]struct{}      // [eth/62] Set of the completed block (body) fetches
 
 	receiptTaskPool  map[common.Hash]*types.Header // [eth/63] Pending receipt retrieval tasks, mapping hashes to headers
 	receiptTaskQueue *prque.Prque                  // [eth/63] Priority queue of the headers to fetch the receipts for
-	receiptPendPool  map[string]*fetchRequest      // [eth/63] Currently pending receipt retrieval operations
+	receiptPendPool  map[pendKey]*fetchRequest     // [eth/63] Currently pending receipt retrieval operations
 	receiptDonePool  map[common.Hash]struct{}      // [eth/63] Set of the completed receipt fetches
 
 	resultCache  []*fetchResult     // Downloaded but not yet delivered fetch results
@@ -104,15 +117,15 @@ type queue struct {
 func newQueue() *queue {
 	lock := new(sync.Mutex)
 	return &queue{
-		headerPendPool:   make(map[string]*fetchRequest),
+		headerPendPool:   make(map[pendKey]*fetchRequest),
 		headerContCh:     make(chan bool),
 		blockTaskPool:    make(map[common.Hash]*types.Header),
 		blockTaskQueue:   prque.New(),
-		blockPendPool:    make(map[string]*fetchRequest),
+		blockPendPool:    make(map[pendKey]*fetchRequest),
 		blockDonePool:    make(map[common.Hash]struct{}),
 		receiptTaskPool:  make(map[common.Hash]*types.Header),
 		receiptTaskQueue: prque.New(),
-		receiptPendPool:  make(map[string]*fetchRequest),
+		receiptPendPool:  make(map[pendKey]*fetchRequest),
 		receiptDonePool:  make(map[common.Hash]struct{}),
 		resultCache:      make([]*fetchResult, blockCacheItems),
 		active:           sync.NewCond(lock),
@@ -129,16 +142,16 @@ func (q *queue) Reset() {
 	q.mode = FullSync
 
 	q.headerHead = common.Hash{}
-	q.headerPendPool = make(map[string]*fetchRequest)
+	q.headerPendPool = make(map[pendKey]*fetchRequest)
 
 	q.blockTaskPool = make(map[common.Hash]*types.Header)
 	q.blockTaskQueue.Reset()
-	q.blockPendPool = make(map[string]*fetchRequest)
+	q.blockPendPool = make(map[pendKey]*fetchRequest)
 	q.blockDonePool = make(map[common.Hash]struct{})
 
 	q.receiptTaskPool = make(map[common.Hash]*types.Header)
 	q.receiptTaskQueue.Reset()
-	q.receiptPendPool = make(map[string]*fetchRequest)
+	q.receiptPendPool = make(map[pendKey]*fetchRequest)
 	q.receiptDonePool = make(map[common.Hash]struct{})
 
 	q.resultCache = make([]*fetchResult, blockCacheItems)
@@ -238,7 +251,7 @@ func (q *queue) ShouldThrottleReceipts() bool {
 // resultSlots calculates the number of results slots available for requests
 // whilst adhering to both the item and the memory limit too of the results
 // cache.
-func (q *queue) resultSlots(pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}) int {
+func (q *queue) resultSlots(pendPool map[pendKey]*fetchRequest, donePool map[common.Hash]struct{}) int {
 	// Calculate the maximum length capped by the memory limit
 	limit := len(q.resultCache)
 	if common.StorageSize(len(q.resultCache))*q.resultSize > common.StorageSize(blockCacheMemory) {
@@ -416,9 +429,10 @@ func (q *queue) ReserveHeaders(p *peer, count int) *fetchRequest {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// Short circuit if the peer's already downloading something (sanity check to
-	// not corrupt state)
-	if _, ok := q.headerPendPool[p.id]; ok {
+	// Short circuit if the request is already pending (sanity check to not
+	// corrupt state)
+	id := p.newRequestID()
+	if _, ok := q.headerPendPool[pendKey{p.id, id}]; ok {
 		return nil
 	}
 	// Retrieve a batch of hashes, skipping previously failed ones
@@ -443,10 +457,11 @@ func (q *queue) ReserveHeaders(p *peer, count int) *fetchRequest {
 	}
 	request := &fetchRequest{
 		Peer: p,
+		ID:   id,
 		From: send,
 		Time: time.Now(),
 	}
-	q.headerPendPool[p.id] = request
+	q.headerPendPool[request.key()] = request
 	return request
 }
 
@@ -484,13 +499,14 @@ func (q *queue) ReserveReceipts(p *peer, count int) (*fetchRequest, bool, error)
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
 func (q *queue) reserveHeaders(p *peer, count int, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
-	pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}, isNoop func(*types.Header) bool) (*fetchRequest, bool, error) {
-	// Short circuit if the pool has been depleted, or if the peer's already
-	// downloading something (sanity check not to corrupt state)
+	pendPool map[pendKey]*fetchRequest, donePool map[common.Hash]struct{}, isNoop func(*types.Header) bool) (*fetchRequest, bool, error) {
+	// Short circuit if the pool has been depleted, or if the request is already
+	// pending (sanity check not to corrupt state)
 	if taskQueue.Empty() {
 		return nil, false, nil
 	}
-	if _, ok := pendPool[p.id]; ok {
+	id := p.newRequestID()
+	if _, ok := pendPool[pendKey{p.id, id}]; ok {
 		return nil, false, nil
 	}
 	// Calculate an upper limit on the items we might fetch (i.e. throttling)
@@ -553,10 +569,11 @@ func (q *queue) reserveHeaders(p *peer, count int, taskPool map[common.Hash]*typ
 	}
 	request := &fetchRequest{
 		Peer:    p,
+		ID:      id,
 		Headers: send,
 		Time:    time.Now(),
 	}
-	pendPool[p.id] = request
+	pendPool[request.key()] = request
 
 	return request, progress, nil
 }
@@ -579,7 +596,7 @@ func (q *queue) CancelReceipts(request *fetchRequest) {
 }
 
 // Cancel aborts a fetch request, returning all pending hashes to the task queue.
-func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool map[string]*fetchRequest) {
+func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool map[pendKey]*fetchRequest) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -589,7 +606,7 @@ func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool m
 	for _, header := range request.Headers {
 		taskQueue.Push(header, -float32(header.Number.Uint64()))
 	}
-	delete(pendPool, request.Peer.id)
+	delete(pendPool, request.key())
 }
 
 // Revoke cancels all pending requests belonging to a given peer. This method is
@@ -599,23 +616,29 @@ func (q *queue) Revoke(peerId string) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if request, ok := q.blockPendPool[peerId]; ok {
+	for key, request := range q.blockPendPool {
+		if key.peer != peerId {
+			continue
+		}
 		for _, header := range request.Headers {
 			q.blockTaskQueue.Push(header, -float32(header.Number.Uint64()))
 		}
-		delete(q.blockPendPool, peerId)
+		delete(q.blockPendPool, key)
 	}
-	if request, ok := q.receiptPendPool[peerId]; ok {
+	for key, request := range q.receiptPendPool {
+		if key.peer != peerId {
+			continue
+		}
 		for _, header := range request.Headers {
 			q.receiptTaskQueue.Push(header, -float32(header.Number.Uint64()))
 		}
-		delete(q.receiptPendPool, peerId)
+		delete(q.receiptPendPool, key)
 	}
 }
 
 // ExpireHeaders checks for in flight requests that exceeded a timeout allowance,
-// canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireHeaders(timeout time.Duration) map[string]int {
+// canceling them and returning the responsible requests for penalisation.
+func (q *queue) ExpireHeaders(timeout time.Duration) map[pendKey]int {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -623,8 +646,8 @@ func (q *queue) ExpireHeaders(timeout time.Duration) map[string]int {
 }
 
 // ExpireBodies checks for in flight block body requests that exceeded a timeout
-// allowance, canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireBodies(timeout time.Duration) map[string]int {
+// allowance, canceling them and returning the responsible requests for penalisation.
+func (q *queue) ExpireBodies(timeout time.Duration) map[pendKey]int {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -632,8 +655,8 @@ func (q *queue) ExpireBodies(timeout time.Duration) map[string]int {
 }
 
 // ExpireReceipts checks for in flight receipt requests that exceeded a timeout
-// allowance, canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireReceipts(timeout time.Duration) map[string]int {
+// allowance, canceling them and returning the responsible requests for penalisation.
+func (q *queue) ExpireReceipts(timeout time.Duration) map[pendKey]int {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -641,15 +664,15 @@ func (q *queue) ExpireReceipts(timeout time.Duration) map[string]int {
 }
 
 // expire is the generic check that move expired tasks from a pending pool back
-// into a task pool, returning all entities caught with expired tasks.
+// into a task pool, returning all requests caught with expired tasks.
 //
 // Note, this method expects the queue lock to be already held. The
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
-func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest, taskQueue *prque.Prque, timeoutMarker func(int64)) map[string]int {
+func (q *queue) expire(timeout time.Duration, pendPool map[pendKey]*fetchRequest, taskQueue *prque.Prque, timeoutMarker func(int64)) map[pendKey]int {
 	// Iterate over the expired requests and return each to the queue
-	expiries := make(map[string]int)
-	for id, request := range pendPool {
+	expiries := make(map[pendKey]int)
+	for key, request := range pendPool {
 		if time.Since(request.Time) > timeout {
 			// Update the metrics with the timeout
 			timeoutMarker(1)
@@ -661,13 +684,13 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 			for _, header := range request.Headers {
 				taskQueue.Push(header, -float32(header.Number.Uint64()))
 			}
-			// Add the peer to the expiry report along the the number of failed requests
-			expiries[id] = len(request.Headers)
+			// Add the request to the expiry report along the the number of failed items
+			expiries[key] = len(request.Headers)
 		}
 	}
 	// Remove the expired requests from the pending pool
-	for id := range expiries {
-		delete(pendPool, id)
+	for key := range expiries {
+		delete(pendPool, key)
 	}
 	return expiries
 }
@@ -679,17 +702,17 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 // If the headers are accepted, the method makes an attempt to deliver the set
 // of ready headers to the processor to keep the pipeline full. However it will
 // not block to prevent stalling other pending deliveries.
-func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
+func (q *queue) DeliverHeaders(id string, reqID uint64, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
 	// Short circuit if the data was never requested
-	request := q.headerPendPool[id]
+	request := q.headerPendPool[pendKey{id, reqID}]
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
 	metrics.DLHeaderTimer.UpdateSince(request.Time)
-	delete(q.headerPendPool, id)
+	delete(q.headerPendPool, request.key())
 
 	// Ensure headers can be mapped onto the skeleton chain
 	target := q.headerTaskPool[request.From].Hash()
@@ -763,7 +786,7 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 // DeliverBodies injects a block body retrieval response into the results queue.
 // The method returns the number of blocks bodies accepted from the delivery and
 // also wakes any threads waiting for data delivery.
-func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
+func (q *queue) DeliverBodies(id string, reqID uint64, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -775,13 +798,13 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLi
 		result.Uncles = uncleLists[index]
 		return nil
 	}
-	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, metrics.DLBodyTimer.UpdateSince, len(txLists), reconstruct)
+	return q.deliver(pendKey{id, reqID}, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, metrics.DLBodyTimer.UpdateSince, len(txLists), reconstruct)
 }
 
 // DeliverReceipts injects a receipt retrieval response into the results queue.
 // The method returns the number of transaction receipts accepted from the delivery
 // and also wakes any threads waiting for data delivery.
-func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int, error) {
+func (q *queue) DeliverReceipts(id string, reqID uint64, receiptList [][]*types.Receipt) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -792,7 +815,7 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 		result.Receipts = receiptList[index]
 		return nil
 	}
-	return q.deliver(id, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, metrics.DLReceiptTimer.UpdateSince, len(receiptList), reconstruct)
+	return q.deliver(pendKey{id, reqID}, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, metrics.DLReceiptTimer.UpdateSince, len(receiptList), reconstruct)
 }
 
 // deliver injects a data retrieval response into the results queue.
@@ -800,17 +823,17 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 // Note, this method expects the queue lock to be already held for writing. The
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
-func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
-	pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}, timeSince func(time.Time),
+func (q *queue) deliver(key pendKey, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
+	pendPool map[pendKey]*fetchRequest, donePool map[common.Hash]struct{}, timeSince func(time.Time),
 	results int, reconstruct func(header *types.Header, index int, result *fetchResult) error) (int, error) {
 
 	// Short circuit if the data was never requested
-	request := pendPool[id]
+	request := pendPool[key]
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
 	timeSince(request.Time)
-	delete(pendPool, id)
+	delete(pendPool, key)
 
 	// If no data items were retrieved, mark them as unavailable for the origin peer
 	if results == 0 {
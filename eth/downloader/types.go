@@ -69,6 +69,7 @@ type peerDropFn func(id string)
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() string
+	RequestId() uint64
 	Items() int
 	Stats() string
 }
@@ -76,21 +77,25 @@ type dataPack interface {
 // headerPack is a batch of block headers returned by a peer.
 type headerPack struct {
 	peerId  string
+	reqId   uint64
 	headers []*types.Header
 }
 
-func (p *headerPack) PeerId() string { return p.peerId }
-func (p *headerPack) Items() int     { return len(p.headers) }
-func (p *headerPack) Stats() string  { return fmt.Sprintf("%d", len(p.headers)) }
+func (p *headerPack) PeerId() string    { return p.peerId }
+func (p *headerPack) RequestId() uint64 { return p.reqId }
+func (p *headerPack) Items() int        { return len(p.headers) }
+func (p *headerPack) Stats() string     { return fmt.Sprintf("%d", len(p.headers)) }
 
 // bodyPack is a batch of block bodies returned by a peer.
 type bodyPack struct {
 	peerId       string
+	reqId        uint64
 	transactions [][]*types.Transaction
 	uncles       [][]*types.Header
 }
 
-func (p *bodyPack) PeerId() string { return p.peerId }
+func (p *bodyPack) PeerId() string    { return p.peerId }
+func (p *bodyPack) RequestId() uint64 { return p.reqId }
 func (p *bodyPack) Items() int {
 	if len(p.transactions) <= len(p.uncles) {
 		return len(p.transactions)
@@ -102,19 +107,23 @@ func (p *bodyPack) Stats() string { return fmt.Sprintf("%d:%d", len(p.transactio
 // receiptPack is a batch of receipts returned by a peer.
 type receiptPack struct {
 	peerId   string
+	reqId    uint64
 	receipts [][]*types.Receipt
 }
 
-func (p *receiptPack) PeerId() string { return p.peerId }
-func (p *receiptPack) Items() int     { return len(p.receipts) }
-func (p *receiptPack) Stats() string  { return fmt.Sprintf("%d", len(p.receipts)) }
+func (p *receiptPack) PeerId() string    { return p.peerId }
+func (p *receiptPack) RequestId() uint64 { return p.reqId }
+func (p *receiptPack) Items() int        { return len(p.receipts) }
+func (p *receiptPack) Stats() string     { return fmt.Sprintf("%d", len(p.receipts)) }
 
 // statePack is a batch of states returned by a peer.
 type statePack struct {
 	peerId string
+	reqId  uint64
 	states [][]byte
 }
 
-func (p *statePack) PeerId() string { return p.peerId }
-func (p *statePack) Items() int     { return len(p.states) }
-func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+func (p *statePack) PeerId() string    { return p.peerId }
+func (p *statePack) RequestId() uint64 { return p.reqId }
+func (p *statePack) Items() int        { return len(p.states) }
+func (p *statePack) Stats() string     { return fmt.Sprintf("%d", len(p.states)) }
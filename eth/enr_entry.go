@@ -0,0 +1,93 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/forkid"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+// ethEntry is the "eth" entry of the node record, which advertises the eth
+// protocol and the chain of the node on the discovery network.
+type ethEntry struct {
+	ForkID forkid.ID // Fork identifier per EIP-2124
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+// currentEthEntry creates the "eth" entry from the current chain head.
+func (pm *ProtocolManager) currentEthEntry() *ethEntry {
+	head := pm.blockchain.CurrentHeader().Number.Uint64()
+	return &ethEntry{ForkID: forkid.NewID(pm.chainConfig, pm.blockchain.Genesis().Hash(), head)}
+}
+
+// startEthEntryUpdate starts keeping the "eth" entry of the local node up to
+// date. It must be called after Start.
+func (pm *ProtocolManager) startEthEntryUpdate(ln *discover.LocalNode) {
+	pm.wg.Add(1)
+	go pm.ethEntryUpdateLoop(ln)
+}
+
+// ethEntryUpdateLoop keeps the "eth" entry of the local node record in sync
+// with the chain head, the fork ID changes when passing a fork block.
+func (pm *ProtocolManager) ethEntryUpdateLoop(ln *discover.LocalNode) {
+	defer pm.wg.Done()
+
+	sub := pm.eventMux.Subscribe(core.ChainHeadEvent{})
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case _, ok := <-sub.Chan():
+			if !ok {
+				return
+			}
+			ln.Set(pm.currentEthEntry())
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// nodeFilter reports whether a node found by discovery may be on our chain.
+// Nodes without a record are accepted because older clients don't serve
+// one, the fork ID is verified in the handshake anyway. Nodes with a record
+// must announce a compatible fork ID in their "eth" entry.
+func (pm *ProtocolManager) nodeFilter(n *discover.Node) bool {
+	r := n.Record()
+	if r == nil {
+		return true
+	}
+	var eth ethEntry
+	if err := r.Load(&eth); err != nil {
+		return false
+	}
+	if err := pm.forkFilter(eth.ForkID); err != nil {
+		glog.V(logger.Detail).Infof("Skipping node %x: %v", n.ID[:8], err)
+		return false
+	}
+	return true
+}
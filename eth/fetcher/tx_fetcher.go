@@ -0,0 +1,436 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+)
+
+const (
+	// MaxTxRetrievals is the maximum number of transactions requested from or
+	// served to a peer in a single message.
+	MaxTxRetrievals = 256
+
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+)
+
+// txPoolCheckFn is a callback type to check whether a transaction is known
+// locally.
+type txPoolCheckFn func(common.Hash) bool
+
+// txPoolAddFn is a callback type to add transactions to the local pool.
+type txPoolAddFn func([]*types.Transaction)
+
+// txRequesterFn is a callback type for sending a transaction retrieval request
+// to a peer.
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the notification of the availability of a batch of new
+// transactions at a peer.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes being announced
+}
+
+// txDelivery is the notification that a batch of transactions has been added
+// to the pool and should be forgotten by the fetcher.
+type txDelivery struct {
+	origin string        // Identifier of the peer originating the delivery
+	hashes []common.Hash // Batch of transaction hashes having been delivered
+	direct bool          // Whether this is a direct reply or a broadcast
+}
+
+// txRequest is an outstanding transaction retrieval request.
+type txRequest struct {
+	hashes []common.Hash // Transactions having been requested
+	time   time.Time     // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on hash
+// announcements. Announced transactions wait a short while for a broadcast
+// to deliver them first, then each one is requested from a single announcing
+// peer at a time. If that peer times out or doesn't deliver, it is requested
+// from another one.
+type TxFetcher struct {
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	// Stage 1: announced transactions waiting for a broadcast to arrive
+	waitlist  map[common.Hash]map[string]struct{} // Peers having announced a waiting transaction
+	waittime  map[common.Hash]time.Time           // Time of the first announcement of a waiting transaction
+	waitslots map[string]map[common.Hash]struct{} // Waiting announcements grouped by peer
+
+	// Stage 2: transactions to retrieve, either queued or being fetched
+	announces map[string]map[common.Hash]struct{} // Retrievable announcements grouped by peer
+	announced map[common.Hash]map[string]struct{} // Peers able to deliver a transaction
+	fetching  map[common.Hash]string              // Peer a transaction is currently being fetched from
+	requests  map[string]*txRequest               // Outstanding retrieval requests by peer
+
+	// Callbacks
+	hasTx    txPoolCheckFn // Checks whether a transaction is already known
+	addTxs   txPoolAddFn   // Inserts a batch of transactions into the pool
+	fetchTxs txRequesterFn // Retrieves a batch of transactions from a peer
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx txPoolCheckFn, addTxs txPoolAddFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		waitlist:  make(map[common.Hash]map[string]struct{}),
+		waittime:  make(map[common.Hash]time.Time),
+		waitslots: make(map[string]map[common.Hash]struct{}),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+	}
+}
+
+// Start boots up the announcement based transaction retrieval.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retrieval, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions at a peer. Transactions already known to the pool are ignored.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if !f.hasTx(hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknown}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue imports a batch of received transactions into the pool and stops
+// tracking their announcements. Direct deliveries are replies to retrieval
+// requests of the fetcher, the others are broadcasts.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) error {
+	f.addTxs(txs)
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop removes all announcements and requests of a disconnected peer, and
+// reschedules its outstanding retrievals with other peers.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, handling announcements, deliveries and
+// timeouts.
+func (f *TxFetcher) loop() {
+	waitTimer := time.NewTimer(0)
+	<-waitTimer.C
+	timeoutTimer := time.NewTimer(0)
+	<-timeoutTimer.C
+
+	for {
+		select {
+		case ann := <-f.notify:
+			// Announcements of transactions already being retrieved make the
+			// peer an alternate source, new ones wait for a broadcast first.
+			waiting := len(f.waitlist)
+			used := len(f.waitslots[ann.origin]) + len(f.announces[ann.origin])
+			for _, hash := range ann.hashes {
+				if used >= txAnnounceLimit {
+					glog.V(logger.Debug).Infof("Peer %s: exceeded outstanding transaction announcements (%d)", ann.origin, txAnnounceLimit)
+					break
+				}
+				if _, ok := f.announced[hash]; ok {
+					if _, ok := f.announces[ann.origin][hash]; !ok {
+						f.addAnnounce(ann.origin, hash)
+						used++
+					}
+					continue
+				}
+				if _, ok := f.waitlist[hash]; !ok {
+					f.waitlist[hash] = make(map[string]struct{})
+					f.waittime[hash] = time.Now()
+				}
+				if _, ok := f.waitlist[hash][ann.origin]; ok {
+					continue
+				}
+				f.waitlist[hash][ann.origin] = struct{}{}
+				if f.waitslots[ann.origin] == nil {
+					f.waitslots[ann.origin] = make(map[common.Hash]struct{})
+				}
+				f.waitslots[ann.origin][hash] = struct{}{}
+				used++
+			}
+			if waiting == 0 && len(f.waitlist) > 0 {
+				f.rescheduleWait(waitTimer)
+			}
+			if f.scheduleFetches() {
+				f.rescheduleTimeout(timeoutTimer)
+			}
+
+		case <-waitTimer.C:
+			// Move the transactions which waited long enough to the retrieval
+			// stage and request them.
+			now := time.Now()
+			for hash, first := range f.waittime {
+				if now.Sub(first) < txArriveTimeout {
+					continue
+				}
+				for peer := range f.waitlist[hash] {
+					delete(f.waitslots[peer], hash)
+					if len(f.waitslots[peer]) == 0 {
+						delete(f.waitslots, peer)
+					}
+					f.addAnnounce(peer, hash)
+				}
+				delete(f.waitlist, hash)
+				delete(f.waittime, hash)
+			}
+			if len(f.waitlist) > 0 {
+				f.rescheduleWait(waitTimer)
+			}
+			if f.scheduleFetches() {
+				f.rescheduleTimeout(timeoutTimer)
+			}
+
+		case <-timeoutTimer.C:
+			// Requests which timed out are retried with other peers. The slow
+			// peer is not asked for the same transactions again.
+			now := time.Now()
+			for peer, req := range f.requests {
+				if now.Sub(req.time) < txFetchTimeout {
+					continue
+				}
+				glog.V(logger.Debug).Infof("Peer %s: transaction retrieval timed out (%d txs)", peer, len(req.hashes))
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+					}
+					f.removeAnnounce(peer, hash)
+				}
+				delete(f.requests, peer)
+			}
+			f.scheduleFetches()
+			if len(f.requests) > 0 {
+				f.rescheduleTimeout(timeoutTimer)
+			}
+
+		case delivery := <-f.cleanup:
+			// Forget everything about the delivered transactions.
+			delivered := make(map[common.Hash]struct{}, len(delivery.hashes))
+			for _, hash := range delivery.hashes {
+				delivered[hash] = struct{}{}
+				f.forget(hash)
+			}
+			// Transactions requested from the peer but missing from its reply
+			// are not available there, retry them elsewhere.
+			if req := f.requests[delivery.origin]; req != nil && delivery.direct {
+				for _, hash := range req.hashes {
+					if _, ok := delivered[hash]; ok {
+						continue
+					}
+					if f.fetching[hash] == delivery.origin {
+						delete(f.fetching, hash)
+					}
+					f.removeAnnounce(delivery.origin, hash)
+				}
+				delete(f.requests, delivery.origin)
+			}
+			if f.scheduleFetches() {
+				f.rescheduleTimeout(timeoutTimer)
+			}
+
+		case peer := <-f.drop:
+			for hash := range f.waitslots[peer] {
+				delete(f.waitlist[hash], peer)
+				if len(f.waitlist[hash]) == 0 {
+					delete(f.waitlist, hash)
+					delete(f.waittime, hash)
+				}
+			}
+			delete(f.waitslots, peer)
+
+			if req := f.requests[peer]; req != nil {
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+					}
+				}
+				delete(f.requests, peer)
+			}
+			for hash := range f.announces[peer] {
+				f.removeAnnounce(peer, hash)
+			}
+			if f.scheduleFetches() {
+				f.rescheduleTimeout(timeoutTimer)
+			}
+
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// addAnnounce records that peer can deliver the transaction with the given hash.
+func (f *TxFetcher) addAnnounce(peer string, hash common.Hash) {
+	if f.announces[peer] == nil {
+		f.announces[peer] = make(map[common.Hash]struct{})
+	}
+	f.announces[peer][hash] = struct{}{}
+
+	if f.announced[hash] == nil {
+		f.announced[hash] = make(map[string]struct{})
+	}
+	f.announced[hash][peer] = struct{}{}
+}
+
+// removeAnnounce removes peer as a source of the transaction with the given
+// hash. The transaction is forgotten if no other source is known.
+func (f *TxFetcher) removeAnnounce(peer string, hash common.Hash) {
+	delete(f.announces[peer], hash)
+	if len(f.announces[peer]) == 0 {
+		delete(f.announces, peer)
+	}
+	delete(f.announced[hash], peer)
+	if len(f.announced[hash]) == 0 {
+		delete(f.announced, hash)
+	}
+}
+
+// forget drops all tracking of a transaction, which has arrived.
+func (f *TxFetcher) forget(hash common.Hash) {
+	for peer := range f.waitlist[hash] {
+		delete(f.waitslots[peer], hash)
+		if len(f.waitslots[peer]) == 0 {
+			delete(f.waitslots, peer)
+		}
+	}
+	delete(f.waitlist, hash)
+	delete(f.waittime, hash)
+
+	for peer := range f.announced[hash] {
+		delete(f.announces[peer], hash)
+		if len(f.announces[peer]) == 0 {
+			delete(f.announces, peer)
+		}
+	}
+	delete(f.announced, hash)
+	delete(f.fetching, hash)
+}
+
+// scheduleFetches requests transactions from every idle peer which announced
+// transactions not being fetched yet. It reports whether requests were sent.
+func (f *TxFetcher) scheduleFetches() bool {
+	scheduled := false
+	for peer, hashes := range f.announces {
+		if f.requests[peer] != nil {
+			continue
+		}
+		var batch []common.Hash
+		for hash := range hashes {
+			if _, ok := f.fetching[hash]; ok {
+				continue
+			}
+			batch = append(batch, hash)
+			if len(batch) == MaxTxRetrievals {
+				break
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		for _, hash := range batch {
+			f.fetching[hash] = peer
+		}
+		f.requests[peer] = &txRequest{hashes: batch, time: time.Now()}
+		scheduled = true
+
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				glog.V(logger.Debug).Infof("Peer %s: failed to request transactions: %v", peer, err)
+				f.Drop(peer)
+			}
+		}(peer, batch)
+	}
+	return scheduled
+}
+
+// rescheduleWait resets the timer to fire when the earliest waiting
+// announcement expires.
+func (f *TxFetcher) rescheduleWait(timer *time.Timer) {
+	var earliest time.Time
+	for _, t := range f.waittime {
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	timer.Reset(txArriveTimeout - time.Now().Sub(earliest))
+}
+
+// rescheduleTimeout resets the timer to fire when the earliest outstanding
+// request times out.
+func (f *TxFetcher) rescheduleTimeout(timer *time.Timer) {
+	var earliest time.Time
+	for _, req := range f.requests {
+		if earliest.IsZero() || req.time.Before(earliest) {
+			earliest = req.time
+		}
+	}
+	timer.Reset(txFetchTimeout - time.Now().Sub(earliest))
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+)
+
+// txFetchRequest is a retrieval request sent by the fetcher under test.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for mocking out the transaction pool and
+// the network.
+type txFetcherTester struct {
+	fetcher *TxFetcher
+
+	pool     map[common.Hash]*types.Transaction
+	requests chan txFetchRequest
+	failing  map[string]bool // Peers whose requests fail to send
+	lock     sync.RWMutex
+}
+
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		pool:     make(map[common.Hash]*types.Transaction),
+		requests: make(chan txFetchRequest, 16),
+		failing:  make(map[string]bool),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.addTxs, tester.fetchTxs)
+	tester.fetcher.Start()
+	return tester
+}
+
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.pool[hash] != nil
+}
+
+func (f *txFetcherTester) addTxs(txs []*types.Transaction) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, tx := range txs {
+		f.pool[tx.Hash()] = tx
+	}
+}
+
+func (f *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	f.lock.RLock()
+	failing := f.failing[peer]
+	f.lock.RUnlock()
+	if failing {
+		return errors.New("send failed")
+	}
+	f.requests <- txFetchRequest{peer, hashes}
+	return nil
+}
+
+// expectRequest waits for a retrieval request to the given peer.
+func (f *txFetcherTester) expectRequest(t *testing.T, peer string, hashes ...common.Hash) {
+	select {
+	case req := <-f.requests:
+		if req.peer != peer {
+			t.Fatalf("request sent to %s, want %s", req.peer, peer)
+		}
+		if len(req.hashes) != len(hashes) {
+			t.Fatalf("requested %d transactions, want %d", len(req.hashes), len(hashes))
+		}
+		want := make(map[common.Hash]bool)
+		for _, hash := range hashes {
+			want[hash] = true
+		}
+		for _, hash := range req.hashes {
+			if !want[hash] {
+				t.Fatalf("unexpected transaction %x requested", hash[:4])
+			}
+		}
+	case <-time.After(2 * txArriveTimeout):
+		t.Fatalf("no request sent to %s", peer)
+	}
+}
+
+// expectNoRequest checks that no retrieval request is sent within the arrival
+// timeout.
+func (f *txFetcherTester) expectNoRequest(t *testing.T) {
+	select {
+	case req := <-f.requests:
+		t.Fatalf("unexpected request to %s for %d transactions", req.peer, len(req.hashes))
+	case <-time.After(2 * txArriveTimeout):
+	}
+}
+
+func makeTestTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil)
+	}
+	return txs
+}
+
+// Tests that announced transactions are retrieved from the announcing peer
+// after the arrival timeout, and imported upon delivery.
+func TestTxFetcherAnnounceRetrieval(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTestTxs(3)
+	hashes := []common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}
+	tester.fetcher.Notify("A", hashes)
+	tester.expectRequest(t, "A", hashes...)
+
+	tester.fetcher.Enqueue("A", txs, true)
+	for _, hash := range hashes {
+		if !tester.hasTx(hash) {
+			t.Fatalf("transaction %x not imported", hash[:4])
+		}
+	}
+	// Announcing them again doesn't trigger a retrieval.
+	tester.fetcher.Notify("B", hashes)
+	tester.expectNoRequest(t)
+}
+
+// Tests that transactions broadcast while waiting for the arrival timeout are
+// not requested.
+func TestTxFetcherBroadcastBeforeRetrieval(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTestTxs(2)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash(), txs[1].Hash()})
+	tester.fetcher.Enqueue("B", txs[:1], false)
+	tester.expectRequest(t, "A", txs[1].Hash())
+}
+
+// Tests that transactions are requested from a single peer at a time, and
+// that transactions missing from a reply are retrieved from another peer.
+func TestTxFetcherMissingDelivery(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTestTxs(2)
+	hashes := []common.Hash{txs[0].Hash(), txs[1].Hash()}
+	tester.fetcher.Notify("A", hashes)
+	tester.fetcher.Notify("B", hashes)
+
+	req := <-tester.requests
+	if len(req.hashes) != 2 {
+		t.Fatalf("requested %d transactions, want 2", len(req.hashes))
+	}
+	tester.expectNoRequest(t)
+
+	other := "B"
+	if req.peer == "B" {
+		other = "A"
+	}
+	tester.fetcher.Enqueue(req.peer, txs[:1], true)
+	tester.expectRequest(t, other, txs[1].Hash())
+}
+
+// Tests that the retrievals of a dropped peer are rescheduled with another
+// peer, and that failing requests drop the peer.
+func TestTxFetcherDrop(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	tester.lock.Lock()
+	tester.failing["A"] = true
+	tester.lock.Unlock()
+
+	txs := makeTestTxs(1)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash()})
+	tester.fetcher.Notify("B", []common.Hash{txs[0].Hash()})
+
+	// Either A fails and B is asked, or B is asked right away.
+	tester.expectRequest(t, "B", txs[0].Hash())
+
+	tester.fetcher.Notify("C", []common.Hash{txs[0].Hash()})
+	tester.fetcher.Drop("B")
+	tester.expectRequest(t, "C", txs[0].Hash())
+}
+
+// Tests that transactions known to the pool are not tracked.
+func TestTxFetcherKnown(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTestTxs(1)
+	tester.addTxs(txs)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash()})
+	tester.expectNoRequest(t)
+}
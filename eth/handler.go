@@ -28,6 +28,7 @@ import (
 
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/core"
+	"github.com/eth-classic/go-ethereum/core/forkid"
 	"github.com/eth-classic/go-ethereum/core/types"
 	"github.com/eth-classic/go-ethereum/eth/downloader"
 	"github.com/eth-classic/go-ethereum/eth/fetcher"
@@ -37,6 +38,7 @@ import (
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/p2p"
 	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 	"github.com/eth-classic/go-ethereum/pow"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
@@ -76,7 +78,9 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
+	forkFilter forkid.Filter // Fork ID filter, constant for the lifetime of the node
 
 	SubProtocols []p2p.Protocol
 
@@ -107,6 +111,7 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 		chaindb:     chaindb,
 		chainConfig: config,
 		peers:       newPeerSet(),
+		forkFilter:  forkid.NewFilter(blockchain),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
@@ -170,6 +175,8 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{manager.currentEthEntry()},
+			NodeFilter: manager.nodeFilter,
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
@@ -195,6 +202,18 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 	}
 	manager.fetcher = fetcher.New(mux, blockchain.GetBlock, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
 
+	hasTx := func(hash common.Hash) bool {
+		return txpool.GetTransaction(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, txpool.AddTransactions, fetchTxs)
+
 	return manager, nil
 }
 
@@ -213,6 +232,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		glog.V(logger.Error).Infoln("Removal failed:", err)
 	}
@@ -278,7 +298,8 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	// Execute the Ethereum handshake
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		glog.V(logger.Debug).Infof("handler: %s ->handshakefailed err=%v", p, err)
 		return err
 	}
@@ -312,7 +333,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	pHead, _ := p.Head()
 	if headerN, doValidate := pm.getRequiredHashBlockNumber(head, pHead); doValidate {
 		// Request the peer's fork block header for extra-dat
-		if err := p.RequestHeadersByNumber(headerN, 1, 0, false); err != nil {
+		if err := p.RequestHeadersByNumber(downloader.RequestID(p.version), headerN, 1, 0, false); err != nil {
 			glog.V(logger.Debug).Infof("handler: %s ->headersbynumber err=%v", p, err)
 			return err
 		}
@@ -391,7 +412,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 	case p.version >= eth62 && msg.Code == GetBlockHeadersMsg:
 		// Decode the complex header query
 		var query getBlockHeadersData
-		if e := msg.Decode(&query); e != nil {
+		reqID, e := p.decodeMsg(msg, &query)
+		if e != nil {
 			err = errResp(ErrDecode, "%v: %v", msg, e)
 			mlogWireDelegate(p, "receive", GetBlockHeadersMsg, intSize, &query, err)
 			return
@@ -465,18 +487,23 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 				query.Origin.Number += (query.Skip + 1)
 			}
 		}
-		return p.SendBlockHeaders(headers)
+		return p.SendBlockHeaders(reqID, headers)
 
 	case p.version >= eth62 && msg.Code == BlockHeadersMsg:
 		// A batch of headers arrived to one of our previous requests
 		var headers []*types.Header
-		if e := msg.Decode(&headers); e != nil {
+		reqID, e := p.decodeMsg(msg, &headers)
+		if e != nil {
 			err = errResp(ErrDecode, "msg %v: %v", msg, e)
 			mlogWireDelegate(p, "receive", BlockHeadersMsg, intSize, headers, err)
 			return
 		}
 		defer mlogWireDelegate(p, "receive", BlockHeadersMsg, intSize, headers, err)
 
+		if !pm.fulfilRequest(p, reqID, BlockHeadersMsg) {
+			return nil
+		}
+
 		// Good will assumption. Even if the peer is ahead of the fork check header but returns
 		// empty header response, it might be that the peer is a light client which only keeps
 		// the last 256 block headers. Besides it does not prevent network attacks. See #313 for
@@ -504,7 +531,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
 		}
 		if len(headers) > 0 || !filter {
-			err := pm.downloader.DeliverHeaders(p.id, headers)
+			err := pm.downloader.DeliverHeaders(p.id, reqID, headers)
 			if err != nil {
 				glog.V(logger.Debug).Infoln("peer", p.id, err)
 			}
@@ -512,9 +539,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 
 	case p.version >= eth62 && msg.Code == GetBlockBodiesMsg:
 		// Decode the retrieval message
-		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err = msgStream.List(); err != nil {
-			return err
+		msgStream, reqID, e := p.requestStream(msg)
+		if e != nil {
+			return e
 		}
 		// Gather blocks until the fetch or network limits is reached
 		var (
@@ -538,19 +565,24 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 		}
 		mlogWireDelegate(p, "receive", GetBlockBodiesMsg, intSize, bodies, err)
-		return p.SendBlockBodiesRLP(bodies)
+		return p.SendBlockBodiesRLP(reqID, bodies)
 
 	case p.version >= eth62 && msg.Code == BlockBodiesMsg:
 		// A batch of block bodies arrived to one of our previous requests
 		var request blockBodiesData
 		// Deliver them all to the downloader for queuing
-		if e := msg.Decode(&request); e != nil {
+		reqID, e := p.decodeMsg(msg, &request)
+		if e != nil {
 			err = errResp(ErrDecode, "msg %v: %v", msg, e)
 			mlogWireDelegate(p, "receive", BlockBodiesMsg, intSize, request, err)
 			return
 		}
 		mlogWireDelegate(p, "receive", BlockBodiesMsg, intSize, request, err)
 
+		if !pm.fulfilRequest(p, reqID, BlockBodiesMsg) {
+			return nil
+		}
+
 		transactions := make([][]*types.Transaction, len(request))
 		uncles := make([][]*types.Header, len(request))
 
@@ -564,15 +596,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			transactions, uncles = pm.fetcher.FilterBodies(p.id, transactions, uncles, time.Now())
 		}
 		if len(transactions) > 0 || len(uncles) > 0 || !filter {
-			if e := pm.downloader.DeliverBodies(p.id, transactions, uncles); e != nil {
+			if e := pm.downloader.DeliverBodies(p.id, reqID, transactions, uncles); e != nil {
 				glog.V(logger.Debug).Infoln(e)
 			}
 		}
 
 	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
 		// Decode the retrieval message
-		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err = msgStream.List(); err != nil {
+		msgStream, reqID, e := p.requestStream(msg)
+		if e != nil {
+			err = e
 			mlogWireDelegate(p, "receive", GetNodeDataMsg, intSize, [][]byte{}, err)
 			return err
 		}
@@ -598,27 +631,33 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 		}
 		mlogWireDelegate(p, "receive", GetNodeDataMsg, intSize, data, err)
-		return p.SendNodeData(data)
+		return p.SendNodeData(reqID, data)
 
 	case p.version >= eth63 && msg.Code == NodeDataMsg:
 		// A batch of node state data arrived to one of our previous requests
 		var data [][]byte
 
-		if e := msg.Decode(&data); e != nil {
+		reqID, e := p.decodeMsg(msg, &data)
+		if e != nil {
 			err = errResp(ErrDecode, "msg %v: %v", msg, e)
 			mlogWireDelegate(p, "receive", NodeDataMsg, intSize, data, err)
 			return
 		}
 		mlogWireDelegate(p, "receive", NodeDataMsg, intSize, data, err)
+
+		if !pm.fulfilRequest(p, reqID, NodeDataMsg) {
+			return nil
+		}
 		// Deliver all to the downloader
-		if e := pm.downloader.DeliverNodeData(p.id, data); e != nil {
+		if e := pm.downloader.DeliverNodeData(p.id, reqID, data); e != nil {
 			glog.V(logger.Core).Warnf("failed to deliver node state data: %v", e)
 		}
 
 	case p.version >= eth63 && msg.Code == GetReceiptsMsg:
 		// Decode the retrieval message
-		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err = msgStream.List(); err != nil {
+		msgStream, reqID, e := p.requestStream(msg)
+		if e != nil {
+			err = e
 			mlogWireDelegate(p, "receive", GetReceiptsMsg, intSize, []rlp.RawValue{}, err)
 			return err
 		}
@@ -653,18 +692,23 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 		}
 		mlogWireDelegate(p, "receive", GetReceiptsMsg, intSize, receipts, err)
-		return p.SendReceiptsRLP(receipts)
+		return p.SendReceiptsRLP(reqID, receipts)
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
 		// A batch of receipts arrived to one of our previous requests
 		var receipts [][]*types.Receipt
-		if err := msg.Decode(&receipts); err != nil {
+		reqID, err := p.decodeMsg(msg, &receipts)
+		if err != nil {
 			mlogWireDelegate(p, "receive", ReceiptsMsg, intSize, receipts, err)
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		mlogWireDelegate(p, "receive", ReceiptsMsg, intSize, receipts, err)
+
+		if !pm.fulfilRequest(p, reqID, ReceiptsMsg) {
+			return nil
+		}
 		// Deliver all to the downloader
-		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
+		if err := pm.downloader.DeliverReceipts(p.id, reqID, receipts); err != nil {
 			glog.V(logger.Core).Warnf("failed to deliver receipts: %v", err)
 		}
 
@@ -710,7 +754,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 		}
 		for _, block := range unknown {
 			// TODO Breaking /eth tests
-			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.RequestBodies)
+			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, func(hashes []common.Hash) error {
+				return p.RequestBodies(downloader.RequestID(p.version), hashes)
+			})
 		}
 
 	case msg.Code == NewBlockMsg:
@@ -764,20 +810,95 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			glog.V(logger.Detail).Infof("Peer %s: NOT setting head: tdWas=%v trueTD=%v", p.id, td, trueTD)
 		}
 
-	case msg.Code == TxMsg:
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// New transaction announcements arrived, make sure we have a valid and
+		// fresh chain to handle them
+		if atomic.LoadUint32(&pm.acceptsTxs) == 0 {
+			mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, []common.Hash{}, errors.New("not synced"))
+			break
+		}
+		var hashes []common.Hash
+		if e := msg.Decode(&hashes); e != nil {
+			err = errResp(ErrDecode, "msg %v: %v", msg, e)
+			mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, hashes, err)
+			return
+		}
+		mlogWireDelegate(p, "receive", NewPooledTransactionHashesMsg, intSize, hashes, err)
+		// Schedule all the unknown hashes for retrieval
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream, reqID, e := p.requestStream(msg)
+		if e != nil {
+			err = e
+			mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, []common.Hash{}, err)
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash      common.Hash
+			bytes     int
+			requested []common.Hash
+			hashes    []common.Hash
+			txs       []rlp.RawValue
+		)
+		for bytes < softResponseLimit && len(requested) < fetcher.MaxTxRetrievals {
+			// Retrieve the hash of the next transaction
+			if e := msgStream.Decode(&hash); e == rlp.EOL {
+				break
+			} else if e != nil {
+				err = errResp(ErrDecode, "msg %v: %v", msg, e)
+				mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, requested, err)
+				return err
+			}
+			requested = append(requested, hash)
+			// Retrieve the requested transaction, skipping if unknown to us
+			tx := pm.txpool.GetTransaction(hash)
+			if tx == nil {
+				continue
+			}
+			if encoded, err := rlp.EncodeToBytes(tx); err != nil {
+				glog.V(logger.Error).Infof("failed to encode transaction: %v", err)
+			} else {
+				hashes = append(hashes, hash)
+				txs = append(txs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		mlogWireDelegate(p, "receive", GetPooledTransactionsMsg, intSize, requested, err)
+		return p.SendPooledTransactionsRLP(reqID, hashes, txs)
+
+	case msg.Code == TxMsg || (p.version >= eth65 && msg.Code == PooledTransactionsMsg):
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
 		if atomic.LoadUint32(&pm.acceptsTxs) == 0 {
-			mlogWireDelegate(p, "receive", TxMsg, intSize, []*types.Transaction{}, errors.New("not synced"))
+			mlogWireDelegate(p, "receive", msg.Code, intSize, []*types.Transaction{}, errors.New("not synced"))
 			break
 		}
 		// Transactions can be processed, parse all of them and deliver to the pool
-		var txs []*types.Transaction
-		if e := msg.Decode(&txs); e != nil {
+		var (
+			txs   []*types.Transaction
+			reqID uint64
+			e     error
+		)
+		if msg.Code == PooledTransactionsMsg {
+			reqID, e = p.decodeMsg(msg, &txs)
+		} else {
+			e = msg.Decode(&txs)
+		}
+		if e != nil {
 			err = errResp(ErrDecode, "msg %v: %v", msg, e)
-			mlogWireDelegate(p, "receive", TxMsg, intSize, txs, err)
+			mlogWireDelegate(p, "receive", msg.Code, intSize, txs, err)
 			return
 		}
-		mlogWireDelegate(p, "receive", TxMsg, intSize, txs, err)
+		mlogWireDelegate(p, "receive", msg.Code, intSize, txs, err)
+
+		if msg.Code == PooledTransactionsMsg && !pm.fulfilRequest(p, reqID, PooledTransactionsMsg) {
+			return nil
+		}
 		for i, tx := range txs {
 			// Validate and mark the remote transaction
 			if tx == nil {
@@ -785,7 +906,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddTransactions(txs)
+		pm.txFetcher.Enqueue(p.id, txs, msg.Code == PooledTransactionsMsg)
 
 	default:
 		err = errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -795,6 +916,22 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 	return nil
 }
 
+// fulfilRequest matches a response from an eth/66 peer up with the request it
+// answers, reporting whether the response should be processed further. Late
+// or unsolicited responses are dropped without disconnecting the peer.
+func (pm *ProtocolManager) fulfilRequest(p *peer, id uint64, code uint64) bool {
+	if p.version < eth66 {
+		return true
+	}
+	elapsed, err := p.tracker.fulfil(id, code)
+	if err != nil {
+		glog.V(logger.Debug).Infof("%v: dropping %s response id=%d: %v", p, ProtocolMessageStringer(uint(code)), id, err)
+		return false
+	}
+	glog.V(logger.Detail).Infof("%v: %s response id=%d took %v", p, ProtocolMessageStringer(uint(code)), id, elapsed)
+	return true
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -828,15 +965,24 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 }
 
 // BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// already have the given transaction. The full transaction is only sent to the
+// square root of them; eth/65 peers among the rest get just its hash and can
+// retrieve it if unknown, older peers get the full transaction as well.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
 	// Broadcast transaction to a batch of peers not knowing about it
 	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
-		peer.AsyncSendTransactions(types.Transactions{tx})
+	direct := int(math.Sqrt(float64(len(peers))))
+	var sent, announced int
+	for i, peer := range peers {
+		if i < direct || peer.version < eth65 {
+			peer.AsyncSendTransactions(types.Transactions{tx})
+			sent++
+		} else {
+			peer.AsyncSendPooledTransactionHashes([]common.Hash{hash})
+			announced++
+		}
 	}
-	glog.V(logger.Detail).Infof("broadcast tx [%s] to %d peers", tx.Hash().Hex(), len(peers))
+	glog.V(logger.Detail).Infof("broadcast tx [%s] to %d peers, announced to %d peers", tx.Hash().Hex(), sent, announced)
 }
 
 // Mined broadcast loop
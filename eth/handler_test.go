@@ -64,6 +64,7 @@ func TestProtocolCompatibility(t *testing.T) {
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders62(t *testing.T) { testGetBlockHeaders(t, 62) }
 func TestGetBlockHeaders63(t *testing.T) { testGetBlockHeaders(t, 63) }
+func TestGetBlockHeaders64(t *testing.T) { testGetBlockHeaders(t, 64) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxHashFetch+15, nil, nil)
@@ -226,9 +227,32 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that eth/66 header queries are answered with the request id echoed, so
+// that the requester can match them up with concurrent queries.
+func TestGetBlockHeaders66(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 16, nil, nil)
+	peer, _ := newTestPeer("peer", 66, pm, true)
+	defer peer.close()
+
+	headers := []*types.Header{
+		pm.blockchain.GetBlockByNumber(4).Header(),
+		pm.blockchain.GetBlockByNumber(5).Header(),
+	}
+	for _, id := range []uint64{1, 1111, 2} {
+		query := &getBlockHeadersData{Origin: hashOrNumber{Number: 4}, Amount: 2}
+		if _, err := p2p.Send(peer.app, GetBlockHeadersMsg, []interface{}{id, query}); err != nil {
+			t.Fatalf("send error: %v", err)
+		}
+		if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, []interface{}{id, headers}); err != nil {
+			t.Errorf("request %d: headers mismatch: %v", id, err)
+		}
+	}
+}
+
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies62(t *testing.T) { testGetBlockBodies(t, 62) }
 func TestGetBlockBodies63(t *testing.T) { testGetBlockBodies(t, 63) }
+func TestGetBlockBodies64(t *testing.T) { testGetBlockBodies(t, 64) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxBlockFetch+15, nil, nil)
@@ -303,6 +327,7 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the node state database can be retrieved based on hashes.
 func TestGetNodeData63(t *testing.T) { testGetNodeData(t, 63) }
+func TestGetNodeData64(t *testing.T) { testGetNodeData(t, 64) }
 
 func testGetNodeData(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -397,6 +422,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
+func TestGetReceipt64(t *testing.T) { testGetReceipt(t, 64) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
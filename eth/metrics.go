@@ -60,7 +60,7 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		messages, bytes = metrics.MsgHashIn, metrics.MsgHashInBytes
 	case msg.Code == NewBlockMsg:
 		messages, bytes = metrics.MsgBlockIn, metrics.MsgBlockInBytes
-	case msg.Code == TxMsg:
+	case msg.Code == TxMsg, rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
 		messages, bytes = metrics.MsgTXNIn, metrics.MsgTXNInBytes
 	}
 	messages.Mark(1)
@@ -84,7 +84,7 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		messages, bytes = metrics.MsgHashOut, metrics.MsgHashOutBytes
 	case msg.Code == NewBlockMsg:
 		messages, bytes = metrics.MsgBlockOut, metrics.MsgBlockOutBytes
-	case msg.Code == TxMsg:
+	case msg.Code == TxMsg, rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
 		messages, bytes = metrics.MsgTXNOut, metrics.MsgTXNOutBytes
 	}
 	messages.Mark(1)
@@ -36,6 +36,12 @@ var mlogLinesWire = []*logger.MLogT{
 	mlogWireReceiveGetReceipts,
 	mlogWireSendReceipts,
 	mlogWireReceiveReceipts,
+	mlogWireSendNewPooledTransactionHashes,
+	mlogWireReceiveNewPooledTransactionHashes,
+	mlogWireSendGetPooledTransactions,
+	mlogWireReceiveGetPooledTransactions,
+	mlogWireSendPooledTransactions,
+	mlogWireReceivePooledTransactions,
 	mlogWireReceiveInvalid,
 }
 
@@ -76,6 +82,15 @@ func mlogWireDelegate(p *peer, direction string, msgCode uint64, size int, data
 	switch msgCode {
 	case StatusMsg:
 		d, ok := data.(*statusData)
+		if d64, ok64 := data.(*statusData64); ok64 && d64 != nil {
+			d, ok = &statusData{
+				ProtocolVersion: d64.ProtocolVersion,
+				NetworkId:       uint32(d64.NetworkId),
+				TD:              d64.TD,
+				CurrentBlock:    d64.CurrentBlock,
+				GenesisBlock:    d64.GenesisBlock,
+			}, true
+		}
 		if !ok || d == nil {
 			d = &statusData{
 				TD:           new(big.Int),
@@ -371,6 +386,55 @@ func mlogWireDelegate(p *peer, direction string, msgCode uint64, size int, data
 			}
 		}
 
+	case NewPooledTransactionHashesMsg:
+		if payload, ok := data.([]common.Hash); ok {
+			details = append(details, len(payload))
+		} else if err != nil {
+			details = append(details, 0)
+		} else {
+			glog.Fatal("cant cast: NewPooledTransactionHashesMsg", direction)
+		}
+		if direction == "send" {
+			line = mlogWireSendNewPooledTransactionHashes
+		} else {
+			line = mlogWireReceiveNewPooledTransactionHashes
+		}
+
+	case GetPooledTransactionsMsg:
+		if payload, ok := data.([]common.Hash); ok {
+			details = append(details, len(payload))
+		} else if err != nil {
+			details = append(details, 0)
+		} else {
+			glog.Fatal("cant cast: GetPooledTransactionsMsg", direction)
+		}
+		if direction == "send" {
+			line = mlogWireSendGetPooledTransactions
+		} else {
+			line = mlogWireReceiveGetPooledTransactions
+		}
+
+	case PooledTransactionsMsg:
+		if direction == "send" {
+			line = mlogWireSendPooledTransactions
+			if payload, ok := data.([]rlp.RawValue); ok {
+				details = append(details, len(payload))
+			} else if err != nil {
+				details = append(details, 0)
+			} else {
+				glog.Fatal("cant cast: PooledTransactionsMsg", direction)
+			}
+		} else {
+			line = mlogWireReceivePooledTransactions
+			if payload, ok := data.([]*types.Transaction); ok {
+				details = append(details, len(payload))
+			} else if err != nil {
+				details = append(details, 0)
+			} else {
+				glog.Fatal("cant cast: PooledTransactionsMsg", direction)
+			}
+		}
+
 	default:
 		line = mlogWireReceiveInvalid
 	}
@@ -665,6 +729,66 @@ var mlogWireReceiveReceipts = &logger.MLogT{
 	}...),
 }
 
+var mlogWireSendNewPooledTransactionHashes = &logger.MLogT{
+	Description: "Called once for each outgoing NewPooledTransactionHashesMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(NewPooledTransactionHashesMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceiveNewPooledTransactionHashes = &logger.MLogT{
+	Description: "Called once for each incoming NewPooledTransactionHashesMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(NewPooledTransactionHashesMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireSendGetPooledTransactions = &logger.MLogT{
+	Description: "Called once for each outgoing GetPooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(GetPooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceiveGetPooledTransactions = &logger.MLogT{
+	Description: "Called once for each incoming GetPooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(GetPooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireSendPooledTransactions = &logger.MLogT{
+	Description: "Called once for each outgoing PooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "SEND",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(PooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
+var mlogWireReceivePooledTransactions = &logger.MLogT{
+	Description: "Called once for each incoming PooledTransactionsMsg message.",
+	Receiver:    "WIRE",
+	Verb:        "RECEIVE",
+	Subject:     strings.ToUpper(ProtocolMessageStringer(PooledTransactionsMsg)),
+	Details: append(mlogWireCommonDetails, []logger.MLogDetailT{
+		{Owner: "MSG", Key: "LEN_ITEMS", Value: "INT"},
+	}...),
+}
+
 var mlogWireReceiveInvalid = &logger.MLogT{
 	Description: "Called once for each incoming wire message that is invalid.",
 	Receiver:    "WIRE",
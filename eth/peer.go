@@ -24,7 +24,9 @@ import (
 	"time"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/forkid"
 	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/eth/downloader"
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/p2p"
@@ -47,6 +49,10 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcement lists to
+	// queue up before dropping broadcasts.
+	maxQueuedTxAnns = 128
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -90,26 +96,31 @@ type peer struct {
 	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
 	knownBlocks *set.Set // Set of block hashes known to be known by this peer
 
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	tracker *requestTracker // Requests in flight awaiting a response (eth/66)
+
+	queuedTxs    chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedTxAnns chan []common.Hash        // Queue of transactions to announce to the peer (eth/65)
+	queuedProps  chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns   chan *types.Block         // Queue of blocks to announce to the peer
+	term         chan struct{}             // Termination channel to stop the broadcaster
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	id := p.ID()
 
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", id[:8]),
-		knownTxs:    set.New(),
-		knownBlocks: set.New(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		id:           fmt.Sprintf("%x", id[:8]),
+		knownTxs:     set.New(),
+		knownBlocks:  set.New(),
+		tracker:      newRequestTracker(),
+		queuedTxs:    make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnns: make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:  make(chan *propEvent, maxQueuedProps),
+		queuedAnns:   make(chan *types.Block, maxQueuedAnns),
+		term:         make(chan struct{}),
 	}
 }
 
@@ -125,6 +136,12 @@ func (p *peer) broadcast() {
 			}
 			glog.V(logger.Detail).Infoln("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendNewPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			glog.V(logger.Detail).Infoln("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -198,6 +215,60 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 	p.knownTxs.Add(hash)
 }
 
+// send sends a request or response message to the peer, wrapping the payload
+// into a request id envelope on eth/66 connections.
+func (p *peer) send(code uint64, id uint64, data interface{}) (int, error) {
+	if p.version >= eth66 {
+		return p2p.Send(p.rw, code, []interface{}{id, data})
+	}
+	return p2p.Send(p.rw, code, data)
+}
+
+// request tracks a request with the given id expecting a response of the given
+// code on eth/66 connections, and sends it over to the peer.
+func (p *peer) request(code uint64, id uint64, resCode uint64, data interface{}) (int, error) {
+	if p.version >= eth66 {
+		if err := p.tracker.track(id, resCode); err != nil {
+			return 0, err
+		}
+	}
+	return p.send(code, id, data)
+}
+
+// decodeMsg decodes a request or response message into val, returning the
+// request id it was tagged with on eth/66 connections.
+func (p *peer) decodeMsg(msg p2p.Msg, val interface{}) (uint64, error) {
+	if p.version < eth66 {
+		return 0, msg.Decode(val)
+	}
+	var packet requestPacket66
+	if err := msg.Decode(&packet); err != nil {
+		return 0, err
+	}
+	return packet.RequestId, rlp.DecodeBytes(packet.Data, val)
+}
+
+// requestStream opens an RLP stream over a request message carrying a list of
+// hashes, positioned inside the list, along with the request id it was tagged
+// with on eth/66 connections.
+func (p *peer) requestStream(msg p2p.Msg) (*rlp.Stream, uint64, error) {
+	stream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := stream.List(); err != nil {
+		return nil, 0, err
+	}
+	var id uint64
+	if p.version >= eth66 {
+		var err error
+		if id, err = stream.Uint(); err != nil {
+			return nil, 0, err
+		}
+		if _, err := stream.List(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return stream, id, nil
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
@@ -222,6 +293,42 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendNewPooledTransactionHashes announces the availability of a number of
+// transactions through a hash notification (eth/65).
+func (p *peer) SendNewPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	s, e := p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+	mlogWireDelegate(p, "send", NewPooledTransactionHashesMsg, s, hashes, nil)
+	return e
+}
+
+// AsyncSendPooledTransactionHashes queues a list of transaction announcements
+// to a remote peer. If the peer's announcement queue is full, the event is
+// silently dropped.
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnns <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		glog.V(logger.Debug).Infoln("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// SendPooledTransactionsRLP sends requested transactions to the peer from an
+// already RLP encoded format and adds their hashes to its known set.
+func (p *peer) SendPooledTransactionsRLP(id uint64, hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	s, e := p.send(PooledTransactionsMsg, id, txs)
+	mlogWireDelegate(p, "send", PooledTransactionsMsg, s, txs, nil)
+	return e
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -269,40 +376,41 @@ func (p *peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
 	}
 }
 
-// SendBlockHeaders sends a batch of block headers to the remote peer.m
-func (p *peer) SendBlockHeaders(headers []*types.Header) error {
-	s, e := p2p.Send(p.rw, BlockHeadersMsg, headers)
+// SendBlockHeaders sends a batch of block headers to the remote peer in reply
+// to the request with the given id.
+func (p *peer) SendBlockHeaders(id uint64, headers []*types.Header) error {
+	s, e := p.send(BlockHeadersMsg, id, headers)
 	mlogWireDelegate(p, "send", BlockHeadersMsg, s, headers, nil)
 	return e
 }
 
 // SendBlockBodies sends a batch of block contents to the remote peer.
-func (p *peer) SendBlockBodies(bodies []*blockBody) error {
-	s, e := p2p.Send(p.rw, BlockBodiesMsg, blockBodiesData(bodies))
+func (p *peer) SendBlockBodies(id uint64, bodies []*blockBody) error {
+	s, e := p.send(BlockBodiesMsg, id, blockBodiesData(bodies))
 	mlogWireDelegate(p, "send", BlockBodiesMsg, s, bodies, nil)
 	return e
 }
 
 // SendBlockBodiesRLP sends a batch of block contents to the remote peer from
 // an already RLP encoded format.
-func (p *peer) SendBlockBodiesRLP(bodies []rlp.RawValue) error {
-	s, e := p2p.Send(p.rw, BlockBodiesMsg, bodies)
+func (p *peer) SendBlockBodiesRLP(id uint64, bodies []rlp.RawValue) error {
+	s, e := p.send(BlockBodiesMsg, id, bodies)
 	mlogWireDelegate(p, "send", BlockBodiesMsg, s, bodies, nil)
 	return e
 }
 
 // SendNodeDataRLP sends a batch of arbitrary internal data, corresponding to the
 // hashes requested.
-func (p *peer) SendNodeData(data [][]byte) error {
-	s, e := p2p.Send(p.rw, NodeDataMsg, data)
+func (p *peer) SendNodeData(id uint64, data [][]byte) error {
+	s, e := p.send(NodeDataMsg, id, data)
 	mlogWireDelegate(p, "send", NodeDataMsg, s, data, nil)
 	return e
 }
 
 // SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
 // ones requested from an already RLP encoded format.
-func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
-	s, e := p2p.Send(p.rw, ReceiptsMsg, receipts)
+func (p *peer) SendReceiptsRLP(id uint64, receipts []rlp.RawValue) error {
+	s, e := p.send(ReceiptsMsg, id, receipts)
 	mlogWireDelegate(p, "send", ReceiptsMsg, s, receipts, nil)
 	return e
 }
@@ -312,73 +420,95 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 func (p *peer) RequestOneHeader(hash common.Hash) error {
 	glog.V(logger.Debug).Infof("fetching from: %v req=singleheader hash=%x", p, hash)
 	d := &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false}
-	s, e := p2p.Send(p.rw, GetBlockHeadersMsg, d)
+	s, e := p.request(GetBlockHeadersMsg, downloader.RequestID(p.version), BlockHeadersMsg, d)
 	mlogWireDelegate(p, "send", GetBlockHeadersMsg, s, d, nil)
 	return e
 }
 
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
-func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
-	glog.V(logger.Debug).Infof("fetching from: %v req=headersbyhash n=%d origin=%x, skipping=%d reverse=%v", p, amount, origin[:4], skip, reverse)
+func (p *peer) RequestHeadersByHash(id uint64, origin common.Hash, amount int, skip int, reverse bool) error {
+	glog.V(logger.Debug).Infof("fetching from: %v req=headersbyhash id=%d n=%d origin=%x, skipping=%d reverse=%v", p, id, amount, origin[:4], skip, reverse)
 	d := &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse}
-	s, e := p2p.Send(p.rw, GetBlockHeadersMsg, d)
+	s, e := p.request(GetBlockHeadersMsg, id, BlockHeadersMsg, d)
 	mlogWireDelegate(p, "send", GetBlockHeadersMsg, s, d, nil)
 	return e
 }
 
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
-func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
-	glog.V(logger.Debug).Infof("fetching from: %v %d req=headersbynumber n=%d, skipping=%d reverse=%v", p, amount, origin, skip, reverse)
+func (p *peer) RequestHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error {
+	glog.V(logger.Debug).Infof("fetching from: %v %d req=headersbynumber id=%d n=%d, skipping=%d reverse=%v", p, amount, id, origin, skip, reverse)
 	d := &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse}
-	s, e := p2p.Send(p.rw, GetBlockHeadersMsg, d)
+	s, e := p.request(GetBlockHeadersMsg, id, BlockHeadersMsg, d)
 	mlogWireDelegate(p, "send", GetBlockHeadersMsg, s, d, nil)
 	return e
 }
 
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
-func (p *peer) RequestBodies(hashes []common.Hash) error {
-	glog.V(logger.Debug).Infof("fetching from: %v req=blockbodies n=%d first=%s", p, len(hashes), hashes[0].Hex())
-	s, e := p2p.Send(p.rw, GetBlockBodiesMsg, hashes)
+func (p *peer) RequestBodies(id uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("fetching from: %v req=blockbodies id=%d n=%d first=%s", p, id, len(hashes), hashes[0].Hex())
+	s, e := p.request(GetBlockBodiesMsg, id, BlockBodiesMsg, hashes)
 	mlogWireDelegate(p, "send", GetBlockBodiesMsg, s, hashes, nil)
 	return e
 }
 
 // RequestNodeData fetches a batch of arbitrary data from a node's known state
 // data, corresponding to the specified hashes.
-func (p *peer) RequestNodeData(hashes []common.Hash) error {
-	glog.V(logger.Debug).Infof("fetching from: %v req=statedata n=%d first=%s", p, len(hashes), hashes[0].Hex())
-	s, e := p2p.Send(p.rw, GetNodeDataMsg, hashes)
+func (p *peer) RequestNodeData(id uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("fetching from: %v req=statedata id=%d n=%d first=%s", p, id, len(hashes), hashes[0].Hex())
+	s, e := p.request(GetNodeDataMsg, id, NodeDataMsg, hashes)
 	mlogWireDelegate(p, "send", GetNodeDataMsg, s, hashes, nil)
 	return e
 }
 
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
-func (p *peer) RequestReceipts(hashes []common.Hash) error {
-	glog.V(logger.Debug).Infof("fetching from: %v req=receipts n=%d first=%s", p, len(hashes), hashes[0].Hex())
-	s, e := p2p.Send(p.rw, GetReceiptsMsg, hashes)
+func (p *peer) RequestReceipts(id uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("fetching from: %v req=receipts id=%d n=%d first=%s", p, id, len(hashes), hashes[0].Hex())
+	s, e := p.request(GetReceiptsMsg, id, ReceiptsMsg, hashes)
 	mlogWireDelegate(p, "send", GetReceiptsMsg, s, hashes, nil)
 	return e
 }
 
+// RequestTxs fetches a batch of transactions from a remote node (eth/65).
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("fetching from: %v req=pooledtxs n=%d first=%s", p, len(hashes), hashes[0].Hex())
+	s, e := p.request(GetPooledTransactionsMsg, downloader.RequestID(p.version), PooledTransactionsMsg, hashes)
+	mlogWireDelegate(p, "send", GetPooledTransactionsMsg, s, hashes, nil)
+	return e
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks. Since eth/64 the fork IDs
+// are exchanged too, validating the remote one with the given filter.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	sendErrc := make(chan error, 1)
 	recErrc := make(chan error, 1)
 	var sendErr, recErr error
 	var sendSize, recSize int
 	var status statusData // safe to read after two values have been received from errc
-
-	d := &statusData{
-		ProtocolVersion: uint32(p.version),
-		NetworkId:       uint32(network),
-		TD:              td,
-		CurrentBlock:    head,
-		GenesisBlock:    genesis,
+	var status64 statusData64
+
+	var d interface{}
+	if p.version >= eth64 {
+		d = &statusData64{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       network,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ForkID:          forkID,
+		}
+	} else {
+		d = &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       uint32(network),
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+		}
 	}
 
 	go func() {
@@ -389,14 +519,22 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	go func() {
 		var e error
 		var s uint32
-		s, e = p.readStatusReturnSize(network, &status, genesis)
+		if p.version >= eth64 {
+			s, e = p.readStatus64(network, &status64, genesis, forkFilter)
+		} else {
+			s, e = p.readStatusReturnSize(network, &status, genesis)
+		}
 		recSize = int(s)
 		recErrc <- e
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
 
-	defer mlogWireDelegate(p, "receive", StatusMsg, recSize, &status, recErr)
+	if p.version >= eth64 {
+		defer mlogWireDelegate(p, "receive", StatusMsg, recSize, &status64, recErr)
+	} else {
+		defer mlogWireDelegate(p, "receive", StatusMsg, recSize, &status, recErr)
+	}
 	defer mlogWireDelegate(p, "send", StatusMsg, sendSize, d, sendErr)
 
 	for i := 0; i < 2; i++ {
@@ -416,7 +554,11 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	if p.version >= eth64 {
+		p.td, p.head = status64.TD, status64.CurrentBlock
+	} else {
+		p.td, p.head = status.TD, status.CurrentBlock
+	}
 	return nil
 }
 
@@ -452,6 +594,38 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	return
 }
 
+// readStatus64 reads and validates the eth/64 status message of the peer,
+// rejecting peers whose fork ID isn't compatible with the local chain.
+func (p *peer) readStatus64(network uint64, status *statusData64, genesis common.Hash, forkFilter forkid.Filter) (size uint32, err error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return msg.Size, err
+	}
+	if msg.Code != StatusMsg {
+		return msg.Size, errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return msg.Size, errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(&status); err != nil {
+		return msg.Size, errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.GenesisBlock != genesis {
+		return msg.Size, errResp(ErrGenesisBlockMismatch, "%x (!= %x…)", status.GenesisBlock, genesis.Bytes()[:8])
+	}
+	if status.NetworkId != network {
+		return msg.Size, errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return msg.Size, errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	if err := forkFilter(status.ForkID); err != nil {
+		return msg.Size, errResp(ErrForkIDRejected, "%v: %v", status.ForkID, err)
+	}
+	return msg.Size, nil
+}
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	// id is %x[:8]
@@ -23,10 +23,13 @@ import (
 	"time"
 
 	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/forkid"
 	"github.com/eth-classic/go-ethereum/core/types"
 	"github.com/eth-classic/go-ethereum/crypto"
 	"github.com/eth-classic/go-ethereum/eth/downloader"
 	"github.com/eth-classic/go-ethereum/p2p"
+	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
 
@@ -90,10 +93,97 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that eth/64 handshake failures, including incompatible fork IDs, are
+// detected and reported correctly.
+func TestStatusMsgErrors64(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	td, currentBlock, genesis := pm.blockchain.Status()
+	forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{10, NetworkId, td, currentBlock, genesis, forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", eth64),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, 999, td, currentBlock, genesis, forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, NetworkId, td, currentBlock, common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x…)", genesis.Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, NetworkId, td, currentBlock, genesis, forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "00010203/0: %v", forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", eth64, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down withing 2 seconds")
+		}
+		p.close()
+	}
+}
+
+// Tests that discovered nodes are filtered by the fork ID in their record.
+func TestNodeFilter(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	newNode := func(entries ...enr.Entry) *discover.Node {
+		key, _ := crypto.GenerateKey()
+		ln := discover.NewLocalNode(key)
+		for _, e := range entries {
+			ln.Set(e)
+		}
+		return ln.Node()
+	}
+	tests := []struct {
+		node *discover.Node
+		want bool
+	}{
+		{discover.NewNode(discover.NodeID{1}, nil, 30303, 30303), true}, // no record
+		{newNode(pm.currentEthEntry()), true},
+		{newNode(&ethEntry{ForkID: forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}}), false},
+		{newNode(), false}, // record without "eth" entry
+	}
+	for i, tt := range tests {
+		if have := pm.nodeFilter(tt.node); have != tt.want {
+			t.Errorf("test %d: filter result mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions61(t *testing.T) { testRecvTransactions(t, 61) }
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
+func TestRecvTransactions66(t *testing.T) { testRecvTransactions(t, 66) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -125,6 +215,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 func TestSendTransactions61(t *testing.T) { testSendTransactions(t, 61) }
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -180,6 +271,195 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that a new transaction is sent in full to the square root of the peers
+// only, while the remaining eth/65 peers are just announced its hash. Peers of
+// older versions can't be announced transactions, so they always get them.
+func TestBroadcastTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var peers []*testPeer
+	for i := 0; i < 9; i++ {
+		p, _ := newTestPeer(fmt.Sprintf("eth/65 peer #%d", i), 65, pm, true)
+		defer p.close()
+		peers = append(peers, p)
+	}
+	for i := 0; i < 3; i++ {
+		p, _ := newTestPeer(fmt.Sprintf("eth/63 peer #%d", i), 63, pm, true)
+		defer p.close()
+		peers = append(peers, p)
+	}
+	for start := time.Now(); pm.peers.Len() < len(peers); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("peers not registered: have %d, want %d", pm.peers.Len(), len(peers))
+		}
+	}
+	tx := newTestTransaction(testAccount, 0, 0)
+	pm.BroadcastTx(tx.Hash(), tx)
+
+	var sent, announced int
+	for _, p := range peers {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("%v: read error: %v", p.Peer, err)
+		}
+		switch msg.Code {
+		case TxMsg:
+			var txs []*types.Transaction
+			if err := msg.Decode(&txs); err != nil || len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+				t.Errorf("%v: wrong transactions: %v (%v)", p.Peer, txs, err)
+			}
+			if p.version >= eth65 {
+				sent++
+			}
+		case NewPooledTransactionHashesMsg:
+			var hashes []common.Hash
+			if err := msg.Decode(&hashes); err != nil || len(hashes) != 1 || hashes[0] != tx.Hash() {
+				t.Errorf("%v: wrong announcement: %v (%v)", p.Peer, hashes, err)
+			}
+			if p.version < eth65 {
+				t.Errorf("%v: eth/%d peer announced a transaction", p.Peer, p.version)
+			}
+			announced++
+		default:
+			t.Errorf("%v: got code %d, want TxMsg or NewPooledTransactionHashesMsg", p.Peer, msg.Code)
+		}
+	}
+	// At most the square root of all peers get the full transaction, some of
+	// them possibly being the eth/63 ones.
+	if direct := 3; sent > direct || sent+announced != 9 {
+		t.Errorf("eth/65 peers: sent %d, announced %d, want at most %d sent of 9", sent, announced, direct)
+	}
+}
+
+// Tests that eth/65 peers are announced the pending transactions on connect,
+// and are served them upon request.
+func TestAnnounceAndServeTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	alltxs := make([]*types.Transaction, 10)
+	for nonce := range alltxs {
+		alltxs[nonce] = newTestTransaction(testAccount, uint64(nonce), 0)
+	}
+	pm.txpool.AddTransactions(alltxs)
+
+	p, _ := newTestPeer("peer", 65, pm, true)
+	defer p.close()
+
+	// The pending transactions are announced by hash.
+	seen := make(map[common.Hash]bool)
+	for len(seen) < len(alltxs) {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if msg.Code != NewPooledTransactionHashesMsg {
+			t.Fatalf("got code %d, want NewPooledTransactionHashesMsg", msg.Code)
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			t.Fatal(err)
+		}
+		for _, hash := range hashes {
+			seen[hash] = true
+		}
+	}
+	// Request some of them together with an unknown hash.
+	request := []common.Hash{alltxs[0].Hash(), {0x01}, alltxs[3].Hash()}
+	if _, err := p2p.Send(p.app, GetPooledTransactionsMsg, request); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, PooledTransactionsMsg, []*types.Transaction{alltxs[0], alltxs[3]}); err != nil {
+		t.Errorf("pooled transactions mismatch: %v", err)
+	}
+}
+
+// Tests that transactions announced by an eth/65 peer are retrieved from it.
+func TestRetrieveAnnouncedTransactions65(t *testing.T) {
+	txAdded := make(chan []*types.Transaction, 1)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptsTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", 65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if _, err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("retrieval request mismatch: %v", err)
+	}
+	if _, err := p2p.Send(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added wrong transactions: %v", added)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no TxPreEvent received within 2 seconds")
+	}
+}
+
+// Tests that eth/66 pooled transaction requests are tagged with a request id,
+// and that only responses echoing it are accepted.
+func TestRetrieveAnnouncedTransactions66(t *testing.T) {
+	txAdded := make(chan []*types.Transaction, 1)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptsTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", 66, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if _, err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if msg.Code != GetPooledTransactionsMsg {
+		t.Fatalf("got code %d, want GetPooledTransactionsMsg", msg.Code)
+	}
+	var (
+		request requestPacket66
+		hashes  []common.Hash
+	)
+	if err := msg.Decode(&request); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if err := rlp.DecodeBytes(request.Data, &hashes); err != nil {
+		t.Fatalf("failed to decode requested hashes: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != tx.Hash() {
+		t.Fatalf("requested hashes mismatch: have %x, want [%x]", hashes, tx.Hash())
+	}
+	// A response with an unknown id is dropped, the real one accepted
+	if _, err := p2p.Send(p.app, PooledTransactionsMsg, []interface{}{request.RequestId + 1, []*types.Transaction{tx}}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		t.Fatalf("unsolicited transactions added: %v", added)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := p2p.Send(p.app, PooledTransactionsMsg, []interface{}{request.RequestId, []*types.Transaction{tx}}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added wrong transactions: %v", added)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no TxPreEvent received within 2 seconds")
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
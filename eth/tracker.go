@@ -0,0 +1,122 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/metrics"
+)
+
+// trackedRequestTTL is the time after which an unanswered request is forgotten.
+// It is well above any of the downloader's and fetchers' request timeouts, so
+// a response arriving later than this is of no use to anyone anymore.
+const trackedRequestTTL = time.Minute
+
+var (
+	errUnsolicitedResponse = errors.New("unsolicited response")
+	errDuplicateRequestID  = errors.New("duplicate request id")
+)
+
+// trackedRequest is a request sent to a remote peer that still waits for its
+// response.
+type trackedRequest struct {
+	code uint64    // Message code of the expected response
+	time time.Time // Timestamp when the request was sent
+}
+
+// requestTracker keeps track of the eth/66 requests in flight to a single
+// peer, so that responses can be matched up with the requests that caused them
+// and the latency of each individual request can be measured.
+type requestTracker struct {
+	pending map[uint64]*trackedRequest // Requests waiting for a response, keyed by request id
+	lock    sync.Mutex
+}
+
+// newRequestTracker creates an empty tracker for a single peer.
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		pending: make(map[uint64]*trackedRequest),
+	}
+}
+
+// track registers a new request with the given id, expecting a response with
+// the given message code. Requests stale beyond the tracking TTL are dropped
+// and accounted as timeouts.
+func (t *requestTracker) track(id uint64, code uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	for reqID, req := range t.pending {
+		if now.Sub(req.time) > trackedRequestTTL {
+			delete(t.pending, reqID)
+			metrics.ReqTimeouts.Mark(1)
+		}
+	}
+	if _, ok := t.pending[id]; ok {
+		return errDuplicateRequestID
+	}
+	t.pending[id] = &trackedRequest{code: code, time: now}
+	return nil
+}
+
+// fulfil marks the request with the given id as answered by a response of the
+// given message code, returning the time elapsed since the request was sent.
+// An error is returned if no such request is pending or if it expected some
+// other kind of response.
+func (t *requestTracker) fulfil(id uint64, code uint64) (time.Duration, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	req, ok := t.pending[id]
+	if !ok {
+		metrics.ReqUnsolicited.Mark(1)
+		return 0, errUnsolicitedResponse
+	}
+	if req.code != code {
+		metrics.ReqUnsolicited.Mark(1)
+		return 0, fmt.Errorf("response code mismatch: have %d, want %d", code, req.code)
+	}
+	delete(t.pending, id)
+
+	elapsed := time.Since(req.time)
+	switch code {
+	case BlockHeadersMsg:
+		metrics.ReqHeaderLatency.Update(elapsed)
+	case BlockBodiesMsg:
+		metrics.ReqBodyLatency.Update(elapsed)
+	case NodeDataMsg:
+		metrics.ReqStateLatency.Update(elapsed)
+	case ReceiptsMsg:
+		metrics.ReqReceiptLatency.Update(elapsed)
+	case PooledTransactionsMsg:
+		metrics.ReqTxLatency.Update(elapsed)
+	}
+	return elapsed, nil
+}
+
+// pendingCount returns the number of requests waiting for a response.
+func (t *requestTracker) pendingCount() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return len(t.pending)
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the request tracker routes responses to the requests awaiting
+// them, regardless of the order in which they arrive.
+func TestRequestTrackerFulfil(t *testing.T) {
+	tracker := newRequestTracker()
+
+	for _, id := range []uint64{1, 2, 3} {
+		if err := tracker.track(id, BlockHeadersMsg); err != nil {
+			t.Fatalf("failed to track request %d: %v", id, err)
+		}
+	}
+	if err := tracker.track(2, BlockBodiesMsg); err != errDuplicateRequestID {
+		t.Errorf("duplicate request error mismatch: have %v, want %v", err, errDuplicateRequestID)
+	}
+	// Out of order responses are all accepted
+	for _, id := range []uint64{3, 1} {
+		if _, err := tracker.fulfil(id, BlockHeadersMsg); err != nil {
+			t.Errorf("failed to fulfil request %d: %v", id, err)
+		}
+	}
+	// Repeated, unknown and mismatching responses are rejected
+	if _, err := tracker.fulfil(1, BlockHeadersMsg); err != errUnsolicitedResponse {
+		t.Errorf("repeated response error mismatch: have %v, want %v", err, errUnsolicitedResponse)
+	}
+	if _, err := tracker.fulfil(4, BlockHeadersMsg); err != errUnsolicitedResponse {
+		t.Errorf("unknown response error mismatch: have %v, want %v", err, errUnsolicitedResponse)
+	}
+	if _, err := tracker.fulfil(2, ReceiptsMsg); err == nil {
+		t.Errorf("mismatching response accepted")
+	}
+	// The mismatch doesn't cancel the request
+	if _, err := tracker.fulfil(2, BlockHeadersMsg); err != nil {
+		t.Errorf("failed to fulfil request 2: %v", err)
+	}
+	if n := tracker.pendingCount(); n != 0 {
+		t.Errorf("pending requests mismatch: have %d, want 0", n)
+	}
+}
+
+// Tests that requests left unanswered for too long are forgotten.
+func TestRequestTrackerExpire(t *testing.T) {
+	tracker := newRequestTracker()
+
+	if err := tracker.track(1, NodeDataMsg); err != nil {
+		t.Fatalf("failed to track request: %v", err)
+	}
+	tracker.pending[1].time = time.Now().Add(-2 * trackedRequestTTL)
+
+	if err := tracker.track(2, NodeDataMsg); err != nil {
+		t.Fatalf("failed to track request: %v", err)
+	}
+	if n := tracker.pendingCount(); n != 1 {
+		t.Errorf("pending requests mismatch: have %d, want 1", n)
+	}
+	if _, err := tracker.fulfil(1, NodeDataMsg); err != errUnsolicitedResponse {
+		t.Errorf("expired response error mismatch: have %v, want %v", err, errUnsolicitedResponse)
+	}
+}
@@ -0,0 +1,532 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ethclient provides a typed client for the eth, net and geth RPC
+// namespaces, decoding responses into core/types structures.
+package ethclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/core/vm"
+	"github.com/eth-classic/go-ethereum/rpc"
+)
+
+// ErrNotFound is returned by API methods if the requested item does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Client defines typed wrappers for the Ethereum RPC API.
+//
+// Besides the context-aware methods, Client implements bind.ContractBackend
+// (HasCode, ContractCall, PendingAccountNonce, SuggestGasPrice,
+// EstimateGasLimit and SendTransaction), so it can be used as the backend of
+// generated contract bindings.
+type Client struct {
+	c *rpc.ContextClient
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL, the context bounds the
+// initial connection establishment.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.ContextClient) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (ec *Client) Close() {
+	ec.c.Close()
+}
+
+// call performs an RPC call, translating a missing result into ErrNotFound.
+// Methods returning objects must also check for a null result themselves.
+func (ec *Client) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	err := ec.c.CallContext(ctx, result, method, args...)
+	if err == rpc.ErrNoResult {
+		return ErrNotFound
+	}
+	return err
+}
+
+// callBig performs an RPC call returning a number, which may be encoded either
+// as a hex string or as a plain JSON number.
+func (ec *Client) callBig(ctx context.Context, method string, args ...interface{}) (*big.Int, error) {
+	var result rpc.HexNumber
+	if err := ec.call(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+	return result.BigInt(), nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// Blockchain Access
+
+// ChainID retrieves the EIP-155 chain id configured on the node.
+func (ec *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return ec.callBig(ctx, "eth_chainId")
+}
+
+// BlockNumber returns the number of the most recent block.
+func (ec *Client) BlockNumber(ctx context.Context) (*big.Int, error) {
+	return ec.callBig(ctx, "eth_blockNumber")
+}
+
+// BlockByHash returns the given full block.
+//
+// Note that loading full blocks requires two requests. Use HeaderByHash
+// if you don't need all transactions or uncle headers.
+func (ec *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return ec.getBlock(ctx, "eth_getBlockByHash", hash, true)
+}
+
+// BlockByNumber returns a block from the current canonical chain. If number is nil, the
+// latest known block is returned.
+//
+// Note that loading full blocks requires two requests. Use HeaderByNumber
+// if you don't need all transactions or uncle headers.
+func (ec *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return ec.getBlock(ctx, "eth_getBlockByNumber", toBlockNumArg(number), true)
+}
+
+type rpcBlock struct {
+	Hash         common.Hash          `json:"hash"`
+	Transactions []*types.Transaction `json:"transactions"`
+	UncleHashes  []common.Hash        `json:"uncles"`
+}
+
+func (ec *Client) getBlock(ctx context.Context, method string, args ...interface{}) (*types.Block, error) {
+	var raw json.RawMessage
+	if err := ec.call(ctx, &raw, method, args...); err != nil {
+		return nil, err
+	} else if isNull(raw) {
+		return nil, ErrNotFound
+	}
+	// Decode header and transactions.
+	var head types.Header
+	var body rpcBlock
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	if head.Hash() != body.Hash {
+		return nil, fmt.Errorf("server returned block with mismatching hash %x, header hashes to %x", body.Hash, head.Hash())
+	}
+	// Quick-verify transaction and uncle lists. This mostly helps with debugging the server.
+	if head.UncleHash == types.EmptyUncleHash && len(body.UncleHashes) > 0 {
+		return nil, fmt.Errorf("server returned non-empty uncle list but block header indicates no uncles")
+	}
+	if head.UncleHash != types.EmptyUncleHash && len(body.UncleHashes) == 0 {
+		return nil, fmt.Errorf("server returned empty uncle list but block header indicates uncles")
+	}
+	if head.TxHash == types.EmptyRootHash && len(body.Transactions) > 0 {
+		return nil, fmt.Errorf("server returned non-empty transaction list but block header indicates no transactions")
+	}
+	if head.TxHash != types.EmptyRootHash && len(body.Transactions) == 0 {
+		return nil, fmt.Errorf("server returned empty transaction list but block header indicates transactions")
+	}
+	// Load uncles because they are not included in the block response.
+	var uncles []*types.Header
+	if len(body.UncleHashes) > 0 {
+		uncles = make([]*types.Header, len(body.UncleHashes))
+		reqs := make([]rpc.BatchElem, len(body.UncleHashes))
+		for i := range reqs {
+			uncles[i] = new(types.Header)
+			reqs[i] = rpc.BatchElem{
+				Method: "eth_getUncleByBlockHashAndIndex",
+				Args:   []interface{}{body.Hash, hexutil.Uint64(i)},
+				Result: uncles[i],
+			}
+		}
+		if err := ec.c.BatchCallContext(ctx, reqs); err != nil {
+			return nil, err
+		}
+		for i := range reqs {
+			if reqs[i].Error != nil {
+				return nil, reqs[i].Error
+			}
+			if uncles[i].Hash() != body.UncleHashes[i] {
+				return nil, fmt.Errorf("got uncle %x, want %x", uncles[i].Hash(), body.UncleHashes[i])
+			}
+		}
+	}
+	return types.NewBlockWithHeader(&head).WithBody(body.Transactions, uncles), nil
+}
+
+// HeaderByHash returns the block header with the given hash.
+func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	var head *types.Header
+	if err := ec.call(ctx, &head, "eth_getBlockByHash", hash, false); err != nil {
+		return nil, err
+	} else if head == nil {
+		return nil, ErrNotFound
+	}
+	return head, nil
+}
+
+// HeaderByNumber returns a block header from the current canonical chain. If number is
+// nil, the latest known header is returned.
+func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var head *types.Header
+	if err := ec.call(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(number), false); err != nil {
+		return nil, err
+	} else if head == nil {
+		return nil, ErrNotFound
+	}
+	return head, nil
+}
+
+// TransactionByHash returns the transaction with the given hash.
+func (ec *Client) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	var raw json.RawMessage
+	if err := ec.call(ctx, &raw, "eth_getTransactionByHash", hash); err != nil {
+		return nil, false, err
+	} else if isNull(raw) {
+		return nil, false, ErrNotFound
+	}
+	var extra struct {
+		BlockNumber *rpc.HexNumber `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		return nil, false, err
+	}
+	tx = new(types.Transaction)
+	if err := json.Unmarshal(raw, tx); err != nil {
+		return nil, false, err
+	}
+	return tx, extra.BlockNumber == nil, nil
+}
+
+// TransactionReceipt returns the receipt of a mined transaction. Note that the
+// transaction may not be included in the current canonical chain even if a receipt
+// exists.
+func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var r *types.Receipt
+	if err := ec.call(ctx, &r, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, err
+	} else if r == nil {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// SubscribeNewHead subscribes to notifications about the current blockchain head
+// on the given channel.
+func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (*rpc.ClientSubscription, error) {
+	args := map[string]interface{}{"includeTransactions": false}
+	return ec.c.Subscribe(ctx, "eth", ch, "newBlocks", args)
+}
+
+// State Access
+
+// NetworkID returns the network ID (also known as the chain ID) for this chain.
+func (ec *Client) NetworkID(ctx context.Context) (*big.Int, error) {
+	version := new(big.Int)
+	var ver string
+	if err := ec.c.CallContext(ctx, &ver, "net_version"); err != nil {
+		return nil, err
+	}
+	if _, ok := version.SetString(ver, 10); !ok {
+		return nil, fmt.Errorf("invalid net_version result %q", ver)
+	}
+	return version, nil
+}
+
+// BalanceAt returns the wei balance of the given account.
+// The block number can be nil, in which case the balance is taken from the latest known block.
+func (ec *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return ec.callBig(ctx, "eth_getBalance", account, toBlockNumArg(blockNumber))
+}
+
+// StorageAt returns the value of key in the contract storage of the given account.
+// The block number can be nil, in which case the value is taken from the latest known block.
+func (ec *Client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.call(ctx, &result, "eth_getStorageAt", account, key, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// GetProof returns the account and storage values of the given account along
+// with their merkle proofs (EIP-1186), which can be checked against the state
+// root of the block using AccountResult.VerifyProof.
+// The block number can be nil, in which case the proof is taken from the latest known block.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountResult, error) {
+	var result *AccountResult
+	err := ec.call(ctx, &result, "eth_getProof", account, keys, toBlockNumArg(blockNumber))
+	if err == nil && result == nil {
+		err = ErrNotFound
+	}
+	return result, err
+}
+
+// CodeAt returns the contract code of the given account.
+// The block number can be nil, in which case the code is taken from the latest known block.
+func (ec *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.call(ctx, &result, "eth_getCode", account, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// NonceAt returns the account nonce of the given account.
+// The block number can be nil, in which case the nonce is taken from the latest known block.
+func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	nonce, err := ec.callBig(ctx, "eth_getTransactionCount", account, toBlockNumArg(blockNumber))
+	if err != nil {
+		return 0, err
+	}
+	return nonce.Uint64(), nil
+}
+
+// PendingCodeAt returns the contract code of the given account in the pending state.
+func (ec *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.call(ctx, &result, "eth_getCode", account, "pending")
+	return result, err
+}
+
+// PendingNonceAt returns the account nonce of the given account in the pending state.
+// This is the nonce that should be used for the next transaction.
+func (ec *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	nonce, err := ec.callBig(ctx, "eth_getTransactionCount", account, "pending")
+	if err != nil {
+		return 0, err
+	}
+	return nonce.Uint64(), nil
+}
+
+// Filters
+
+// FilterQuery contains options for contract log filtering.
+type FilterQuery struct {
+	FromBlock *big.Int         // beginning of the queried range, nil means latest block
+	ToBlock   *big.Int         // end of the range, nil means latest block
+	Addresses []common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	Topics [][]common.Hash
+}
+
+// FilterLogs executes a filter query.
+func (ec *Client) FilterLogs(ctx context.Context, q FilterQuery) (vm.Logs, error) {
+	var result vm.Logs
+	err := ec.c.CallContext(ctx, &result, "eth_getLogs", toFilterArg(q))
+	return result, err
+}
+
+func toFilterArg(q FilterQuery) interface{} {
+	// The server only treats null as a wildcard, not an empty list.
+	topics := make([]interface{}, len(q.Topics))
+	for i, alternatives := range q.Topics {
+		if len(alternatives) > 0 {
+			topics[i] = alternatives
+		}
+	}
+	arg := map[string]interface{}{
+		"fromBlock": toBlockNumArg(q.FromBlock),
+		"toBlock":   toBlockNumArg(q.ToBlock),
+		"address":   q.Addresses,
+		"topics":    topics,
+	}
+	if q.Addresses == nil {
+		arg["address"] = []common.Address{}
+	}
+	return arg
+}
+
+// Contract Calling
+
+// CallMsg contains parameters for contract calls.
+type CallMsg struct {
+	From       common.Address  // the sender of the 'transaction'
+	To         *common.Address // the destination contract (nil for contract creation)
+	Gas        *big.Int        // if nil, the call executes with near-infinite gas
+	GasPrice   *big.Int        // wei <-> gas exchange ratio
+	Value      *big.Int        // amount of wei sent along with the call
+	Data       []byte          // input data, usually an ABI-encoded contract method invocation
+	AccessList types.AccessList
+}
+
+// CallContract executes a message call transaction, which is directly executed in the VM
+// of the node, but never mined into the blockchain.
+//
+// blockNumber selects the block height at which the call runs. It can be nil, in which
+// case the code is taken from the latest known block. Note that state from very old
+// blocks might not be available.
+func (ec *Client) CallContract(ctx context.Context, msg CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var hex hexutil.Bytes
+	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
+// PendingCallContract executes a message call transaction using the EVM.
+// The state seen by the contract call is the pending state.
+func (ec *Client) PendingCallContract(ctx context.Context, msg CallMsg) ([]byte, error) {
+	var hex hexutil.Bytes
+	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), "pending")
+	if err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
+// SuggestGasPriceContext retrieves the currently suggested gas price to allow a timely
+// execution of a transaction.
+func (ec *Client) SuggestGasPriceContext(ctx context.Context) (*big.Int, error) {
+	return ec.callBig(ctx, "eth_gasPrice")
+}
+
+// EstimateGas tries to estimate the gas needed to execute a specific transaction based on
+// the current pending state of the backend blockchain. There is no guarantee that this is
+// the true gas limit requirement as other transactions may be added or removed by miners,
+// but it should provide a basis for setting a reasonable default.
+func (ec *Client) EstimateGas(ctx context.Context, msg CallMsg) (*big.Int, error) {
+	return ec.callBig(ctx, "eth_estimateGas", toCallArg(msg))
+}
+
+// SendTransactionContext injects a signed transaction into the pending pool for execution.
+//
+// If the transaction was a contract creation use the TransactionReceipt method to get the
+// contract address after the transaction has been mined.
+func (ec *Client) SendTransactionContext(ctx context.Context, tx *types.Transaction) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ec.c.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Bytes(data))
+}
+
+func toCallArg(msg CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != nil {
+		arg["gas"] = (*hexutil.Big)(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// Address-Transaction Index
+
+// AddressTransactions returns the hashes of the transactions involving the
+// given address between the start and end blocks, using the node's
+// address-transaction index (ATXI). An end block of nil means the latest block.
+//
+// toOrFrom is one of "t", "f" or "tf" and txKindOf is one of "s", "c" or "sc",
+// selecting the direction and kind (standard or contract) of the transactions;
+// empty strings match both. Pagination is applied to the sorted result list.
+func (ec *Client) AddressTransactions(ctx context.Context, address common.Address, startBlock uint64, endBlock *big.Int, toOrFrom, txKindOf string, pagStart, pagEnd int, reverse bool) ([]common.Hash, error) {
+	var hashes []common.Hash
+	err := ec.c.CallContext(ctx, &hashes, "geth_getAddressTransactions", address, startBlock, toBlockNumArg(endBlock), toOrFrom, txKindOf, pagStart, pagEnd, reverse)
+	return hashes, err
+}
+
+// bind.ContractBackend
+
+// HasCode implements bind.ContractCaller.HasCode, checking whether there is any
+// code associated with the contract in the latest or pending state.
+func (ec *Client) HasCode(contract common.Address, pending bool) (bool, error) {
+	ctx := context.Background()
+	var (
+		code []byte
+		err  error
+	)
+	if pending {
+		code, err = ec.PendingCodeAt(ctx, contract)
+	} else {
+		code, err = ec.CodeAt(ctx, contract, nil)
+	}
+	return len(code) > 0, err
+}
+
+// ContractCall implements bind.ContractCaller.ContractCall, executing a call
+// against the latest or pending state.
+func (ec *Client) ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error) {
+	ctx := context.Background()
+	msg := CallMsg{To: &contract, Data: data}
+	if pending {
+		return ec.PendingCallContract(ctx, msg)
+	}
+	return ec.CallContract(ctx, msg, nil)
+}
+
+// PendingAccountNonce implements bind.ContractTransactor.PendingAccountNonce.
+func (ec *Client) PendingAccountNonce(account common.Address) (uint64, error) {
+	return ec.PendingNonceAt(context.Background(), account)
+}
+
+// SuggestGasPrice implements bind.ContractTransactor.SuggestGasPrice.
+func (ec *Client) SuggestGasPrice() (*big.Int, error) {
+	return ec.SuggestGasPriceContext(context.Background())
+}
+
+// EstimateGasLimit implements bind.ContractTransactor.EstimateGasLimit.
+func (ec *Client) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
+	return ec.EstimateGas(context.Background(), CallMsg{From: sender, To: contract, Value: value, Data: data})
+}
+
+// SendTransaction implements bind.ContractTransactor.SendTransaction.
+func (ec *Client) SendTransaction(tx *types.Transaction) error {
+	return ec.SendTransactionContext(context.Background(), tx)
+}
@@ -0,0 +1,351 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/core/vm"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/rpc"
+)
+
+// contractBackend mirrors bind.ContractBackend, which lives in a separate
+// module and can't be imported here.
+type contractBackend interface {
+	HasCode(contract common.Address, pending bool) (bool, error)
+	ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error)
+	PendingAccountNonce(account common.Address) (uint64, error)
+	SuggestGasPrice() (*big.Int, error)
+	EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error)
+	SendTransaction(tx *types.Transaction) error
+}
+
+var _ contractBackend = (*Client)(nil)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)
+)
+
+// TestEthAPI serves a single block in the same JSON format as package eth.
+type TestEthAPI struct {
+	block   *types.Block
+	receipt *types.Receipt
+}
+
+func rpcHeader(h *types.Header) map[string]interface{} {
+	return map[string]interface{}{
+		"number":           rpc.NewHexNumber(h.Number),
+		"hash":             h.Hash(),
+		"parentHash":       h.ParentHash,
+		"nonce":            h.Nonce,
+		"mixHash":          h.MixDigest,
+		"sha3Uncles":       h.UncleHash,
+		"logsBloom":        h.Bloom,
+		"stateRoot":        h.Root,
+		"miner":            h.Coinbase,
+		"difficulty":       rpc.NewHexNumber(h.Difficulty),
+		"extraData":        fmt.Sprintf("0x%x", h.Extra),
+		"gasLimit":         rpc.NewHexNumber(h.GasLimit),
+		"gasUsed":          rpc.NewHexNumber(h.GasUsed),
+		"timestamp":        rpc.NewHexNumber(h.Time),
+		"transactionsRoot": h.TxHash,
+		"receiptsRoot":     h.ReceiptHash,
+	}
+}
+
+func rpcTx(tx *types.Transaction, block *types.Block) map[string]interface{} {
+	v, r, s := tx.RawSignatureValues()
+	fields := map[string]interface{}{
+		"blockHash":   nil,
+		"blockNumber": nil,
+		"gas":         rpc.NewHexNumber(tx.Gas()),
+		"gasPrice":    rpc.NewHexNumber(tx.GasPrice()),
+		"hash":        tx.Hash(),
+		"input":       fmt.Sprintf("0x%x", tx.Data()),
+		"nonce":       rpc.NewHexNumber(tx.Nonce()),
+		"to":          tx.To(),
+		"value":       rpc.NewHexNumber(tx.Value()),
+		"type":        rpc.NewHexNumber(tx.Type()),
+		"v":           rpc.NewHexNumber(v),
+		"r":           rpc.NewHexNumber(r),
+		"s":           rpc.NewHexNumber(s),
+	}
+	if block != nil {
+		fields["blockHash"] = block.Hash()
+		fields["blockNumber"] = rpc.NewHexNumber(block.Number())
+	}
+	if tx.Protected() {
+		fields["chainId"] = tx.ChainId()
+	}
+	return fields
+}
+
+func (api *TestEthAPI) rpcBlock(fullTx bool) map[string]interface{} {
+	fields := rpcHeader(api.block.Header())
+	txs := make([]interface{}, len(api.block.Transactions()))
+	for i, tx := range api.block.Transactions() {
+		if fullTx {
+			txs[i] = rpcTx(tx, api.block)
+		} else {
+			txs[i] = tx.Hash()
+		}
+	}
+	fields["transactions"] = txs
+	uncles := make([]common.Hash, len(api.block.Uncles()))
+	for i, uncle := range api.block.Uncles() {
+		uncles[i] = uncle.Hash()
+	}
+	fields["uncles"] = uncles
+	return fields
+}
+
+func (api *TestEthAPI) GetBlockByNumber(blockNr rpc.BlockNumber, fullTx bool) map[string]interface{} {
+	if blockNr == rpc.LatestBlockNumber || blockNr.Int64() == api.block.Number().Int64() {
+		return api.rpcBlock(fullTx)
+	}
+	return nil
+}
+
+func (api *TestEthAPI) GetBlockByHash(hash common.Hash, fullTx bool) map[string]interface{} {
+	if hash == api.block.Hash() {
+		return api.rpcBlock(fullTx)
+	}
+	return nil
+}
+
+func (api *TestEthAPI) GetUncleByBlockHashAndIndex(hash common.Hash, index rpc.HexNumber) map[string]interface{} {
+	if hash == api.block.Hash() && index.Int() < len(api.block.Uncles()) {
+		return rpcHeader(api.block.Uncles()[index.Int()])
+	}
+	return nil
+}
+
+func (api *TestEthAPI) GetTransactionByHash(hash common.Hash) map[string]interface{} {
+	if tx := api.block.Transaction(hash); tx != nil {
+		return rpcTx(tx, api.block)
+	}
+	return nil
+}
+
+func (api *TestEthAPI) GetTransactionReceipt(hash common.Hash) map[string]interface{} {
+	if hash != api.receipt.TxHash {
+		return nil
+	}
+	return map[string]interface{}{
+		"type":              rpc.NewHexNumber(api.receipt.Type),
+		"root":              common.Bytes2Hex(api.receipt.PostState),
+		"transactionHash":   hash,
+		"gasUsed":           rpc.NewHexNumber(api.receipt.GasUsed),
+		"cumulativeGasUsed": rpc.NewHexNumber(api.receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              api.receipt.Logs,
+		"status":            rpc.NewHexNumber(api.receipt.Status),
+	}
+}
+
+func (api *TestEthAPI) GetBalance(address common.Address, blockNr rpc.BlockNumber) *big.Int {
+	if address == testAddr {
+		return testBalance
+	}
+	return new(big.Int)
+}
+
+func (api *TestEthAPI) GetTransactionCount(address common.Address, blockNr rpc.BlockNumber) *rpc.HexNumber {
+	if blockNr == rpc.PendingBlockNumber {
+		return rpc.NewHexNumber(2)
+	}
+	return rpc.NewHexNumber(1)
+}
+
+func (api *TestEthAPI) GetCode(address common.Address, blockNr rpc.BlockNumber) string {
+	if address == testAddr {
+		return "0x"
+	}
+	return "0x6000"
+}
+
+func (api *TestEthAPI) GasPrice() *big.Int {
+	return big.NewInt(20000000000)
+}
+
+func (api *TestEthAPI) GetLogs(args map[string]interface{}) vm.Logs {
+	return api.receipt.Logs
+}
+
+type TestGethAPI struct{}
+
+func (api *TestGethAPI) GetAddressTransactions(address common.Address, blockStartN uint64, blockEndN rpc.BlockNumber, toOrFrom string, txKindOf string, pagStart, pagEnd int, reverse bool) []string {
+	if address != testAddr || blockEndN != rpc.LatestBlockNumber {
+		return []string{}
+	}
+	return []string{common.Hash{1}.Hex(), common.Hash{2}.Hex()}
+}
+
+type TestNetAPI struct{}
+
+func (api *TestNetAPI) Version() string {
+	return "1"
+}
+
+func newTestClient(t *testing.T) (*Client, *TestEthAPI) {
+	to := common.Address{0xaa}
+	tx, err := types.NewTransaction(1, to, big.NewInt(10), big.NewInt(21000), big.NewInt(1), nil).WithSigner(types.NewChainIdSigner(big.NewInt(61))).SignECDSA(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := types.NewReceipt(nil, big.NewInt(21000))
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = big.NewInt(21000)
+	receipt.Status = types.TxSuccess
+	receipt.Logs = vm.Logs{vm.NewLog(to, []common.Hash{{0xff}}, []byte{1, 2, 3}, 2)}
+	receipt.Logs[0].TxHash = tx.Hash()
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	uncle := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(131072), GasLimit: big.NewInt(3141592), GasUsed: new(big.Int), Time: big.NewInt(1), Extra: []byte("uncle")}
+	header := &types.Header{Number: big.NewInt(2), Difficulty: big.NewInt(131072), GasLimit: big.NewInt(3141592), GasUsed: big.NewInt(21000), Time: big.NewInt(2)}
+	api := &TestEthAPI{
+		block:   types.NewBlock(header, []*types.Transaction{tx}, []*types.Header{uncle}, []*types.Receipt{receipt}),
+		receipt: receipt,
+	}
+
+	server := rpc.NewServer()
+	for namespace, service := range map[string]interface{}{"eth": api, "geth": new(TestGethAPI), "net": new(TestNetAPI)} {
+		if err := server.RegisterName(namespace, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewClient(rpc.DialInProc(server)), api
+}
+
+func TestBlockAccess(t *testing.T) {
+	ec, api := newTestClient(t)
+	defer ec.Close()
+	ctx := context.Background()
+
+	block, err := ec.BlockByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash() != api.block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", block.Hash(), api.block.Hash())
+	}
+	if len(block.Transactions()) != 1 || block.Transactions()[0].Hash() != api.block.Transactions()[0].Hash() {
+		t.Errorf("transaction mismatch: %v", block.Transactions())
+	}
+	if len(block.Uncles()) != 1 || block.Uncles()[0].Hash() != api.block.Uncles()[0].Hash() {
+		t.Errorf("uncle mismatch: %v", block.Uncles())
+	}
+
+	head, err := ec.HeaderByHash(ctx, api.block.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Hash() != api.block.Hash() {
+		t.Errorf("header hash mismatch: have %x, want %x", head.Hash(), api.block.Hash())
+	}
+	if _, err := ec.HeaderByNumber(ctx, big.NewInt(100)); err != ErrNotFound {
+		t.Errorf("expected %v for unknown block, got %v", ErrNotFound, err)
+	}
+}
+
+func TestTransactionAccess(t *testing.T) {
+	ec, api := newTestClient(t)
+	defer ec.Close()
+	ctx := context.Background()
+
+	want := api.block.Transactions()[0]
+	tx, pending, err := ec.TransactionByHash(ctx, want.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending {
+		t.Error("mined transaction reported as pending")
+	}
+	if from, err := types.Sender(types.DeriveSigner(tx), tx); err != nil || from != testAddr {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, testAddr)
+	}
+
+	receipt, err := ec.TransactionReceipt(ctx, want.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.TxSuccess || receipt.GasUsed.Cmp(big.NewInt(21000)) != 0 {
+		t.Errorf("receipt mismatch: %v", receipt)
+	}
+	if receipt.Bloom != api.receipt.Bloom || len(receipt.Logs) != 1 {
+		t.Errorf("receipt logs mismatch: %v", receipt.Logs)
+	}
+	if _, err := ec.TransactionReceipt(ctx, common.Hash{}); err != ErrNotFound {
+		t.Errorf("expected %v for unknown receipt, got %v", ErrNotFound, err)
+	}
+
+	logs, err := ec.FilterLogs(ctx, FilterQuery{Topics: [][]common.Hash{nil, {{0xff}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Topics[0] != (common.Hash{0xff}) || logs[0].TxHash != want.Hash() {
+		t.Errorf("log mismatch: %v", logs)
+	}
+}
+
+func TestStateAccess(t *testing.T) {
+	ec, _ := newTestClient(t)
+	defer ec.Close()
+	ctx := context.Background()
+
+	balance, err := ec.BalanceAt(ctx, testAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(testBalance) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, testBalance)
+	}
+	if nonce, err := ec.NonceAt(ctx, testAddr, big.NewInt(1)); err != nil || nonce != 1 {
+		t.Errorf("nonce mismatch: have %d (%v), want 1", nonce, err)
+	}
+	if nonce, err := ec.PendingAccountNonce(testAddr); err != nil || nonce != 2 {
+		t.Errorf("pending nonce mismatch: have %d (%v), want 2", nonce, err)
+	}
+	if ok, err := ec.HasCode(testAddr, false); err != nil || ok {
+		t.Errorf("HasCode(account) = %v, %v", ok, err)
+	}
+	if ok, err := ec.HasCode(common.Address{0xaa}, true); err != nil || !ok {
+		t.Errorf("HasCode(contract) = %v, %v", ok, err)
+	}
+	if price, err := ec.SuggestGasPrice(); err != nil || price.Cmp(big.NewInt(20000000000)) != 0 {
+		t.Errorf("gas price mismatch: have %v (%v)", price, err)
+	}
+	if id, err := ec.NetworkID(ctx); err != nil || id.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("network id mismatch: have %v (%v)", id, err)
+	}
+
+	hashes, err := ec.AddressTransactions(ctx, testAddr, 0, nil, "tf", "sc", -1, -1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || hashes[0] != (common.Hash{1}) || hashes[1] != (common.Hash{2}) {
+		t.Errorf("address transactions mismatch: %x", hashes)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"github.com/eth-classic/go-ethereum/trie"
+)
+
+// AccountResult is the account state returned by eth_getProof, along with the
+// merkle proof of the account in the state trie and the proofs of the requested
+// storage slots in the account's storage trie.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the value of a storage slot and its merkle proof.
+type StorageResult struct {
+	Key   common.Hash     `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// VerifyProof checks the account proof against the given state root and the
+// storage proofs against the proven storage root of the account. An error is
+// returned if any proof is invalid or doesn't prove the values in the result.
+func (r *AccountResult) VerifyProof(root common.Hash) error {
+	enc, err := verifyProof(root, r.Address[:], r.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	// Accounts missing from the trie are proven to be empty
+	account := state.Account{
+		Balance:  new(big.Int),
+		Root:     types.EmptyRootHash,
+		CodeHash: crypto.Keccak256(nil),
+	}
+	if enc != nil {
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return fmt.Errorf("invalid account proof: %v", err)
+		}
+	}
+	if account.Nonce != uint64(r.Nonce) {
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", r.Nonce, account.Nonce)
+	}
+	if account.Balance.Cmp(bigOrZero(r.Balance)) != 0 {
+		return fmt.Errorf("balance mismatch: have %v, proven %v", bigOrZero(r.Balance), account.Balance)
+	}
+	if common.BytesToHash(account.CodeHash) != r.CodeHash {
+		return fmt.Errorf("code hash mismatch: have %x, proven %x", r.CodeHash, account.CodeHash)
+	}
+	if account.Root != r.StorageHash {
+		return fmt.Errorf("storage hash mismatch: have %x, proven %x", r.StorageHash, account.Root)
+	}
+	for _, slot := range r.StorageProof {
+		enc, err := verifyProof(account.Root, slot.Key[:], slot.Proof)
+		if err != nil {
+			return fmt.Errorf("invalid storage proof for key %x: %v", slot.Key, err)
+		}
+		value := new(big.Int)
+		if enc != nil {
+			var content []byte
+			if err := rlp.DecodeBytes(enc, &content); err != nil {
+				return fmt.Errorf("invalid storage proof for key %x: %v", slot.Key, err)
+			}
+			value.SetBytes(content)
+		}
+		if value.Cmp(bigOrZero(slot.Value)) != 0 {
+			return fmt.Errorf("storage value mismatch for key %x: have %v, proven %v", slot.Key, bigOrZero(slot.Value), value)
+		}
+	}
+	return nil
+}
+
+// verifyProof checks the proof of key in the secure trie with the given root,
+// returning the proven value, or nil if the proof shows the key is absent.
+func verifyProof(root common.Hash, key []byte, proof []hexutil.Bytes) ([]byte, error) {
+	// An empty trie has no nodes to prove anything with
+	if root == types.EmptyRootHash && len(proof) == 0 {
+		return nil, nil
+	}
+	db, _ := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	value, err, _ := trie.VerifyProof(root, crypto.Keccak256(key), db)
+	return value, err
+}
+
+func bigOrZero(b *hexutil.Big) *big.Int {
+	if b == nil {
+		return new(big.Int)
+	}
+	return b.ToInt()
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/common/hexutil"
+	"github.com/eth-classic/go-ethereum/core/state"
+	"github.com/eth-classic/go-ethereum/core/types"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+)
+
+// proveAccount assembles an eth_getProof result for addr from the given state.
+func proveAccount(t *testing.T, statedb *state.StateDB, addr common.Address, keys ...common.Hash) *AccountResult {
+	toBytes := func(proof [][]byte, err error) []hexutil.Bytes {
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := make([]hexutil.Bytes, len(proof))
+		for i, node := range proof {
+			nodes[i] = node
+		}
+		return nodes
+	}
+	result := &AccountResult{
+		Address:      addr,
+		AccountProof: toBytes(statedb.GetProof(addr)),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(addr)),
+		CodeHash:     statedb.GetCodeHash(addr),
+		Nonce:        hexutil.Uint64(statedb.GetNonce(addr)),
+		StorageHash:  statedb.GetStorageRoot(addr),
+	}
+	for _, key := range keys {
+		result.StorageProof = append(result.StorageProof, StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(statedb.GetState(addr, key).Big()),
+			Proof: toBytes(statedb.GetStorageProof(addr, key)),
+		})
+	}
+	return result
+}
+
+func TestVerifyProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	for i := byte(1); i < 64; i++ {
+		statedb.SetBalance(common.Address{i}, big.NewInt(int64(i)))
+	}
+	addr := common.Address{0xaa}
+	statedb.SetBalance(addr, testBalance)
+	statedb.SetNonce(addr, 5)
+	statedb.SetCode(addr, []byte{0x60, 0x00})
+	statedb.SetState(addr, common.Hash{1}, common.Hash{0x11})
+	statedb.SetState(addr, common.Hash{2}, common.Hash{0x22})
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	// Existing account with present and missing storage slots
+	result := proveAccount(t, statedb, addr, common.Hash{1}, common.Hash{2}, common.Hash{3})
+	if err := result.VerifyProof(root); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+	// Tampered values must be rejected
+	result.Balance = (*hexutil.Big)(big.NewInt(1))
+	if err := result.VerifyProof(root); err == nil {
+		t.Error("tampered balance accepted")
+	}
+	result = proveAccount(t, statedb, addr, common.Hash{1})
+	result.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(1))
+	if err := result.VerifyProof(root); err == nil {
+		t.Error("tampered storage value accepted")
+	}
+	result = proveAccount(t, statedb, addr)
+	if err := result.VerifyProof(common.Hash{0xff}); err == nil {
+		t.Error("proof accepted against wrong root")
+	}
+	// Missing accounts are proven empty
+	missing := common.Address{0xbb}
+	result = &AccountResult{
+		Address:      missing,
+		AccountProof: proveAccount(t, statedb, missing).AccountProof,
+		Balance:      new(hexutil.Big),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: []StorageResult{{Key: common.Hash{1}, Value: new(hexutil.Big)}},
+	}
+	if err := result.VerifyProof(root); err != nil {
+		t.Fatalf("valid absence proof rejected: %v", err)
+	}
+	result.Balance = (*hexutil.Big)(big.NewInt(1))
+	if err := result.VerifyProof(root); err == nil {
+		t.Error("tampered absence proof accepted")
+	}
+}
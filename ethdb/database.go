@@ -136,6 +136,12 @@ func (self *LDBDatabase) NewIteratorRange(slice *ldbutil.Range) iterator.Iterato
 	return self.db.NewIterator(slice, nil)
 }
 
+// NewIteratorWithPrefix creates an iterator over the entries whose keys start
+// with the given prefix.
+func (self *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return self.db.NewIterator(ldbutil.BytesPrefix(prefix), nil)
+}
+
 func NewBytesPrefix(prefix []byte) *ldbutil.Range {
 	return ldbutil.BytesPrefix(prefix)
 }
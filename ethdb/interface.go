@@ -34,6 +34,23 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// Iterator iterates over the key-value pairs of a database in ascending key
+// order. It has to be released after use.
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Release()
+	Error() error
+}
+
+// Iteratee is implemented by databases which can iterate over their contents.
+type Iteratee interface {
+	// NewIteratorWithPrefix creates an iterator over the entries whose keys
+	// start with the given prefix.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+}
+
 type Batch interface {
 	Putter
 	ValueSize() int // amount of data in the batch
@@ -10,6 +10,7 @@ require (
 	github.com/eth-classic/go-ethereum/accounts/abi/bind v0.0.0-20190521151733-fe17e9e1e2ce
 	github.com/fatih/color v1.7.0
 	github.com/gizak/termui v2.3.0+incompatible
+	github.com/golang/snappy v0.0.1
 	github.com/hashicorp/golang-lru v0.5.1
 	github.com/huin/goupnp v1.0.0
 	github.com/jackpal/go-nat-pmp v1.0.1
@@ -113,6 +113,17 @@ var (
 	FetchBroadcastDOS   = metrics.NewRegisteredMeter("fetch/broadcast/dos", reg)
 )
 
+var (
+	ReqHeaderLatency  = metrics.NewRegisteredTimer("request/header/latency", reg)
+	ReqBodyLatency    = metrics.NewRegisteredTimer("request/body/latency", reg)
+	ReqStateLatency   = metrics.NewRegisteredTimer("request/state/latency", reg)
+	ReqReceiptLatency = metrics.NewRegisteredTimer("request/receipt/latency", reg)
+	ReqTxLatency      = metrics.NewRegisteredTimer("request/txn/latency", reg)
+
+	ReqUnsolicited = metrics.NewRegisteredMeter("request/unsolicited", reg)
+	ReqTimeouts    = metrics.NewRegisteredMeter("request/timeout", reg)
+)
+
 var (
 	P2PIn       = metrics.NewRegisteredMeter("p2p/in", reg)
 	P2PInBytes  = metrics.NewRegisteredMeter("p2p/in/bytes", reg)
@@ -23,10 +23,10 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/eth-classic/ethash"
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/pow/etchash"
 )
 
 type hashrate struct {
@@ -48,12 +48,18 @@ type RemoteAgent struct {
 	hashrate   map[common.Hash]hashrate
 
 	running int32 // running indicates whether the agent is active. Call atomically
+
+	ecip1099Transition *big.Int // block from which the seed hash uses the ECIP-1099 epoch length
 }
 
-func NewRemoteAgent() *RemoteAgent {
+// NewRemoteAgent creates an agent handing out work to external miners. The
+// ECIP-1099 transition block (nil if not configured) determines the seed hash
+// returned by GetWork.
+func NewRemoteAgent(ecip1099Transition *big.Int) *RemoteAgent {
 	return &RemoteAgent{
-		work:     make(map[common.Hash]*Work),
-		hashrate: make(map[common.Hash]hashrate),
+		work:               make(map[common.Hash]*Work),
+		hashrate:           make(map[common.Hash]hashrate),
+		ecip1099Transition: ecip1099Transition,
 	}
 }
 
@@ -113,7 +119,7 @@ func (a *RemoteAgent) GetWork() ([3]string, error) {
 		block := a.currentWork.Block
 
 		res[0] = block.HashNoNonce().Hex()
-		seedHash, _ := ethash.GetSeedHash(block.NumberU64())
+		seedHash, _ := etchash.SeedHash(block.NumberU64(), a.ecip1099Transition)
 		res[1] = common.BytesToHash(seedHash).Hex()
 		// Calculate the "target" to be returned to the external miner
 		n := big.NewInt(1)
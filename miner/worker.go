@@ -267,7 +267,6 @@ func (self *worker) wait() {
 				}
 				go self.mux.Post(core.NewMinedBlockEvent{Block: block})
 			} else {
-				work.state.CommitTo(self.chainDb, work.config.IsAtlantis(block.Number()))
 				parent := self.chain.GetBlock(block.ParentHash())
 				if parent == nil {
 					glog.V(logger.Error).Infoln("Invalid block found during mining")
@@ -280,7 +279,7 @@ func (self *worker) wait() {
 					continue
 				}
 
-				stat, err := self.chain.WriteBlock(block)
+				stat, err := self.chain.WriteBlockWithState(block, work.state)
 				if err != nil {
 					glog.V(logger.Error).Infoln("error writing block to chain", err)
 					continue
@@ -85,9 +85,20 @@ type Config struct {
 	// or not. Disabling is usually useful for protocol debugging (manual topology).
 	NoDiscovery bool
 
+	// DiscoveryV5 specifies whether the discovery v5 protocol should be started
+	// alongside v4. It is started even if NoDiscovery is set.
+	DiscoveryV5 bool
+
 	// Bootstrap nodes used to establish connectivity with the rest of the network.
 	BootstrapNodes []*discover.Node
 
+	// Bootstrap nodes used by the discovery v5 protocol.
+	BootstrapNodesV5 []*discover.Node
+
+	// DiscoveryDNS is a list of enrtree:// URLs of DNS node lists (EIP-1459)
+	// whose nodes are dialed. It is used even if NoDiscovery is set.
+	DiscoveryDNS []string
+
 	// Network interface address on which the node should listen for inbound peers.
 	ListenAddr string
 
@@ -101,19 +101,22 @@ func New(conf *Config) (*Node, error) {
 	return &Node{
 		datadir: conf.DataDir,
 		serverConfig: p2p.Config{
-			PrivateKey:      conf.NodeKey(),
-			Name:            conf.Name,
-			Discovery:       !conf.NoDiscovery,
-			BootstrapNodes:  conf.BootstrapNodes,
-			StaticNodes:     conf.StaticNodes(),
-			TrustedNodes:    conf.TrusterNodes(),
-			NodeDatabase:    nodeDbPath,
-			ListenAddr:      conf.ListenAddr,
-			NAT:             conf.NAT,
-			Dialer:          conf.Dialer,
-			NoDial:          conf.NoDial,
-			MaxPeers:        conf.MaxPeers,
-			MaxPendingPeers: conf.MaxPendingPeers,
+			PrivateKey:       conf.NodeKey(),
+			Name:             conf.Name,
+			Discovery:        !conf.NoDiscovery,
+			DiscoveryV5:      conf.DiscoveryV5,
+			BootstrapNodes:   conf.BootstrapNodes,
+			BootstrapNodesV5: conf.BootstrapNodesV5,
+			DiscoveryDNS:     conf.DiscoveryDNS,
+			StaticNodes:      conf.StaticNodes(),
+			TrustedNodes:     conf.TrusterNodes(),
+			NodeDatabase:     nodeDbPath,
+			ListenAddr:       conf.ListenAddr,
+			NAT:              conf.NAT,
+			Dialer:           conf.Dialer,
+			NoDial:           conf.NoDial,
+			MaxPeers:         conf.MaxPeers,
+			MaxPendingPeers:  conf.MaxPendingPeers,
 		},
 		serviceFuncs:  []ServiceConstructor{},
 		ipcEndpoint:   conf.IPCEndpoint(),
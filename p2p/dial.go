@@ -48,6 +48,7 @@ const (
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
+	filter      func(*discover.Node) bool // checks dynamic dial candidates, may be nil
 
 	lookupRunning bool
 	dialing       map[discover.NodeID]connFlag
@@ -65,6 +66,65 @@ type discoverTable interface {
 	ReadRandomNodes([]*discover.Node) int
 }
 
+// discoverMix combines the tables of several discovery protocols. Random
+// nodes are read from all tables in equal parts and lookups query all of
+// them.
+type discoverMix []discoverTable
+
+func (m discoverMix) Self() *discover.Node {
+	return m[0].Self()
+}
+
+func (m discoverMix) Close() {
+	for _, t := range m {
+		t.Close()
+	}
+}
+
+func (m discoverMix) Resolve(target discover.NodeID) *discover.Node {
+	for _, t := range m {
+		if n := t.Resolve(target); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (m discoverMix) Lookup(target discover.NodeID) []*discover.Node {
+	var (
+		result []*discover.Node
+		seen   = make(map[discover.NodeID]bool)
+	)
+	for _, t := range m {
+		for _, n := range t.Lookup(target) {
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				result = append(result, n)
+			}
+		}
+	}
+	return result
+}
+
+func (m discoverMix) ReadRandomNodes(buf []*discover.Node) int {
+	n := 0
+	for i, t := range m {
+		// Give each table its share of the remaining space.
+		end := n + (len(buf)-n)/(len(m)-i)
+		if i == len(m)-1 {
+			end = len(buf)
+		}
+		if end > n {
+			k := t.ReadRandomNodes(buf[n:end])
+			if k > end-n {
+				k = end - n
+			}
+			n += k
+		}
+	}
+	return n
+}
+
 // the dial history remembers recent dials.
 type dialHistory []pastDial
 
@@ -139,6 +199,9 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 		if isDialing(n.ID) {
 			return false
 		}
+		if s.filter != nil && !s.filter(n) {
+			return false
+		}
 		s.dialing[n.ID] = flag
 		newtasks = append(newtasks, &dialTask{flags: flag, dest: n})
 		return true
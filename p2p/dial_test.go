@@ -492,6 +492,48 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+// Tests that dynamic dial candidates rejected by the filter are skipped
+// while static nodes are always dialed.
+func TestDialStateFilter(t *testing.T) {
+	table := fakeTable{{ID: uintID(1)}, {ID: uintID(2)}, {ID: uintID(3)}}
+	state := newDialState([]*discover.Node{{ID: uintID(4)}}, table, 6)
+	state.filter = func(n *discover.Node) bool { return n.ID != uintID(2) && n.ID != uintID(4) }
+
+	var dialed []discover.NodeID
+	for _, task := range state.newTasks(0, nil, time.Time{}) {
+		if dt, ok := task.(*dialTask); ok {
+			dialed = append(dialed, dt.dest.ID)
+		}
+	}
+	for _, id := range dialed {
+		if id == uintID(2) {
+			t.Errorf("filtered node was dialed")
+		}
+	}
+	if len(dialed) != 3 {
+		t.Errorf("wrong number of dials: %d, want 3", len(dialed))
+	}
+}
+
+// Tests that discoverMix reads random nodes from all tables.
+func TestDiscoverMixRandomNodes(t *testing.T) {
+	mix := discoverMix{
+		fakeTable{{ID: uintID(1)}, {ID: uintID(2)}, {ID: uintID(3)}},
+		fakeTable{{ID: uintID(4)}},
+	}
+	buf := make([]*discover.Node, 4)
+	n := mix.ReadRandomNodes(buf)
+	if n != 3 {
+		t.Fatalf("wrong count %d, want 3", n)
+	}
+	want := []discover.NodeID{uintID(1), uintID(2), uintID(4)}
+	for i := range want {
+		if buf[i].ID != want[i] {
+			t.Errorf("node %d: have %x, want %x", i, buf[i].ID[:4], want[i][:4])
+		}
+	}
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
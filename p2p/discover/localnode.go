@@ -0,0 +1,201 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+// LocalNode produces the signed node record of the local node, which is
+// served by both discovery protocols. Entries can be changed while the node
+// is running, every change to the record content increments its sequence
+// number.
+type LocalNode struct {
+	id  NodeID
+	key *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	seq     uint64
+	ip      net.IP
+	udp     uint16
+	tcp     uint16
+	entries map[string]enr.Entry
+	cur     *Node // current signed node, nil if the entries changed since
+}
+
+// NewLocalNode creates a local node. The sequence number starts at the
+// current time in milliseconds, so records created after a restart always
+// supersede the ones the network saw before.
+func NewLocalNode(key *ecdsa.PrivateKey) *LocalNode {
+	return &LocalNode{
+		id:      PubkeyID(&key.PublicKey),
+		key:     key,
+		seq:     uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		entries: make(map[string]enr.Entry),
+	}
+}
+
+// ID returns the identifier of the local node.
+func (ln *LocalNode) ID() NodeID {
+	return ln.id
+}
+
+// Seq returns the current sequence number of the local node record.
+func (ln *LocalNode) Seq() uint64 {
+	return ln.Node().Record().Seq()
+}
+
+// Node returns the local node, including its current signed record.
+func (ln *LocalNode) Node() *Node {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.cur == nil {
+		ln.sign()
+	}
+	return ln.cur
+}
+
+// Record returns the current signed record of the local node.
+func (ln *LocalNode) Record() *enr.Record {
+	return ln.Node().Record()
+}
+
+// Set adds or updates an entry of the local node record.
+func (ln *LocalNode) Set(e enr.Entry) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	ln.set(e)
+}
+
+// SetEndpoint updates the address the local node announces. Unspecified IP
+// addresses and zero ports are left out of the record.
+func (ln *LocalNode) SetEndpoint(ip net.IP, udp, tcp uint16) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	}
+	if !ip.Equal(ln.ip) || udp != ln.udp || tcp != ln.tcp {
+		ln.ip, ln.udp, ln.tcp = ip, udp, tcp
+		ln.invalidate()
+	}
+	if ip != nil && !ip.IsUnspecified() {
+		ln.set(enr.IP(ip))
+	} else {
+		delete(ln.entries, enr.IPv4{}.ENRKey())
+		delete(ln.entries, enr.IPv6{}.ENRKey())
+	}
+	if udp != 0 {
+		ln.set(enr.UDP(udp))
+	}
+	if tcp != 0 {
+		ln.set(enr.TCP(tcp))
+	}
+}
+
+// hasEndpoint reports whether a UDP endpoint was set.
+func (ln *LocalNode) hasEndpoint() bool {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	return ln.udp != 0
+}
+
+// set stores the entry if its value changed. The caller must hold ln.mu.
+func (ln *LocalNode) set(e enr.Entry) {
+	if old, ok := ln.entries[e.ENRKey()]; ok && entryEqual(old, e) {
+		return
+	}
+	ln.entries[e.ENRKey()] = e
+	ln.invalidate()
+}
+
+// invalidate drops the signed node after a change. The caller must hold ln.mu.
+func (ln *LocalNode) invalidate() {
+	if ln.cur != nil {
+		ln.cur = nil
+		ln.seq++
+	}
+}
+
+// sign creates the signed record from the entries. The caller must hold ln.mu.
+func (ln *LocalNode) sign() {
+	var r enr.Record
+	for _, e := range ln.entries {
+		r.Set(e)
+	}
+	r.SetSeq(ln.seq)
+	if err := enr.SignV4(&r, ln.key); err != nil {
+		// This can only happen if the entries exceed the size limit.
+		glog.V(logger.Error).Errorf("can't sign local node record: %v", err)
+		r = enr.Record{}
+		r.SetSeq(ln.seq)
+		enr.SignV4(&r, ln.key)
+	}
+	n := NewNode(ln.id, ln.ip, ln.udp, ln.tcp)
+	n.record = &r
+	ln.cur = n
+}
+
+func entryEqual(a, b enr.Entry) bool {
+	ab, err1 := rlp.EncodeToBytes(a)
+	bb, err2 := rlp.EncodeToBytes(b)
+	return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
+
+// nodeFromRecord creates a node from a verified record, checking that the
+// record belongs to the given node if id is non-zero.
+func nodeFromRecord(r *enr.Record, id NodeID) (*Node, error) {
+	pubkey := r.PublicKey()
+	if pubkey == nil {
+		return nil, errNoPubkey
+	}
+	rid := PubkeyID(pubkey)
+	if id != (NodeID{}) && rid != id {
+		return nil, errRecordMismatch
+	}
+	var (
+		ip4 enr.IPv4
+		ip6 enr.IPv6
+		udp enr.UDP
+		tcp enr.TCP
+		ip  net.IP
+	)
+	if r.Load(&ip4) == nil {
+		ip = net.IP(ip4)
+	} else if r.Load(&ip6) == nil {
+		ip = net.IP(ip6)
+	}
+	r.Load(&udp)
+	r.Load(&tcp)
+
+	n := NewNode(rid, ip, uint16(udp), uint16(tcp))
+	n.record = r
+	return n, nil
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/crypto"
 	"github.com/eth-classic/go-ethereum/crypto/secp256k1"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 )
 
 const nodeIDBits = 512
@@ -60,6 +61,9 @@ type Node struct {
 
 	// Time when the node was added to the table.
 	addedAt time.Time
+
+	// The signed node record, if known.
+	record *enr.Record
 }
 
 // NewNode creates a new node. It is mostly meant to be used for
@@ -77,6 +81,16 @@ func NewNode(id NodeID, ip net.IP, udpPort, tcpPort uint16) *Node {
 	}
 }
 
+// NewNodeFromRecord creates a node from a signed node record.
+func NewNodeFromRecord(r *enr.Record) (*Node, error) {
+	return nodeFromRecord(r, NodeID{})
+}
+
+// Record returns the signed node record, or nil if it is unknown.
+func (n *Node) Record() *enr.Record {
+	return n.record
+}
+
 func (n *Node) addr() *net.UDPAddr {
 	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP)}
 }
@@ -146,7 +160,16 @@ var incompleteNodeURL = regexp.MustCompile("(?i)^(?:enode://)?([0-9a-f]+)$")
 // and UDP discovery port 30301.
 //
 //    enode://<hex node id>@10.3.58.6:30303?discport=30301
+//
+// Signed node records in their text encoding ("enr:...") are accepted as well.
 func ParseNode(rawurl string) (*Node, error) {
+	if strings.HasPrefix(rawurl, "enr:") {
+		r, err := enr.Parse(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node record (%v)", err)
+		}
+		return NewNodeFromRecord(r)
+	}
 	if m := incompleteNodeURL.FindStringSubmatch(rawurl); m != nil {
 		id, err := HexID(m[1])
 		if err != nil {
@@ -36,6 +36,7 @@ import (
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/p2p/distip"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 )
 
 const (
@@ -94,8 +95,9 @@ type Table struct {
 
 	nodeAddedHook func(*Node) // for testing
 
-	net  transport
-	self *Node // metadata of the local node
+	net   transport
+	self  *Node      // metadata of the local node
+	local *LocalNode // signed record of the local node, if served
 }
 
 type bondproc struct {
@@ -108,9 +110,10 @@ type bondproc struct {
 // it is an interface so we can test without opening lots of UDP
 // sockets and without generating a private key.
 type transport interface {
-	ping(NodeID, *net.UDPAddr) error
+	ping(NodeID, *net.UDPAddr) (seq uint64, err error)
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
 	close()
 }
 
@@ -155,9 +158,17 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 // Self returns the local node.
 // The returned node should not be modified by the caller.
 func (tab *Table) Self() *Node {
+	if tab.local != nil {
+		return tab.local.Node()
+	}
 	return tab.self
 }
 
+// LocalNode returns the local node record served by the table's transport.
+func (tab *Table) LocalNode() *LocalNode {
+	return tab.local
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
@@ -576,7 +587,8 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	defer func() { tab.bondslots <- struct{}{} }()
 
 	// Ping the remote side and wait for a pong.
-	if w.err = tab.ping(id, addr); w.err != nil {
+	seq, err := tab.ping(id, addr)
+	if w.err = err; w.err != nil {
 		close(w.done)
 		return
 	}
@@ -589,15 +601,25 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	// Bonding succeeded, update the node database.
 	w.n = NewNode(id, addr.IP, uint16(addr.Port), tcpPort)
 	tab.db.updateNode(w.n)
+
+	// Fetch the node record if the remote has one.
+	if seq > 0 {
+		if record, err := tab.net.requestENR(id, addr); err == nil {
+			w.n.record = record
+		} else {
+			glog.V(logger.Detail).Infof("Requesting record of %x failed: %v", id[:8], err)
+		}
+	}
 	close(w.done)
 }
 
 // ping a remote endpoint and wait for a reply, also updating the node
-// database accordingly.
-func (tab *Table) ping(id NodeID, addr *net.UDPAddr) error {
+// database accordingly. It returns the remote record sequence number.
+func (tab *Table) ping(id NodeID, addr *net.UDPAddr) (uint64, error) {
 	tab.db.updateLastPing(id, time.Now())
-	if err := tab.net.ping(id, addr); err != nil {
-		return err
+	seq, err := tab.net.ping(id, addr)
+	if err != nil {
+		return 0, err
 	}
 	tab.db.updateLastPong(id, time.Now())
 
@@ -607,7 +629,7 @@ func (tab *Table) ping(id NodeID, addr *net.UDPAddr) error {
 	// so that the search for seed nodes also considers older nodes
 	// that would otherwise be removed by the expiration.
 	tab.db.ensureExpirer()
-	return nil
+	return seq, nil
 }
 
 // add attempts to add the given node its corresponding bucket. If the
@@ -25,10 +25,12 @@ import (
 	"net"
 	"time"
 
+	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/crypto"
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/p2p/distip"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 	"github.com/eth-classic/go-ethereum/p2p/nat"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
@@ -47,6 +49,8 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errNoPubkey         = errors.New("record has no secp256k1 public key")
+	errRecordMismatch   = errors.New("record belongs to a different node")
 
 	// Note: golang/net.IP provides some similar functionality via #IsLinkLocalUnicast, ...Multicast, etc.
 	// I would rather duplicate the information in a unified and comprehensive system than
@@ -97,6 +101,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket // EIP-868
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -105,7 +111,8 @@ type (
 		Version    uint
 		From, To   rpcEndpoint
 		Expiration uint64
-		// Ignore additional fields (for forward compatibility).
+		// Ignore additional fields (for forward compatibility). The
+		// first one is the sender's record sequence number (EIP-868).
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
@@ -118,7 +125,8 @@ type (
 
 		ReplyTok   []byte // This contains the hash of the ping packet.
 		Expiration uint64 // Absolute timestamp at which the packet becomes invalid.
-		// Ignore additional fields (for forward compatibility).
+		// Ignore additional fields (for forward compatibility). The
+		// first one is the sender's record sequence number (EIP-868).
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
@@ -138,6 +146,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest queries for the remote node's record.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -152,6 +175,23 @@ type (
 	}
 )
 
+// seqTail encodes a record sequence number as the extension field of
+// ping and pong packets.
+func seqTail(seq uint64) []rlp.RawValue {
+	blob, _ := rlp.EncodeToBytes(seq)
+	return []rlp.RawValue{blob}
+}
+
+// seqFromTail decodes the record sequence number from the extension fields
+// of ping and pong packets, returning zero if the sender didn't include it.
+func seqFromTail(tail []rlp.RawValue) uint64 {
+	var seq uint64
+	if len(tail) > 0 {
+		rlp.DecodeBytes(tail[0], &seq)
+	}
+	return seq
+}
+
 func makeEndpoint(addr *net.UDPAddr, tcpPort uint16) rpcEndpoint {
 	ip := addr.IP.To4()
 	if ip == nil {
@@ -183,19 +223,42 @@ type packet interface {
 	handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error
 }
 
-type conn interface {
+// UDPConn is a network connection on which discovery can operate.
+type UDPConn interface {
 	ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error)
 	WriteToUDP(b []byte, addr *net.UDPAddr) (n int, err error)
 	Close() error
 	LocalAddr() net.Addr
 }
 
+// Config holds settings for the discovery listeners.
+type Config struct {
+	// This field must be set to a valid secp256k1 private key.
+	PrivateKey *ecdsa.PrivateKey
+
+	// These settings are optional:
+	NAT        nat.Interface     // port mapper of the listening port
+	NodeDBPath string            // path of the node database, in-memory if empty
+	LocalNode  *LocalNode        // local node record, created from PrivateKey if nil
+	Unhandled  chan<- ReadPacket // packets the listener can't handle are sent here
+}
+
+// ReadPacket is a packet that couldn't be handled. Those packets are sent to
+// the unhandled channel if configured, allowing another protocol to share
+// the socket.
+type ReadPacket struct {
+	Data []byte
+	Addr *net.UDPAddr
+}
+
 // udp implements the RPC protocol.
 type udp struct {
-	conn        conn
+	conn        UDPConn
 	netrestrict *distip.Netlist
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
+	local       *LocalNode
+	unhandled   chan<- ReadPacket
 
 	addpending chan *pending
 	gotreply   chan reply
@@ -252,40 +315,53 @@ func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBP
 	if err != nil {
 		return nil, err
 	}
-	tab, _, err := newUDP(priv, conn, natm, nodeDBPath)
+	return ListenV4(conn, Config{PrivateKey: priv, NAT: natm, NodeDBPath: nodeDBPath})
+}
+
+// ListenV4 starts the v4 discovery protocol on the given connection.
+func ListenV4(c UDPConn, cfg Config) (*Table, error) {
+	tab, _, err := newUDP(c, cfg)
 	if err != nil {
 		return nil, err
 	}
-	glog.V(logger.Info).Infoln("Listening,", tab.self)
-	glog.D(logger.Warn).Infoln("UDP listening. Client enode:", logger.ColorGreen(tab.self.String()))
+	glog.V(logger.Info).Infoln("Listening,", tab.Self())
+	glog.D(logger.Warn).Infoln("UDP listening. Client enode:", logger.ColorGreen(tab.Self().String()))
 
 	return tab, nil
 }
 
-func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath string) (*Table, *udp, error) {
+func newUDP(c UDPConn, cfg Config) (*Table, *udp, error) {
 	udp := &udp{
 		conn:       c,
-		priv:       priv,
+		priv:       cfg.PrivateKey,
+		local:      cfg.LocalNode,
+		unhandled:  cfg.Unhandled,
 		closing:    make(chan struct{}),
 		gotreply:   make(chan reply),
 		addpending: make(chan *pending),
 	}
+	if udp.local == nil {
+		udp.local = NewLocalNode(cfg.PrivateKey)
+	}
 	realaddr := c.LocalAddr().(*net.UDPAddr)
-	if natm != nil {
+	if cfg.NAT != nil {
 		if !realaddr.IP.IsLoopback() {
-			go nat.Map(natm, udp.closing, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+			go nat.Map(cfg.NAT, udp.closing, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
 		}
 		// TODO: react to external IP changes over time.
-		if ext, err := natm.ExternalIP(); err == nil {
+		if ext, err := cfg.NAT.ExternalIP(); err == nil {
 			realaddr = &net.UDPAddr{IP: ext, Port: realaddr.Port}
 		}
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
-	tab, err := newTable(udp, PubkeyID(&priv.PublicKey), realaddr, nodeDBPath)
+	udp.local.SetEndpoint(realaddr.IP, uint16(realaddr.Port), uint16(realaddr.Port))
+
+	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath)
 	if err != nil {
 		return nil, nil, err
 	}
+	tab.local = udp.local
 	udp.Table = tab
 
 	go udp.loop()
@@ -299,17 +375,24 @@ func (t *udp) close() {
 	// TODO: wait for the loops to end.
 }
 
-// ping sends a ping message to the given node and waits for a reply.
-func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
+// ping sends a ping message to the given node and waits for a reply. It
+// returns the record sequence number announced in the pong.
+func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) (uint64, error) {
 	// TODO: maybe check for ReplyTo field in callback to measure RTT
-	errc := t.pending(toid, pongPacket, func(interface{}) bool { return true })
+	var seq uint64
+	errc := t.pending(toid, pongPacket, func(r interface{}) bool {
+		seq = seqFromTail(r.(*pong).Rest)
+		return true
+	})
 	t.send(toaddr, pingPacket, ping{
 		Version:    Version,
 		From:       t.ourEndpoint,
 		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       seqTail(t.local.Seq()),
 	})
-	return <-errc
+	err := <-errc
+	return seq, err
 }
 
 func (t *udp) waitping(from NodeID) error {
@@ -361,6 +444,34 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// requestENR sends an enrRequest to the given node and waits for the
+// response carrying its record.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	// The reply token is only known after sending, it's checked once the
+	// response arrived.
+	var resp *enrResponse
+	errc := t.pending(toid, enrResponsePacket, func(r interface{}) bool {
+		resp = r.(*enrResponse)
+		return true
+	})
+	hash, err := t.sendPacket(toaddr, enrRequestPacket, enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(resp.ReplyTok, hash) {
+		return nil, errUnsolicitedReply
+	}
+	if _, err := nodeFromRecord(&resp.Record, toid); err != nil {
+		return nil, err
+	}
+	return &resp.Record, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -512,9 +623,16 @@ func init() {
 }
 
 func (t *udp) send(toaddr *net.UDPAddr, ptype byte, req interface{}) error {
+	_, err := t.sendPacket(toaddr, ptype, req)
+	return err
+}
+
+// sendPacket sends a packet and returns its hash, which is echoed as the
+// reply token of responses.
+func (t *udp) sendPacket(toaddr *net.UDPAddr, ptype byte, req interface{}) ([]byte, error) {
 	packet, err := encodePacket(t.priv, ptype, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if logger.MlogEnabled() {
 		switch ptype {
@@ -548,7 +666,7 @@ func (t *udp) send(toaddr *net.UDPAddr, ptype byte, req interface{}) error {
 	if _, err = t.conn.WriteToUDP(packet, toaddr); err != nil {
 		glog.V(logger.Detail).Infoln("UDP send failed:", err)
 	}
-	return err
+	return packet[:macSize], err
 }
 
 func encodePacket(priv *ecdsa.PrivateKey, ptype byte, req interface{}) ([]byte, error) {
@@ -605,6 +723,14 @@ func (t *udp) readLoop() {
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
 	packet, fromID, hash, err := decodePacket(buf)
 	if err != nil {
+		if t.unhandled != nil {
+			// Not a v4 packet, leave it to the protocol sharing the socket
+			select {
+			case t.unhandled <- ReadPacket{Data: common.CopyBytes(buf), Addr: from}:
+			default:
+			}
+			return err
+		}
 		glog.V(logger.Debug).Infof("Bad packet from %v: %v\n", from, err)
 		return err
 	}
@@ -671,6 +797,10 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -687,6 +817,7 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 		To:         makeEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       seqTail(t.local.Seq()),
 	})
 	if !t.handleReply(fromID, pingPacket, req) {
 		// Note: we're ignoring the provided IP address right now
@@ -745,6 +876,28 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 	return nil
 }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.node(fromID) == nil {
+		// Only bonded nodes may request the record, see findnode.
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, enrResponse{
+		ReplyTok: mac,
+		Record:   *t.local.Record(),
+	})
+	return nil
+}
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
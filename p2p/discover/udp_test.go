@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/eth-classic/go-ethereum/common"
 	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 	"github.com/eth-classic/go-ethereum/rlp"
 )
 
@@ -120,7 +121,7 @@ func newUDPTest(t *testing.T) *udpTest {
 		remotekey:  newkey(),
 		remoteaddr: &net.UDPAddr{IP: net.IP{10, 2, 3, 4}, Port: 30303}, // must come from "reserved" address to be valid since findNode tests use reserved address enodes
 	}
-	test.table, test.udp, _ = newUDP(test.localkey, test.pipe, nil, "")
+	test.table, test.udp, _ = newUDP(test.pipe, Config{PrivateKey: test.localkey})
 	<-test.table.initDone
 	return test
 }
@@ -186,7 +187,7 @@ func TestUDP_pingTimeout(t *testing.T) {
 
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
 	toid := NodeID{1, 2, 3, 4}
-	if err := test.udp.ping(toid, toaddr); err != errTimeout {
+	if _, err := test.udp.ping(toid, toaddr); err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
 }
@@ -438,6 +439,83 @@ func TestUDP_successfulPing(t *testing.T) {
 	}
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// Records are only served to bonded nodes.
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	remote := NewNode(PubkeyID(&test.remotekey.PublicKey), test.remoteaddr.IP, uint16(test.remoteaddr.Port), 99)
+	test.table.db.updateNode(remote)
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	test.waitPacketOut(func(p *enrResponse) {
+		reqhash := test.sent[len(test.sent)-1][:macSize]
+		if !bytes.Equal(p.ReplyTok, reqhash) {
+			t.Errorf("got enrResponse.ReplyTok %x, want %x", p.ReplyTok, reqhash)
+		}
+		if p.Record.Seq() != test.udp.local.Seq() {
+			t.Errorf("wrong record seq %d, want %d", p.Record.Seq(), test.udp.local.Seq())
+		}
+		n, err := NewNodeFromRecord(&p.Record)
+		if err != nil {
+			t.Fatalf("invalid record: %v", err)
+		}
+		if n.ID != PubkeyID(&test.localkey.PublicKey) {
+			t.Errorf("record has wrong ID %x", n.ID[:8])
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	remoteID := PubkeyID(&test.remotekey.PublicKey)
+	remoteNode := NewLocalNode(test.remotekey)
+	remoteNode.Set(enr.UDP(test.remoteaddr.Port))
+
+	type result struct {
+		record *enr.Record
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		record, err := test.udp.requestENR(remoteID, test.remoteaddr)
+		done <- result{record, err}
+	}()
+
+	// Answer the request with the remote record.
+	dgram := test.pipe.waitPacketOut()
+	if p, _, _, err := decodePacket(dgram); err != nil {
+		t.Fatalf("sent packet decode error: %v", err)
+	} else if _, ok := p.(*enrRequest); !ok {
+		t.Fatalf("sent packet type mismatch, got %T, want *enrRequest", p)
+	}
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: dgram[:macSize], Record: *remoteNode.Record()})
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("requestENR error: %v", res.err)
+	}
+	if res.record.Seq() != remoteNode.Seq() {
+		t.Errorf("wrong record seq %d, want %d", res.record.Seq(), remoteNode.Seq())
+	}
+}
+
+func TestUDP_pingAnnouncesSeq(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	go test.packetIn(nil, pingPacket, &ping{From: testRemote, To: testLocalAnnounced, Version: Version, Expiration: futureExp})
+	test.waitPacketOut(func(p *pong) {
+		if seq := seqFromTail(p.Rest); seq != test.udp.local.Seq() {
+			t.Errorf("pong announces seq %d, want %d", seq, test.udp.local.Seq())
+		}
+	})
+}
+
 var testPackets = []struct {
 	input      string
 	wantPacket interface{}
@@ -0,0 +1,451 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/p2p/distip"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+	"github.com/eth-classic/go-ethereum/p2p/nat"
+)
+
+const (
+	v5RespTimeout      = time.Second // covers the handshake round trips
+	v5MaxNodesResponse = 16          // records served in reply to one FINDNODE
+	v5NodesPerMessage  = 3           // records per NODES message, keeps packets below 1280 bytes
+)
+
+var errV5BadResponse = errors.New("invalid response")
+
+// udpV5 implements the discovery v5 protocol. It serves the same Table
+// interface as the v4 implementation, so the node table logic is shared.
+type udpV5 struct {
+	conn  UDPConn
+	priv  *ecdsa.PrivateKey
+	local *LocalNode
+
+	mu           sync.Mutex
+	codec        *v5Codec
+	calls        map[string]*v5Call // active calls by request ID
+	callsByNonce map[[v5NonceSize]byte]*v5Call
+
+	closing chan struct{}
+
+	*Table
+}
+
+// v5Call is a request awaiting its response.
+type v5Call struct {
+	id    NodeID
+	addr  *net.UDPAddr
+	msg   v5Message
+	nonce [v5NonceSize]byte
+
+	handshake bool // whether a challenge was answered already
+	responses chan v5Message
+}
+
+// ListenV5 starts the discovery v5 protocol on the given connection. The
+// connection may be shared with the v4 protocol, see Config.Unhandled. The
+// v5 node table is always kept in memory.
+func ListenV5(c UDPConn, cfg Config) (*Table, error) {
+	t := &udpV5{
+		conn:         c,
+		priv:         cfg.PrivateKey,
+		local:        cfg.LocalNode,
+		calls:        make(map[string]*v5Call),
+		callsByNonce: make(map[[v5NonceSize]byte]*v5Call),
+		closing:      make(chan struct{}),
+	}
+	realaddr := c.LocalAddr().(*net.UDPAddr)
+	if t.local == nil {
+		t.local = NewLocalNode(cfg.PrivateKey)
+	}
+	if cfg.NAT != nil {
+		if !realaddr.IP.IsLoopback() {
+			go nat.Map(cfg.NAT, t.closing, "udp", realaddr.Port, realaddr.Port, "ethereum discovery v5")
+		}
+		if ext, err := cfg.NAT.ExternalIP(); err == nil {
+			realaddr = &net.UDPAddr{IP: ext, Port: realaddr.Port}
+		}
+		t.local.SetEndpoint(realaddr.IP, uint16(realaddr.Port), uint16(realaddr.Port))
+	} else if !t.local.hasEndpoint() {
+		t.local.SetEndpoint(realaddr.IP, uint16(realaddr.Port), uint16(realaddr.Port))
+	}
+	t.codec = newV5Codec(t.priv, t.local)
+
+	tab, err := newTable(t, t.local.ID(), realaddr, "")
+	if err != nil {
+		return nil, err
+	}
+	tab.local = t.local
+	t.Table = tab
+
+	go t.readLoop()
+	glog.V(logger.Info).Infoln("Discovery v5 listening,", t.local.Record())
+	return tab, nil
+}
+
+func (t *udpV5) close() {
+	close(t.closing)
+	t.conn.Close()
+}
+
+// ping sends a PING and returns the record sequence number of the PONG.
+func (t *udpV5) ping(toid NodeID, toaddr *net.UDPAddr) (uint64, error) {
+	var seq uint64
+	err := t.call(toid, toaddr, &v5Ping{ENRSeq: t.local.Seq()}, func(resp v5Message) (bool, error) {
+		pong, ok := resp.(*v5Pong)
+		if !ok {
+			return false, errV5BadResponse
+		}
+		seq = pong.ENRSeq
+		return true, nil
+	})
+	return seq, err
+}
+
+// waitping is a no-op in v5. Remote nodes authenticate us in the handshake,
+// there is no need to wait for their ping.
+func (t *udpV5) waitping(NodeID) error {
+	return nil
+}
+
+// findnode asks the given node for nodes close to the target. The target
+// itself can't be sent in v5, the log distances around it are requested
+// instead.
+func (t *udpV5) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
+	d := uint(logdist(v5NodeID(target), v5NodeID(toid)))
+	dists := []uint{d}
+	if d < uint(hashBits) {
+		dists = append(dists, d+1)
+	}
+	if d > 1 {
+		dists = append(dists, d-1)
+	}
+	nodes, err := t.findnodeDistances(toid, toaddr, dists)
+	if err != nil {
+		return nil, err
+	}
+	// Like in v4, nodes on reserved networks are only accepted from nodes
+	// on such networks.
+	var result []*Node
+	for _, n := range nodes {
+		if !isReserved(toaddr.IP) && isReserved(n.IP) {
+			continue
+		}
+		if n.validateComplete() != nil {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// requestENR fetches the current record of the node.
+func (t *udpV5) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	nodes, err := t.findnodeDistances(toid, toaddr, []uint{0})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 || nodes[0].ID != toid {
+		return nil, errV5BadResponse
+	}
+	return nodes[0].record, nil
+}
+
+func (t *udpV5) findnodeDistances(toid NodeID, toaddr *net.UDPAddr, dists []uint) ([]*Node, error) {
+	var (
+		nodes    []*Node
+		seen     = make(map[NodeID]bool)
+		received int
+		total    = -1
+		toHash   = v5NodeID(toid)
+	)
+	err := t.call(toid, toaddr, &v5Findnode{Distances: dists}, func(resp v5Message) (bool, error) {
+		msg, ok := resp.(*v5Nodes)
+		if !ok {
+			return false, errV5BadResponse
+		}
+		if total < 0 {
+			total = int(msg.Total)
+			if total > v5MaxNodesResponse {
+				total = v5MaxNodesResponse
+			}
+		}
+		received++
+		for _, r := range msg.Nodes {
+			n, err := nodeFromRecord(r, NodeID{})
+			if err != nil || seen[n.ID] {
+				continue
+			}
+			if !containsDist(dists, uint(logdist(toHash, n.sha))) {
+				continue
+			}
+			if n.IP != nil && distip.CheckRelayIP(toaddr.IP, n.IP) != nil {
+				continue
+			}
+			seen[n.ID] = true
+			nodes = append(nodes, n)
+		}
+		return received >= total, nil
+	})
+	return nodes, err
+}
+
+func containsDist(dists []uint, d uint) bool {
+	for _, x := range dists {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+// call sends a request and delivers responses to the callback until it
+// reports completion or the request times out.
+func (t *udpV5) call(toid NodeID, toaddr *net.UDPAddr, msg v5Message, callback func(v5Message) (bool, error)) error {
+	reqid := make([]byte, 8)
+	crand.Read(reqid)
+	msg.setRequestID(reqid)
+	c := &v5Call{id: toid, addr: toaddr, msg: msg, responses: make(chan v5Message, 4)}
+
+	t.mu.Lock()
+	t.calls[string(reqid)] = c
+	err := t.sendCall(c)
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.calls, string(reqid))
+		delete(t.callsByNonce, c.nonce)
+		t.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(v5RespTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case resp := <-c.responses:
+			done, err := callback(resp)
+			if err != nil || done {
+				return err
+			}
+			timeout.Reset(v5RespTimeout)
+		case <-timeout.C:
+			return errTimeout
+		case <-t.closing:
+			return errClosed
+		}
+	}
+}
+
+// sendCall writes the request packet. The caller must hold t.mu.
+func (t *udpV5) sendCall(c *v5Call) error {
+	packet, nonce, err := t.codec.encode(c.id, c.addr.String(), c.msg)
+	if err != nil {
+		return err
+	}
+	delete(t.callsByNonce, c.nonce)
+	c.nonce = nonce
+	t.callsByNonce[nonce] = c
+	_, err = t.conn.WriteToUDP(packet, c.addr)
+	return err
+}
+
+// send writes a message to a node we have a session with. The caller must
+// hold t.mu.
+func (t *udpV5) send(toid NodeID, toaddr *net.UDPAddr, msg v5Message) {
+	packet, _, err := t.codec.encode(toid, toaddr.String(), msg)
+	if err != nil {
+		glog.V(logger.Debug).Infof("Can't encode v5 %T for %v: %v", msg, toaddr, err)
+		return
+	}
+	t.conn.WriteToUDP(packet, toaddr)
+}
+
+// readLoop runs in its own goroutine. it handles incoming UDP packets.
+func (t *udpV5) readLoop() {
+	buf := make([]byte, v5MaxPacketSize)
+	for {
+		nbytes, from, err := t.conn.ReadFromUDP(buf)
+		if isTemporaryError(err) {
+			glog.V(logger.Debug).Infof("Temporary read error: %v", err)
+			continue
+		} else if err != nil {
+			glog.V(logger.Debug).Infof("Read error: %v", err)
+			return
+		}
+		t.handlePacket(from, buf[:nbytes])
+	}
+}
+
+func (t *udpV5) handlePacket(from *net.UDPAddr, buf []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	addr := from.String()
+	p, err := t.codec.decode(buf, addr)
+	switch {
+	case err == errV5NoSession:
+		// Challenge the sender, it will retry with a handshake.
+		packet, err := t.codec.encodeWhoareyou(p.srcID, addr, p.nonce, t.knownNode(p.srcID))
+		if err == nil {
+			t.conn.WriteToUDP(packet, from)
+		}
+		return err
+	case err != nil:
+		glog.V(logger.Debug).Infof("Bad discv5 packet from %v: %v", from, err)
+		return err
+	case p.challenge != nil:
+		return t.handleWhoareyou(p, from)
+	}
+	if glog.V(logger.Detail) {
+		glog.Infof("<<< %v %T", from, p.msg)
+	}
+	if p.handshake {
+		// The node proved its identity, try adding it to the table.
+		go t.bond(true, p.node.ID, from, p.node.TCP)
+	}
+	t.handleMessage(p.node, from, p.msg)
+	return nil
+}
+
+// knownNode returns the table entry with the given discovery v5 ID if its
+// record is known, sparing the node from sending it in the handshake.
+func (t *udpV5) knownNode(id common.Hash) *Node {
+	t.Table.mutex.Lock()
+	defer t.Table.mutex.Unlock()
+
+	for _, b := range t.buckets {
+		for _, n := range b.entries {
+			if n.sha == id && n.record != nil {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// handleWhoareyou answers a challenge sent in reply to one of our requests
+// by resending the request in a handshake packet. The caller must hold t.mu.
+func (t *udpV5) handleWhoareyou(p *v5Packet, from *net.UDPAddr) error {
+	c := t.callsByNonce[p.nonce]
+	if c == nil || c.handshake {
+		return errUnsolicitedReply
+	}
+	c.handshake = true
+	n := NewNode(c.id, c.addr.IP, uint16(c.addr.Port), 0)
+	packet, nonce, err := t.codec.encodeHandshake(n, from.String(), p.challenge, c.msg)
+	if err != nil {
+		glog.V(logger.Debug).Infof("Can't create handshake for %v: %v", from, err)
+		return err
+	}
+	delete(t.callsByNonce, c.nonce)
+	c.nonce = nonce
+	t.callsByNonce[nonce] = c
+	_, err = t.conn.WriteToUDP(packet, from)
+	return err
+}
+
+// handleMessage processes a decrypted message. The caller must hold t.mu.
+func (t *udpV5) handleMessage(n *Node, from *net.UDPAddr, msg v5Message) {
+	switch msg := msg.(type) {
+	case *v5Ping:
+		t.send(n.ID, from, &v5Pong{
+			ReqID:  msg.ReqID,
+			ENRSeq: t.local.Seq(),
+			ToIP:   from.IP,
+			ToPort: uint16(from.Port),
+		})
+	case *v5Findnode:
+		t.handleFindnode(n, from, msg)
+	case *v5TalkRequest:
+		// No talk protocols are supported, reply with an empty response.
+		t.send(n.ID, from, &v5TalkResponse{ReqID: msg.ReqID})
+	default:
+		c := t.calls[string(msg.requestID())]
+		if c == nil || c.id != n.ID {
+			glog.V(logger.Debug).Infof("Unsolicited discv5 %T from %v", msg, from)
+			return
+		}
+		select {
+		case c.responses <- msg:
+		default:
+		}
+	}
+}
+
+func (t *udpV5) handleFindnode(n *Node, from *net.UDPAddr, req *v5Findnode) {
+	var records []*enr.Record
+	for _, d := range req.Distances {
+		if len(records) >= v5MaxNodesResponse {
+			break
+		}
+		if d == 0 {
+			records = append(records, t.local.Record())
+			continue
+		}
+		for _, rn := range t.nodesAtDistance(d, v5MaxNodesResponse-len(records)) {
+			records = append(records, rn.record)
+		}
+	}
+	// Send the records in chunks, announcing the number of messages.
+	total := (len(records) + v5NodesPerMessage - 1) / v5NodesPerMessage
+	if total == 0 {
+		total = 1
+	}
+	for i := 0; i < total; i++ {
+		resp := &v5Nodes{ReqID: req.ReqID, Total: uint8(total)}
+		for j := i * v5NodesPerMessage; j < len(records) && j < (i+1)*v5NodesPerMessage; j++ {
+			resp.Nodes = append(resp.Nodes, records[j])
+		}
+		t.send(n.ID, from, resp)
+	}
+}
+
+// nodesAtDistance returns up to max table entries with records whose v5
+// log distance to the local node is d.
+func (t *udpV5) nodesAtDistance(d uint, max int) []*Node {
+	t.Table.mutex.Lock()
+	defer t.Table.mutex.Unlock()
+
+	self := v5NodeID(t.local.ID())
+	var nodes []*Node
+	for _, b := range t.Table.buckets {
+		for _, n := range b.entries {
+			if len(nodes) >= max {
+				return nodes
+			}
+			if n.record != nil && uint(logdist(self, n.sha)) == d {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
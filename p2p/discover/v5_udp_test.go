@@ -0,0 +1,139 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+)
+
+func startV5Test(t *testing.T) *udpV5 {
+	key, _ := crypto.GenerateKey()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := ListenV5(conn, Config{PrivateKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tab.net.(*udpV5)
+}
+
+func v5Addr(t *udpV5) *net.UDPAddr {
+	return t.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Tests that a handshake is performed on the first request and that the
+// session is reused afterwards.
+func TestUDPv5_pingHandshake(t *testing.T) {
+	a, b := startV5Test(t), startV5Test(t)
+	defer a.Close()
+	defer b.Close()
+
+	for i := 0; i < 2; i++ {
+		seq, err := a.ping(b.local.ID(), v5Addr(b))
+		if err != nil {
+			t.Fatalf("ping %d failed: %v", i, err)
+		}
+		if seq != b.local.Seq() {
+			t.Errorf("ping %d: wrong seq %d, want %d", i, seq, b.local.Seq())
+		}
+	}
+	// The handshake teaches b the record of a.
+	b.mu.Lock()
+	s := b.codec.sessions[v5SessionID{v5NodeID(a.local.ID()), v5Addr(a).String()}]
+	b.mu.Unlock()
+	if s == nil || s.node.ID != a.local.ID() || s.node.record == nil {
+		t.Fatalf("b has no session with a")
+	}
+}
+
+// Tests that requestENR returns the current record of the remote node.
+func TestUDPv5_requestENR(t *testing.T) {
+	a, b := startV5Test(t), startV5Test(t)
+	defer a.Close()
+	defer b.Close()
+
+	b.local.Set(enr.WithEntry("test", uint(7)))
+	r, err := a.requestENR(b.local.ID(), v5Addr(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Seq() != b.local.Seq() || !r.Equal(b.local.Record()) {
+		t.Errorf("wrong record %v, want %v", r, b.local.Record())
+	}
+	var v uint
+	if err := r.Load(enr.WithEntry("test", &v)); err != nil || v != 7 {
+		t.Errorf("test entry missing: %v %v", v, err)
+	}
+}
+
+// Tests that FINDNODE returns table entries at the requested distance.
+func TestUDPv5_findnode(t *testing.T) {
+	a, b := startV5Test(t), startV5Test(t)
+	defer a.Close()
+	defer b.Close()
+
+	// Fill b's table with nodes that have records.
+	var want []*Node
+	for i := 0; i < 5; i++ {
+		key, _ := crypto.GenerateKey()
+		ln := NewLocalNode(key)
+		ln.SetEndpoint(net.IP{127, 0, 0, 1}, uint16(30000+i), uint16(30000+i))
+		n := ln.Node()
+		b.Table.add(n)
+		want = append(want, n)
+	}
+	bHash := v5NodeID(b.local.ID())
+	for _, n := range want {
+		d := uint(logdist(bHash, n.sha))
+		nodes, err := a.findnodeDistances(b.local.ID(), v5Addr(b), []uint{d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, rn := range nodes {
+			if uint(logdist(bHash, rn.sha)) != d {
+				t.Errorf("node %x at wrong distance", rn.ID[:8])
+			}
+			if rn.ID == n.ID {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("node %x at distance %d not returned", n.ID[:8], d)
+		}
+	}
+}
+
+// Tests that packets of other protocols and garbage are rejected.
+func TestUDPv5_badPackets(t *testing.T) {
+	a := startV5Test(t)
+	defer a.Close()
+
+	from := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 1}
+	if err := a.handlePacket(from, make([]byte, 10)); err != errPacketTooSmall {
+		t.Errorf("wrong error for short packet: %v", err)
+	}
+	if err := a.handlePacket(from, make([]byte, 100)); err != errV5BadHeader {
+		t.Errorf("wrong error for garbage: %v", err)
+	}
+}
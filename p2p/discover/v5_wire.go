@@ -0,0 +1,597 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/crypto/secp256k1"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+	"github.com/eth-classic/go-ethereum/rlp"
+	"golang.org/x/crypto/hkdf"
+)
+
+// This file implements the packet encoding of discovery v5 (version 5.1 of
+// the wire protocol). Packets start with a random masking IV, followed by a
+// header which is masked with the destination node ID, followed by the
+// AES-GCM encrypted message. Session keys are agreed on in a handshake
+// initiated by a WHOAREYOU challenge.
+
+const (
+	v5ProtocolID = "discv5"
+	v5Version    = 1
+
+	v5FlagMessage   = 0
+	v5FlagWhoareyou = 1
+	v5FlagHandshake = 2
+
+	v5MaskingIVSize    = 16
+	v5NonceSize        = 12
+	v5IDNonceSize      = 16
+	v5StaticHeaderSize = len(v5ProtocolID) + 2 + 1 + v5NonceSize + 2
+	v5MinPacketSize    = v5MaskingIVSize + v5StaticHeaderSize + 24
+	v5MaxPacketSize    = 1280
+	v5GCMTagSize       = 16
+
+	v5WhoareyouAuthSize = v5IDNonceSize + 8
+	v5HandshakeAuthSize = 32 + 1 + 1 // src-id, sig-size, eph-key-size
+
+	v5RandomPacketSize = 20 // size of the random message starting a handshake
+	v5MaxSessions      = 1024
+	v5MaxChallenges    = 512
+	v5ChallengeTimeout = 2 * time.Second
+)
+
+// Message types of discovery v5.
+const (
+	v5PingMsg byte = iota + 1
+	v5PongMsg
+	v5FindnodeMsg
+	v5NodesMsg
+	v5TalkRequestMsg
+	v5TalkResponseMsg
+)
+
+var (
+	errV5NoSession     = errors.New("no session")
+	errV5BadHeader     = errors.New("invalid packet header")
+	errV5BadVersion    = errors.New("unsupported discv5 version")
+	errV5NoChallenge   = errors.New("handshake without challenge")
+	errV5BadSignature  = errors.New("invalid id signature")
+	errV5UnknownKey    = errors.New("handshake from node without known key")
+	errV5UnknownMsg    = errors.New("unknown message type")
+	errV5MessageFailed = errors.New("can't decrypt message")
+)
+
+// Discovery v5 messages.
+type (
+	v5Ping struct {
+		ReqID  []byte
+		ENRSeq uint64
+	}
+
+	v5Pong struct {
+		ReqID  []byte
+		ENRSeq uint64
+		ToIP   net.IP // the recipient's address as seen by the sender
+		ToPort uint16
+	}
+
+	v5Findnode struct {
+		ReqID     []byte
+		Distances []uint
+	}
+
+	v5Nodes struct {
+		ReqID []byte
+		Total uint8 // number of NODES messages in the response
+		Nodes []*enr.Record
+	}
+
+	v5TalkRequest struct {
+		ReqID    []byte
+		Protocol string
+		Message  []byte
+	}
+
+	v5TalkResponse struct {
+		ReqID   []byte
+		Message []byte
+	}
+)
+
+// v5Message is implemented by all discovery v5 messages.
+type v5Message interface {
+	kind() byte
+	requestID() []byte
+	setRequestID([]byte)
+}
+
+func (p *v5Ping) kind() byte                     { return v5PingMsg }
+func (p *v5Ping) requestID() []byte              { return p.ReqID }
+func (p *v5Ping) setRequestID(id []byte)         { p.ReqID = id }
+func (p *v5Pong) kind() byte                     { return v5PongMsg }
+func (p *v5Pong) requestID() []byte              { return p.ReqID }
+func (p *v5Pong) setRequestID(id []byte)         { p.ReqID = id }
+func (p *v5Findnode) kind() byte                 { return v5FindnodeMsg }
+func (p *v5Findnode) requestID() []byte          { return p.ReqID }
+func (p *v5Findnode) setRequestID(id []byte)     { p.ReqID = id }
+func (p *v5Nodes) kind() byte                    { return v5NodesMsg }
+func (p *v5Nodes) requestID() []byte             { return p.ReqID }
+func (p *v5Nodes) setRequestID(id []byte)        { p.ReqID = id }
+func (p *v5TalkRequest) kind() byte              { return v5TalkRequestMsg }
+func (p *v5TalkRequest) requestID() []byte       { return p.ReqID }
+func (p *v5TalkRequest) setRequestID(id []byte)  { p.ReqID = id }
+func (p *v5TalkResponse) kind() byte             { return v5TalkResponseMsg }
+func (p *v5TalkResponse) requestID() []byte      { return p.ReqID }
+func (p *v5TalkResponse) setRequestID(id []byte) { p.ReqID = id }
+
+// v5NodeID returns the discovery v5 identifier of a node, which is the
+// keccak256 hash of its public key.
+func v5NodeID(id NodeID) common.Hash {
+	return crypto.Keccak256Hash(id[:])
+}
+
+// v5Challenge is a WHOAREYOU challenge, either sent by us and awaiting the
+// handshake, or received in reply to one of our packets.
+type v5Challenge struct {
+	data    []byte // masking-iv || static-header || authdata, signed in the handshake
+	nonce   [v5NonceSize]byte
+	idNonce [v5IDNonceSize]byte
+	seq     uint64 // record sequence number known by the challenger
+	node    *Node  // the challenged node if already known, set by the challenger
+	sent    time.Time
+}
+
+// v5Session holds the keys of an established session.
+type v5Session struct {
+	writeKey []byte
+	readKey  []byte
+	node     *Node // the remote node, including its record
+}
+
+type v5SessionID struct {
+	id   common.Hash
+	addr string
+}
+
+// v5Packet is a decoded packet.
+type v5Packet struct {
+	flag      byte
+	srcID     common.Hash // sender, not set for WHOAREYOU
+	nonce     [v5NonceSize]byte
+	node      *Node        // sender, set if the packet came through a session
+	handshake bool         // whether the packet established a new session
+	msg       v5Message    // decrypted message
+	challenge *v5Challenge // received WHOAREYOU
+}
+
+// v5Codec encodes and decodes discovery v5 packets. It is not safe for
+// concurrent use.
+type v5Codec struct {
+	priv       *ecdsa.PrivateKey
+	localID    common.Hash
+	local      *LocalNode
+	sessions   map[v5SessionID]*v5Session
+	challenges map[v5SessionID]*v5Challenge // challenges we sent
+}
+
+func newV5Codec(priv *ecdsa.PrivateKey, local *LocalNode) *v5Codec {
+	return &v5Codec{
+		priv:       priv,
+		localID:    v5NodeID(local.ID()),
+		local:      local,
+		sessions:   make(map[v5SessionID]*v5Session),
+		challenges: make(map[v5SessionID]*v5Challenge),
+	}
+}
+
+func (c *v5Codec) storeSession(sid v5SessionID, s *v5Session) {
+	if len(c.sessions) >= v5MaxSessions {
+		for k := range c.sessions {
+			delete(c.sessions, k)
+			break
+		}
+	}
+	c.sessions[sid] = s
+}
+
+// encode packs msg into an ordinary message packet for the given node. If
+// there is no session with the node, a packet with random content is
+// returned instead, causing the remote node to send a challenge.
+func (c *v5Codec) encode(toID NodeID, addr string, msg v5Message) ([]byte, [v5NonceSize]byte, error) {
+	var (
+		toHash = v5NodeID(toID)
+		nonce  [v5NonceSize]byte
+	)
+	if _, err := crand.Read(nonce[:]); err != nil {
+		return nil, nonce, err
+	}
+	head := c.makeHeader(v5FlagMessage, nonce, c.localID[:])
+	s := c.sessions[v5SessionID{toHash, addr}]
+	if s == nil {
+		msgdata := make([]byte, v5RandomPacketSize)
+		crand.Read(msgdata)
+		return c.finish(toHash, head, msgdata, nil), nonce, nil
+	}
+	packet, err := c.seal(toHash, head, s.writeKey, nonce, msg)
+	return packet, nonce, err
+}
+
+// encodeHandshake answers the given challenge with a handshake packet
+// carrying msg. This establishes a new session with the node.
+func (c *v5Codec) encodeHandshake(to *Node, addr string, challenge *v5Challenge, msg v5Message) ([]byte, [v5NonceSize]byte, error) {
+	var (
+		toHash = v5NodeID(to.ID)
+		nonce  [v5NonceSize]byte
+	)
+	if _, err := crand.Read(nonce[:]); err != nil {
+		return nil, nonce, err
+	}
+	toPub, err := to.ID.Pubkey()
+	if err != nil {
+		return nil, nonce, err
+	}
+	ephkey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nonce, err
+	}
+	ephpub := crypto.CompressPubkey(&ephkey.PublicKey)
+	sig, err := v5IDSignature(c.priv, challenge.data, ephpub, toHash)
+	if err != nil {
+		return nil, nonce, err
+	}
+	var record []byte
+	if challenge.seq < c.local.Seq() {
+		if record, err = rlp.EncodeToBytes(c.local.Record()); err != nil {
+			return nil, nonce, err
+		}
+	}
+	auth := make([]byte, 0, v5HandshakeAuthSize+len(sig)+len(ephpub)+len(record))
+	auth = append(auth, c.localID[:]...)
+	auth = append(auth, byte(len(sig)), byte(len(ephpub)))
+	auth = append(auth, sig...)
+	auth = append(auth, ephpub...)
+	auth = append(auth, record...)
+
+	initKey, recipKey := v5DeriveKeys(ecdh(ephkey, toPub), c.localID, toHash, challenge.data)
+	c.storeSession(v5SessionID{toHash, addr}, &v5Session{writeKey: initKey, readKey: recipKey, node: to})
+
+	head := c.makeHeader(v5FlagHandshake, nonce, auth)
+	packet, err := c.seal(toHash, head, initKey, nonce, msg)
+	return packet, nonce, err
+}
+
+// encodeWhoareyou creates a challenge in reply to the packet with the given
+// nonce, which couldn't be decrypted. The record of the remote node is
+// requested in the handshake unless known is given.
+func (c *v5Codec) encodeWhoareyou(toHash common.Hash, addr string, nonce [v5NonceSize]byte, known *Node) ([]byte, error) {
+	ch := &v5Challenge{nonce: nonce, sent: time.Now()}
+	if _, err := crand.Read(ch.idNonce[:]); err != nil {
+		return nil, err
+	}
+	// A zero sequence number makes the remote node include its record in
+	// the handshake. This way we always learn the key and endpoint of nodes
+	// we have a session with.
+	if known != nil && known.record != nil {
+		ch.node, ch.seq = known, known.record.Seq()
+	}
+	auth := make([]byte, v5WhoareyouAuthSize)
+	copy(auth, ch.idNonce[:])
+	binary.BigEndian.PutUint64(auth[v5IDNonceSize:], ch.seq)
+
+	head := c.makeHeader(v5FlagWhoareyou, nonce, auth)
+	packet := c.finish(toHash, head, nil, nil)
+	ch.data = make([]byte, len(head)+v5MaskingIVSize)
+	copy(ch.data, packet[:v5MaskingIVSize])
+	copy(ch.data[v5MaskingIVSize:], head)
+
+	c.expireChallenges()
+	if len(c.challenges) >= v5MaxChallenges {
+		return nil, errors.New("too many pending challenges")
+	}
+	c.challenges[v5SessionID{toHash, addr}] = ch
+	return packet, nil
+}
+
+func (c *v5Codec) expireChallenges() {
+	for k, ch := range c.challenges {
+		if time.Since(ch.sent) > v5ChallengeTimeout {
+			delete(c.challenges, k)
+		}
+	}
+}
+
+// makeHeader assembles the unmasked header.
+func (c *v5Codec) makeHeader(flag byte, nonce [v5NonceSize]byte, auth []byte) []byte {
+	head := make([]byte, 0, v5StaticHeaderSize+len(auth))
+	head = append(head, v5ProtocolID...)
+	head = append(head, 0, v5Version, flag)
+	head = append(head, nonce[:]...)
+	head = append(head, byte(len(auth)>>8), byte(len(auth)))
+	return append(head, auth...)
+}
+
+// seal encrypts msg and assembles the packet.
+func (c *v5Codec) seal(toHash common.Hash, head, key []byte, nonce [v5NonceSize]byte, msg v5Message) ([]byte, error) {
+	pt, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	pt = append([]byte{msg.kind()}, pt...)
+	var iv [v5MaskingIVSize]byte
+	if _, err := crand.Read(iv[:]); err != nil {
+		return nil, err
+	}
+	ad := append(iv[:], head...)
+	ct, err := v5Encrypt(key, nonce[:], pt, ad)
+	if err != nil {
+		return nil, err
+	}
+	return c.finish(toHash, head, ct, iv[:]), nil
+}
+
+// finish masks the header and appends the message data. A random masking IV
+// is created if iv is nil.
+func (c *v5Codec) finish(toHash common.Hash, head, msgdata, iv []byte) []byte {
+	packet := make([]byte, v5MaskingIVSize+len(head)+len(msgdata))
+	if iv == nil {
+		crand.Read(packet[:v5MaskingIVSize])
+	} else {
+		copy(packet, iv)
+	}
+	mask(toHash, packet[:v5MaskingIVSize]).XORKeyStream(packet[v5MaskingIVSize:], head)
+	copy(packet[v5MaskingIVSize+len(head):], msgdata)
+	return packet
+}
+
+// decode unmasks and decrypts a packet sent to us. Ordinary packets which
+// can't be decrypted return errV5NoSession along with the partially decoded
+// packet, the caller should answer them with a challenge.
+func (c *v5Codec) decode(input []byte, addr string) (*v5Packet, error) {
+	if len(input) < v5MinPacketSize {
+		return nil, errPacketTooSmall
+	}
+	iv := input[:v5MaskingIVSize]
+	stream := mask(c.localID, iv)
+
+	head := make([]byte, v5StaticHeaderSize, len(input)-v5MaskingIVSize)
+	stream.XORKeyStream(head, input[v5MaskingIVSize:v5MaskingIVSize+v5StaticHeaderSize])
+	if !bytes.Equal(head[:len(v5ProtocolID)], []byte(v5ProtocolID)) {
+		return nil, errV5BadHeader
+	}
+	if v := binary.BigEndian.Uint16(head[len(v5ProtocolID):]); v != v5Version {
+		return nil, errV5BadVersion
+	}
+	p := &v5Packet{flag: head[len(v5ProtocolID)+2]}
+	copy(p.nonce[:], head[len(v5ProtocolID)+3:])
+	authsize := int(binary.BigEndian.Uint16(head[v5StaticHeaderSize-2:]))
+	authend := v5MaskingIVSize + v5StaticHeaderSize + authsize
+	if authend > len(input) {
+		return nil, errV5BadHeader
+	}
+	auth := make([]byte, authsize)
+	stream.XORKeyStream(auth, input[v5MaskingIVSize+v5StaticHeaderSize:authend])
+	head = append(head, auth...)
+	ad := append(common.CopyBytes(iv), head...)
+	msgdata := input[authend:]
+
+	switch p.flag {
+	case v5FlagWhoareyou:
+		if authsize != v5WhoareyouAuthSize || len(msgdata) != 0 {
+			return nil, errV5BadHeader
+		}
+		ch := &v5Challenge{nonce: p.nonce, data: ad}
+		copy(ch.idNonce[:], auth)
+		ch.seq = binary.BigEndian.Uint64(auth[v5IDNonceSize:])
+		p.challenge = ch
+		return p, nil
+
+	case v5FlagMessage:
+		if authsize != len(p.srcID) {
+			return nil, errV5BadHeader
+		}
+		copy(p.srcID[:], auth)
+		s := c.sessions[v5SessionID{p.srcID, addr}]
+		if s == nil {
+			return p, errV5NoSession
+		}
+		msg, err := v5DecodeMessage(s.readKey, p.nonce[:], msgdata, ad)
+		if err != nil {
+			// The remote node may have dropped the session.
+			return p, errV5NoSession
+		}
+		p.node, p.msg = s.node, msg
+		return p, nil
+
+	case v5FlagHandshake:
+		return p, c.decodeHandshake(p, auth, msgdata, ad, addr)
+
+	default:
+		return nil, errV5BadHeader
+	}
+}
+
+func (c *v5Codec) decodeHandshake(p *v5Packet, auth, msgdata, ad []byte, addr string) error {
+	if len(auth) < v5HandshakeAuthSize {
+		return errV5BadHeader
+	}
+	copy(p.srcID[:], auth)
+	sigsize, keysize := int(auth[32]), int(auth[33])
+	if len(auth) < v5HandshakeAuthSize+sigsize+keysize {
+		return errV5BadHeader
+	}
+	sig := auth[v5HandshakeAuthSize : v5HandshakeAuthSize+sigsize]
+	ephpub := auth[v5HandshakeAuthSize+sigsize : v5HandshakeAuthSize+sigsize+keysize]
+	record := auth[v5HandshakeAuthSize+sigsize+keysize:]
+
+	sid := v5SessionID{p.srcID, addr}
+	ch := c.challenges[sid]
+	if ch == nil || time.Since(ch.sent) > v5ChallengeTimeout {
+		return errV5NoChallenge
+	}
+	// The record may only be omitted if we already know the node.
+	n := ch.node
+	if len(record) > 0 {
+		var r enr.Record
+		if err := rlp.DecodeBytes(record, &r); err != nil {
+			return fmt.Errorf("invalid record in handshake: %v", err)
+		}
+		var err error
+		if n, err = nodeFromRecord(&r, NodeID{}); err != nil {
+			return err
+		}
+	} else if n == nil {
+		return errV5UnknownKey
+	}
+	if n.sha != p.srcID {
+		return errRecordMismatch
+	}
+	pub, _ := n.ID.Pubkey()
+	if !v5VerifyIDSignature(pub, sig, ch.data, ephpub, c.localID) {
+		return errV5BadSignature
+	}
+	eph, err := crypto.DecompressPubkey(ephpub)
+	if err != nil {
+		return err
+	}
+	initKey, recipKey := v5DeriveKeys(ecdh(c.priv, eph), p.srcID, c.localID, ch.data)
+	msg, err := v5DecodeMessage(initKey, p.nonce[:], msgdata, ad)
+	if err != nil {
+		return err
+	}
+	delete(c.challenges, sid)
+	c.storeSession(sid, &v5Session{writeKey: recipKey, readKey: initKey, node: n})
+	p.node, p.msg, p.handshake = n, msg, true
+	return nil
+}
+
+// mask returns the stream cipher masking headers sent to the given node.
+func mask(destID common.Hash, iv []byte) cipher.Stream {
+	block, err := aes.NewCipher(destID[:16])
+	if err != nil {
+		panic("can't create block cipher")
+	}
+	return cipher.NewCTR(block, iv)
+}
+
+func v5Encrypt(key, nonce, pt, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, pt, ad), nil
+}
+
+func v5DecodeMessage(key, nonce, ct, ad []byte) (v5Message, error) {
+	if len(ct) < v5GCMTagSize+1 {
+		return nil, errV5MessageFailed
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	pt, err := aead.Open(nil, nonce, ct, ad)
+	if err != nil {
+		return nil, errV5MessageFailed
+	}
+	var msg v5Message
+	switch pt[0] {
+	case v5PingMsg:
+		msg = new(v5Ping)
+	case v5PongMsg:
+		msg = new(v5Pong)
+	case v5FindnodeMsg:
+		msg = new(v5Findnode)
+	case v5NodesMsg:
+		msg = new(v5Nodes)
+	case v5TalkRequestMsg:
+		msg = new(v5TalkRequest)
+	case v5TalkResponseMsg:
+		msg = new(v5TalkResponse)
+	default:
+		return nil, errV5UnknownMsg
+	}
+	if err := rlp.DecodeBytes(pt[1:], msg); err != nil {
+		return nil, err
+	}
+	if len(msg.requestID()) > 8 {
+		return nil, errors.New("request id too long")
+	}
+	return msg, nil
+}
+
+// ecdh returns the compressed shared point of the key agreement.
+func ecdh(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) []byte {
+	x, y := secp256k1.S256().ScalarMult(pub.X, pub.Y, common.LeftPadBytes(priv.D.Bytes(), 32))
+	return crypto.CompressPubkey(&ecdsa.PublicKey{Curve: pub.Curve, X: x, Y: y})
+}
+
+// v5DeriveKeys creates the session keys of a handshake between the
+// initiator (A) and the recipient (B).
+func v5DeriveKeys(secret []byte, idA, idB common.Hash, challenge []byte) (initKey, recipKey []byte) {
+	info := make([]byte, 0, 26+2*len(idA))
+	info = append(info, "discovery v5 key agreement"...)
+	info = append(info, idA[:]...)
+	info = append(info, idB[:]...)
+	kdf := hkdf.New(sha256.New, secret, challenge, info)
+	keys := make([]byte, 32)
+	kdf.Read(keys)
+	return keys[:16], keys[16:]
+}
+
+func v5IDSignatureHash(challenge, ephpub []byte, destID common.Hash) []byte {
+	h := sha256.New()
+	h.Write([]byte("discovery v5 identity proof"))
+	h.Write(challenge)
+	h.Write(ephpub)
+	h.Write(destID[:])
+	return h.Sum(nil)
+}
+
+// v5IDSignature proves the ownership of the node key in a handshake.
+func v5IDSignature(priv *ecdsa.PrivateKey, challenge, ephpub []byte, destID common.Hash) ([]byte, error) {
+	sig, err := crypto.Sign(v5IDSignatureHash(challenge, ephpub, destID), priv)
+	if err != nil {
+		return nil, err
+	}
+	return sig[:64], nil
+}
+
+func v5VerifyIDSignature(pub *ecdsa.PublicKey, sig, challenge, ephpub []byte, destID common.Hash) bool {
+	return crypto.VerifySignature(crypto.FromECDSAPub(pub), v5IDSignatureHash(challenge, ephpub, destID), sig)
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+)
+
+// The test vectors below are taken from the discovery v5.1 wire specification.
+// All packets are sent by node A to node B.
+var (
+	v5TestKeyA = hexKey("eef77acb6c6a6eebc5b363a475ac583ec7eccdb42b6481424c60f59aa326547f")
+	v5TestKeyB = hexKey("66fb62bfbd66b9177a138c1e5cddbe4f7c30c343e94e68df8769459cb1cde628")
+
+	v5TestAddr      = "127.0.0.1:30303"
+	v5TestNonce     = [v5NonceSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	v5TestIDNonce   = [v5IDNonceSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	v5TestPingNonce = [v5NonceSize]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func hexKey(s string) *ecdsa.PrivateKey {
+	return crypto.ToECDSA(hexBytes(s))
+}
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// newV5TestCodec creates the codec of node B.
+func newV5TestCodec() *v5Codec {
+	return newV5Codec(v5TestKeyB, NewLocalNode(v5TestKeyB))
+}
+
+// Tests that the node IDs of the specification keys are derived correctly.
+func TestV5WireNodeIDs(t *testing.T) {
+	idA := v5NodeID(PubkeyID(&v5TestKeyA.PublicKey))
+	if want := common.HexToHash("0xaaaa8419e9f49d0083561b48287df592939a8d19947d8c0ef88f2a4856a69fbb"); idA != want {
+		t.Errorf("node A id mismatch: have %x, want %x", idA, want)
+	}
+	idB := v5NodeID(PubkeyID(&v5TestKeyB.PublicKey))
+	if want := common.HexToHash("0xbbbb9d047f0488c0b5a93c1c3f2d8bafc7c8ff337024a55434a0d0555de64db9"); idB != want {
+		t.Errorf("node B id mismatch: have %x, want %x", idB, want)
+	}
+}
+
+// Tests that an ordinary message packet is decrypted with the session keys.
+func TestV5WirePingVector(t *testing.T) {
+	packet := hexBytes(`
+		00000000000000000000000000000000088b3d4342774649325f313964a39e55
+		ea96c005ad52be8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d3
+		4c4f53245d08dab84102ed931f66d1492acb308fa1c6715b9d139b81acbdcc`)
+
+	codec := newV5TestCodec()
+	idA := v5NodeID(PubkeyID(&v5TestKeyA.PublicKey))
+	codec.storeSession(v5SessionID{idA, v5TestAddr}, &v5Session{
+		readKey:  make([]byte, 16),
+		writeKey: make([]byte, 16),
+	})
+	p, err := codec.decode(packet, v5TestAddr)
+	if err != nil {
+		t.Fatalf("can't decode ping: %v", err)
+	}
+	if p.flag != v5FlagMessage || p.srcID != idA || p.nonce != v5TestPingNonce {
+		t.Errorf("wrong header: flag %d, src %x, nonce %x", p.flag, p.srcID, p.nonce)
+	}
+	want := &v5Ping{ReqID: []byte{0, 0, 0, 1}, ENRSeq: 2}
+	if !reflect.DeepEqual(p.msg, want) {
+		t.Errorf("wrong message: have %+v, want %+v", p.msg, want)
+	}
+}
+
+// Tests that a WHOAREYOU challenge is decoded along with the data signed in
+// the handshake.
+func TestV5WireWhoareyouVector(t *testing.T) {
+	packet := hexBytes(`
+		00000000000000000000000000000000088b3d434277464933a1ccc59f5967ad
+		1d6035f15e528627dde75cd68292f9e6c27d6b66c8100a873fcbaed4e16b8d`)
+	challengeData := hexBytes(`
+		000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000000`)
+
+	p, err := newV5TestCodec().decode(packet, v5TestAddr)
+	if err != nil {
+		t.Fatalf("can't decode whoareyou: %v", err)
+	}
+	if p.flag != v5FlagWhoareyou || p.challenge == nil {
+		t.Fatalf("wrong packet: flag %d, challenge %v", p.flag, p.challenge)
+	}
+	ch := p.challenge
+	if ch.nonce != v5TestNonce || ch.idNonce != v5TestIDNonce || ch.seq != 0 {
+		t.Errorf("wrong challenge: nonce %x, id nonce %x, seq %d", ch.nonce, ch.idNonce, ch.seq)
+	}
+	if !bytes.Equal(ch.data, challengeData) {
+		t.Errorf("wrong challenge data:\nhave %x\nwant %x", ch.data, challengeData)
+	}
+}
+
+// Tests that a handshake packet answering a challenge is verified and that
+// its message is decrypted with the derived session keys.
+func TestV5WireHandshakeVector(t *testing.T) {
+	packet := hexBytes(`
+		00000000000000000000000000000000088b3d4342774649305f313964a39e55
+		ea96c005ad521d8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d3
+		4c4f53245d08da4bb252012b2cba3f4f374a90a75cff91f142fa9be3e0a5f3ef
+		268ccb9065aeecfd67a999e7fdc137e062b2ec4a0eb92947f0d9a74bfbf44dfb
+		a776b21301f8b65efd5796706adff216ab862a9186875f9494150c4ae06fa4d1
+		f0396c93f215fa4ef524f1eadf5f0f4126b79336671cbcf7a885b1f8bd2a5d83
+		9cf8`)
+	challengeData := hexBytes(`
+		000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000001`)
+
+	// Node A's record is known to node B, so the handshake omits it.
+	local := NewLocalNode(v5TestKeyA)
+	nodeA := local.Node()
+
+	codec := newV5TestCodec()
+	sid := v5SessionID{nodeA.sha, v5TestAddr}
+	codec.challenges[sid] = &v5Challenge{
+		data:    challengeData,
+		nonce:   v5TestNonce,
+		idNonce: v5TestIDNonce,
+		seq:     1,
+		node:    nodeA,
+		sent:    time.Now(),
+	}
+	p, err := codec.decode(packet, v5TestAddr)
+	if err != nil {
+		t.Fatalf("can't decode handshake: %v", err)
+	}
+	if p.flag != v5FlagHandshake || !p.handshake || p.srcID != nodeA.sha || p.nonce != v5TestPingNonce {
+		t.Errorf("wrong header: flag %d, src %x, nonce %x", p.flag, p.srcID, p.nonce)
+	}
+	if p.node != nodeA {
+		t.Errorf("wrong sender: %v", p.node)
+	}
+	want := &v5Ping{ReqID: []byte{0, 0, 0, 1}, ENRSeq: 1}
+	if !reflect.DeepEqual(p.msg, want) {
+		t.Errorf("wrong message: have %+v, want %+v", p.msg, want)
+	}
+	if _, ok := codec.challenges[sid]; ok {
+		t.Error("challenge not removed after handshake")
+	}
+	if codec.sessions[sid] == nil {
+		t.Error("no session established")
+	}
+
+	// Without the record, the handshake of an unknown node is refused.
+	codec = newV5TestCodec()
+	codec.challenges[sid] = &v5Challenge{data: challengeData, nonce: v5TestNonce, seq: 1, sent: time.Now()}
+	if _, err := codec.decode(packet, v5TestAddr); err != errV5UnknownKey {
+		t.Errorf("wrong error for unknown node: have %v, want %v", err, errV5UnknownKey)
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via DNS (EIP-1459).
+package dnsdisc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/p2p/discover"
+)
+
+const (
+	maxLinkedTrees = 16  // maximum number of trees followed via links
+	lookupResults  = 16  // maximum number of nodes returned by Source.Lookup
+	maxSyncDepth   = 100 // maximum depth of a tree
+)
+
+// Config holds configuration options for the client.
+type Config struct {
+	Timeout         time.Duration // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration // time between tree root update checks (default 30min)
+	CacheLimit      int           // maximum number of cached entries (default 1000)
+	Resolver        Resolver      // the DNS resolver to use (defaults to system DNS)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = 30 * time.Minute
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = 1000
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	return cfg
+}
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries map[string]entry // verified entries by "hash.domain"
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg.withDefaults(), entries: make(map[string]entry)}
+}
+
+// SyncTree downloads the entire node tree at the given URL. The signature of
+// the tree root is checked against the public key contained in the URL.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ctx := context.Background()
+	root, err := c.resolveRoot(ctx, domain, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	return c.syncTree(ctx, domain, root)
+}
+
+func (c *Client) syncTree(ctx context.Context, domain string, root *rootEntry) (*Tree, error) {
+	t := &Tree{root: root, entries: make(map[string]entry)}
+	if err := c.syncBranch(ctx, t, domain, root.eroot, false, 0); err != nil {
+		return nil, err
+	}
+	if err := c.syncBranch(ctx, t, domain, root.lroot, true, 0); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// syncBranch resolves the entry at hash and all its children, adding them to t.
+// Link trees may only contain links, node trees only node records.
+func (c *Client) syncBranch(ctx context.Context, t *Tree, domain, hash string, link bool, depth int) error {
+	if depth > maxSyncDepth {
+		return fmt.Errorf("tree at %s is too deep", domain)
+	}
+	e, err := c.resolveEntry(ctx, domain, hash)
+	if err != nil {
+		return err
+	}
+	t.entries[hash] = e
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := c.syncBranch(ctx, t, domain, child, link, depth+1); err != nil {
+				return err
+			}
+		}
+	case *enrEntry:
+		if link {
+			return errENRInLinkTree
+		}
+	case *linkEntry:
+		if !link {
+			return errLinkInENRTree
+		}
+	}
+	return nil
+}
+
+// resolveRoot retrieves a root entry via DNS and verifies its signature.
+func (c *Client) resolveRoot(ctx context.Context, domain string, pubkey *ecdsa.PublicKey) (*rootEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, rootPrefix) {
+			continue
+		}
+		root, err := parseRoot(txt)
+		if err != nil {
+			return nil, err
+		}
+		if !root.verifySignature(pubkey) {
+			return nil, entryError{"root", errInvalidSig}
+		}
+		return root, nil
+	}
+	return nil, errNoRoot
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	name := hash + "." + domain
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok {
+		return e, nil
+	}
+
+	e, err := c.doResolveEntry(ctx, name, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.entries) >= c.cfg.CacheLimit {
+		// Drop an arbitrary entry to make room.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[name] = e
+	c.mu.Unlock()
+	return e, nil
+}
+
+func (c *Client) doResolveEntry(ctx context.Context, name, hash string) (entry, error) {
+	wantHash, err := b32format.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 hash")
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+		if !bytes.HasPrefix(crypto.Keccak256([]byte(txt)), wantHash) {
+			err = nameError{name, errHashMismatch}
+		} else if err != nil {
+			err = nameError{name, err}
+		}
+		return e, err
+	}
+	return nil, nameError{name, errNoEntry}
+}
+
+// nameError wraps errors that occurred while resolving a certain name.
+type nameError struct {
+	name string
+	err  error
+}
+
+func (err nameError) Error() string {
+	if ee, ok := err.err.(entryError); ok {
+		return fmt.Sprintf("invalid %s entry at %s: %v", ee.typ, err.name, ee.err)
+	}
+	return err.name + ": " + err.err.Error()
+}
+
+// Source provides dial candidates from one or more DNS trees. Trees
+// referenced by links are followed. Source implements the node table
+// interface used by the p2p server, so it can be mixed with the discovery
+// protocols.
+type Source struct {
+	c    *Client
+	urls []string
+
+	mu    sync.Mutex
+	nodes []*discover.Node
+	trees map[string]*Tree // synced trees by URL
+
+	ctx       context.Context // canceled by Close
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	loopDone  chan struct{}
+}
+
+// NewSource creates a node source for the given enrtree:// URLs and starts
+// syncing them in the background.
+func (c *Client) NewSource(urls ...string) (*Source, error) {
+	for _, url := range urls {
+		if _, _, err := ParseURL(url); err != nil {
+			return nil, fmt.Errorf("invalid enrtree URL %q: %v", url, err)
+		}
+	}
+	s := &Source{
+		c:        c,
+		urls:     urls,
+		trees:    make(map[string]*Tree),
+		loopDone: make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.loop()
+	return s, nil
+}
+
+func (s *Source) loop() {
+	defer close(s.loopDone)
+
+	recheck := time.NewTicker(s.c.cfg.RecheckInterval)
+	defer recheck.Stop()
+	for {
+		s.refresh()
+		select {
+		case <-recheck.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh checks the roots of all known trees and resyncs trees whose
+// sequence number has changed.
+func (s *Source) refresh() {
+	var (
+		queue = append([]string{}, s.urls...)
+		seen  = make(map[string]bool)
+		trees = make(map[string]*Tree)
+		nodes []*discover.Node
+		ids   = make(map[discover.NodeID]bool)
+	)
+	for len(queue) > 0 && len(seen) < maxLinkedTrees && s.ctx.Err() == nil {
+		url := queue[0]
+		queue = queue[1:]
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		t, err := s.syncTree(url)
+		if err != nil {
+			glog.V(logger.Debug).Infof("DNS discovery: can't sync %s: %v", url, err)
+			continue
+		}
+		trees[url] = t
+		queue = append(queue, t.Links()...)
+		for _, r := range t.Nodes() {
+			n, err := discover.NewNodeFromRecord(r)
+			if err != nil || n.Incomplete() || n.TCP == 0 || ids[n.ID] {
+				continue
+			}
+			ids[n.ID] = true
+			nodes = append(nodes, n)
+		}
+	}
+	glog.V(logger.Debug).Infof("DNS discovery: %d nodes from %d trees", len(nodes), len(trees))
+
+	s.mu.Lock()
+	s.trees, s.nodes = trees, nodes
+	s.mu.Unlock()
+}
+
+// syncTree returns the tree at url, downloading it only if the root has changed.
+func (s *Source) syncTree(url string) (*Tree, error) {
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	root, err := s.c.resolveRoot(s.ctx, domain, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	old := s.trees[url]
+	s.mu.Unlock()
+	if old != nil && old.root.seq == root.seq && old.root.eroot == root.eroot && old.root.lroot == root.lroot {
+		return old, nil
+	}
+	return s.c.syncTree(s.ctx, domain, root)
+}
+
+// Self implements the node table interface. A DNS source has no local node.
+func (s *Source) Self() *discover.Node {
+	return nil
+}
+
+// Close stops background syncing.
+func (s *Source) Close() {
+	s.closeOnce.Do(s.cancel)
+	<-s.loopDone
+}
+
+// Resolve returns the node with the given ID if it is contained in one of the
+// trees.
+func (s *Source) Resolve(id discover.NodeID) *discover.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range s.nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Lookup returns random nodes from the trees. DNS trees have no notion of
+// distance, so the target is ignored.
+func (s *Source) Lookup(target discover.NodeID) []*discover.Node {
+	buf := make([]*discover.Node, lookupResults)
+	return buf[:s.ReadRandomNodes(buf)]
+}
+
+// ReadRandomNodes fills buf with random nodes from the trees.
+func (s *Source) ReadRandomNodes(buf []*discover.Node) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, i := range rand.Perm(len(s.nodes)) {
+		if n == len(buf) {
+			break
+		}
+		buf[n] = s.nodes[i]
+		n++
+	}
+	return n
+}
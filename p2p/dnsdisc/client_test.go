@@ -0,0 +1,176 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/p2p/discover"
+)
+
+// mapResolver is an in-memory DNS zone.
+type mapResolver map[string]string
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
+
+func makeTestTree(t *testing.T, domain string, nodes int, links []string) (*Tree, string, *ecdsa.PrivateKey) {
+	key, _ := crypto.GenerateKey()
+	tree, err := MakeTree(1, testNodes(nodes), links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, url, key
+}
+
+func TestClientSyncTree(t *testing.T) {
+	tree, url, _ := makeTestTree(t, "n", 30, []string{testLink("other.example.org")})
+	r := mapResolver(tree.ToTXT("n"))
+	c := NewClient(Config{Resolver: r})
+
+	got, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if len(got.Nodes()) != len(tree.Nodes()) {
+		t.Errorf("synced %d nodes, want %d", len(got.Nodes()), len(tree.Nodes()))
+	}
+	for i, n := range got.Nodes() {
+		if !n.Equal(tree.Nodes()[i]) {
+			t.Errorf("node %d differs", i)
+		}
+	}
+	if len(got.Links()) != 1 || got.Links()[0] != tree.Links()[0] {
+		t.Errorf("wrong links %v", got.Links())
+	}
+	if got.Seq() != tree.Seq() || got.Signature() != tree.Signature() {
+		t.Error("root differs")
+	}
+}
+
+// Tests that a root signed by the wrong key is rejected.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	tree, _, _ := makeTestTree(t, "n", 3, nil)
+	other, _ := crypto.GenerateKey()
+	url := (&linkEntry{domain: "n", pubkey: &other.PublicKey}).String()
+
+	c := NewClient(Config{Resolver: mapResolver(tree.ToTXT("n"))})
+	if _, err := c.SyncTree(url); err != (entryError{"root", errInvalidSig}) {
+		t.Fatalf("wrong error for bad signature: %v", err)
+	}
+}
+
+// Tests that entries which don't match their name are rejected.
+func TestClientSyncTreeHashMismatch(t *testing.T) {
+	tree, url, _ := makeTestTree(t, "n", 3, nil)
+	r := mapResolver(tree.ToTXT("n"))
+	// Swap the content of two leaves.
+	var names []string
+	for name, value := range r {
+		if value[:len(enrPrefix)] == enrPrefix {
+			names = append(names, name)
+		}
+	}
+	r[names[0]], r[names[1]] = r[names[1]], r[names[0]]
+
+	c := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	if ne, ok := err.(nameError); !ok || ne.err != errHashMismatch {
+		t.Fatalf("wrong error for mismatching entry: %v", err)
+	}
+}
+
+// Tests that links may not appear in the node subtree.
+func TestClientSyncTreeLinkInENRTree(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	link, _ := parseLink(testLink("other.example.org"))
+	tree := &Tree{entries: make(map[string]entry)}
+	tree.entries[subdomain(link)] = link
+	lroot := &branchEntry{}
+	tree.entries[subdomain(lroot)] = lroot
+	tree.root = &rootEntry{seq: 1, eroot: subdomain(link), lroot: subdomain(lroot)}
+	url, _ := tree.Sign(key, "n")
+
+	c := NewClient(Config{Resolver: mapResolver(tree.ToTXT("n"))})
+	if _, err := c.SyncTree(url); err != errLinkInENRTree {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// Tests that a source follows links and serves nodes of all trees.
+func TestSource(t *testing.T) {
+	tree2, url2, _ := makeTestTree(t, "n2", 10, nil)
+	tree1, url1, _ := makeTestTree(t, "n1", 10, []string{url2})
+	r := mapResolver(tree1.ToTXT("n1"))
+	r.add(tree2.ToTXT("n2"))
+
+	c := NewClient(Config{Resolver: r, RecheckInterval: 20 * time.Millisecond})
+	src, err := c.NewSource(url1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	want := make(map[discover.NodeID]bool)
+	for _, tree := range []*Tree{tree1, tree2} {
+		for _, rec := range tree.Nodes() {
+			n, _ := discover.NewNodeFromRecord(rec)
+			want[n.ID] = true
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	buf := make([]*discover.Node, 100)
+	for {
+		n := src.ReadRandomNodes(buf)
+		if n == len(want) {
+			for _, node := range buf[:n] {
+				if !want[node.ID] {
+					t.Fatalf("unexpected node %v", node)
+				}
+				if src.Resolve(node.ID) != node {
+					t.Fatalf("can't resolve %x", node.ID[:8])
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("source has %d nodes, want %d", n, len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := src.Lookup(discover.NodeID{}); len(got) != lookupResults {
+		t.Errorf("Lookup returned %d nodes, want %d", len(got), lookupResults)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
+)
+
+func testNodes(n int) []*enr.Record {
+	records := make([]*enr.Record, n)
+	for i := range records {
+		key, _ := crypto.GenerateKey()
+		ln := discover.NewLocalNode(key)
+		ln.SetEndpoint(net.IP{127, 0, 0, 1}, uint16(30000+i), uint16(30000+i))
+		records[i] = ln.Record()
+	}
+	return records
+}
+
+func testLink(domain string) string {
+	key, _ := crypto.GenerateKey()
+	return (&linkEntry{domain: domain, pubkey: &key.PublicKey}).String()
+}
+
+func TestParseRoot(t *testing.T) {
+	tests := []struct {
+		input string
+		e     *rootEntry
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: "enrtree-root:v1 e=QFT4PBCRX4XQCV3VUYJ6BTCEPU l=JGUFMSAGI7KZYB3P7IZW4S5Y3A seq=3 sig=3FmXuVwpa8Y7OstZTx9PIb1mt8FrW7VpDOFv4AaGCsZ2EIHmhraWhe4NxYhQDlw5MjeFXYMbJjsPeKlHzmJREQE",
+			e: &rootEntry{
+				eroot: "QFT4PBCRX4XQCV3VUYJ6BTCEPU",
+				lroot: "JGUFMSAGI7KZYB3P7IZW4S5Y3A",
+				seq:   3,
+				sig:   mustDecodeSig("3FmXuVwpa8Y7OstZTx9PIb1mt8FrW7VpDOFv4AaGCsZ2EIHmhraWhe4NxYhQDlw5MjeFXYMbJjsPeKlHzmJREQE"),
+			},
+		},
+	}
+	for i, test := range tests {
+		e, err := parseRoot(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %v, want %v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func mustDecodeSig(s string) []byte {
+	sig, err := b64format.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func TestParseEntry(t *testing.T) {
+	testkey, _ := crypto.GenerateKey()
+	link := &linkEntry{domain: "nodes.example.org", pubkey: &testkey.PublicKey}
+	node := testNodes(1)[0]
+
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAA",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBBBBBBBB",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBBBBBBBBBBBB"}},
+		},
+		// Links:
+		{
+			input: link.String(),
+			e:     link,
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs:
+		{
+			input: node.String(),
+			e:     &enrEntry{node},
+		},
+		{
+			input: "enr:-HW4QLZHjM4vZXkbp-5xJoHsKSbE7W39FPC8283X-y8oHcHPTnDDlIlzL5ArvDUlHZVDPgmFASrh7cWgLOLxj4wprRkHgmlkgnY0iXNlY3AyNTZrMaEC3t2jLMhDpCDX5mbSEwDn4L3iUfyXzoO8G28XvjGRkrAg=",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input)
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+			continue
+		}
+		if test.e != nil && e.String() != test.e.String() {
+			t.Errorf("test %d: wrong entry %s, want %s", i, e, test.e)
+		}
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := testNodes(50)
+	links := []string{testLink("a.example.org"), testLink("b.example.org")}
+	tree, err := MakeTree(2, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Seq() != 2 {
+		t.Errorf("wrong seq %d", tree.Seq())
+	}
+	if got := tree.Nodes(); len(got) != len(nodes) {
+		t.Errorf("tree has %d nodes, want %d", len(got), len(nodes))
+	}
+	if got := tree.Links(); !reflect.DeepEqual(got, sortedStrings(links)) {
+		t.Errorf("wrong links %v, want %v", got, links)
+	}
+	for _, e := range tree.entries {
+		if be, ok := e.(*branchEntry); ok && len(be.children) > maxChildren {
+			t.Errorf("branch has %d children", len(be.children))
+		}
+	}
+}
+
+func TestSignTree(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tree, err := MakeTree(1, testNodes(3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain != "nodes.example.org" || !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Errorf("wrong URL %s", url)
+	}
+
+	// The signature must survive the text round trip.
+	txt := tree.ToTXT("nodes.example.org")
+	root, err := parseRoot(txt["nodes.example.org"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.verifySignature(&key.PublicKey) {
+		t.Error("signature of root doesn't verify")
+	}
+	for name, value := range txt {
+		if name == "nodes.example.org" {
+			continue
+		}
+		if h := subdomain(&stringEntry{value}); !strings.HasPrefix(name, h+".") {
+			t.Errorf("entry %s published at wrong name %s", value, name)
+		}
+	}
+
+	// SetSignature accepts only valid signatures.
+	other, _ := crypto.GenerateKey()
+	if err := tree.SetSignature(&other.PublicKey, tree.Signature()); err != errInvalidSig {
+		t.Errorf("SetSignature accepted signature for wrong key: %v", err)
+	}
+	if err := tree.SetSignature(&key.PublicKey, tree.Signature()); err != nil {
+		t.Errorf("SetSignature rejected valid signature: %v", err)
+	}
+}
+
+type stringEntry struct{ s string }
+
+func (e *stringEntry) String() string { return e.s }
+
+func sortedStrings(s []string) []string {
+	c := append([]string{}, s...)
+	for i := range c {
+		for j := i + 1; j < len(c); j++ {
+			if c[j] < c[i] {
+				c[i], c[j] = c[j], c[i]
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package enr
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+var privkey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// The example record from EIP-778.
+const exampleRecord = "enr:-IS4QHCYrYZbAKWCBRlAy5zzaDZXJBGkcnh4MHcBFZntXNFrdvJjX04jRzjzCBOonrkTfj499SZuOh8R33Ls8RRcy5wBgmlkgnY0gmlwhH8AAAGJc2VjcDI1NmsxoQPKY0yuDUmstAHYpMa2_oxVtw0RW_QAdpzBQA8yWM0xOIN1ZHCCdl8"
+
+// Tests that the example record of the specification is decoded and
+// recreated from its entries.
+func TestExampleRecord(t *testing.T) {
+	r, err := Parse(exampleRecord)
+	if err != nil {
+		t.Fatalf("can't parse record: %v", err)
+	}
+	var (
+		ip  IPv4
+		udp UDP
+	)
+	if err := r.Load(&ip); err != nil || !net.IP(ip).Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("ip mismatch: %v, %v", net.IP(ip), err)
+	}
+	if err := r.Load(&udp); err != nil || udp != 30303 {
+		t.Errorf("udp mismatch: %v, %v", udp, err)
+	}
+	if pub := r.PublicKey(); pub == nil || pub.X.Cmp(privkey.PublicKey.X) != 0 {
+		t.Errorf("public key mismatch")
+	}
+	if r.Seq() != 1 {
+		t.Errorf("seq mismatch: have %d, want 1", r.Seq())
+	}
+
+	// Recreating the record must yield the same content.
+	var r2 Record
+	r2.SetSeq(1)
+	r2.Set(IP(net.IPv4(127, 0, 0, 1)))
+	r2.Set(UDP(30303))
+	if err := SignV4(&r2, privkey); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(&r2) || r2.Seq() != r.Seq() {
+		t.Errorf("recreated record mismatch:\nhave %s\nwant %s", r2.String(), exampleRecord)
+	}
+	if _, err := Parse(r2.String()); err != nil {
+		t.Errorf("can't parse recreated record: %v", err)
+	}
+}
+
+// Tests that the "enr://" text form is accepted and other prefixes rejected.
+func TestParsePrefixes(t *testing.T) {
+	if _, err := Parse("enr://" + exampleRecord[len(textPrefix):]); err != nil {
+		t.Errorf("enr:// form rejected: %v", err)
+	}
+	if _, err := Parse("enode://" + exampleRecord[len(textPrefix):]); err != errBadPrefix {
+		t.Errorf("wrong error for bad prefix: %v", err)
+	}
+}
+
+// Tests that records survive an RLP round trip and that modifying a signed
+// record bumps the sequence number and drops the signature.
+func TestSignEncodeAndDecode(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	r.Set(TCP(30303))
+	r.Set(IP(net.IPv4(10, 0, 0, 1)))
+	r.Set(WithEntry("eth", []uint{1, 2}))
+	if err := SignV4(&r, privkey); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r2 Record
+	if err := rlp.DecodeBytes(blob, &r2); err != nil {
+		t.Fatalf("can't decode: %v", err)
+	}
+	blob2, _ := rlp.EncodeToBytes(r2)
+	if !bytes.Equal(blob, blob2) {
+		t.Errorf("round trip mismatch:\nhave %x\nwant %x", blob2, blob)
+	}
+	var eth []uint
+	if err := r2.Load(WithEntry("eth", &eth)); err != nil || len(eth) != 2 {
+		t.Errorf("can't load generic entry: %v %v", eth, err)
+	}
+	if err := r2.Load(WithEntry("les", &eth)); !IsNotFound(err) {
+		t.Errorf("wrong error for missing key: %v", err)
+	}
+
+	r2.Set(TCP(30304))
+	if r2.Signed() || r2.Seq() != r.Seq()+1 {
+		t.Errorf("modified record still signed or seq not bumped: signed %v, seq %d", r2.Signed(), r2.Seq())
+	}
+	if _, err := rlp.EncodeToBytes(r2); err != errEncodeUnsigned {
+		t.Errorf("wrong error encoding unsigned record: %v", err)
+	}
+	if r.Equal(&r2) {
+		t.Errorf("records with different ports are equal")
+	}
+}
+
+// Tests that records with tampered signatures or content are rejected.
+func TestBadSignature(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	if err := SignV4(&r, privkey); err != nil {
+		t.Fatal(err)
+	}
+	blob, _ := rlp.EncodeToBytes(r)
+	blob[len(blob)-1]++ // change the UDP port
+
+	var r2 Record
+	if err := rlp.DecodeBytes(blob, &r2); err != errInvalidSig {
+		t.Errorf("wrong error for tampered record: %v", err)
+	}
+}
+
+// Tests that records above the size limit can't be signed.
+func TestSizeLimit(t *testing.T) {
+	var r Record
+	r.Set(WithEntry("junk", make([]byte, SizeLimit)))
+	if err := SignV4(&r, privkey); err != errTooBig {
+		t.Errorf("wrong error for oversized record: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package enr
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/rlp"
+)
+
+// SignV4 signs a record using the v4 scheme, i.e. the secp256k1 signature of
+// the keccak256 hash of the record content. The "id" and "secp256k1" entries
+// are set from the key, and the sequence number is left unchanged.
+func SignV4(r *Record, privkey *ecdsa.PrivateKey) error {
+	// Copy r to avoid modifying it if signing fails.
+	cpy := *r
+	cpy.Set(IDv4)
+	cpy.Set(Secp256k1(privkey.PublicKey))
+	cpy.seq = r.seq
+
+	h := crypto.Keccak256(rlpContent(&cpy))
+	sig, err := crypto.Sign(h, privkey)
+	if err != nil {
+		return err
+	}
+	sig = sig[:len(sig)-1] // remove v
+	raw, err := cpy.encode(sig)
+	if err != nil {
+		return err
+	}
+	cpy.signature, cpy.raw = sig, raw
+	*r = cpy
+	return nil
+}
+
+// PublicKey returns the secp256k1 public key of the record, or nil if the
+// record doesn't hold a valid one.
+func (r *Record) PublicKey() *ecdsa.PublicKey {
+	var pk Secp256k1
+	if err := r.Load(&pk); err != nil {
+		return nil
+	}
+	return (*ecdsa.PublicKey)(&pk)
+}
+
+// verifySignature checks the signature of a decoded record against the
+// public key it carries, as defined by the v4 identity scheme.
+func (r *Record) verifySignature() error {
+	var id ID
+	if err := r.Load(&id); err != nil {
+		return err
+	}
+	if id != IDv4 {
+		return errNoID
+	}
+	pubkey := r.PublicKey()
+	if pubkey == nil {
+		return errInvalidSig
+	}
+	h := crypto.Keccak256(rlpContent(r))
+	if !crypto.VerifySignature(crypto.FromECDSAPub(pubkey), h, r.signature) {
+		return errInvalidSig
+	}
+	return nil
+}
+
+// rlpContent returns the RLP encoding of the record content which is signed,
+// i.e. the sequence number followed by the sorted key/value pairs.
+func rlpContent(r *Record) []byte {
+	blob, err := rlp.EncodeToBytes(r.AppendElements(nil))
+	if err != nil {
+		panic(err)
+	}
+	return blob
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,13 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific information for the node record.
+	Attributes []enr.Entry
+
+	// NodeFilter is an optional check applied to nodes found by discovery
+	// before dialing them. Nodes are dialed if any protocol accepts them.
+	NodeFilter func(n *discover.Node) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -29,6 +29,7 @@ import (
 	"github.com/eth-classic/go-ethereum/logger"
 	"github.com/eth-classic/go-ethereum/logger/glog"
 	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/dnsdisc"
 	"github.com/eth-classic/go-ethereum/p2p/nat"
 )
 
@@ -75,6 +76,10 @@ type Config struct {
 	// or not. Disabling is usually useful for protocol debugging (manual topology).
 	Discovery bool
 
+	// DiscoveryV5 specifies whether the discovery v5 protocol should be
+	// started. It shares the UDP socket with the v4 protocol if both run.
+	DiscoveryV5 bool
+
 	// Name sets the node name of this server.
 	Name string
 
@@ -82,6 +87,14 @@ type Config struct {
 	// with the rest of the network.
 	BootstrapNodes []*discover.Node
 
+	// BootstrapNodesV5 are used to establish connectivity with the rest of
+	// the network using the v5 discovery protocol.
+	BootstrapNodesV5 []*discover.Node
+
+	// DiscoveryDNS is a list of enrtree:// URLs of node lists published in
+	// DNS (EIP-1459). The listed nodes are used as dial candidates.
+	DiscoveryDNS []string
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*discover.Node
@@ -134,6 +147,7 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	localnode    *discover.LocalNode
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
@@ -290,22 +304,17 @@ func (srv *Server) Self() *discover.Node {
 	if !srv.running {
 		return &discover.Node{IP: net.ParseIP("0.0.0.0")}
 	}
-	// If the node is running but discovery is off, manually assemble the node infos
-	if srv.ntab == nil {
-		// Inbound connections disabled, use zero address
-		if srv.listener == nil {
-			return &discover.Node{IP: net.ParseIP("0.0.0.0"), ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
-		}
-		// Otherwise inject the listener address too
-		addr := srv.listener.Addr().(*net.TCPAddr)
-		return &discover.Node{
-			ID:  discover.PubkeyID(&srv.PrivateKey.PublicKey),
-			IP:  addr.IP,
-			TCP: uint16(addr.Port),
-		}
-	}
-	// Otherwise return the live node infos
-	return srv.ntab.Self()
+	// Otherwise return the live node infos, including the signed record
+	return srv.localnode.Node()
+}
+
+// LocalNode returns the local node record, which protocols may update while
+// the server is running. It returns nil if the server was not started.
+func (srv *Server) LocalNode() *discover.LocalNode {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	return srv.localnode
 }
 
 // Stop terminates the server and all active peer connections.
@@ -355,32 +364,35 @@ func (srv *Server) Start() (err error) {
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
-	// node table
-	if srv.Discovery {
-		ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase)
-		if err != nil {
-			return err
-		}
-		if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
-			return err
+	// local node record
+	srv.localnode = discover.NewLocalNode(srv.PrivateKey)
+	for _, p := range srv.Protocols {
+		for _, e := range p.Attributes {
+			srv.localnode.Set(e)
 		}
-		srv.ntab = ntab
 	}
 
-	dynPeers := srv.maxDialedConns()
-	dialer := newDialState(srv.StaticNodes, srv.ntab, dynPeers)
-
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
-	// listen/dial
+	// listen/dial. The TCP listener is started before discovery so that
+	// both use the same port if ListenAddr doesn't specify one.
 	if srv.ListenAddr != "" {
 		if err := srv.startListening(); err != nil {
 			return err
 		}
 	}
+
+	// node table
+	if err := srv.setupDiscovery(); err != nil {
+		return err
+	}
+
+	dynPeers := srv.maxDialedConns()
+	dialer := newDialState(srv.StaticNodes, srv.ntab, dynPeers)
+	dialer.filter = srv.dialFilter()
 	if srv.NoDial && srv.ListenAddr == "" {
 		glog.V(logger.Warn).Infoln("I will be kind-of useless, neither dialing nor listening.")
 		glog.V(logger.Warn).Warnln("Server will be kind of useless, neither dialing nor listening.")
@@ -392,6 +404,147 @@ func (srv *Server) Start() (err error) {
 	return nil
 }
 
+func (srv *Server) setupDiscovery() error {
+	tables, err := srv.setupUDPDiscovery()
+	if err != nil {
+		return err
+	}
+	if len(srv.DiscoveryDNS) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{})
+		src, err := client.NewSource(srv.DiscoveryDNS...)
+		if err != nil {
+			for _, t := range tables {
+				t.Close()
+			}
+			return err
+		}
+		tables = append(tables, src)
+	}
+	switch len(tables) {
+	case 0:
+	case 1:
+		srv.ntab = tables[0]
+	default:
+		srv.ntab = discoverMix(tables)
+	}
+	return nil
+}
+
+// setupUDPDiscovery starts the UDP discovery protocols which are enabled.
+func (srv *Server) setupUDPDiscovery() ([]discoverTable, error) {
+	if !srv.Discovery && !srv.DiscoveryV5 {
+		// Announce the listener address, if any.
+		ip, port := net.ParseIP("0.0.0.0"), 0
+		if srv.listener != nil {
+			addr := srv.listener.Addr().(*net.TCPAddr)
+			ip, port = addr.IP, addr.Port
+		}
+		srv.localnode.SetEndpoint(ip, 0, uint16(port))
+		return nil, nil
+	}
+	addr, err := net.ResolveUDPAddr("udp", srv.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := discover.Config{
+		PrivateKey: srv.PrivateKey,
+		NAT:        srv.NAT,
+		NodeDBPath: srv.NodeDatabase,
+		LocalNode:  srv.localnode,
+	}
+
+	var (
+		tables []discoverTable
+		v5conn discover.UDPConn = conn
+	)
+	if srv.Discovery {
+		if srv.DiscoveryV5 {
+			// Packets v4 can't decode are handed to v5.
+			unhandled := make(chan discover.ReadPacket, 100)
+			cfg.Unhandled = unhandled
+			v5conn = &sharedUDPConn{UDPConn: conn, unhandled: unhandled, closed: make(chan struct{})}
+		}
+		ntab, err := discover.ListenV4(conn, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
+			return nil, err
+		}
+		tables = append(tables, ntab)
+		// The v4 listener maps the port and sets the endpoint.
+		cfg.NAT, cfg.Unhandled = nil, nil
+	}
+	if srv.DiscoveryV5 {
+		ntab, err := discover.ListenV5(v5conn, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := ntab.SetFallbackNodes(srv.BootstrapNodesV5); err != nil {
+			return nil, err
+		}
+		tables = append(tables, ntab)
+	}
+	return tables, nil
+}
+
+// sharedUDPConn is the connection of the v5 protocol if it shares the socket
+// with v4. It reads the packets v4 couldn't handle.
+type sharedUDPConn struct {
+	*net.UDPConn
+	unhandled chan discover.ReadPacket
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ReadFromUDP implements discover.UDPConn.
+func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
+	select {
+	case packet := <-s.unhandled:
+		n = copy(b, packet.Data)
+		if n < len(packet.Data) {
+			return 0, nil, errors.New("packet too big")
+		}
+		return n, packet.Addr, nil
+	case <-s.closed:
+		return 0, nil, errors.New("connection was closed")
+	}
+}
+
+// Close implements discover.UDPConn. The socket itself is closed by v4.
+func (s *sharedUDPConn) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+// dialFilter returns the check applied to discovered nodes before dialing
+// them, combined from the protocol filters.
+func (srv *Server) dialFilter() func(*discover.Node) bool {
+	var filters []func(*discover.Node) bool
+	for _, p := range srv.Protocols {
+		if p.NodeFilter == nil {
+			// The protocol accepts any node.
+			return nil
+		}
+		filters = append(filters, p.NodeFilter)
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(n *discover.Node) bool {
+		for _, f := range filters {
+			if f(n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func (srv *Server) startListening() error {
 	// Launch the TCP listener.
 	listener, err := net.Listen("tcp", srv.ListenAddr)
@@ -600,7 +753,7 @@ func (srv *Server) maxInboundConns() int {
 }
 
 func (srv *Server) maxDialedConns() int {
-	if !srv.Discovery || srv.NoDial {
+	if srv.ntab == nil || srv.NoDial {
 		return 0
 	}
 	r := srv.DialRatio
@@ -787,6 +940,7 @@ type NodeInfo struct {
 	ID    string `json:"id"`    // Unique node identifier (also the encryption key)
 	Name  string `json:"name"`  // Name of the node, including client type, version, OS, custom data
 	Enode string `json:"enode"` // Enode URL for adding this peer from remote peers
+	ENR   string `json:"enr"`   // Ethereum Node Record
 	IP    string `json:"ip"`    // IP address of the node
 	Ports struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
@@ -804,6 +958,7 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	info := &NodeInfo{
 		Name:       srv.Name,
 		Enode:      node.String(),
+		ENR:        enrString(node),
 		ID:         node.ID.String(),
 		IP:         node.IP.String(),
 		ListenAddr: srv.ListenAddr,
@@ -825,6 +980,13 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+func enrString(n *discover.Node) string {
+	if r := n.Record(); r != nil {
+		return r.String()
+	}
+	return ""
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
@@ -28,6 +28,8 @@ import (
 	"github.com/eth-classic/go-ethereum/crypto"
 	"github.com/eth-classic/go-ethereum/crypto/sha3"
 	"github.com/eth-classic/go-ethereum/p2p/discover"
+	"github.com/eth-classic/go-ethereum/p2p/dnsdisc"
+	"github.com/eth-classic/go-ethereum/p2p/enr"
 )
 
 func init() {
@@ -481,6 +483,98 @@ func (c *setupTransport) ReadMsg() (Msg, error) {
 	panic("ReadMsg called on setupTransport")
 }
 
+// Tests that both discovery protocols run on one socket and that the node
+// record carries the protocol attributes.
+func TestServerDiscoveryV5(t *testing.T) {
+	newServer := func(bootnodes []*discover.Node) *Server {
+		srv := &Server{Config: Config{
+			Name:             "test",
+			MaxPeers:         10,
+			ListenAddr:       "127.0.0.1:0",
+			PrivateKey:       newkey(),
+			Discovery:        true,
+			DiscoveryV5:      true,
+			BootstrapNodesV5: bootnodes,
+			NoDial:           true,
+			Protocols: []Protocol{{
+				Name:       "test",
+				Attributes: []enr.Entry{enr.WithEntry("test", uint(1))},
+			}},
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Could not start server: %v", err)
+		}
+		return srv
+	}
+	a := newServer(nil)
+	defer a.Stop()
+
+	info := a.NodeInfo()
+	r, err := enr.Parse(info.ENR)
+	if err != nil {
+		t.Fatalf("invalid record in node info %q: %v", info.ENR, err)
+	}
+	var (
+		v   uint
+		tcp enr.TCP
+		udp enr.UDP
+	)
+	if err := r.Load(enr.WithEntry("test", &v)); err != nil || v != 1 {
+		t.Errorf("protocol attribute missing: %v %v", v, err)
+	}
+	r.Load(&tcp)
+	r.Load(&udp)
+	if tcp == 0 || uint16(tcp) != uint16(udp) || int(tcp) != info.Ports.Listener {
+		t.Errorf("wrong ports in record: tcp %d, udp %d, listener %d", tcp, udp, info.Ports.Listener)
+	}
+
+	// Bootstrapping from a makes the v5 table of b find it.
+	b := newServer([]*discover.Node{a.Self()})
+	defer b.Stop()
+	mix, ok := b.ntab.(discoverMix)
+	if !ok || len(mix) != 2 {
+		t.Fatalf("wrong discovery table type %T", b.ntab)
+	}
+	for _, n := range mix[1].Lookup(a.Self().ID) {
+		if n.ID == a.Self().ID {
+			return
+		}
+	}
+	t.Errorf("v5 lookup didn't find bootstrap node")
+}
+
+func TestServerDiscoveryDNS(t *testing.T) {
+	newServer := func(urls ...string) *Server {
+		return &Server{Config: Config{
+			Name:         "test",
+			MaxPeers:     10,
+			PrivateKey:   newkey(),
+			DiscoveryDNS: urls,
+		}}
+	}
+	if err := newServer("enrtree://nodes.example.org").Start(); err == nil {
+		t.Fatal("server started with invalid enrtree URL")
+	}
+
+	// DNS discovery alone provides dial candidates.
+	tree, _ := dnsdisc.MakeTree(1, nil, nil)
+	url, err := tree.Sign(newkey(), "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(url)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	defer srv.Stop()
+	if _, ok := srv.ntab.(*dnsdisc.Source); !ok {
+		t.Fatalf("wrong discovery table type %T", srv.ntab)
+	}
+	if srv.maxDialedConns() == 0 {
+		t.Error("no dialed connections with DNS discovery")
+	}
+}
+
 func newkey() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
@@ -0,0 +1,269 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package etchash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/crypto/sha3"
+)
+
+const (
+	datasetInitBytes   = 1 << 30 // Bytes in dataset at genesis
+	datasetGrowthBytes = 1 << 23 // Dataset growth per epoch
+	cacheInitBytes     = 1 << 24 // Bytes in cache at genesis
+	cacheGrowthBytes   = 1 << 17 // Cache growth per epoch
+	mixBytes           = 128     // Width of mix
+	hashBytes          = 64      // Hash length in bytes
+	hashWords          = 16      // Number of 32 bit ints in a hash
+	datasetParents     = 256     // Number of parents of each dataset element
+	cacheRounds        = 3       // Number of rounds in cache production
+	loopAccesses       = 64      // Number of accesses in hashimoto loop
+
+	epochLengthDefault  = 30000 // Blocks per epoch of the original ethash
+	epochLengthECIP1099 = 60000 // Blocks per epoch since ECIP-1099
+	maxEpoch            = 2048  // Maximum epoch the cache and dataset sizes are defined for
+)
+
+// cacheSize calculates the size of the verification cache of the given epoch,
+// the largest prime multiple of hashBytes below the linear growth limit.
+func cacheSize(epoch uint64) uint64 {
+	size := cacheInitBytes + cacheGrowthBytes*epoch - hashBytes
+	for !new(big.Int).SetUint64(size / hashBytes).ProbablyPrime(1) {
+		size -= 2 * hashBytes
+	}
+	return size
+}
+
+// datasetSize calculates the size of the mining dataset of the given epoch,
+// the largest prime multiple of mixBytes below the linear growth limit.
+func datasetSize(epoch uint64) uint64 {
+	size := datasetInitBytes + datasetGrowthBytes*epoch - mixBytes
+	for !new(big.Int).SetUint64(size / mixBytes).ProbablyPrime(1) {
+		size -= 2 * mixBytes
+	}
+	return size
+}
+
+// seedHash is the seed to use for generating the verification cache and the
+// mining dataset of the given epoch. The seed is chained once per 30000
+// blocks, so an ECIP-1099 epoch advances it twice.
+func seedHash(epoch, epochLength uint64) []byte {
+	seed := make([]byte, common.HashLength)
+	for i := uint64(0); i < epoch*epochLength/epochLengthDefault; i++ {
+		seed = crypto.Keccak256(seed)
+	}
+	return seed
+}
+
+// hasher is a repetitive hasher allowing the same hash data structures to be
+// reused between hash runs instead of requiring new ones to be created.
+type hasher func(dest []byte, data []byte)
+
+// makeHasher creates a repetitive hasher. The returned function writes the
+// digest of data into the beginning of dest.
+func makeHasher(h hash.Hash) hasher {
+	return func(dest []byte, data []byte) {
+		h.Reset()
+		h.Write(data)
+		copy(dest, h.Sum(nil))
+	}
+}
+
+// generateCache creates a verification cache of the given size, seeded with
+// the given seed hash, using the RandMemoHash algorithm.
+func generateCache(size uint64, seed []byte) []uint32 {
+	var (
+		keccak512 = makeHasher(sha3.NewKeccak512())
+		buffer    = make([]byte, size)
+		rows      = int(size / hashBytes)
+	)
+	// Sequentially produce the initial dataset
+	keccak512(buffer, seed)
+	for offset := uint64(hashBytes); offset < size; offset += hashBytes {
+		keccak512(buffer[offset:], buffer[offset-hashBytes:offset])
+	}
+	// Use a low-round version of randmemohash
+	temp := make([]byte, hashBytes)
+	for i := 0; i < cacheRounds; i++ {
+		for j := 0; j < rows; j++ {
+			var (
+				srcOff = ((j - 1 + rows) % rows) * hashBytes
+				dstOff = j * hashBytes
+				xorOff = int(binary.LittleEndian.Uint32(buffer[dstOff:])%uint32(rows)) * hashBytes
+			)
+			for k := 0; k < hashBytes; k++ {
+				temp[k] = buffer[srcOff+k] ^ buffer[xorOff+k]
+			}
+			keccak512(buffer[dstOff:], temp)
+		}
+	}
+	cache := make([]uint32, size/4)
+	for i := range cache {
+		cache[i] = binary.LittleEndian.Uint32(buffer[i*4:])
+	}
+	return cache
+}
+
+// fnv is an algorithm inspired by the FNV hash, which in some cases is used as
+// a non-associative substitute for XOR.
+func fnv(a, b uint32) uint32 {
+	return a*0x01000193 ^ b
+}
+
+// fnvHash mixes in data into mix using the ethash fnv method.
+func fnvHash(mix []uint32, data []uint32) {
+	for i := 0; i < len(mix); i++ {
+		mix[i] = mix[i]*0x01000193 ^ data[i]
+	}
+}
+
+// generateDatasetItem combines data from 256 pseudorandomly selected cache
+// nodes, and hashes that to compute a single dataset node.
+func generateDatasetItem(cache []uint32, index uint32, keccak512 hasher) []uint32 {
+	rows := uint32(len(cache) / hashWords)
+
+	// Initialize the mix
+	mix := make([]byte, hashBytes)
+	binary.LittleEndian.PutUint32(mix, cache[(index%rows)*hashWords]^index)
+	for i := 1; i < hashWords; i++ {
+		binary.LittleEndian.PutUint32(mix[i*4:], cache[(index%rows)*hashWords+uint32(i)])
+	}
+	keccak512(mix, mix)
+
+	// Convert the mix to uint32s to avoid constant bit shifting
+	intMix := make([]uint32, hashWords)
+	for i := 0; i < len(intMix); i++ {
+		intMix[i] = binary.LittleEndian.Uint32(mix[i*4:])
+	}
+	// fnv it with a lot of random cache nodes based on index
+	for i := uint32(0); i < datasetParents; i++ {
+		parent := fnv(index^i, intMix[i%16]) % rows
+		fnvHash(intMix, cache[parent*hashWords:])
+	}
+	// Flatten the uint32 mix into a binary one and return it
+	for i, val := range intMix {
+		binary.LittleEndian.PutUint32(mix[i*4:], val)
+	}
+	keccak512(mix, mix)
+
+	item := make([]uint32, hashWords)
+	for i := range item {
+		item[i] = binary.LittleEndian.Uint32(mix[i*4:])
+	}
+	return item
+}
+
+// generateDataset generates the entire mining dataset of the given size from
+// the verification cache, spreading the work over all available CPUs.
+func generateDataset(size uint64, cache []uint32) []uint32 {
+	var (
+		dataset = make([]uint32, size/4)
+		items   = uint32(size / hashBytes)
+		threads = uint32(runtime.NumCPU())
+		batch   = (items + threads - 1) / threads
+		pend    sync.WaitGroup
+	)
+	for i := uint32(0); i < threads; i++ {
+		pend.Add(1)
+		go func(id uint32) {
+			defer pend.Done()
+
+			keccak512 := makeHasher(sha3.NewKeccak512())
+			first, limit := id*batch, (id+1)*batch
+			if limit > items {
+				limit = items
+			}
+			for index := first; index < limit; index++ {
+				copy(dataset[index*hashWords:], generateDatasetItem(cache, index, keccak512))
+			}
+		}(i)
+	}
+	pend.Wait()
+	return dataset
+}
+
+// hashimoto aggregates data from the full dataset in order to produce our final
+// value for a particular header hash and nonce.
+func hashimoto(hash []byte, nonce uint64, size uint64, lookup func(index uint32) []uint32) ([]byte, []byte) {
+	// Calculate the number of theoretical rows (we use one buffer nonetheless)
+	rows := uint32(size / mixBytes)
+
+	// Combine header+nonce into a 64 byte seed
+	seed := make([]byte, 40)
+	copy(seed, hash)
+	binary.LittleEndian.PutUint64(seed[32:], nonce)
+
+	seed = crypto.Keccak512(seed)
+	seedHead := binary.LittleEndian.Uint32(seed)
+
+	// Start the mix with replicated seed
+	mix := make([]uint32, mixBytes/4)
+	for i := 0; i < len(mix); i++ {
+		mix[i] = binary.LittleEndian.Uint32(seed[i%16*4:])
+	}
+	// Mix in random dataset nodes
+	temp := make([]uint32, len(mix))
+
+	for i := 0; i < loopAccesses; i++ {
+		parent := fnv(uint32(i)^seedHead, mix[i%len(mix)]) % rows
+		for j := uint32(0); j < mixBytes/hashBytes; j++ {
+			copy(temp[j*hashWords:], lookup(2*parent+j))
+		}
+		fnvHash(mix, temp)
+	}
+	// Compress mix
+	for i := 0; i < len(mix); i += 4 {
+		mix[i/4] = fnv(fnv(fnv(mix[i], mix[i+1]), mix[i+2]), mix[i+3])
+	}
+	mix = mix[:len(mix)/4]
+
+	digest := make([]byte, common.HashLength)
+	for i, val := range mix {
+		binary.LittleEndian.PutUint32(digest[i*4:], val)
+	}
+	return digest, crypto.Keccak256(append(seed, digest...))
+}
+
+// hashimotoLight aggregates data from the full dataset (using only a small
+// in-memory cache) in order to produce our final value for a particular header
+// hash and nonce.
+func hashimotoLight(size uint64, cache []uint32, hash []byte, nonce uint64) ([]byte, []byte) {
+	keccak512 := makeHasher(sha3.NewKeccak512())
+
+	lookup := func(index uint32) []uint32 {
+		return generateDatasetItem(cache, index, keccak512)
+	}
+	return hashimoto(hash, nonce, size, lookup)
+}
+
+// hashimotoFull aggregates data from the full dataset (using the full in-memory
+// dataset) in order to produce our final value for a particular header hash and
+// nonce.
+func hashimotoFull(dataset []uint32, hash []byte, nonce uint64) ([]byte, []byte) {
+	lookup := func(index uint32) []uint32 {
+		offset := index * hashWords
+		return dataset[offset : offset+hashWords]
+	}
+	return hashimoto(hash, nonce, uint64(len(dataset))*4, lookup)
+}
@@ -0,0 +1,451 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package etchash implements the ECIP-1099 modification of the ethash proof of
+// work, which doubles the epoch length to 60000 blocks from a configured
+// transition block on. Blocks before the transition are handled by ethash.
+package etchash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eth-classic/ethash"
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+	"github.com/eth-classic/go-ethereum/pow"
+)
+
+const (
+	algorithmRevision   = 23
+	cacheSizeForTesting = 1024
+	dagSizeForTesting   = 1024 * 32
+	numCaches           = 3
+
+	// dumpMagic is the little endian header of DAG files, shared with ethash.
+	dumpMagic uint64 = 0xfee1deadbaddcafe
+)
+
+var (
+	maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
+
+	errEpochTooHigh = fmt.Errorf("block number too high, limit is epoch %d", maxEpoch)
+)
+
+// EpochLength returns the length of the ethash epoch the given block belongs
+// to, given the ECIP-1099 transition block (nil if not configured).
+func EpochLength(block uint64, transition *big.Int) uint64 {
+	if activated(block, transition) {
+		return epochLengthECIP1099
+	}
+	return epochLengthDefault
+}
+
+// SeedHash returns the seed hash of the epoch the given block belongs to.
+func SeedHash(block uint64, transition *big.Int) ([]byte, error) {
+	if !activated(block, transition) {
+		return ethash.GetSeedHash(block)
+	}
+	epoch := block / epochLengthECIP1099
+	if epoch >= maxEpoch {
+		return nil, errEpochTooHigh
+	}
+	return seedHash(epoch, epochLengthECIP1099), nil
+}
+
+// DAGFile returns the name (not the path) of the DAG file of the epoch the
+// given block belongs to.
+func DAGFile(block uint64, transition *big.Int) (string, error) {
+	seed, err := SeedHash(block, transition)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("full-R%d-%x", algorithmRevision, seed[:8]), nil
+}
+
+// MakeDAG pre-generates the DAG file of the epoch the given block belongs to in
+// the given directory. If dir is the empty string, the default ethash directory
+// is used.
+func MakeDAG(block uint64, dir string, transition *big.Int) error {
+	if !activated(block, transition) {
+		return ethash.MakeDAG(block, dir)
+	}
+	epoch := block / epochLengthECIP1099
+	if epoch >= maxEpoch {
+		return errEpochTooHigh
+	}
+	if dir == "" {
+		dir = ethash.DefaultDir
+	}
+	d := &dataset{epoch: epoch, dir: dir}
+	d.generate()
+	return d.err
+}
+
+// activated reports whether the ECIP-1099 epoch length applies to block.
+func activated(block uint64, transition *big.Int) bool {
+	return transition != nil && new(big.Int).SetUint64(block).Cmp(transition) >= 0
+}
+
+// cache is a verification cache of a single ECIP-1099 epoch.
+type cache struct {
+	epoch uint64
+	test  bool
+	used  time.Time
+
+	gen  sync.Once // ensures the cache is only generated once
+	data []uint32
+}
+
+// generate creates the verification cache. It can be called from multiple
+// goroutines, only the first call generates the cache, subsequent calls wait
+// until it's generated.
+func (c *cache) generate() {
+	c.gen.Do(func() {
+		started := time.Now()
+		seed := seedHash(c.epoch, epochLengthECIP1099)
+		glog.V(logger.Debug).Infof("Generating etchash cache for epoch %d (%x)", c.epoch, seed)
+
+		size := cacheSize(c.epoch)
+		if c.test {
+			size = cacheSizeForTesting
+		}
+		c.data = generateCache(size, seed)
+		glog.V(logger.Debug).Infof("Done generating etchash cache for epoch %d, it took %v", c.epoch, time.Since(started))
+	})
+}
+
+// dataset is the full mining dataset of a single ECIP-1099 epoch, backed by a
+// DAG file if a directory is set.
+type dataset struct {
+	epoch uint64
+	test  bool
+	dir   string
+
+	gen  sync.Once // ensures the dataset is only generated once
+	data []uint32
+	err  error
+}
+
+// generate loads the dataset from its DAG file, or generates it (and writes
+// the DAG file) if it doesn't exist yet. It can be called from multiple
+// goroutines like cache.generate.
+func (d *dataset) generate() {
+	d.gen.Do(func() {
+		var (
+			seed = seedHash(d.epoch, epochLengthECIP1099)
+			size = datasetSize(d.epoch)
+		)
+		c := &cache{epoch: d.epoch, test: d.test}
+		if d.test {
+			size = dagSizeForTesting
+		}
+		if d.dir == "" {
+			c.generate()
+			d.data = generateDataset(size, c.data)
+			return
+		}
+		path := filepath.Join(d.dir, fmt.Sprintf("full-R%d-%x", algorithmRevision, seed[:8]))
+
+		var err error
+		if d.data, err = loadDAG(path, size); err == nil {
+			glog.V(logger.Debug).Infof("Loaded etchash DAG for epoch %d from %s", d.epoch, path)
+			return
+		}
+		glog.V(logger.Info).Infof("Generating etchash DAG for epoch %d (%s)", d.epoch, path)
+		started := time.Now()
+		c.generate()
+		d.data = generateDataset(size, c.data)
+		if err := os.MkdirAll(d.dir, 0700); err != nil {
+			d.err = err
+			return
+		}
+		if d.err = writeDAG(path, d.data); d.err != nil {
+			return
+		}
+		glog.V(logger.Info).Infof("Done generating etchash DAG for epoch %d, it took %v", d.epoch, time.Since(started))
+	})
+}
+
+// loadDAG reads a DAG file of the given dataset size.
+func loadDAG(path string, size uint64) ([]uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(info.Size()) != 8+size {
+		return nil, errors.New("DAG file size mismatch")
+	}
+	r := bufio.NewReader(f)
+
+	var magic uint64
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return nil, err
+	}
+	if magic != dumpMagic {
+		return nil, errors.New("invalid DAG file magic")
+	}
+	data := make([]uint32, size/4)
+	buf := make([]byte, 4)
+	for i := range data {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		data[i] = binary.LittleEndian.Uint32(buf)
+	}
+	return data, nil
+}
+
+// writeDAG atomically writes a dataset into a DAG file.
+func writeDAG(path string, data []uint32) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, dumpMagic)
+	w.Write(buf)
+	for _, word := range data {
+		binary.LittleEndian.PutUint32(buf, word)
+		w.Write(buf[:4])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), path)
+}
+
+// Etchash is a proof of work engine switching from ethash to the ECIP-1099
+// epoch length at the transition block. Blocks before the transition are
+// verified and mined by the wrapped ethash engine.
+type Etchash struct {
+	ethash     *ethash.Ethash
+	transition *big.Int
+	dir        string // DAG directory, datasets are kept in memory only if empty
+	test       bool   // if set use a smaller cache and DAG size
+
+	mu      sync.Mutex        // protects caches and current
+	caches  map[uint64]*cache // verification caches by epoch
+	current *dataset          // mining dataset of the current epoch
+
+	hashRate int32
+	turbo    int32
+}
+
+// New creates an etchash engine switching to the ECIP-1099 epoch length at the
+// given transition block (nil to never switch).
+func New(transition *big.Int) *Etchash {
+	return newEtchash(ethash.New(), transition, ethash.DefaultDir, false)
+}
+
+// NewShared creates an etchash engine like New, wrapping an ethash engine in
+// shared mode.
+func NewShared(transition *big.Int) *Etchash {
+	return newEtchash(ethash.NewShared(), transition, ethash.DefaultDir, false)
+}
+
+// NewForTesting creates an etchash engine for use in unit tests. Like the
+// ethash test engine it uses a smaller cache and DAG size, and stores DAG files
+// in a temporary directory.
+func NewForTesting(transition *big.Int) (*Etchash, error) {
+	base, err := ethash.NewForTesting()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "etchash-test")
+	if err != nil {
+		return nil, err
+	}
+	return newEtchash(base, transition, dir, true), nil
+}
+
+func newEtchash(base *ethash.Ethash, transition *big.Int, dir string, test bool) *Etchash {
+	return &Etchash{
+		ethash:     base,
+		transition: transition,
+		dir:        dir,
+		test:       test,
+		caches:     make(map[uint64]*cache),
+		turbo:      1,
+	}
+}
+
+// Verify checks whether the block's nonce is valid.
+func (e *Etchash) Verify(block pow.Block) bool {
+	number := block.NumberU64()
+	if !activated(number, e.transition) {
+		return e.ethash.Verify(block)
+	}
+	epoch := number / epochLengthECIP1099
+	if epoch >= maxEpoch {
+		glog.V(logger.Debug).Infof("block number %d too high, limit is epoch %d", number, maxEpoch)
+		return false
+	}
+	difficulty := block.Difficulty()
+	if difficulty.Sign() == 0 {
+		glog.V(logger.Debug).Infof("invalid block difficulty")
+		return false
+	}
+	size := datasetSize(epoch)
+	if e.test {
+		size = dagSizeForTesting
+	}
+	digest, result := hashimotoLight(size, e.cache(epoch).data, block.HashNoNonce().Bytes(), block.Nonce())
+
+	// avoid mixdigest malleability as it's not included in a block's "hashNononce"
+	if block.MixDigest() != common.BytesToHash(digest) {
+		return false
+	}
+	target := new(big.Int).Div(maxUint256, difficulty)
+	return new(big.Int).SetBytes(result).Cmp(target) <= 0
+}
+
+// cache returns the verification cache of the given epoch, generating it if
+// needed and evicting the least recently used one if the limit is reached.
+func (e *Etchash) cache(epoch uint64) *cache {
+	e.mu.Lock()
+	c := e.caches[epoch]
+	if c == nil {
+		if len(e.caches) >= numCaches {
+			var evict *cache
+			for _, cache := range e.caches {
+				if evict == nil || evict.used.After(cache.used) {
+					evict = cache
+				}
+			}
+			glog.V(logger.Debug).Infof("Evicting etchash cache for epoch %d in favour of epoch %d", evict.epoch, epoch)
+			delete(e.caches, evict.epoch)
+		}
+		c = &cache{epoch: epoch, test: e.test}
+		e.caches[epoch] = c
+	}
+	c.used = time.Now()
+	e.mu.Unlock()
+
+	c.generate()
+	return c
+}
+
+// dataset returns the mining dataset of the given epoch.
+func (e *Etchash) dataset(epoch uint64) *dataset {
+	e.mu.Lock()
+	d := e.current
+	if d == nil || d.epoch != epoch {
+		d = &dataset{epoch: epoch, test: e.test, dir: e.dir}
+		e.current = d
+	}
+	e.mu.Unlock()
+
+	d.generate()
+	return d
+}
+
+// Search looks for a nonce satisfying the block's difficulty, until one is
+// found or stop is closed.
+func (e *Etchash) Search(block pow.Block, stop <-chan struct{}, index int) (nonce uint64, mixDigest []byte) {
+	number := block.NumberU64()
+	if !activated(number, e.transition) {
+		return e.ethash.Search(block, stop, index)
+	}
+	epoch := number / epochLengthECIP1099
+	if epoch >= maxEpoch {
+		glog.V(logger.Error).Infof("block number %d too high, limit is epoch %d", number, maxEpoch)
+		<-stop
+		return 0, nil
+	}
+	d := e.dataset(epoch)
+	if d.err != nil {
+		glog.V(logger.Error).Infof("Failed to generate etchash DAG for epoch %d: %v", epoch, d.err)
+		<-stop
+		return 0, nil
+	}
+	var (
+		r      = rand.New(rand.NewSource(time.Now().UnixNano()))
+		hash   = block.HashNoNonce().Bytes()
+		target = new(big.Int).Div(maxUint256, block.Difficulty())
+
+		i                = int64(0)
+		start            = time.Now().UnixNano()
+		previousHashrate = int32(0)
+	)
+	nonce = uint64(r.Int63())
+	for {
+		select {
+		case <-stop:
+			atomic.AddInt32(&e.hashRate, -previousHashrate)
+			return 0, nil
+		default:
+			i++
+
+			// we don't have to update hash rate on every nonce, so update after
+			// first nonce check and then after 2^X nonces
+			if i == 2 || ((i % (1 << 16)) == 0) {
+				elapsed := time.Now().UnixNano() - start
+				hashes := (float64(1e9) / float64(elapsed)) * float64(i)
+				hashrateDiff := int32(hashes) - previousHashrate
+				previousHashrate = int32(hashes)
+				atomic.AddInt32(&e.hashRate, hashrateDiff)
+			}
+			digest, result := hashimotoFull(d.data, hash, nonce)
+			if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+				atomic.AddInt32(&e.hashRate, -previousHashrate)
+				return nonce, digest
+			}
+			nonce++
+		}
+		if atomic.LoadInt32(&e.turbo) == 0 {
+			time.Sleep(20 * time.Microsecond)
+		}
+	}
+}
+
+// GetHashrate returns the current hash rate of the engine.
+func (e *Etchash) GetHashrate() int64 {
+	return e.ethash.GetHashrate() + int64(atomic.LoadInt32(&e.hashRate))
+}
+
+// Turbo disables the throttling between nonce attempts.
+func (e *Etchash) Turbo(on bool) {
+	e.ethash.Turbo(on)
+	if on {
+		atomic.StoreInt32(&e.turbo, 1)
+	} else {
+		atomic.StoreInt32(&e.turbo, 0)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package etchash
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eth-classic/ethash"
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/core/types"
+)
+
+func TestSizes(t *testing.T) {
+	tests := []struct {
+		epoch          uint64
+		cache, dataset uint64
+	}{
+		{0, 16776896, 1073739904},
+		{1, 16907456, 1082130304},
+		{2, 17039296, 1090514816},
+	}
+	for _, test := range tests {
+		if size := cacheSize(test.epoch); size != test.cache {
+			t.Errorf("epoch %d: cache size mismatch: have %d, want %d", test.epoch, size, test.cache)
+		}
+		if size := datasetSize(test.epoch); size != test.dataset {
+			t.Errorf("epoch %d: dataset size mismatch: have %d, want %d", test.epoch, size, test.dataset)
+		}
+	}
+}
+
+func TestEpochLength(t *testing.T) {
+	transition := big.NewInt(11700000)
+	tests := []struct {
+		block      uint64
+		transition *big.Int
+		length     uint64
+	}{
+		{0, nil, 30000},
+		{11700000, nil, 30000},
+		{11699999, transition, 30000},
+		{11700000, transition, 60000},
+		{20000000, transition, 60000},
+	}
+	for _, test := range tests {
+		if length := EpochLength(test.block, test.transition); length != test.length {
+			t.Errorf("block %d, transition %v: epoch length mismatch: have %d, want %d", test.block, test.transition, length, test.length)
+		}
+	}
+}
+
+func TestSeedHash(t *testing.T) {
+	if seed := hex.EncodeToString(seedHash(1, epochLengthDefault)); seed != "290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563" {
+		t.Errorf("epoch 1 seed mismatch: have %s", seed)
+	}
+	// The seed of an ECIP-1099 epoch equals the ethash seed of its first block.
+	transition := big.NewInt(11700000)
+	for _, block := range []uint64{11699999, 11700000, 11759999, 11760000} {
+		have, err := SeedHash(block, transition)
+		if err != nil {
+			t.Fatalf("block %d: %v", block, err)
+		}
+		first := block
+		if block >= transition.Uint64() {
+			first = block / epochLengthECIP1099 * epochLengthECIP1099
+		}
+		want, err := ethash.GetSeedHash(first)
+		if err != nil {
+			t.Fatalf("block %d: %v", block, err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("block %d: seed mismatch: have %x, want %x", block, have, want)
+		}
+	}
+	if _, err := SeedHash(maxEpoch*epochLengthECIP1099, common.Big0); err == nil {
+		t.Errorf("expected error for block beyond the last epoch")
+	}
+}
+
+func TestHashimoto(t *testing.T) {
+	var (
+		cache   = generateCache(cacheSizeForTesting, seedHash(0, epochLengthECIP1099))
+		dataset = generateDataset(dagSizeForTesting, cache)
+		hash    = common.HexToHash("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f").Bytes()
+	)
+	for nonce := uint64(0); nonce < 16; nonce++ {
+		lightDigest, lightResult := hashimotoLight(dagSizeForTesting, cache, hash, nonce)
+		fullDigest, fullResult := hashimotoFull(dataset, hash, nonce)
+		if !bytes.Equal(lightDigest, fullDigest) || !bytes.Equal(lightResult, fullResult) {
+			t.Errorf("nonce %d: light and full hashimoto mismatch", nonce)
+		}
+	}
+}
+
+func testBlock(number int64) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{
+		Number:     big.NewInt(number),
+		Difficulty: big.NewInt(32),
+		Time:       big.NewInt(1),
+	})
+}
+
+// Tests that etchash without transition agrees with ethash in both directions.
+func TestCompatibleWithEthash(t *testing.T) {
+	base, err := ethash.NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewForTesting(common.Big0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(e.dir)
+
+	block := testBlock(1)
+	nonce, digest := base.Search(block, nil, 0)
+	if !e.Verify(block.WithMiningResult(nonce, common.BytesToHash(digest))) {
+		t.Errorf("etchash failed to verify ethash block")
+	}
+	nonce, digest = e.Search(block, nil, 0)
+	if !base.Verify(block.WithMiningResult(nonce, common.BytesToHash(digest))) {
+		t.Errorf("ethash failed to verify etchash block")
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, "full-R23-0000000000000000")); err != nil {
+		t.Errorf("DAG file not written: %v", err)
+	}
+}
+
+func TestVerifyAfterTransition(t *testing.T) {
+	e, err := NewForTesting(big.NewInt(60000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(e.dir)
+
+	block := testBlock(60000)
+	nonce, digest := e.Search(block, nil, 0)
+	mined := block.WithMiningResult(nonce, common.BytesToHash(digest))
+	if !e.Verify(mined) {
+		t.Fatalf("failed to verify mined block")
+	}
+	if e.Verify(block.WithMiningResult(nonce+1, common.BytesToHash(digest))) {
+		t.Errorf("verified block with wrong nonce")
+	}
+	if e.Verify(block.WithMiningResult(nonce, common.Hash{})) {
+		t.Errorf("verified block with wrong mix digest")
+	}
+	// A fresh engine loads the DAG from disk.
+	reloaded := newEtchash(e.ethash, e.transition, e.dir, true)
+	if d := reloaded.dataset(1); d.err != nil || len(d.data) != dagSizeForTesting/4 {
+		t.Fatalf("failed to reload DAG: %v", d.err)
+	}
+}
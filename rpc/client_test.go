@@ -0,0 +1,279 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type SleepService struct{}
+
+func (s *SleepService) Sleep(ctx context.Context, ms int) {
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+	case <-ctx.Done():
+	}
+}
+
+type testDataError struct{}
+
+func (e *testDataError) Error() string          { return "test error" }
+func (e *testDataError) Code() int              { return 3 }
+func (e *testDataError) ErrorData() interface{} { return "0x01" }
+
+type FailService struct{}
+
+func (s *FailService) Fail() error {
+	return new(testDataError)
+}
+
+func newTestServer(t *testing.T) *Server {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("sleep", new(SleepService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("eth", new(NotificationTestService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("fail", new(FailService)); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestClientRequest(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp, Result{"hello", 10, &Args{"world"}}) {
+		t.Errorf("incorrect result %#v", resp)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "test_unknownMethod")
+	if _, ok := err.(*JSONError); !ok {
+		t.Fatalf("expected *JSONError, got %T: %v", err, err)
+	}
+}
+
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "fail_fail")
+	jsonErr, ok := err.(*JSONError)
+	if !ok {
+		t.Fatalf("expected *JSONError, got %T: %v", err, err)
+	}
+	if jsonErr.Code != 3 || jsonErr.Message != "test error" || jsonErr.Data != "0x01" {
+		t.Errorf("error mismatch: have %+v, want code 3, message %q, data %q", jsonErr, "test error", "0x01")
+	}
+	if dataErr, ok := err.(DataError); !ok || dataErr.ErrorData() != "0x01" {
+		t.Errorf("expected DataError with data %q, got %T: %v", "0x01", err, err)
+	}
+}
+
+func TestClientBatchRequest(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := []BatchElem{
+		{
+			Method: "test_echo",
+			Args:   []interface{}{"hello", 10, &Args{"world"}},
+			Result: new(Result),
+		},
+		{
+			Method: "test_echo",
+			Args:   []interface{}{"hello2", 11, &Args{"world"}},
+			Result: new(Result),
+		},
+		{
+			Method: "test_noSuchMethod",
+			Args:   []interface{}{1, 2, 3},
+			Result: new(int),
+		},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []Result{{"hello", 10, &Args{"world"}}, {"hello2", 11, &Args{"world"}}} {
+		if batch[i].Error != nil {
+			t.Fatalf("batch element %d: unexpected error %v", i, batch[i].Error)
+		}
+		if got := *batch[i].Result.(*Result); !reflect.DeepEqual(got, want) {
+			t.Errorf("batch element %d: got %#v, want %#v", i, got, want)
+		}
+	}
+	if _, ok := batch[2].Error.(*JSONError); !ok {
+		t.Errorf("batch element 2: expected *JSONError, got %v", batch[2].Error)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := client.CallContext(ctx, nil, "sleep_sleep", 5000)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("call was not cancelled in time")
+	}
+}
+
+func TestClientCloseUnblocksCalls(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+
+	errc := make(chan error, 1)
+	go func() { errc <- client.Call(nil, "sleep_sleep", 5000) }()
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+
+	select {
+	case err := <-errc:
+		if err != ErrClientQuit {
+			t.Fatalf("expected %v, got %v", ErrClientQuit, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not unblock pending call")
+	}
+	if err := client.Call(nil, "test_noArgsRets"); err != ErrClientQuit {
+		t.Fatalf("call after close: expected %v, got %v", ErrClientQuit, err)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	count := 10
+	sub, err := client.Subscribe(context.Background(), "eth", nc, "someSubscription", count, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case val := <-nc:
+			if val != i {
+				t.Fatalf("value mismatch: got %d, want %d", val, i)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription error: %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for notification %d", i)
+		}
+	}
+	sub.Unsubscribe()
+	if _, ok := <-sub.Err(); ok {
+		t.Error("error channel not closed after Unsubscribe")
+	}
+}
+
+func TestClientSubscribeClose(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+
+	nc := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "eth", nc, "someSubscription", 0, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	client.Close()
+
+	select {
+	case err := <-sub.Err():
+		if err != nil {
+			t.Fatalf("expected nil error after Close, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not ended by Close")
+	}
+}
+
+func TestClientHTTP(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	hs := httptest.NewServer(NewHTTPServer("*", server).Handler)
+	defer hs.Close()
+
+	client, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp, Result{"hello", 10, &Args{"world"}}) {
+		t.Errorf("incorrect result %#v", resp)
+	}
+
+	batch := []BatchElem{
+		{Method: "test_echo", Args: []interface{}{"a", 1, &Args{"b"}}, Result: new(Result)},
+		{Method: "test_echo", Args: []interface{}{"c", 2, &Args{"d"}}, Result: new(Result)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if got := *batch[1].Result.(*Result); !reflect.DeepEqual(got, Result{"c", 2, &Args{"d"}}) {
+		t.Errorf("incorrect batch result %#v", got)
+	}
+
+	if _, err := client.Subscribe(context.Background(), "eth", make(chan int), "someSubscription", 1, 0); err != ErrNotificationsUnsupported {
+		t.Fatalf("expected %v, got %v", ErrNotificationsUnsupported, err)
+	}
+}
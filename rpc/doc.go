@@ -94,5 +94,24 @@ Subscriptions are deleted when:
  - the connection which was used to create the subscription is closed. This can be initiated
    by the client and server. The server will close the connection on an write error or when
    the queue of buffered notifications gets too big.
+
+Clients
+
+ContextClient is a request/response client for all transports (Dial, DialHTTP,
+DialWebsocket, DialIPC and DialInProc). Calls can be cancelled through a context
+and subscriptions are delivered on a Go channel:
+
+ client, _ := rpc.Dial("ws://127.0.0.1:8546")
+ ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+ defer cancel()
+ var block map[string]interface{}
+ err := client.CallContext(ctx, &block, "eth_getBlockByNumber", "latest", false)
+
+ heads := make(chan map[string]interface{})
+ sub, err := client.Subscribe(ctx, "eth", heads, "newHeads")
+
+When a websocket or IPC connection fails, pending calls return the read error and
+subscriptions send it on their Err channel. The next call reconnects; subscriptions
+must be re-established by the caller.
 */
 package rpc
@@ -18,12 +18,17 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/cors"
 )
@@ -78,6 +83,106 @@ func (client *httpClient) SupportedModules() (map[string]string, error) {
 	return SupportedModules(client)
 }
 
+// httpConn is treated specially by ContextClient. It implements net.Conn so
+// that newClient can use it, but writes go through sendHTTP instead.
+type httpConn struct {
+	client    *http.Client
+	endpoint  string
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var errHTTPConnWrite = errors.New("httpConn does not support Write")
+
+func (hc *httpConn) Write([]byte) (int, error) { return 0, errHTTPConnWrite }
+
+func (hc *httpConn) Read(b []byte) (int, error) {
+	<-hc.closed
+	return 0, io.EOF
+}
+
+func (hc *httpConn) Close() error {
+	hc.closeOnce.Do(func() { close(hc.closed) })
+	return nil
+}
+
+func (hc *httpConn) LocalAddr() net.Addr              { return nil }
+func (hc *httpConn) RemoteAddr() net.Addr             { return nil }
+func (hc *httpConn) SetReadDeadline(time.Time) error  { return nil }
+func (hc *httpConn) SetWriteDeadline(time.Time) error { return nil }
+func (hc *httpConn) SetDeadline(time.Time) error      { return nil }
+
+// DialHTTP creates a new RPC client that connects to an RPC server over HTTP.
+// Subscriptions are not available over HTTP.
+func DialHTTP(endpoint string) (*ContextClient, error) {
+	// Validate the endpoint up front, requests are only built on demand.
+	if _, err := http.NewRequest("POST", endpoint, nil); err != nil {
+		return nil, err
+	}
+
+	initctx := context.Background()
+	return newClient(initctx, func(context.Context) (net.Conn, error) {
+		return &httpConn{client: new(http.Client), endpoint: endpoint, closed: make(chan struct{})}, nil
+	})
+}
+
+func (c *ContextClient) sendHTTP(ctx context.Context, op *requestOp, msg interface{}) error {
+	hc := c.writeConn.(*httpConn)
+	respBody, err := hc.doRequest(ctx, msg)
+	if err != nil {
+		return err
+	}
+	defer respBody.Close()
+	var respmsg jsonrpcMessage
+	if err := json.NewDecoder(respBody).Decode(&respmsg); err != nil {
+		return err
+	}
+	op.resp <- &respmsg
+	return nil
+}
+
+func (c *ContextClient) sendBatchHTTP(ctx context.Context, op *requestOp, msgs []*jsonrpcMessage) error {
+	hc := c.writeConn.(*httpConn)
+	respBody, err := hc.doRequest(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	defer respBody.Close()
+	var respmsgs []jsonrpcMessage
+	if err := json.NewDecoder(respBody).Decode(&respmsgs); err != nil {
+		return err
+	}
+	for i := 0; i < len(respmsgs); i++ {
+		op.resp <- &respmsgs[i]
+	}
+	return nil
+}
+
+func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadCloser, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", hc.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
 // httpReadWriteNopCloser wraps a io.Reader and io.Writer with a NOP Close method.
 type httpReadWriteNopCloser struct {
 	io.Reader
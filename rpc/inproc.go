@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net"
@@ -59,3 +60,14 @@ func (c *inProcClient) Recv(msg interface{}) error {
 func (c *inProcClient) SupportedModules() (map[string]string, error) {
 	return SupportedModules(c)
 }
+
+// DialInProc attaches an in-process connection to the given RPC server.
+func DialInProc(handler *Server) *ContextClient {
+	initctx := context.Background()
+	c, _ := newClient(initctx, func(context.Context) (net.Conn, error) {
+		p1, p2 := net.Pipe()
+		go handler.ServeCodec(NewJSONCodec(p1), OptionMethodInvocation|OptionSubscriptions)
+		return p2, nil
+	})
+	return c
+}
@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"net"
 )
@@ -82,3 +83,15 @@ func (client *ipcClient) Close() {
 func (client *ipcClient) SupportedModules() (map[string]string, error) {
 	return SupportedModules(client)
 }
+
+// DialIPC create a new IPC client that connects to the given endpoint. On Unix it assumes
+// the endpoint is the full path to a unix socket, and Windows the endpoint is an
+// identifier for a named pipe.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialIPC(ctx context.Context, endpoint string) (*ContextClient, error) {
+	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		return newIPCConnectionContext(ctx, endpoint)
+	})
+}
@@ -19,6 +19,7 @@
 package rpc
 
 import (
+	"context"
 	"net"
 	"os"
 	"path/filepath"
@@ -43,3 +44,9 @@ func ipcListen(endpoint string) (net.Listener, error) {
 func newIPCConnection(endpoint string) (net.Conn, error) {
 	return net.DialUnix("unix", nil, &net.UnixAddr{Name: endpoint, Net: "unix"})
 }
+
+// newIPCConnectionContext dials the unix socket at endpoint, aborting if ctx
+// is cancelled or expires first.
+func newIPCConnectionContext(ctx context.Context, endpoint string) (net.Conn, error) {
+	return new(net.Dialer).DialContext(ctx, "unix", endpoint)
+}
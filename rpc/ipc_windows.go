@@ -19,6 +19,7 @@
 package rpc
 
 import (
+	"context"
 	"net"
 	"time"
 
@@ -35,3 +36,13 @@ func newIPCConnection(endpoint string) (net.Conn, error) {
 	timeout := 5 * time.Second
 	return winio.DialPipe(endpoint, &timeout)
 }
+
+// newIPCConnectionContext dials the named pipe at endpoint, using the context
+// deadline as dial timeout if there is one.
+func newIPCConnectionContext(ctx context.Context, endpoint string) (net.Conn, error) {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return winio.DialPipe(endpoint, &timeout)
+}
@@ -60,6 +60,24 @@ type JSONError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+func (err *JSONError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("json-rpc error %d", err.Code)
+	}
+	return err.Message
+}
+
+// ErrorCode returns the JSON-RPC error code.
+func (err *JSONError) ErrorCode() int {
+	return err.Code
+}
+
+// ErrorData returns the data field of the error, which is nil if the server
+// didn't send any. It makes JSONError a DataError.
+func (err *JSONError) ErrorData() interface{} {
+	return err.Data
+}
+
 // JSON-RPC notification payload
 type jsonSubscription struct {
 	Subscription string      `json:"subscription"`
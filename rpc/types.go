@@ -87,7 +87,9 @@ type rpcRequest struct {
 	params   interface{}
 }
 
-// RPCError implements RPC error, is add support for error codec over regular go errors
+// RPCError implements RPC error, is add support for error codec over regular go errors.
+// Errors returned by callbacks that implement it are sent with their own code
+// instead of the generic callback error code.
 type RPCError interface {
 	// RPC error code
 	Code() int
@@ -95,6 +97,15 @@ type RPCError interface {
 	Error() string
 }
 
+// DataError is implemented by errors returned from callbacks that carry extra
+// information, which is sent to the client in the data field of the error.
+type DataError interface {
+	// Error message
+	Error() string
+	// Additional error data
+	ErrorData() interface{}
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
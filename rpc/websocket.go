@@ -17,7 +17,9 @@
 package rpc
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -174,3 +176,44 @@ func (client *wsClient) Close() {
 	}
 
 }
+
+// DialWebsocket creates a new RPC client that communicates with a JSON-RPC server
+// that is listening on the given endpoint.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialWebsocket(ctx context.Context, endpoint, origin string) (*ContextClient, error) {
+	if origin == "" {
+		var err error
+		if origin, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(endpoint, "wss") {
+			origin = "https://" + strings.ToLower(origin)
+		} else {
+			origin = "http://" + strings.ToLower(origin)
+		}
+	}
+	config, err := websocket.NewConfig(endpoint, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		return wsDialContext(ctx, config)
+	})
+}
+
+// wsDialContext dials the websocket endpoint described by config, bounding
+// the dial by the context deadline or defaultDialTimeout.
+func wsDialContext(ctx context.Context, config *websocket.Config) (*websocket.Conn, error) {
+	dialer := &net.Dialer{KeepAlive: tcpKeepAliveInterval}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	} else {
+		dialer.Timeout = defaultDialTimeout
+	}
+	cfg := *config
+	cfg.Dialer = dialer
+	return websocket.DialConfig(&cfg)
+}
@@ -148,6 +148,14 @@ var Forks = map[string]RuleSet{
 		AtlantisBlock:            big.NewInt(0),
 		AghartaBlock:             big.NewInt(0),
 	},
+	"Istanbul": {
+		HomesteadBlock:           big.NewInt(0),
+		HomesteadGasRepriceBlock: big.NewInt(0),
+		DiehardBlock:             big.NewInt(0),
+		AtlantisBlock:            big.NewInt(0),
+		AghartaBlock:             big.NewInt(0),
+		PhoenixBlock:             big.NewInt(0),
+	},
 }
 
 // ChainConfigs table used to map configs to difficulty test files
@@ -156,6 +156,8 @@ type RuleSet struct {
 	ExplosionBlock           *big.Int
 	AtlantisBlock            *big.Int
 	AghartaBlock             *big.Int
+	PhoenixBlock             *big.Int
+	MagnetoBlock             *big.Int
 }
 
 // StateTest object that matches the General State Test json file
@@ -208,6 +210,19 @@ func (r RuleSet) IsAgharta(n *big.Int) bool {
 	return r.AghartaBlock != nil && n.Cmp(r.AghartaBlock) >= 0
 }
 
+func (r RuleSet) IsPhoenix(n *big.Int) bool {
+	return r.PhoenixBlock != nil && n.Cmp(r.PhoenixBlock) >= 0
+}
+
+func (r RuleSet) IsEIP2929(n *big.Int) bool {
+	return r.MagnetoBlock != nil && n.Cmp(r.MagnetoBlock) >= 0
+}
+
+// GetChainID returns the chain id the ethereum test suites are generated with.
+func (r RuleSet) GetChainID() *big.Int {
+	return big.NewInt(1)
+}
+
 func (r RuleSet) GasTable(num *big.Int) *vm.GasTable {
 	if r.HomesteadGasRepriceBlock == nil || num == nil || num.Cmp(r.HomesteadGasRepriceBlock) < 0 {
 		return &vm.GasTable{
@@ -247,12 +262,26 @@ func (r RuleSet) GasTable(num *big.Int) *vm.GasTable {
 		}
 	}
 
+	if r.PhoenixBlock == nil || num == nil || num.Cmp(r.PhoenixBlock) < 0 {
+		return &vm.GasTable{
+			ExtcodeSize:     big.NewInt(700),
+			ExtcodeCopy:     big.NewInt(700),
+			ExtcodeHash:     big.NewInt(400),
+			Balance:         big.NewInt(400),
+			SLoad:           big.NewInt(200),
+			Calls:           big.NewInt(700),
+			Suicide:         big.NewInt(5000),
+			ExpByte:         big.NewInt(50),
+			CreateBySuicide: big.NewInt(25000),
+		}
+	}
+
 	return &vm.GasTable{
 		ExtcodeSize:     big.NewInt(700),
 		ExtcodeCopy:     big.NewInt(700),
-		ExtcodeHash:     big.NewInt(400),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
+		ExtcodeHash:     big.NewInt(700),
+		Balance:         big.NewInt(700),
+		SLoad:           big.NewInt(800),
 		Calls:           big.NewInt(700),
 		Suicide:         big.NewInt(5000),
 		ExpByte:         big.NewInt(50),
@@ -449,3 +478,4 @@ func (self Message) Gas() *big.Int                         { return self.gas }
 func (self Message) Value() *big.Int                       { return self.value }
 func (self Message) Nonce() uint64                         { return self.nonce }
 func (self Message) Data() []byte                          { return self.data }
+func (self Message) AccessList() types.AccessList          { return nil }
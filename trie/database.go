@@ -0,0 +1,358 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/ethdb"
+	"github.com/eth-classic/go-ethereum/logger"
+	"github.com/eth-classic/go-ethereum/logger/glog"
+)
+
+// NodeDatabase is an intermediate write layer between the trie data structures
+// and the disk database. Trie nodes committed into it are held in memory with
+// reference counts, so that the nodes of tries which are no longer needed can
+// be discarded without ever being written to disk.
+//
+// Reads are served from memory first, falling back to the disk database.
+// NodeDatabase is safe for concurrent use.
+type NodeDatabase struct {
+	diskdb ethdb.Database // Persistent storage for matured trie nodes
+
+	nodes     map[common.Hash]*cachedNode // Data and references relationships of dirty nodes
+	preimages map[string][]byte           // Secure trie key preimages, keyed by their database key
+
+	nodesSize     common.StorageSize // Storage size of the nodes cache
+	preimagesSize common.StorageSize // Storage size of the preimages cache
+
+	gcnodes uint64             // Nodes garbage collected since last commit
+	gcsize  common.StorageSize // Data storage garbage collected since last commit
+
+	lock sync.RWMutex
+}
+
+// cachedNode is a trie node or contract code held in memory, along with the
+// references to other in-memory nodes.
+type cachedNode struct {
+	blob     []byte                 // Encoded node or contract code
+	parents  int                    // Number of live nodes referencing this one
+	children map[common.Hash]uint16 // Nodes referenced by this one, with their reference counts
+}
+
+// NewNodeDatabase creates a new trie node database on top of a persistent
+// disk database. The metadata entry under the empty hash holds the references
+// keeping the tries of interest alive.
+func NewNodeDatabase(diskdb ethdb.Database) *NodeDatabase {
+	return &NodeDatabase{
+		diskdb: diskdb,
+		nodes: map[common.Hash]*cachedNode{
+			{}: {children: make(map[common.Hash]uint16)},
+		},
+		preimages: make(map[string][]byte),
+	}
+}
+
+// DiskDB retrieves the persistent storage backing the trie node database.
+func (db *NodeDatabase) DiskDB() ethdb.Database {
+	return db.diskdb
+}
+
+// Put inserts a trie node or a secure key preimage into the memory database.
+// Trie nodes are keyed by their 32 byte hash, any other key is a preimage.
+// The children of a node present in the database are referenced by it.
+func (db *NodeDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(key) != common.HashLength {
+		if _, ok := db.preimages[string(key)]; !ok {
+			db.preimages[string(key)] = common.CopyBytes(value)
+			db.preimagesSize += common.StorageSize(len(key) + len(value))
+		}
+		return nil
+	}
+	hash := common.BytesToHash(key)
+	if _, ok := db.nodes[hash]; ok {
+		return nil
+	}
+	entry := &cachedNode{blob: common.CopyBytes(value), children: make(map[common.Hash]uint16)}
+	if n, err := decodeNode(key, value, 0); err == nil {
+		forGatherChildren(n, func(child common.Hash) {
+			if _, ok := entry.children[child]; ok {
+				return
+			}
+			if c := db.nodes[child]; c != nil {
+				c.parents++
+				entry.children[child] = 1
+			}
+		})
+	}
+	db.nodes[hash] = entry
+	db.nodesSize += common.StorageSize(common.HashLength + len(value))
+	return nil
+}
+
+// InsertBlob inserts a data blob without internal references, such as contract
+// code, into the memory database under the given hash.
+func (db *NodeDatabase) InsertBlob(hash common.Hash, blob []byte) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, ok := db.nodes[hash]; ok {
+		return
+	}
+	db.nodes[hash] = &cachedNode{blob: common.CopyBytes(blob), children: make(map[common.Hash]uint16)}
+	db.nodesSize += common.StorageSize(common.HashLength + len(blob))
+}
+
+// Get retrieves a trie node, contract code or preimage from memory, falling
+// back to the disk database if it's not cached.
+func (db *NodeDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return node.blob, nil
+		}
+	} else if preimage, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return preimage, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Get(key)
+}
+
+// Has reports whether the key is present in memory or in the disk database.
+func (db *NodeDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return true, nil
+		}
+	} else if _, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return true, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Has(key)
+}
+
+// Reference adds a new reference from a parent node to a child node. Tries to
+// keep alive are referenced from the empty hash.
+func (db *NodeDatabase) Reference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.reference(child, parent)
+}
+
+// reference is the private locked version of Reference.
+func (db *NodeDatabase) reference(child common.Hash, parent common.Hash) {
+	// If the node does not exist, it's a node pulled from disk, skip
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	// If the reference already exists, only duplicate for roots
+	entry := db.nodes[parent]
+	if entry == nil {
+		return
+	}
+	if _, ok = entry.children[child]; ok && parent != (common.Hash{}) {
+		return
+	}
+	node.parents++
+	entry.children[child]++
+}
+
+// Dereference removes an existing reference from a parent node to a child
+// node, deleting the child and recursively its children if it's left without
+// references.
+func (db *NodeDatabase) Dereference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	nodes, storage, start := len(db.nodes), db.nodesSize, time.Now()
+
+	entry := db.nodes[parent]
+	if entry == nil || entry.children[child] == 0 {
+		return
+	}
+	entry.children[child]--
+	if entry.children[child] == 0 {
+		delete(entry.children, child)
+	}
+	db.dereference(child)
+
+	db.gcnodes += uint64(nodes - len(db.nodes))
+	db.gcsize += storage - db.nodesSize
+
+	glog.V(logger.Debug).Infof("dereferenced trie from memory database: nodes=%d size=%v time=%v gcnodes=%d gcsize=%v livenodes=%d livesize=%v",
+		nodes-len(db.nodes), storage-db.nodesSize, time.Since(start), db.gcnodes, db.gcsize, len(db.nodes), db.nodesSize)
+}
+
+// dereference is the private locked version of Dereference, dropping a single
+// parent reference of the given node.
+func (db *NodeDatabase) dereference(hash common.Hash) {
+	// If the node does not exist, it's a previously committed node
+	node, ok := db.nodes[hash]
+	if !ok {
+		return
+	}
+	node.parents--
+	if node.parents > 0 {
+		return
+	}
+	for child := range node.children {
+		db.dereference(child)
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+}
+
+// Commit writes the trie with the given root, along with everything it
+// references, from memory to the disk database and drops it from memory.
+// All cached preimages are written out too.
+func (db *NodeDatabase) Commit(root common.Hash) error {
+	start := time.Now()
+
+	// Write the preimages and nodes under a read lock, so reads can continue
+	db.lock.RLock()
+	nodes, storage := len(db.nodes), db.nodesSize
+	batch := db.diskdb.NewBatch()
+	for key, preimage := range db.preimages {
+		if err := db.put(&batch, []byte(key), preimage); err != nil {
+			db.lock.RUnlock()
+			return err
+		}
+	}
+	if err := db.commit(root, &batch); err != nil {
+		db.lock.RUnlock()
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Once written, drop the committed data from memory
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[string][]byte)
+	db.preimagesSize = 0
+	db.uncache(root)
+
+	glog.V(logger.Debug).Infof("persisted trie from memory database: nodes=%d size=%v time=%v gcnodes=%d gcsize=%v livenodes=%d livesize=%v",
+		nodes-len(db.nodes), storage-db.nodesSize, time.Since(start), db.gcnodes, db.gcsize, len(db.nodes), db.nodesSize)
+
+	db.gcnodes, db.gcsize = 0, 0
+	return nil
+}
+
+// commit is the private locked version of Commit, writing the node after all
+// its children.
+func (db *NodeDatabase) commit(hash common.Hash, batch *ethdb.Batch) error {
+	node, ok := db.nodes[hash]
+	if !ok || hash == (common.Hash{}) {
+		return nil
+	}
+	for child := range node.children {
+		if err := db.commit(child, batch); err != nil {
+			return err
+		}
+	}
+	return db.put(batch, hash[:], node.blob)
+}
+
+// put adds an entry to the batch, flushing and replacing the batch once it
+// exceeds the ideal size.
+func (db *NodeDatabase) put(batch *ethdb.Batch, key, value []byte) error {
+	if err := (*batch).Put(key, value); err != nil {
+		return err
+	}
+	if (*batch).ValueSize() >= ethdb.IdealBatchSize {
+		if err := (*batch).Write(); err != nil {
+			return err
+		}
+		*batch = db.diskdb.NewBatch()
+	}
+	return nil
+}
+
+// uncache drops a committed node and all its children from memory. Nodes
+// still referenced by other in-memory nodes are dropped too, reads fall back
+// to the disk database which now holds them.
+func (db *NodeDatabase) uncache(hash common.Hash) {
+	node, ok := db.nodes[hash]
+	if !ok || hash == (common.Hash{}) {
+		return
+	}
+	for child := range node.children {
+		db.uncache(child)
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+}
+
+// Size returns the current storage size of the memory cache in front of the
+// persistent database layer.
+func (db *NodeDatabase) Size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.nodesSize + db.preimagesSize
+}
+
+// Nodes retrieves the hashes of all the nodes cached within the memory database.
+func (db *NodeDatabase) Nodes() []common.Hash {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var hashes = make([]common.Hash, 0, len(db.nodes))
+	for hash := range db.nodes {
+		if hash != (common.Hash{}) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// forGatherChildren traverses the node hierarchy of a collapsed trie node and
+// invokes the callback for all the hashnode children.
+func forGatherChildren(n node, onChild func(hash common.Hash)) {
+	switch n := n.(type) {
+	case *shortNode:
+		forGatherChildren(n.Val, onChild)
+	case *fullNode:
+		for i := 0; i < 16; i++ {
+			forGatherChildren(n.Children[i], onChild)
+		}
+	case hashNode:
+		onChild(common.BytesToHash(n))
+	case valueNode, nil:
+	default:
+		panic("unknown node type")
+	}
+}
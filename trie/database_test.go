@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eth-classic/go-ethereum/common"
+	"github.com/eth-classic/go-ethereum/crypto"
+	"github.com/eth-classic/go-ethereum/ethdb"
+)
+
+func TestNodeDatabaseGarbageCollection(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewNodeDatabase(diskdb)
+
+	// Leaf values reference a blob, like accounts reference their code
+	blob := []byte("referenced by the leaves")
+	blobHash := crypto.Keccak256Hash(blob)
+	triedb.InsertBlob(blobHash, blob)
+	onleaf := func(leaf []byte, parent common.Hash) error {
+		triedb.Reference(blobHash, parent)
+		return nil
+	}
+	// Commit two versions of a trie into memory, sharing most of their nodes
+	tr, _ := New(common.Hash{}, triedb)
+	content := make(map[string][]byte)
+	for i := byte(0); i < 100; i++ {
+		key, val := common.LeftPadBytes([]byte{i}, 32), bytes.Repeat([]byte{i}, 40)
+		content[string(key)] = val
+		tr.Update(key, val)
+	}
+	root1, err := tr.CommitToWithCallback(triedb, onleaf)
+	if err != nil {
+		t.Fatalf("failed to commit first trie: %v", err)
+	}
+	triedb.Reference(root1, common.Hash{})
+
+	updated := make(map[string][]byte)
+	for key, val := range content {
+		updated[key] = val
+	}
+	key := common.LeftPadBytes([]byte{0}, 32)
+	updated[string(key)] = bytes.Repeat([]byte{0xff}, 40)
+	tr.Update(key, updated[string(key)])
+
+	root2, err := tr.CommitToWithCallback(triedb, onleaf)
+	if err != nil {
+		t.Fatalf("failed to commit second trie: %v", err)
+	}
+	triedb.Reference(root2, common.Hash{})
+
+	if keys := diskdb.Keys(); len(keys) != 0 {
+		t.Fatalf("disk database written before flush: %d entries", len(keys))
+	}
+	checkTrieContents(t, triedb, root1.Bytes(), content)
+	checkTrieContents(t, triedb, root2.Bytes(), updated)
+
+	// Dropping the first trie should only collect the nodes it doesn't share
+	size := triedb.Size()
+	triedb.Dereference(root1, common.Hash{})
+	if triedb.Size() >= size {
+		t.Errorf("memory not reclaimed: have %v, had %v", triedb.Size(), size)
+	}
+	if _, err := New(root1, triedb); err == nil {
+		t.Errorf("dereferenced trie %x still available", root1)
+	}
+	checkTrieContents(t, triedb, root2.Bytes(), updated)
+	if _, err := triedb.Get(blobHash[:]); err != nil {
+		t.Errorf("blob referenced by live trie collected: %v", err)
+	}
+
+	// Flushing the second trie should write it out and empty the memory
+	if err := triedb.Commit(root2); err != nil {
+		t.Fatalf("failed to flush trie: %v", err)
+	}
+	if nodes := triedb.Nodes(); len(nodes) != 0 {
+		t.Errorf("nodes left in memory after flush: %d", len(nodes))
+	}
+	checkTrieContents(t, diskdb, root2.Bytes(), updated)
+	if _, err := diskdb.Get(blobHash[:]); err != nil {
+		t.Errorf("referenced blob not flushed: %v", err)
+	}
+}
+
+func TestNodeDatabaseDereferenceBlob(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewNodeDatabase(diskdb)
+
+	blob := []byte("referenced by the leaves")
+	blobHash := crypto.Keccak256Hash(blob)
+	triedb.InsertBlob(blobHash, blob)
+
+	tr, _ := New(common.Hash{}, triedb)
+	tr.Update([]byte("key"), bytes.Repeat([]byte{1}, 40))
+	root, _ := tr.CommitToWithCallback(triedb, func(leaf []byte, parent common.Hash) error {
+		triedb.Reference(blobHash, parent)
+		return nil
+	})
+	triedb.Reference(root, common.Hash{})
+	triedb.Dereference(root, common.Hash{})
+
+	if nodes := triedb.Nodes(); len(nodes) != 0 {
+		t.Errorf("nodes left in memory after dereference: %d", len(nodes))
+	}
+	if _, err := triedb.Get(blobHash[:]); err == nil {
+		t.Error("blob of dereferenced trie not collected")
+	}
+}
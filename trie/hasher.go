@@ -43,14 +43,16 @@ var calculatorPool = sync.Pool{
 type hasher struct {
 	cachegen   uint16
 	cachelimit uint16
+	onleaf     LeafCallback // Invoked for the leaves of the nodes stored
 	threaded   bool
 	mu         sync.Mutex
 }
 
-func newHasher(cachegen, cachelimit uint16) *hasher {
+func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback) *hasher {
 	h := &hasher{
 		cachegen:   cachegen,
 		cachelimit: cachelimit,
+		onleaf:     onleaf,
 	}
 	return h
 }
@@ -221,10 +223,30 @@ func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 	if db != nil {
 		// db might be a leveldb batch, which is not safe for concurrent writes
 		h.mu.Lock()
-		err := db.Put(hash, calculator.buffer.Bytes())
-		h.mu.Unlock()
+		defer h.mu.Unlock()
 
-		return hash, err
+		if err := db.Put(hash, calculator.buffer.Bytes()); err != nil {
+			return hash, err
+		}
+		// Report the leaves referenced by the stored node
+		if h.onleaf != nil {
+			parent := common.BytesToHash(hash)
+			switch n := n.(type) {
+			case *shortNode:
+				if child, ok := n.Val.(valueNode); ok {
+					if err := h.onleaf(child, parent); err != nil {
+						return hash, err
+					}
+				}
+			case *fullNode:
+				if child, ok := n.Children[16].(valueNode); ok && len(child) > 0 {
+					if err := h.onleaf(child, parent); err != nil {
+						return hash, err
+					}
+				}
+			}
+		}
+		return hash, nil
 	}
 	return hash, nil
 }